@@ -3,12 +3,15 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
 	"strings"
 	"syscall"
 	"time"
@@ -65,16 +68,58 @@ type NodeDTO struct {
 	Labels     map[string]string `json:"labels"`
 }
 
+// ValidationErrorResponse is returned when submitted resources fail validation
+type ValidationErrorResponse struct {
+	Error       string          `json:"error"`
+	FieldErrors []FieldErrorDTO `json:"field_errors"`
+}
+
+// FieldErrorDTO describes a single invalid field
+type FieldErrorDTO struct {
+	Policy int    `json:"policy"` // index of the policy in the request
+	Field  string `json:"field"`
+	Type   string `json:"type"`
+	Detail string `json:"detail"`
+}
+
+// IntentReport is the outcome of checking connectivity intents
+type IntentReport struct {
+	Satisfied bool                    `json:"satisfied"`
+	Results   []analyzer.IntentResult `json:"results"`
+}
+
 var (
 	kubeconfig     = flag.String("kubeconfig", "", "Path to kubeconfig file")
 	addr           = flag.String("addr", ":8080", "The address to listen on for HTTP requests")
 	probeInterval  = flag.Duration("probe-interval", 30*time.Second, "Interval between connectivity probes")
+	probeMode      = flag.String("probe-mode", "local", "Where TCP probes are dialed from: local (this process) or exec (inside the source pod, which also tests NetworkPolicy enforcement)")
+	dnsServer      = flag.String("dns-server", "", "Cluster DNS server for DNS probes (defaults to the kube-dns Service)")
+	clusterDomain  = flag.String("cluster-domain", "cluster.local", "Cluster DNS domain")
+	dnsExternal    = flag.String("dns-external-names", "kubernetes.io", "Comma-separated external names DNS probes resolve through the cluster DNS")
+	icmpCount      = flag.Int("icmp-count", prober.DefaultICMPCount, "ICMP echo requests sent by each packet loss probe")
+	probeWorkers   = flag.Int("probe-workers", prober.DefaultProbeWorkers, "Probes run concurrently")
+	probeRate      = flag.Float64("probe-rate", prober.DefaultProbeRate, "Probes started per second across all targets")
+	targetRate     = flag.Float64("probe-target-rate", prober.DefaultTargetRate, "Probes started per second against any one IP")
+	probeMaxTasks  = flag.Int("probe-max-tasks", prober.DefaultMaxTasks, "Probe tasks per cycle; sampling rotates through the rest over later cycles")
+	probeJitter    = flag.Float64("probe-jitter", prober.DefaultProbeJitter, "Random delay before each probe cycle, as a fraction of the interval")
+	probeSampling  = flag.String("probe-sampling", prober.SamplingWorkload, "Probe sampling strategy: workload, flows or round-robin")
+	tlsCAFile      = flag.String("tls-ca-file", "", "PEM bundle of extra CAs trusted when TLS probes verify certificate chains")
+	tlsExpiry      = flag.Duration("tls-expiry-window", analyzer.DefaultCertExpiryWindow, "Report TLS certificates expiring within this window")
+	mtuInterval    = flag.Duration("mtu-interval", prober.DefaultMTUInterval, "How often agents discover the path MTU between nodes (0 disables)")
+	throughputOn   = flag.Bool("throughput-tests", false, "Allow on-demand TCP throughput tests, which load the path between two endpoints for their duration")
+	throughputPort = flag.Int("throughput-port", 0, "Port node agents host throughput receivers on (0 hosts none)")
+	receiverAddr   = flag.String("throughput-receiver", "", "Only host a throughput receiver on this address, as in an ephemeral test pod")
+	probeHistory   = flag.Duration("probe-history", prober.DefaultHistoryRetention, "How long each pod pair's probe results are kept for latency percentiles")
 	enableWebUI    = flag.Bool("enable-ui", true, "Enable web UI")
 	namespace      = flag.String("namespace", "", "Namespace to watch (empty for all namespaces)")
 	prometheusURL  = flag.String("prometheus-url", "http://localhost:9090", "Prometheus server URL for metrics collection")
 	aiAPIKey       = flag.String("ai-api-key", "", "OpenRouter AI API key for enhanced analysis")
-	hubbleAddr     = flag.String("hubble-addr", "hubble-relay.kube-system.svc.cluster.local:80", "Hubble relay address for flow collection")
+	hubbleAddr     = flag.String("hubble-addr", "hubble-relay.kube-system.svc.cluster.local:80", "Hubble Relay address; flows are collected from Hubble when it answers there (empty skips it)")
 	enableFlows    = flag.Bool("enable-flows", false, "Enable network flow collection (CNI-agnostic)")
+	intentSpecFile = flag.String("intents-file", "", "Path to a connectivity intent spec to check continuously")
+	intentSpecMap  = flag.String("intents-configmap", "", "ConfigMap holding connectivity intents, as namespace/name")
+	agentServer    = flag.String("agent-server", "", "Run as a node-local probe agent fed by the server at this URL")
+	nodeName       = flag.String("node-name", "", "Node the probe agent runs on (defaults to $NODE_NAME)")
 )
 
 var upgrader = websocket.Upgrader{
@@ -113,6 +158,21 @@ func main() {
 		cancel()
 	}()
 
+	// A throughput receiver pod only serves tests
+	if *receiverAddr != "" {
+		log.Printf("Hosting a throughput receiver on %s", *receiverAddr)
+		if err := prober.ServeThroughput(ctx, *receiverAddr); err != nil {
+			log.Fatalf("Throughput receiver failed: %v", err)
+		}
+		return
+	}
+
+	// In agent mode only run the probes the central server assigns to this node
+	if *agentServer != "" {
+		runAgent(ctx)
+		return
+	}
+
 	// Initialize Kubernetes client
 	k8sClient, err := k8s.NewClient(*kubeconfig)
 	if err != nil {
@@ -123,10 +183,68 @@ func main() {
 	// Initialize components
 	networkCollector := collector.NewCollector(k8sClient, *namespace)
 	networkProber := prober.NewProber(k8sClient)
+	if err := networkProber.SetExecMode(*probeMode); err != nil {
+		log.Fatalf("Invalid -probe-mode: %v", err)
+	}
+	networkProber.SetDNSConfig(prober.DNSConfig{
+		Server:        *dnsServer,
+		ClusterDomain: *clusterDomain,
+		ExternalNames: strings.Split(*dnsExternal, ","),
+	})
+	if err := networkProber.SetICMPCount(*icmpCount); err != nil {
+		log.Fatalf("Invalid -icmp-count: %v", err)
+	}
+	sampling, err := prober.NewSamplingStrategy(*probeSampling)
+	if err != nil {
+		log.Fatalf("Invalid -probe-sampling: %v", err)
+	}
+	if err := networkProber.SetSchedulerConfig(prober.SchedulerConfig{
+		Workers:    *probeWorkers,
+		Rate:       *probeRate,
+		TargetRate: *targetRate,
+		MaxTasks:   *probeMaxTasks,
+		Jitter:     *probeJitter,
+		Strategy:   sampling,
+	}); err != nil {
+		log.Fatalf("Invalid probe scheduler settings: %v", err)
+	}
+	if *tlsCAFile != "" {
+		if err := networkProber.SetTLSCAFile(*tlsCAFile); err != nil {
+			log.Fatalf("Invalid -tls-ca-file: %v", err)
+		}
+	}
+	if err := networkProber.SetMTUInterval(*mtuInterval); err != nil {
+		log.Fatalf("Invalid -mtu-interval: %v", err)
+	}
+	if err := networkProber.SetHistoryRetention(*probeHistory); err != nil {
+		log.Fatalf("Invalid -probe-history: %v", err)
+	}
+	if *throughputOn {
+		if err := networkProber.EnableThroughputTests(*throughputPort); err != nil {
+			log.Fatalf("Invalid -throughput-port: %v", err)
+		}
+		networkProber.SetLocalPod(os.Getenv("POD_NAMESPACE"), os.Getenv("POD_NAME"))
+		log.Println("On-demand throughput tests enabled")
+	}
+	agentHub := networkProber.EnableAgents()
 	graphEngine := graph.NewEngine()
 	networkAnalyzer := analyzer.NewAnalyzer(graphEngine)
+	networkAnalyzer.SetCertExpiryWindow(*tlsExpiry)
 	networkSimulator := simulator.NewSimulator(graphEngine)
 
+	// Load connectivity intents, re-read on every analysis cycle
+	if *intentSpecFile != "" {
+		networkAnalyzer.SetIntentSource(analyzer.FileIntentSource(*intentSpecFile))
+		log.Printf("Checking connectivity intents from %s", *intentSpecFile)
+	} else if *intentSpecMap != "" {
+		parts := strings.SplitN(*intentSpecMap, "/", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			log.Fatalf("Invalid -intents-configmap %q, expected namespace/name", *intentSpecMap)
+		}
+		networkAnalyzer.SetIntentSource(analyzer.ConfigMapIntentSource(k8sClient, parts[0], parts[1]))
+		log.Printf("Checking connectivity intents from ConfigMap %s", *intentSpecMap)
+	}
+
 	// Initialize AI client if API key provided (check both flag and environment variable)
 	apiKey := *aiAPIKey
 	if apiKey == "" {
@@ -167,6 +285,7 @@ func main() {
 		
 		// Auto-detect and create best available collector
 		factory := flowcollector.NewCollectorFactory(ctx)
+		factory.SetHubbleAddr(*hubbleAddr)
 		var err error
 		flowCollector, collectorType, err = factory.CreateCollector()
 		if err != nil {
@@ -190,6 +309,15 @@ func main() {
 			// Start flow analysis (without anomaly detection for now)
 			go startFlowAnalysisSimple(ctx, flowCollector, graphEngine)
 			
+			// Replay observed traffic in policy simulations
+			networkSimulator.SetFlowCollector(flowCollector)
+			
+			// Check connectivity intents against observed traffic
+			networkAnalyzer.SetFlowCollector(flowCollector)
+
+			// Let flow sampling probe the pod pairs seen talking
+			networkProber.SetFlowCollector(flowCollector)
+			
 			log.Println("Flow collection started successfully")
 		}
 	} else {
@@ -218,9 +346,20 @@ func main() {
 	mux.HandleFunc("/api/pods", podsHandler(networkCollector))
 	mux.HandleFunc("/api/services", servicesHandler(networkCollector))
 	mux.HandleFunc("/api/policies", policiesHandler(networkCollector))
+	mux.HandleFunc("/api/policies/generate", generatePoliciesHandler(networkSimulator, networkCollector))
+	mux.HandleFunc("/api/reachability", reachabilityHandler(networkSimulator, networkCollector))
 	mux.HandleFunc("/api/probes", probesHandler(networkProber))
+	mux.HandleFunc("/api/probes/coverage", probeCoverageHandler(networkProber))
+	mux.HandleFunc("/api/probes/stats", probeStatsHandler(networkProber))
+	mux.HandleFunc("/api/probes/mtu", probeMTUHandler(networkProber))
+	mux.HandleFunc("/api/probes/throughput", throughputHandler(networkProber))
+	mux.HandleFunc("/api/probes/run", runProbeHandler(networkProber))
+	mux.HandleFunc("/api/probes/run/", probeRunHandler(networkProber))
+	mux.Handle("/api/agents/", http.StripPrefix("/api/agents", agentHub.Handler()))
 	mux.HandleFunc("/api/issues", issuesHandler(networkAnalyzer))
 	mux.HandleFunc("/api/insights", insightsHandler(networkAnalyzer))
+	mux.HandleFunc("/api/intents", intentsHandler(networkAnalyzer, networkCollector, networkProber))
+	mux.HandleFunc("/api/trace", traceHandler(networkAnalyzer, networkCollector, networkProber))
 	mux.HandleFunc("/api/simulate", simulateHandler(networkSimulator, networkCollector))
 	mux.HandleFunc("/api/simulations", simulationsHandler(networkAnalyzer))
 	
@@ -278,6 +417,51 @@ func main() {
 	log.Println("Server stopped")
 }
 
+// runAgent runs the node-local probe agent until the context is cancelled
+func runAgent(ctx context.Context) {
+	node := *nodeName
+	if node == "" {
+		node = os.Getenv("NODE_NAME")
+	}
+	if node == "" {
+		log.Fatal("Agent mode needs -node-name or $NODE_NAME")
+	}
+
+	// A Kubernetes client is only needed to exec into source pods
+	var k8sClient *k8s.Client
+	if *probeMode == prober.ExecModeExec {
+		client, err := k8s.NewClient(*kubeconfig)
+		if err != nil {
+			log.Fatalf("Failed to create Kubernetes client: %v", err)
+		}
+		k8sClient = client
+	}
+	agentProber := prober.NewProber(k8sClient)
+	if err := agentProber.SetExecMode(*probeMode); err != nil {
+		log.Fatalf("Invalid -probe-mode: %v", err)
+	}
+	if *tlsCAFile != "" {
+		if err := agentProber.SetTLSCAFile(*tlsCAFile); err != nil {
+			log.Fatalf("Invalid -tls-ca-file: %v", err)
+		}
+	}
+
+	if *throughputPort > 0 {
+		go func() {
+			log.Printf("Hosting a throughput receiver on port %d", *throughputPort)
+			if err := prober.ServeThroughput(ctx, fmt.Sprintf(":%d", *throughputPort)); err != nil {
+				log.Printf("Throughput receiver failed: %v", err)
+			}
+		}()
+	}
+
+	log.Printf("Starting probe agent on node %s (server: %s)", node, *agentServer)
+	if err := prober.NewAgent(*agentServer, node, agentProber).Run(ctx); err != nil {
+		log.Fatalf("Probe agent failed: %v", err)
+	}
+	log.Println("Probe agent stopped")
+}
+
 // corsMiddleware adds CORS headers to all responses
 func corsMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -459,6 +643,158 @@ func probesHandler(prober *prober.Prober) http.HandlerFunc {
 	}
 }
 
+func probeCoverageHandler(prober *prober.Prober) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(prober.Coverage()); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}
+}
+
+func probeStatsHandler(networkProber *prober.Prober) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		request := prober.StatsQuery{
+			Source:    query.Get("source"),
+			Target:    query.Get("target"),
+			ProbeType: query.Get("type"),
+		}
+		if value := query.Get("window"); value != "" {
+			parsed, err := time.ParseDuration(value)
+			if err != nil || parsed <= 0 {
+				http.Error(w, fmt.Sprintf("Invalid window %q", value), http.StatusBadRequest)
+				return
+			}
+			request.Window = parsed
+		}
+
+		stats, err := networkProber.PairStats(request)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if stats == nil {
+			stats = []prober.PairStats{}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(stats); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}
+}
+
+func probeMTUHandler(prober *prober.Prober) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(prober.PathMTUs()); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}
+}
+
+// throughputHandler lists the latest throughput test between each pair of
+// endpoints, or runs a test when the request confirms it
+func throughputHandler(networkProber *prober.Prober) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			w.Header().Set("Content-Type", "application/json")
+			if err := json.NewEncoder(w).Encode(networkProber.ThroughputResults()); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+			}
+			return
+		}
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var request prober.ThroughputRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		if request.Target == "" {
+			http.Error(w, "target is required", http.StatusBadRequest)
+			return
+		}
+
+		run, err := networkProber.RunThroughput(r.Context(), request)
+		if errors.Is(err, prober.ErrThroughputDisabled) {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+		if errors.Is(err, prober.ErrThroughputBusy) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if run.Status == prober.ProbeRunRunning {
+			w.Header().Set("Location", "/api/probes/run/"+run.ID)
+			w.WriteHeader(http.StatusAccepted)
+		}
+		if err := json.NewEncoder(w).Encode(run); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}
+}
+
+func runProbeHandler(networkProber *prober.Prober) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var request prober.ProbeRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		if request.Source == "" || request.Target == "" {
+			http.Error(w, "source and target are required", http.StatusBadRequest)
+			return
+		}
+
+		run, err := networkProber.RunProbe(r.Context(), request)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if run.Status == prober.ProbeRunRunning {
+			w.Header().Set("Location", "/api/probes/run/"+run.ID)
+			w.WriteHeader(http.StatusAccepted)
+		}
+		if err := json.NewEncoder(w).Encode(run); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}
+}
+
+func probeRunHandler(networkProber *prober.Prober) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := strings.TrimPrefix(r.URL.Path, "/api/probes/run/")
+		run, ok := networkProber.GetProbeRun(id)
+		if !ok {
+			http.Error(w, fmt.Sprintf("probe run %q not found", id), http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(run); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}
+}
+
 func issuesHandler(analyzer *analyzer.Analyzer) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
@@ -469,7 +805,69 @@ func issuesHandler(analyzer *analyzer.Analyzer) http.HandlerFunc {
 	}
 }
 
-func simulateHandler(sim *simulator.Simulator, collector *collector.Collector) http.HandlerFunc {
+func intentsHandler(networkAnalyzer *analyzer.Analyzer, collector *collector.Collector, prober *prober.Prober) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var results []analyzer.IntentResult
+		switch r.Method {
+		case http.MethodGet:
+			// Results of the last analysis cycle
+			results = networkAnalyzer.GetIntentResults()
+		case http.MethodPost:
+			// Check a submitted spec now, e.g. from CI
+			body, err := io.ReadAll(r.Body)
+			if err != nil {
+				http.Error(w, "Invalid request body", http.StatusBadRequest)
+				return
+			}
+			intents, err := analyzer.ParseIntents(body)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			results = networkAnalyzer.CheckIntents(intents, collector, prober)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		
+		report := IntentReport{Satisfied: true, Results: results}
+		for _, result := range results {
+			if !result.Satisfied {
+				report.Satisfied = false
+			}
+		}
+		
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(report); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}
+}
+
+func traceHandler(networkAnalyzer *analyzer.Analyzer, collector *collector.Collector, prober *prober.Prober) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		from, to := query.Get("from"), query.Get("to")
+		if from == "" || to == "" {
+			http.Error(w, "from and to parameters are required", http.StatusBadRequest)
+			return
+		}
+		protocol := corev1.Protocol(strings.ToUpper(query.Get("protocol")))
+		
+		trace, err := networkAnalyzer.TracePath(from, to, protocol, collector, prober)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(trace); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}
+}
+
+func simulateHandler(base *simulator.Simulator, collector *collector.Collector) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodPost {
 			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -478,33 +876,106 @@ func simulateHandler(sim *simulator.Simulator, collector *collector.Collector) h
 		
 		// Define the struct locally to avoid import issues
 		type SimulationRequest struct {
-			Type        string                 `json:"type"`
-			Name        string                 `json:"name"`
-			Description string                 `json:"description"`
-			Namespace   string                 `json:"namespace"`
-			Changes     map[string]interface{} `json:"changes"`
-			Scope       []string               `json:"scope"`
-			Parameters  map[string]interface{} `json:"parameters"`
+			Type        string                       `json:"type"`
+			Name        string                       `json:"name"`
+			Description string                       `json:"description"`
+			Namespace   string                       `json:"namespace"`
+			Changes     map[string]interface{}       `json:"changes"`
+			Scope       []string                     `json:"scope"`
+			Parameters  map[string]interface{}       `json:"parameters"`
+			Action      string                       `json:"action"`   // add, modify, delete or apply
+			Manifest    string                       `json:"manifest"` // NetworkPolicy YAML or JSON
+			Window      string                       `json:"window"`   // observed traffic window, e.g. "1h"
+			Policies    []networkingv1.NetworkPolicy `json:"policies"`
+		}
+		
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
 		}
 		
 		var request SimulationRequest
-		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		if isPolicyManifest(r, body) {
+			// Raw NetworkPolicy manifests, options come from the query string
+			request.Type = "policy"
+			request.Manifest = string(body)
+			request.Action = r.URL.Query().Get("action")
+			request.Namespace = r.URL.Query().Get("namespace")
+			request.Window = r.URL.Query().Get("window")
+		} else if err := json.Unmarshal(body, &request); err != nil {
 			http.Error(w, "Invalid request body", http.StatusBadRequest)
 			return
 		}
 		
-		// Update simulator with current cluster state
+		// Simulate against this request's own snapshot of the cluster state
 		pods := collector.GetPods()
 		services := collector.GetServices()
 		policies := collector.GetNetworkPolicies()
-		sim.UpdateResources(pods, services, policies)
+		sim := base.Snapshot(pods, services, policies, collector.GetNamespaces())
 		
 		var result interface{}
-		var err error
 		
 		// Run appropriate simulation based on type
 		switch request.Type {
 		case "policy":
+			action := request.Action
+			if action == "" {
+				action = simulator.ActionAdd
+			}
+			if !simulator.ValidAction(action) {
+				http.Error(w, fmt.Sprintf("Invalid action %q: must be add, modify, delete or apply", action), http.StatusBadRequest)
+				return
+			}
+			window := simulator.DefaultFlowWindow
+			if request.Window != "" {
+				window, err = time.ParseDuration(request.Window)
+				if err != nil || window <= 0 {
+					http.Error(w, fmt.Sprintf("Invalid window %q", request.Window), http.StatusBadRequest)
+					return
+				}
+			}
+			
+			if request.Manifest != "" || len(request.Policies) > 0 {
+				candidates := make([]*networkingv1.NetworkPolicy, 0, len(request.Policies))
+				for i := range request.Policies {
+					candidates = append(candidates, &request.Policies[i])
+				}
+				if request.Manifest != "" {
+					parsed, parseErr := simulator.ParsePolicies([]byte(request.Manifest))
+					if parseErr != nil {
+						http.Error(w, fmt.Sprintf("Invalid NetworkPolicy manifest: %v", parseErr), http.StatusBadRequest)
+						return
+					}
+					candidates = append(candidates, parsed...)
+				}
+				
+				var fieldErrors []FieldErrorDTO
+				for i, policy := range candidates {
+					if policy.Namespace == "" {
+						policy.Namespace = request.Namespace
+						if policy.Namespace == "" {
+							policy.Namespace = metav1.NamespaceDefault
+						}
+					}
+					for _, fieldErr := range simulator.ValidatePolicy(policy) {
+						fieldErrors = append(fieldErrors, FieldErrorDTO{
+							Policy: i,
+							Field:  fieldErr.Field,
+							Type:   string(fieldErr.Type),
+							Detail: fieldErr.ErrorBody(),
+						})
+					}
+				}
+				if len(fieldErrors) > 0 {
+					writeValidationErrors(w, fieldErrors)
+					return
+				}
+				
+				result, err = sim.SimulateNetworkPoliciesInWindow(candidates, action, window)
+				break
+			}
+			
 			// Create a mock network policy for simulation based on description
 			policy := &networkingv1.NetworkPolicy{
 				ObjectMeta: metav1.ObjectMeta{
@@ -532,7 +1003,7 @@ func simulateHandler(sim *simulator.Simulator, collector *collector.Collector) h
 				}
 			}
 			
-			result, err = sim.SimulateNetworkPolicy(policy, "add")
+			result, err = sim.SimulateNetworkPoliciesInWindow([]*networkingv1.NetworkPolicy{policy}, action, window)
 			
 		case "resource":
 			// Simulate pod failure or scaling
@@ -555,6 +1026,14 @@ func simulateHandler(sim *simulator.Simulator, collector *collector.Collector) h
 			return
 		}
 		
+		if errors.Is(err, simulator.ErrPolicyNotFound) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		if errors.Is(err, simulator.ErrPolicyExists) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
 		if err != nil {
 			http.Error(w, fmt.Sprintf("Simulation failed: %v", err), http.StatusInternalServerError)
 			return
@@ -567,6 +1046,143 @@ func simulateHandler(sim *simulator.Simulator, collector *collector.Collector) h
 	}
 }
 
+func generatePoliciesHandler(base *simulator.Simulator, collector *collector.Collector) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		namespace := query.Get("namespace")
+		if namespace == "" {
+			http.Error(w, "namespace parameter is required", http.StatusBadRequest)
+			return
+		}
+		window := simulator.DefaultFlowWindow
+		if value := query.Get("window"); value != "" {
+			parsed, err := time.ParseDuration(value)
+			if err != nil || parsed <= 0 {
+				http.Error(w, fmt.Sprintf("Invalid window %q", value), http.StatusBadRequest)
+				return
+			}
+			window = parsed
+		}
+		
+		// Generate from this request's own snapshot of the cluster state
+		sim := base.Snapshot(collector.GetPods(), collector.GetServices(), collector.GetNetworkPolicies(), collector.GetNamespaces())
+		
+		generated, err := sim.GeneratePolicies(simulator.GeneratePolicyOptions{
+			Namespace: namespace,
+			Workload:  query.Get("workload"),
+			Window:    window,
+		})
+		if errors.Is(err, simulator.ErrNoFlowCollector) {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		
+		manifest, err := simulator.RenderPolicies(generated.Policies)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		generated.Manifest = string(manifest)
+		
+		// Check the generated policies against the same traffic before they are applied
+		if len(generated.Policies) > 0 {
+			generated.Simulation, err = sim.SimulateNetworkPoliciesInWindow(generated.Policies, simulator.ActionApply, window)
+			if err != nil {
+				http.Error(w, fmt.Sprintf("Simulation failed: %v", err), http.StatusInternalServerError)
+				return
+			}
+		}
+		
+		if query.Get("format") == "yaml" {
+			w.Header().Set("Content-Type", "application/yaml")
+			w.Write(manifest)
+			return
+		}
+		
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(generated); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}
+}
+
+func reachabilityHandler(base *simulator.Simulator, collector *collector.Collector) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		opts := simulator.ReachabilityOptions{
+			SourceNamespace:      query.Get("source_namespace"),
+			DestinationNamespace: query.Get("destination_namespace"),
+			Protocol:             query.Get("protocol"),
+			Verdict:              query.Get("verdict"),
+			ObservedOnly:         query.Get("observed") == "true",
+			Window:               simulator.DefaultFlowWindow,
+		}
+		if value := query.Get("port"); value != "" {
+			port, err := strconv.Atoi(value)
+			if err != nil {
+				http.Error(w, fmt.Sprintf("Invalid port %q", value), http.StatusBadRequest)
+				return
+			}
+			opts.Port = int32(port)
+		}
+		if value := query.Get("window"); value != "" {
+			parsed, err := time.ParseDuration(value)
+			if err != nil || parsed <= 0 {
+				http.Error(w, fmt.Sprintf("Invalid window %q", value), http.StatusBadRequest)
+				return
+			}
+			opts.Window = parsed
+		}
+		
+		// Compute over this request's own snapshot of the cluster state
+		sim := base.Snapshot(collector.GetPods(), collector.GetServices(), collector.GetNetworkPolicies(), collector.GetNamespaces())
+		
+		matrix, err := sim.ComputeReachability(opts)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(matrix); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}
+}
+
+// isPolicyManifest reports whether a simulate request body is a raw NetworkPolicy
+// manifest rather than a SimulationRequest
+func isPolicyManifest(r *http.Request, body []byte) bool {
+	if strings.Contains(r.Header.Get("Content-Type"), "yaml") {
+		return true
+	}
+	var typeMeta struct {
+		Kind string `json:"kind"`
+	}
+	if err := json.Unmarshal(body, &typeMeta); err != nil {
+		return false
+	}
+	switch typeMeta.Kind {
+	case "NetworkPolicy", "NetworkPolicyList", "List":
+		return true
+	}
+	return false
+}
+
+// writeValidationErrors responds with field-level validation errors
+func writeValidationErrors(w http.ResponseWriter, fieldErrors []FieldErrorDTO) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(ValidationErrorResponse{
+		Error:       "NetworkPolicy validation failed",
+		FieldErrors: fieldErrors,
+	})
+}
+
 func insightsHandler(analyzer *analyzer.Analyzer) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
@@ -1,11 +1,15 @@
 module network-visualizer-backend
 
-go 1.21
+go 1.24.0
 
 require (
 	github.com/gorilla/mux v1.8.1
 	github.com/gorilla/websocket v1.5.1
+	github.com/miekg/dns v1.1.72
+	golang.org/x/net v0.46.0
+	golang.org/x/time v0.3.0
 	k8s.io/api v0.28.4
 	k8s.io/apimachinery v0.28.4
 	k8s.io/client-go v0.28.4
+	sigs.k8s.io/yaml v1.3.0
 )
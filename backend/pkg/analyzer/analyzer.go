@@ -3,13 +3,16 @@ package analyzer
 import (
 	"context"
 	"fmt"
+	"sort"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/christine33-creator/k8-network-visualizer/pkg/collector"
+	"github.com/christine33-creator/k8-network-visualizer/pkg/flowcollector"
 	"github.com/christine33-creator/k8-network-visualizer/pkg/graph"
 	"github.com/christine33-creator/k8-network-visualizer/pkg/prober"
+	"github.com/christine33-creator/k8-network-visualizer/pkg/simulator"
 	corev1 "k8s.io/api/core/v1"
 	networkingv1 "k8s.io/api/networking/v1"
 )
@@ -111,14 +114,46 @@ type SimulationRisk struct {
 	Mitigation  string  `json:"mitigation"`
 }
 
+// PodIsolation describes how NetworkPolicies isolate a pod
+type PodIsolation struct {
+	Pod             string   `json:"pod"` // namespace/name
+	HostNetwork     bool     `json:"host_network"`
+	IngressIsolated bool     `json:"ingress_isolated"`
+	EgressIsolated  bool     `json:"egress_isolated"`
+	IngressPolicies []string `json:"ingress_policies"`
+	EgressPolicies  []string `json:"egress_policies"`
+}
+
+// Status summarizes the isolation as none, ingress, egress, full or host-network
+func (i PodIsolation) Status() string {
+	switch {
+	case i.HostNetwork:
+		return "host-network"
+	case i.IngressIsolated && i.EgressIsolated:
+		return "full"
+	case i.IngressIsolated:
+		return "ingress"
+	case i.EgressIsolated:
+		return "egress"
+	default:
+		return "none"
+	}
+}
+
 // Analyzer performs network analysis and issue detection
 type Analyzer struct {
 	graphEngine *graph.Engine
 	issues      []NetworkIssue
 	insights    []IntelligentInsight
 	simulations []SimulationResult
+	isolation   map[string]PodIsolation
 	mu          sync.RWMutex
 	issueCount  int
+
+	intentSource  IntentSource
+	intentResults []IntentResult
+	flowCollector flowcollector.FlowCollectorInterface
+	certExpiry    time.Duration
 }
 
 // NewAnalyzer creates a new analyzer instance
@@ -128,6 +163,7 @@ func NewAnalyzer(engine *graph.Engine) *Analyzer {
 		issues:      make([]NetworkIssue, 0),
 		insights:    make([]IntelligentInsight, 0),
 		simulations: make([]SimulationResult, 0),
+		isolation:   make(map[string]PodIsolation),
 		issueCount:  0,
 	}
 }
@@ -160,16 +196,27 @@ func (a *Analyzer) analyze(collector *collector.Collector, p *prober.Prober) {
 
 	// Update graph with latest data
 	a.updateGraph(collector)
+	a.updateProbeEdges(p)
+	a.updateMTUEdges(p)
+	a.updateThroughputEdges(p)
 
 	// Perform various analyses
 	a.analyzeConnectivity(p)
+	a.analyzeHealthChecks(p)
+	a.analyzeTLS(p)
 	a.analyzeNetworkPolicies(collector)
+	a.lintNetworkPolicies(collector)
 	a.analyzePodHealth(collector)
 	a.analyzeServiceEndpoints(collector)
 	a.analyzeCIDROverlaps(collector)
 	a.analyzeLatency(p)
+	a.analyzePacketLoss(p)
+	a.analyzePathMTU(p)
 	a.analyzeDNS(collector)
+	a.analyzeDNSProbes(collector, p)
 	a.detectFirewalls(p, collector)
+	a.analyzePolicyEnforcement(p)
+	a.analyzeIntents(collector, p)
 
 	// Generate intelligent insights
 	a.generateIntelligentInsights(collector, p)
@@ -209,6 +256,24 @@ func (a *Analyzer) updateGraph(collector *collector.Collector) {
 	}
 }
 
+// reportedElsewhere reports whether a failed probe is judged by a check other
+// than the connectivity and firewall checks
+func reportedElsewhere(probe ProbeResult) bool {
+	switch {
+	case probe.HealthMismatch():
+		return true // reached the target; analyzeHealthChecks reports these
+	case probe.ProbeType == prober.ProbeTypeDNS:
+		return true // analyzeDNSProbes reports these
+	case probe.ProbeType == prober.ProbeTypeICMP || probe.ProbeType == prober.ProbeTypeMTU:
+		return true // analyzePacketLoss reports echo requests that go unanswered
+	case probe.ProbeType == prober.ProbeTypeThroughput:
+		return true // reported to whoever requested the test
+	case probe.Verdict == prober.VerdictAllowed || probe.Verdict == prober.VerdictDenied:
+		return true // analyzePolicyEnforcement judges these against the policies
+	}
+	return false
+}
+
 // analyzeConnectivity checks for connectivity issues
 func (a *Analyzer) analyzeConnectivity(p *prober.Prober) {
 	failedProbes := p.GetFailedProbes()
@@ -216,6 +281,9 @@ func (a *Analyzer) analyzeConnectivity(p *prober.Prober) {
 	// Group failed probes by target
 	failureMap := make(map[string][]ProbeResult)
 	for _, probe := range failedProbes {
+		if reportedElsewhere(probe) {
+			continue
+		}
 		key := fmt.Sprintf("%s/%s", probe.TargetNS, probe.TargetSvc)
 		if probe.TargetSvc == "" {
 			key = fmt.Sprintf("%s/%s", probe.TargetNS, probe.TargetPod)
@@ -251,6 +319,83 @@ func (a *Analyzer) analyzeConnectivity(p *prober.Prober) {
 	}
 }
 
+// analyzeHealthChecks reports Service ports whose HTTP or gRPC health probes
+// connect but get an unexpected answer
+func (a *Analyzer) analyzeHealthChecks(p *prober.Prober) {
+	results := p.GetRecentResults(5 * time.Minute)
+
+	// Group health probes to Services by target port, in probe order
+	probesByTarget := make(map[string][]ProbeResult)
+	var targets []string
+	for _, probe := range results {
+		if probe.TargetSvc == "" || (probe.ProbeType != prober.ProbeTypeHTTP && probe.ProbeType != prober.ProbeTypeGRPC) {
+			continue
+		}
+		key := fmt.Sprintf("%s/%s:%d", probe.TargetNS, probe.TargetSvc, probe.TargetPort)
+		if _, ok := probesByTarget[key]; !ok {
+			targets = append(targets, key)
+		}
+		probesByTarget[key] = append(probesByTarget[key], probe)
+	}
+
+	for _, target := range targets {
+		probes := probesByTarget[target]
+		latest := probes[len(probes)-1]
+		if !latest.HealthMismatch() {
+			continue
+		}
+		mismatches := 0
+		for _, probe := range probes {
+			if probe.HealthMismatch() {
+				mismatches++
+			}
+		}
+
+		got := latest.GRPCStatus
+		suggestions := []string{
+			"Check the application logs of the Service's backend pods",
+			"Verify the gRPC health service is registered and reports SERVING",
+			fmt.Sprintf("Set the %s annotation if the check uses another service name", prober.AnnotationGRPCService),
+		}
+		if latest.ProbeType == prober.ProbeTypeHTTP {
+			got = fmt.Sprintf("HTTP %d from %s", latest.StatusCode, latest.Path)
+			suggestions = []string{
+				"Check the application logs of the Service's backend pods",
+				fmt.Sprintf("Set the %s annotation if %s is not the health endpoint", prober.AnnotationProbePath, latest.Path),
+				fmt.Sprintf("Add %d to the %s annotation if it is an expected answer", latest.StatusCode, prober.AnnotationExpectStatus),
+			}
+		}
+
+		// A check that fails every time is broken; one that sometimes passes is flapping
+		severity := SeverityHigh
+		if mismatches < len(probes) {
+			severity = SeverityMedium
+		}
+
+		a.addIssue(NetworkIssue{
+			ID:       a.generateIssueID(),
+			Type:     IssueTypeResourceHealth,
+			Severity: severity,
+			Title:    fmt.Sprintf("%s Health Check Failing: %s", latest.ProbeType, target),
+			Description: fmt.Sprintf("%d of %d %s probes reached %s but got %s, expected %s.",
+				mismatches, len(probes), latest.ProbeType, target, got, latest.Expected),
+			Affected:    []string{target},
+			Suggestions: suggestions,
+			Details: map[string]interface{}{
+				"probe_type":    latest.ProbeType,
+				"status_code":   latest.StatusCode,
+				"grpc_status":   latest.GRPCStatus,
+				"path":          latest.Path,
+				"expected":      latest.Expected,
+				"failure_count": mismatches,
+				"probe_count":   len(probes),
+				"last_error":    latest.Error,
+			},
+			Timestamp: time.Now(),
+		})
+	}
+}
+
 // analyzeNetworkPolicies checks for policy-related issues
 func (a *Analyzer) analyzeNetworkPolicies(collector *collector.Collector) {
 	policies := collector.GetNetworkPolicies()
@@ -282,34 +427,89 @@ func (a *Analyzer) analyzeNetworkPolicies(collector *collector.Collector) {
 		}
 	}
 
-	// Check for pods without any policy coverage
+	// Compute per-pod isolation from the policies' selectors
+	isolation := a.computeIsolation(pods, policies, collector.GetNamespaces())
+	a.mu.Lock()
+	a.isolation = isolation
+	a.mu.Unlock()
+
 	for _, pod := range pods {
-		covered := false
-		for _, policy := range policies {
-			if policy.Namespace == pod.Namespace {
-				// Simplified check - in production, evaluate selectors properly
-				covered = true
-				break
-			}
+		key := fmt.Sprintf("%s/%s", pod.Namespace, pod.Name)
+		podIsolation := isolation[key]
+		a.graphEngine.UpdateNodeProperties(fmt.Sprintf("pod/%s", key), map[string]string{
+			"isolation":        podIsolation.Status(),
+			"ingress_isolated": fmt.Sprintf("%t", podIsolation.IngressIsolated),
+			"egress_isolated":  fmt.Sprintf("%t", podIsolation.EgressIsolated),
+			"ingress_policies": strings.Join(podIsolation.IngressPolicies, ","),
+			"egress_policies":  strings.Join(podIsolation.EgressPolicies, ","),
+		})
+
+		// Host network pods cannot be isolated by NetworkPolicy
+		if podIsolation.Status() != "none" || pod.Status.Phase != corev1.PodRunning {
+			continue
+		}
+		issue := NetworkIssue{
+			ID:          a.generateIssueID(),
+			Type:        IssueTypePolicy,
+			Severity:    SeverityLow,
+			Title:       fmt.Sprintf("Pod without NetworkPolicy: %s/%s", pod.Namespace, pod.Name),
+			Description: "No NetworkPolicy selects this pod, so all ingress and egress traffic is allowed",
+			Affected:    []string{key},
+			Suggestions: []string{
+				"Consider adding a NetworkPolicy for this pod",
+				"Add a default-deny policy to the namespace and allow required traffic explicitly",
+				"Review security requirements for this workload",
+			},
+			Details: map[string]interface{}{
+				"ingress_isolated": false,
+				"egress_isolated":  false,
+			},
+			Timestamp: time.Now(),
 		}
+		a.addIssue(issue)
+	}
+}
 
-		if !covered && pod.Status.Phase == corev1.PodRunning {
-			issue := NetworkIssue{
-				ID:          a.generateIssueID(),
-				Type:        IssueTypePolicy,
-				Severity:    SeverityLow,
-				Title:       fmt.Sprintf("Pod without NetworkPolicy: %s/%s", pod.Namespace, pod.Name),
-				Description: "Pod is not covered by any NetworkPolicy, which may be a security risk",
-				Affected:    []string{fmt.Sprintf("%s/%s", pod.Namespace, pod.Name)},
-				Suggestions: []string{
-					"Consider adding a NetworkPolicy for this pod",
-					"Review security requirements for this workload",
-				},
-				Timestamp: time.Now(),
-			}
-			a.addIssue(issue)
+// computeIsolation determines which policies select each pod in each direction
+func (a *Analyzer) computeIsolation(pods []*corev1.Pod, policies []*networkingv1.NetworkPolicy, namespaces []*corev1.Namespace) map[string]PodIsolation {
+	namespaceLabels := make(map[string]map[string]string, len(namespaces))
+	for _, ns := range namespaces {
+		namespaceLabels[ns.Name] = ns.Labels
+	}
+	evaluator := simulator.NewPolicyEvaluator(policies, namespaceLabels)
+
+	isolation := make(map[string]PodIsolation, len(pods))
+	for _, pod := range pods {
+		key := fmt.Sprintf("%s/%s", pod.Namespace, pod.Name)
+		podIsolation := PodIsolation{
+			Pod:             key,
+			HostNetwork:     pod.Spec.HostNetwork,
+			IngressPolicies: evaluator.PoliciesSelecting(pod, networkingv1.PolicyTypeIngress),
+			EgressPolicies:  evaluator.PoliciesSelecting(pod, networkingv1.PolicyTypeEgress),
+		}
+		podIsolation.IngressIsolated = len(podIsolation.IngressPolicies) > 0
+		podIsolation.EgressIsolated = len(podIsolation.EgressPolicies) > 0
+		if podIsolation.IngressPolicies == nil {
+			podIsolation.IngressPolicies = []string{}
 		}
+		if podIsolation.EgressPolicies == nil {
+			podIsolation.EgressPolicies = []string{}
+		}
+		isolation[key] = podIsolation
+	}
+	return isolation
+}
+
+// GetPodIsolation returns the isolation status of every pod, keyed by namespace/name
+func (a *Analyzer) GetPodIsolation() map[string]PodIsolation {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	isolation := make(map[string]PodIsolation, len(a.isolation))
+	for key, value := range a.isolation {
+		isolation[key] = value
 	}
+	return isolation
 }
 
 // analyzePodHealth checks for unhealthy pods
@@ -531,6 +731,169 @@ func (a *Analyzer) analyzeDNS(collector *collector.Collector) {
 	}
 }
 
+// dnsSlowThresholdMs is the lookup latency counted as slow DNS resolution
+const dnsSlowThresholdMs = 250
+
+// analyzeDNSProbes reports what DNS resolution probes saw: a cluster DNS server
+// that fails, NXDOMAIN for Services that exist, and slow lookups
+func (a *Analyzer) analyzeDNSProbes(collector *collector.Collector, p *prober.Prober) {
+	var probes []ProbeResult
+	for _, result := range p.GetRecentResults(5 * time.Minute) {
+		if result.ProbeType == prober.ProbeTypeDNS {
+			probes = append(probes, result)
+		}
+	}
+	if len(probes) == 0 {
+		return
+	}
+	server := fmt.Sprintf("%s:%d", probes[len(probes)-1].TargetIP, probes[len(probes)-1].TargetPort)
+
+	// Server failures: no response at all, or SERVFAIL/REFUSED
+	rcodes := make(map[string]int)
+	var failures []ProbeResult
+	for _, probe := range probes {
+		rcode := probe.Rcode
+		if rcode == "" {
+			rcode = "no response"
+		}
+		rcodes[rcode]++
+		if probe.Rcode == "" || probe.Rcode == "SERVFAIL" || probe.Rcode == "REFUSED" {
+			failures = append(failures, probe)
+		}
+	}
+	if len(failures) >= 3 {
+		severity := SeverityHigh
+		if len(failures)*2 >= len(probes) {
+			severity = SeverityCritical
+		}
+		a.addIssue(NetworkIssue{
+			ID:          a.generateIssueID(),
+			Type:        IssueTypeDNS,
+			Severity:    severity,
+			Title:       fmt.Sprintf("Cluster DNS Failing: %s", server),
+			Description: fmt.Sprintf("%d of %d DNS queries to %s in the last 5 minutes got no answer or a server error", len(failures), len(probes), server),
+			Affected:    []string{server},
+			Suggestions: []string{
+				"Check the CoreDNS pods: kubectl -n kube-system get pods -l k8s-app=kube-dns",
+				"Check the CoreDNS logs for upstream or API server errors",
+				"Verify no NetworkPolicy blocks UDP and TCP port 53 to kube-system",
+				"Check CoreDNS CPU and memory limits for throttling",
+			},
+			Details: map[string]interface{}{
+				"server":         server,
+				"failed_queries": len(failures),
+				"total_queries":  len(probes),
+				"rcodes":         rcodes,
+				"sample_errors":  dnsSamples(failures, func(probe ProbeResult) string { return probe.Error }),
+			},
+			Timestamp: time.Now(),
+		})
+	}
+
+	// NXDOMAIN for a Service that exists, judged on each query's latest answer
+	existing := make(map[string]bool)
+	for _, svc := range collector.GetServices() {
+		existing[fmt.Sprintf("%s/%s", svc.Namespace, svc.Name)] = true
+	}
+	latest := make(map[string]ProbeResult)
+	for _, probe := range probes {
+		if probe.TargetSvc != "" && !probe.Timestamp.Before(latest[probe.Query].Timestamp) {
+			latest[probe.Query] = probe
+		}
+	}
+	missing := make(map[string][]string)
+	for query, probe := range latest {
+		key := fmt.Sprintf("%s/%s", probe.TargetNS, probe.TargetSvc)
+		if probe.Rcode == "NXDOMAIN" && existing[key] {
+			missing[key] = append(missing[key], fmt.Sprintf("%s %s", probe.QueryType, query))
+		}
+	}
+	for key, queries := range missing {
+		sort.Strings(queries)
+		a.addIssue(NetworkIssue{
+			ID:          a.generateIssueID(),
+			Type:        IssueTypeDNS,
+			Severity:    SeverityHigh,
+			Title:       fmt.Sprintf("DNS Record Missing: %s", key),
+			Description: fmt.Sprintf("%s answered NXDOMAIN for %s although the Service exists", server, strings.Join(queries, ", ")),
+			Affected:    []string{key},
+			Suggestions: []string{
+				"Check that CoreDNS's kubernetes plugin serves the cluster domain",
+				"Verify -cluster-domain matches the cluster's DNS domain",
+				"Look for CoreDNS errors watching Services and EndpointSlices",
+			},
+			Details: map[string]interface{}{
+				"server":  server,
+				"queries": queries,
+			},
+			Timestamp: time.Now(),
+		})
+	}
+
+	// Slow resolution among queries that got an answer
+	var answered, slow []ProbeResult
+	var total, slowest int64
+	for _, probe := range probes {
+		if probe.Rcode == "" {
+			continue
+		}
+		answered = append(answered, probe)
+		total += probe.Latency
+		if probe.Latency >= dnsSlowThresholdMs {
+			slow = append(slow, probe)
+		}
+		if probe.Latency > slowest {
+			slowest = probe.Latency
+		}
+	}
+	if len(slow) >= 3 && len(slow)*10 >= len(answered) {
+		sort.Slice(slow, func(i, j int) bool {
+			return slow[i].Latency > slow[j].Latency
+		})
+		a.addIssue(NetworkIssue{
+			ID:          a.generateIssueID(),
+			Type:        IssueTypeDNS,
+			Severity:    SeverityMedium,
+			Title:       fmt.Sprintf("Slow DNS Resolution: %s", server),
+			Description: fmt.Sprintf("%d of %d DNS queries to %s took %dms or longer", len(slow), len(answered), server, dnsSlowThresholdMs),
+			Affected:    []string{server},
+			Suggestions: []string{
+				"Scale CoreDNS or enable NodeLocal DNSCache",
+				"Check CoreDNS upstream resolvers for slow forwarding",
+				"Lower ndots in pod dnsConfig to reduce search-path lookups",
+			},
+			Details: map[string]interface{}{
+				"server":             server,
+				"slow_queries":       len(slow),
+				"total_queries":      len(answered),
+				"average_latency_ms": total / int64(len(answered)),
+				"max_latency_ms":     slowest,
+				"threshold_ms":       dnsSlowThresholdMs,
+				"slowest":            dnsSamples(slow, func(probe ProbeResult) string { return fmt.Sprintf("%dms", probe.Latency) }),
+			},
+			Timestamp: time.Now(),
+		})
+	}
+}
+
+// dnsSamples describes up to five distinct probes as evidence
+func dnsSamples(probes []ProbeResult, describe func(ProbeResult) string) []string {
+	seen := make(map[string]bool)
+	var samples []string
+	for _, probe := range probes {
+		sample := fmt.Sprintf("%s %s: %s", probe.QueryType, probe.Query, describe(probe))
+		if seen[sample] {
+			continue
+		}
+		seen[sample] = true
+		samples = append(samples, sample)
+		if len(samples) == 5 {
+			break
+		}
+	}
+	return samples
+}
+
 // detectFirewalls detects potential firewall or blocked traffic patterns
 func (a *Analyzer) detectFirewalls(p *prober.Prober, collector *collector.Collector) {
 	failedProbes := p.GetFailedProbes()
@@ -541,6 +904,9 @@ func (a *Analyzer) detectFirewalls(p *prober.Prober, collector *collector.Collec
 	connectionRefused := 0
 	
 	for _, probe := range failedProbes {
+		if reportedElsewhere(probe) {
+			continue
+		}
 		if strings.Contains(probe.Error, "timeout") {
 			timeoutErrors++
 			key := fmt.Sprintf("%s->%s:%d", probe.SourceNS, probe.TargetIP, probe.TargetPort)
@@ -603,50 +969,207 @@ func (a *Analyzer) detectFirewalls(p *prober.Prober, collector *collector.Collec
 	}
 }
 
-// analyzeLatency checks for high latency issues
+// Latency thresholds for probe pairs
+const (
+	latencyWindow      = 5 * time.Minute
+	latencyThresholdMs = 100 // 90th percentile of a pair
+	latencyMinSamples  = 3   // round trips before a pair is judged
+)
+
+// analyzeLatency reports targets that are slow to reach from at least one
+// source, judged on each pair's 90th percentile so that one slow probe does
+// not raise an issue and a slow tail is not averaged away
 func (a *Analyzer) analyzeLatency(p *prober.Prober) {
-	results := p.GetRecentResults(5 * time.Minute)
-	
-	// Group by target and calculate average latency
-	latencyMap := make(map[string][]int64)
-	for _, result := range results {
-		if result.Success {
-			key := fmt.Sprintf("%s:%d", result.TargetIP, result.TargetPort)
-			latencyMap[key] = append(latencyMap[key], result.Latency)
+	stats, err := p.PairStats(prober.StatsQuery{Window: latencyWindow})
+	if err != nil {
+		return
+	}
+
+	// Group the pairs by target, keeping those whose tail is slow
+	measured := make(map[string]int)
+	slow := make(map[string][]prober.PairStats)
+	var targets []string
+	for _, pair := range stats {
+		if pair.Samples < latencyMinSamples {
+			continue
+		}
+		measured[pair.Target]++
+		if pair.P90 <= latencyThresholdMs {
+			continue
 		}
+		if _, ok := slow[pair.Target]; !ok {
+			targets = append(targets, pair.Target)
+		}
+		slow[pair.Target] = append(slow[pair.Target], pair)
 	}
 
-	for target, latencies := range latencyMap {
-		if len(latencies) > 0 {
-			var sum int64
-			for _, l := range latencies {
-				sum += l
-			}
-			avg := sum / int64(len(latencies))
+	for _, target := range targets {
+		pairs := slow[target]
+		sort.Slice(pairs, func(i, j int) bool { return pairs[i].P90 > pairs[j].P90 })
+		worst := pairs[0]
 
-			if avg > 100 { // More than 100ms average
-				issue := NetworkIssue{
-					ID:          a.generateIssueID(),
-					Type:        IssueTypeLatency,
-					Severity:    SeverityMedium,
-					Title:       fmt.Sprintf("High Latency Detected: %s", target),
-					Description: fmt.Sprintf("Average latency to %s is %dms", target, avg),
-					Affected:    []string{target},
-					Suggestions: []string{
-						"Check network congestion",
-						"Review pod placement and affinity rules",
-						"Consider using node-local traffic policies",
-						"Check for CPU throttling on target pods",
-					},
-					Details: map[string]interface{}{
-						"average_latency_ms": avg,
-						"sample_count":       len(latencies),
-					},
-					Timestamp: time.Now(),
-				}
-				a.addIssue(issue)
+		// A median over the threshold means the pair is slow, not just spiky
+		severity := SeverityMedium
+		if worst.P50 > latencyThresholdMs {
+			severity = SeverityHigh
+		}
+		sources := make([]string, 0, len(pairs))
+		for _, pair := range pairs {
+			sources = append(sources, pair.Source)
+		}
+
+		issue := NetworkIssue{
+			ID:       a.generateIssueID(),
+			Type:     IssueTypeLatency,
+			Severity: severity,
+			Title:    fmt.Sprintf("High Latency Detected: %s", target),
+			Description: fmt.Sprintf("90th percentile latency to %s is %.0fms from %s (median %.0fms, 99th percentile %.0fms); %d of %d sources are slow",
+				target, worst.P90, worst.Source, worst.P50, worst.P99, len(pairs), measured[target]),
+			Affected: []string{target},
+			Suggestions: []string{
+				"Check network congestion",
+				"Review pod placement and affinity rules",
+				"Consider using node-local traffic policies",
+				"Check for CPU throttling on target pods",
+			},
+			Details: map[string]interface{}{
+				"p50_latency_ms": worst.P50,
+				"p90_latency_ms": worst.P90,
+				"p99_latency_ms": worst.P99,
+				"jitter_ms":      worst.Jitter,
+				"sample_count":   worst.Samples,
+				"success_ratio":  worst.SuccessRatio,
+				"slow_sources":   sources,
+				"window":         worst.Window,
+			},
+			Timestamp: time.Now(),
+		}
+		a.addIssue(issue)
+	}
+}
+
+// updateProbeEdges puts each probed pair's statistics on its graph edge
+func (a *Analyzer) updateProbeEdges(p *prober.Prober) {
+	stats, err := p.PairStats(prober.StatsQuery{Window: latencyWindow})
+	if err != nil {
+		return
+	}
+	for _, pair := range stats {
+		if !strings.Contains(pair.Target, "/") {
+			continue // a bare IP has no node in the graph
+		}
+		a.graphEngine.UpdateEdgeProbeStats(pair.Source, pair.Target, &graph.ProbeStats{
+			Window:       pair.Window,
+			ProbeTypes:   pair.ProbeTypes,
+			Probes:       pair.Probes,
+			SuccessRatio: pair.SuccessRatio,
+			P50:          pair.P50,
+			P90:          pair.P90,
+			P99:          pair.P99,
+			Jitter:       pair.Jitter,
+			LastSeen:     pair.LastSeen.Format(time.RFC3339),
+		})
+	}
+}
+
+// Packet loss thresholds for ICMP echo probes
+const (
+	packetLossMinSent   = 10 // echo requests before loss is judged
+	packetLossThreshold = 5  // percent
+)
+
+// analyzePacketLoss reports targets whose ICMP echo probes lose packets
+func (a *Analyzer) analyzePacketLoss(p *prober.Prober) {
+	type echoTotals struct {
+		sent, received int
+		rtt            int64 // summed over probes with replies
+		answered       int
+		latest         ProbeResult
+	}
+
+	totals := make(map[string]*echoTotals)
+	var targets []string
+	for _, result := range p.GetRecentResults(5 * time.Minute) {
+		if result.ProbeType != prober.ProbeTypeICMP || result.Sent == 0 {
+			continue // no echo request left this host, so nothing was lost
+		}
+		key := result.TargetIP
+		switch {
+		case result.TargetPod != "":
+			key = fmt.Sprintf("%s/%s", result.TargetNS, result.TargetPod)
+		case result.TargetNode != "":
+			key = fmt.Sprintf("node/%s", result.TargetNode)
+		}
+		if result.Node != "" {
+			key = fmt.Sprintf("%s from node/%s", key, result.Node)
+		}
+		t, ok := totals[key]
+		if !ok {
+			t = &echoTotals{}
+			totals[key] = t
+			targets = append(targets, key)
+		}
+		t.sent += result.Sent
+		t.received += result.Received
+		if result.Received > 0 {
+			t.rtt += result.Latency
+			t.answered++
+		}
+		t.latest = result
+	}
+
+	for _, target := range targets {
+		t := totals[target]
+		if t.sent < packetLossMinSent {
+			continue
+		}
+		loss := float64(t.sent-t.received) / float64(t.sent) * 100
+		if loss < packetLossThreshold {
+			continue
+		}
+
+		severity := SeverityMedium
+		title := fmt.Sprintf("Packet Loss Detected: %s", target)
+		suggestions := []string{
+			"Check the network interface error and drop counters on the nodes involved",
+			"Look for CPU saturation or conntrack table exhaustion on the nodes",
+			"Check the CNI plugin logs for dropped packets",
+			"Verify the MTU matches across the overlay and the underlying network",
+		}
+		switch {
+		case t.received == 0:
+			severity = SeverityCritical
+			title = fmt.Sprintf("No ICMP Echo Replies: %s", target)
+			suggestions = []string{
+				"Check whether the target is running and has an IP on the pod network",
+				"Verify NetworkPolicies or security groups do not drop ICMP to the target",
+				"Check routes between the nodes of the source and the target",
 			}
+		case loss >= 20:
+			severity = SeverityHigh
+		}
+
+		details := map[string]interface{}{
+			"packet_loss_percent": loss,
+			"packets_sent":        t.sent,
+			"packets_received":    t.received,
+			"target_ip":           t.latest.TargetIP,
+			"last_error":          t.latest.Error,
 		}
+		if t.answered > 0 {
+			details["average_rtt_ms"] = t.rtt / int64(t.answered)
+		}
+		a.addIssue(NetworkIssue{
+			ID:          a.generateIssueID(),
+			Type:        IssueTypeConnectivity,
+			Severity:    severity,
+			Title:       title,
+			Description: fmt.Sprintf("%.1f%% of %d ICMP echo requests to %s went unanswered in the last 5 minutes.", loss, t.sent, target),
+			Affected:    []string{target},
+			Suggestions: suggestions,
+			Details:     details,
+			Timestamp:   time.Now(),
+		})
 	}
 }
 
@@ -0,0 +1,29 @@
+package analyzer
+
+import (
+	"testing"
+
+	"github.com/christine33-creator/k8-network-visualizer/pkg/prober"
+)
+
+func TestReportedElsewhere(t *testing.T) {
+	tests := []struct {
+		name  string
+		probe ProbeResult
+		want  bool
+	}{
+		{"tcp timeout", ProbeResult{ProbeType: prober.ProbeTypeTCP, Error: "i/o timeout"}, false},
+		{"dns", ProbeResult{ProbeType: prober.ProbeTypeDNS}, true},
+		{"icmp", ProbeResult{ProbeType: prober.ProbeTypeICMP}, true},
+		{"mtu", ProbeResult{ProbeType: prober.ProbeTypeMTU, Error: "timeout"}, true},
+		{"throughput", ProbeResult{ProbeType: prober.ProbeTypeThroughput, Error: "timeout"}, true},
+		{"policy verdict", ProbeResult{ProbeType: prober.ProbeTypeTCP, Verdict: prober.VerdictDenied, Error: "timeout"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := reportedElsewhere(tt.probe); got != tt.want {
+				t.Errorf("reportedElsewhere() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
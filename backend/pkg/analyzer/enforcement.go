@@ -0,0 +1,167 @@
+package analyzer
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/christine33-creator/k8-network-visualizer/pkg/prober"
+)
+
+// Thresholds for judging probes against the NetworkPolicy verdict they expect
+const (
+	enforcementWindow     = 5 * time.Minute
+	unexpectedDenyMinFail = 3 // failed probes on allowed paths before a target is reported
+)
+
+// Ways a probe can contradict the policies
+const (
+	mismatchNotEnforced = "allowed but should be denied"
+	mismatchBlocked     = "denied but should be allowed"
+)
+
+// policyMismatch collects the source pods whose latest probe of a target
+// contradicted the policies in the same way
+type policyMismatch struct {
+	kind    string
+	target  string
+	sources []string
+	probes  int // mismatching probes over the window
+	latest  ProbeResult
+}
+
+// analyzePolicyEnforcement compares probes tagged with an expected verdict with
+// what happened. A denied path that connects means the CNI is not enforcing
+// NetworkPolicy; an allowed path that fails points to routing, a firewall or
+// the target itself. Each source and target pair is judged on its latest
+// probe so that a policy change is not held against earlier probes.
+func (a *Analyzer) analyzePolicyEnforcement(p *prober.Prober) {
+	type pairProbes struct {
+		latest     ProbeResult
+		mismatches int
+	}
+	pairs := make(map[string]*pairProbes)
+	var pairKeys []string
+	for _, result := range p.GetRecentResults(enforcementWindow) {
+		if result.Verdict != prober.VerdictAllowed && result.Verdict != prober.VerdictDenied {
+			continue
+		}
+		key := fmt.Sprintf("%s/%s->%s", result.SourceNS, result.SourcePod, probeTargetKey(result))
+		pair, ok := pairs[key]
+		if !ok {
+			pair = &pairProbes{}
+			pairs[key] = pair
+			pairKeys = append(pairKeys, key)
+		}
+		if verdictMismatch(result) != "" {
+			pair.mismatches++
+		}
+		if !result.Timestamp.Before(pair.latest.Timestamp) {
+			pair.latest = result
+		}
+	}
+
+	mismatches := make(map[string]*policyMismatch)
+	var order []string
+	for _, key := range pairKeys {
+		pair := pairs[key]
+		kind := verdictMismatch(pair.latest)
+		if kind == "" {
+			continue
+		}
+		target := probeTargetKey(pair.latest)
+		groupKey := kind + " " + target
+		mismatch, ok := mismatches[groupKey]
+		if !ok {
+			mismatch = &policyMismatch{kind: kind, target: target}
+			mismatches[groupKey] = mismatch
+			order = append(order, groupKey)
+		}
+		mismatch.sources = append(mismatch.sources, fmt.Sprintf("%s/%s", pair.latest.SourceNS, pair.latest.SourcePod))
+		mismatch.probes += pair.mismatches
+		if !pair.latest.Timestamp.Before(mismatch.latest.Timestamp) {
+			mismatch.latest = pair.latest
+		}
+	}
+
+	for _, key := range order {
+		mismatch := mismatches[key]
+		if mismatch.kind == mismatchBlocked && mismatch.probes < unexpectedDenyMinFail {
+			continue
+		}
+		a.addIssue(a.policyMismatchIssue(mismatch))
+	}
+}
+
+func (a *Analyzer) policyMismatchIssue(mismatch *policyMismatch) NetworkIssue {
+	details := map[string]interface{}{
+		"mismatch":          mismatch.kind,
+		"expected_verdict":  mismatch.latest.Verdict,
+		"sources":           mismatch.sources,
+		"mismatched_probes": mismatch.probes,
+		"target_ip":         mismatch.latest.TargetIP,
+	}
+	affected := append([]string{mismatch.target}, mismatch.sources...)
+
+	if mismatch.kind == mismatchNotEnforced {
+		return NetworkIssue{
+			ID:       a.generateIssueID(),
+			Type:     IssueTypePolicy,
+			Severity: SeverityCritical,
+			Title:    fmt.Sprintf("NetworkPolicy Not Enforced: %s", mismatch.target),
+			Description: fmt.Sprintf("%d source pod(s) connected to %s although the current NetworkPolicies deny it. Traffic the policies are meant to block is getting through.",
+				len(mismatch.sources), mismatch.target),
+			Affected: affected,
+			Suggestions: []string{
+				"Check that the CNI plugin implements NetworkPolicy; Flannel and some cloud defaults do not",
+				"Check that the CNI's policy agent is running on the node of the target pods",
+				"Look for hostNetwork pods or node-local proxies that bypass pod policies",
+				"Allow a few seconds for newly applied policies to be programmed, then probe again",
+			},
+			Details:   details,
+			Timestamp: time.Now(),
+		}
+	}
+
+	details["last_error"] = mismatch.latest.Error
+	return NetworkIssue{
+		ID:       a.generateIssueID(),
+		Type:     IssueTypeConnectivity,
+		Severity: SeverityHigh,
+		Title:    fmt.Sprintf("Blocked Despite Allowing Policies: %s", mismatch.target),
+		Description: fmt.Sprintf("%d probes from %d source pod(s) to %s failed although the current NetworkPolicies allow it, which points to routing, a firewall outside Kubernetes, or the target itself.",
+			mismatch.probes, len(mismatch.sources), mismatch.target),
+		Affected: affected,
+		Suggestions: []string{
+			"Check if the target pods are running and listening on the port",
+			"Check routes between the nodes of the source and target pods",
+			"Verify cloud security groups and node firewalls allow pod traffic",
+			"Check the CNI plugin for denies not expressed as NetworkPolicies, such as cluster-wide policies",
+		},
+		Details:   details,
+		Timestamp: time.Now(),
+	}
+}
+
+// verdictMismatch returns how a probe contradicts its expected verdict, or an
+// empty string if it does not
+func verdictMismatch(result ProbeResult) string {
+	switch {
+	case result.Verdict == prober.VerdictDenied && result.Success:
+		return mismatchNotEnforced
+	case result.Verdict == prober.VerdictAllowed && !result.Success:
+		return mismatchBlocked
+	}
+	return ""
+}
+
+// probeTargetKey names a probe's target as namespace/name:port
+func probeTargetKey(result ProbeResult) string {
+	name := result.TargetSvc
+	if name == "" {
+		name = result.TargetPod
+	}
+	if name == "" {
+		return fmt.Sprintf("%s:%d", result.TargetIP, result.TargetPort)
+	}
+	return fmt.Sprintf("%s/%s:%d", result.TargetNS, name, result.TargetPort)
+}
@@ -0,0 +1,705 @@
+package analyzer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/christine33-creator/k8-network-visualizer/pkg/collector"
+	"github.com/christine33-creator/k8-network-visualizer/pkg/flowcollector"
+	"github.com/christine33-creator/k8-network-visualizer/pkg/k8s"
+	"github.com/christine33-creator/k8-network-visualizer/pkg/prober"
+	"github.com/christine33-creator/k8-network-visualizer/pkg/simulator"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/yaml"
+)
+
+// Expected outcomes of a connectivity intent
+const (
+	IntentExpectAllow = "allow"
+	IntentExpectDeny  = "deny"
+)
+
+// Evidence that an intent was violated
+const (
+	IntentEvidencePolicy   = "policy"   // the current policies decide against the intent
+	IntentEvidenceProbe    = "probe"    // a connectivity probe contradicts the intent
+	IntentEvidenceFlow     = "flow"     // the flow collector saw traffic a deny intent forbids
+	IntentEvidenceSelector = "selector" // an allow intent selects no pods
+)
+
+// IntentWindow is how far back probe results and observed flows are checked
+const IntentWindow = 15 * time.Minute
+
+// maxIntentViolations caps the violations listed per intent
+const maxIntentViolations = 20
+
+// IntentEndpoint selects the pods on one side of an intent. Workload and Labels
+// are optional and narrow the selection within the namespace.
+type IntentEndpoint struct {
+	Namespace string            `json:"namespace"`
+	Workload  string            `json:"workload,omitempty"`
+	Labels    map[string]string `json:"labels,omitempty"`
+}
+
+// ConnectivityIntent declares that traffic between two sets of pods must be allowed or denied
+type ConnectivityIntent struct {
+	Name     string         `json:"name"`
+	From     IntentEndpoint `json:"from"`
+	To       IntentEndpoint `json:"to"`
+	Port     int32          `json:"port,omitempty"`     // 0 for every port
+	Protocol string         `json:"protocol,omitempty"` // defaults to TCP when a port is set
+	Expect   string         `json:"expect"`
+}
+
+// IntentSpec is the format of intent files and ConfigMaps
+type IntentSpec struct {
+	Intents []ConnectivityIntent `json:"intents"`
+}
+
+// IntentViolation is one connection that contradicts an intent
+type IntentViolation struct {
+	Source      string `json:"source"`
+	Destination string `json:"destination"`
+	Port        int32  `json:"port"`
+	Protocol    string `json:"protocol"`
+	Evidence    string `json:"evidence"`
+	Detail      string `json:"detail"`
+}
+
+// IntentResult is the outcome of checking one intent
+type IntentResult struct {
+	Intent          ConnectivityIntent `json:"intent"`
+	Satisfied       bool               `json:"satisfied"`
+	SourcePods      int                `json:"source_pods"`
+	DestinationPods int                `json:"destination_pods"`
+	PairsChecked    int                `json:"pairs_checked"`
+	ProbesChecked   int                `json:"probes_checked"`
+	FlowsChecked    int                `json:"flows_checked"`
+	ViolationCount  int                `json:"violation_count"`
+	Violations      []IntentViolation  `json:"violations"`
+	CheckedAt       time.Time          `json:"checked_at"`
+}
+
+// IntentSource loads intents; it is called on every analysis cycle so edits are picked up
+type IntentSource func(ctx context.Context) ([]ConnectivityIntent, error)
+
+// FileIntentSource reads intents from a YAML or JSON file
+func FileIntentSource(path string) IntentSource {
+	return func(ctx context.Context) ([]ConnectivityIntent, error) {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read intents file: %w", err)
+		}
+		return ParseIntents(data)
+	}
+}
+
+// ConfigMapIntentSource reads intents from every data key of a ConfigMap
+func ConfigMapIntentSource(client *k8s.Client, namespace, name string) IntentSource {
+	return func(ctx context.Context) ([]ConnectivityIntent, error) {
+		cm, err := client.Clientset().CoreV1().ConfigMaps(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get intents ConfigMap %s/%s: %w", namespace, name, err)
+		}
+
+		keys := make([]string, 0, len(cm.Data))
+		for key := range cm.Data {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		var intents []ConnectivityIntent
+		for _, key := range keys {
+			parsed, err := ParseIntents([]byte(cm.Data[key]))
+			if err != nil {
+				return nil, fmt.Errorf("ConfigMap %s/%s key %s: %w", namespace, name, key, err)
+			}
+			intents = append(intents, parsed...)
+		}
+		return intents, validateIntentNames(intents)
+	}
+}
+
+// ParseIntents parses and validates an intent spec in YAML or JSON
+func ParseIntents(data []byte) ([]ConnectivityIntent, error) {
+	var spec IntentSpec
+	if err := yaml.UnmarshalStrict(data, &spec); err != nil {
+		return nil, fmt.Errorf("invalid intent spec: %w", err)
+	}
+	for i := range spec.Intents {
+		intent := &spec.Intents[i]
+		intent.Expect = strings.ToLower(intent.Expect)
+		intent.Protocol = strings.ToUpper(intent.Protocol)
+		if err := validateIntent(*intent); err != nil {
+			return nil, fmt.Errorf("intent %d (%s): %w", i, intent.Name, err)
+		}
+	}
+	return spec.Intents, validateIntentNames(spec.Intents)
+}
+
+func validateIntent(intent ConnectivityIntent) error {
+	switch {
+	case intent.Name == "":
+		return fmt.Errorf("name is required")
+	case intent.From.Namespace == "":
+		return fmt.Errorf("from.namespace is required")
+	case intent.To.Namespace == "":
+		return fmt.Errorf("to.namespace is required")
+	case intent.Expect != IntentExpectAllow && intent.Expect != IntentExpectDeny:
+		return fmt.Errorf("expect must be %q or %q", IntentExpectAllow, IntentExpectDeny)
+	case intent.Port < 0 || intent.Port > 65535:
+		return fmt.Errorf("invalid port %d", intent.Port)
+	}
+	switch corev1.Protocol(intent.Protocol) {
+	case "", corev1.ProtocolTCP, corev1.ProtocolUDP, corev1.ProtocolSCTP:
+	default:
+		return fmt.Errorf("unsupported protocol %q", intent.Protocol)
+	}
+	return nil
+}
+
+func validateIntentNames(intents []ConnectivityIntent) error {
+	seen := make(map[string]bool, len(intents))
+	for _, intent := range intents {
+		if seen[intent.Name] {
+			return fmt.Errorf("duplicate intent name %q", intent.Name)
+		}
+		seen[intent.Name] = true
+	}
+	return nil
+}
+
+// String describes the endpoint as namespace, namespace/workload or namespace{labels}
+func (e IntentEndpoint) String() string {
+	s := e.Namespace
+	if e.Workload != "" {
+		s += "/" + e.Workload
+	}
+	if len(e.Labels) > 0 {
+		s += "{" + metav1.FormatLabelSelector(&metav1.LabelSelector{MatchLabels: e.Labels}) + "}"
+	}
+	return s
+}
+
+func (e IntentEndpoint) matches(pod *corev1.Pod) bool {
+	if pod.Namespace != e.Namespace {
+		return false
+	}
+	if e.Workload != "" && simulator.WorkloadName(pod) != e.Workload {
+		return false
+	}
+	for key, value := range e.Labels {
+		if pod.Labels[key] != value {
+			return false
+		}
+	}
+	return true
+}
+
+// SetIntentSource enables continuous intent checking on every analysis cycle
+func (a *Analyzer) SetIntentSource(source IntentSource) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.intentSource = source
+}
+
+// SetFlowCollector enables checking intents against observed traffic
+func (a *Analyzer) SetFlowCollector(collector flowcollector.FlowCollectorInterface) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.flowCollector = collector
+}
+
+// GetIntentResults returns the results of the last intent check
+func (a *Analyzer) GetIntentResults() []IntentResult {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	results := make([]IntentResult, len(a.intentResults))
+	copy(results, a.intentResults)
+	return results
+}
+
+// analyzeIntents checks the configured intents and raises an issue for each violated one
+func (a *Analyzer) analyzeIntents(collector *collector.Collector, p *prober.Prober) {
+	a.mu.RLock()
+	source := a.intentSource
+	a.mu.RUnlock()
+	if source == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	intents, err := source(ctx)
+	if err != nil {
+		a.addIssue(NetworkIssue{
+			ID:          a.generateIssueID(),
+			Type:        IssueTypeConfiguration,
+			Severity:    SeverityMedium,
+			Title:       "Failed to load connectivity intents",
+			Description: err.Error(),
+			Affected:    []string{},
+			Suggestions: []string{"Fix the intent spec; intents are not checked until it loads"},
+			Timestamp:   time.Now(),
+		})
+		return
+	}
+
+	results := a.CheckIntents(intents, collector, p)
+	a.mu.Lock()
+	a.intentResults = results
+	a.mu.Unlock()
+
+	for _, result := range results {
+		if result.Satisfied {
+			continue
+		}
+		a.addIssue(intentIssue(a.generateIssueID(), result))
+	}
+}
+
+func intentIssue(id string, result IntentResult) NetworkIssue {
+	intent := result.Intent
+	issue := NetworkIssue{
+		ID:       id,
+		Type:     IssueTypeConnectivity,
+		Severity: SeverityHigh,
+		Title:    fmt.Sprintf("Connectivity intent violated: %s", intent.Name),
+		Description: fmt.Sprintf("%s must %s %s, but %d connections contradict it",
+			intent.From, intentVerb(intent.Expect), intentTarget(intent), result.ViolationCount),
+		Affected: []string{intent.From.String(), intent.To.String()},
+		Suggestions: []string{
+			"Check the NetworkPolicies selecting the source and destination pods",
+			"Run a reachability report or a simulation to find the rule that decides the traffic",
+		},
+		Details: map[string]interface{}{
+			"intent":     intent.Name,
+			"expect":     intent.Expect,
+			"violations": result.Violations,
+		},
+		Timestamp: result.CheckedAt,
+	}
+	if intent.Expect == IntentExpectDeny {
+		// Forbidden traffic getting through is a segmentation breach
+		issue.Type = IssueTypePolicy
+		issue.Severity = SeverityCritical
+	}
+	return issue
+}
+
+func intentVerb(expect string) string {
+	if expect == IntentExpectDeny {
+		return "never reach"
+	}
+	return "reach"
+}
+
+func intentTarget(intent ConnectivityIntent) string {
+	if intent.Port == 0 {
+		return intent.To.String()
+	}
+	return fmt.Sprintf("%s:%d/%s", intent.To, intent.Port, intentProtocol(intent))
+}
+
+func intentProtocol(intent ConnectivityIntent) corev1.Protocol {
+	if intent.Protocol == "" {
+		return corev1.ProtocolTCP
+	}
+	return corev1.Protocol(intent.Protocol)
+}
+
+// CheckIntents checks each intent against policy evaluation, recent probe results
+// and observed flows. It does not store the results or raise issues.
+func (a *Analyzer) CheckIntents(intents []ConnectivityIntent, collector *collector.Collector, p *prober.Prober) []IntentResult {
+	namespaceLabels := make(map[string]map[string]string)
+	for _, ns := range collector.GetNamespaces() {
+		namespaceLabels[ns.Name] = ns.Labels
+	}
+	evaluator := simulator.NewPolicyEvaluator(collector.GetNetworkPolicies(), namespaceLabels)
+
+	var pods []*corev1.Pod
+	for _, pod := range collector.GetPods() {
+		if pod.Status.Phase == corev1.PodRunning {
+			pods = append(pods, pod)
+		}
+	}
+	services := collector.GetServices()
+
+	var probes []prober.ProbeResult
+	if p != nil {
+		probes = p.GetRecentResults(IntentWindow)
+	}
+	var flows []*flowcollector.FlowMetric
+	a.mu.RLock()
+	flowCollector := a.flowCollector
+	a.mu.RUnlock()
+	if flowCollector != nil {
+		cutoff := time.Now().Add(-IntentWindow)
+		for _, metric := range flowCollector.GetFlowMetrics() {
+			if !metric.LastSeen.Before(cutoff) {
+				flows = append(flows, metric)
+			}
+		}
+	}
+
+	results := make([]IntentResult, 0, len(intents))
+	for _, intent := range intents {
+		results = append(results, checkIntent(intent, evaluator, pods, services, probes, flows))
+	}
+	return results
+}
+
+func checkIntent(intent ConnectivityIntent, evaluator *simulator.PolicyEvaluator, pods []*corev1.Pod, services []*corev1.Service, probes []prober.ProbeResult, flows []*flowcollector.FlowMetric) IntentResult {
+	result := IntentResult{
+		Intent:     intent,
+		Violations: []IntentViolation{},
+		CheckedAt:  time.Now(),
+	}
+	violate := func(v IntentViolation) {
+		result.ViolationCount++
+		if len(result.Violations) < maxIntentViolations {
+			result.Violations = append(result.Violations, v)
+		}
+	}
+
+	sources := make(map[string]*corev1.Pod)
+	destinations := make(map[string]*corev1.Pod)
+	destinationIPs := make(map[string]*corev1.Pod)
+	for _, pod := range pods {
+		key := fmt.Sprintf("%s/%s", pod.Namespace, pod.Name)
+		if intent.From.matches(pod) {
+			sources[key] = pod
+		}
+		if intent.To.matches(pod) {
+			destinations[key] = pod
+			if pod.Status.PodIP != "" {
+				destinationIPs[pod.Status.PodIP] = pod
+			}
+		}
+	}
+	result.SourcePods = len(sources)
+	result.DestinationPods = len(destinations)
+
+	if intent.Expect == IntentExpectAllow && (len(sources) == 0 || len(destinations) == 0) {
+		violate(IntentViolation{
+			Source:      intent.From.String(),
+			Destination: intent.To.String(),
+			Port:        intent.Port,
+			Protocol:    string(intentProtocol(intent)),
+			Evidence:    IntentEvidenceSelector,
+			Detail:      fmt.Sprintf("no running pods match (%d sources, %d destinations)", len(sources), len(destinations)),
+		})
+	}
+
+	// Policy evaluation for every pod pair
+	for _, destKey := range sortedKeys(destinations) {
+		destination := destinations[destKey]
+		for _, sourceKey := range sortedKeys(sources) {
+			source := sources[sourceKey]
+			if source == destination {
+				continue
+			}
+			for _, port := range intentPorts(intent, evaluator, source, destination) {
+				result.PairsChecked++
+				decision := evaluator.Evaluate(simulator.Connection{
+					Source:      source,
+					Destination: destination,
+					Port:        port.Port,
+					Protocol:    port.Protocol,
+				})
+				if decision.Allowed == (intent.Expect == IntentExpectAllow) {
+					continue
+				}
+				violate(IntentViolation{
+					Source:      sourceKey,
+					Destination: destKey,
+					Port:        port.Port,
+					Protocol:    string(port.Protocol),
+					Evidence:    IntentEvidencePolicy,
+					Detail:      describeIntentDecision(decision),
+				})
+			}
+		}
+	}
+
+	// Probe results between the two sides. Only probes dialed inside the source pod
+	// pass through its egress policies; local and agent probes dial from elsewhere.
+	for _, probe := range probes {
+		if probe.ProbeType == prober.ProbeTypeICMP {
+			continue // NetworkPolicy does not govern ICMP consistently across CNIs
+		}
+		if probe.ExecMode != prober.ExecModeExec {
+			continue
+		}
+		sourceKey := fmt.Sprintf("%s/%s", probe.SourceNS, probe.SourcePod)
+		if _, ok := sources[sourceKey]; !ok {
+			continue
+		}
+		destination, ok := probeDestination(intent, probe, destinations, destinationIPs, services)
+		if !ok {
+			continue
+		}
+		result.ProbesChecked++
+		// A failed health check still got through to the destination
+		connected := probe.Success || probe.HealthMismatch()
+		if connected == (intent.Expect == IntentExpectAllow) {
+			continue
+		}
+		detail := "probe connected"
+		if !connected {
+			detail = fmt.Sprintf("probe failed: %s", probe.Error)
+		}
+		violate(IntentViolation{
+			Source:      sourceKey,
+			Destination: destination,
+			Port:        probe.TargetPort,
+			Protocol:    probe.ProbeType,
+			Evidence:    IntentEvidenceProbe,
+			Detail:      detail,
+		})
+	}
+
+	// Observed traffic only proves a deny intent wrong; absent traffic proves nothing
+	for _, flow := range flows {
+		sourceKey := fmt.Sprintf("%s/%s", flow.SourceNamespace, flow.SourcePod)
+		destKey := fmt.Sprintf("%s/%s", flow.DestNamespace, flow.DestPod)
+		if _, ok := sources[sourceKey]; !ok {
+			continue
+		}
+		if _, ok := destinations[destKey]; !ok {
+			continue
+		}
+		ports := flow.Ports
+		if len(ports) == 0 {
+			ports = []flowcollector.PortMetric{{Protocol: flow.Protocol}}
+		}
+		for _, port := range ports {
+			if intent.Port != 0 && (int32(port.Port) != intent.Port || !strings.EqualFold(port.Protocol, string(intentProtocol(intent)))) {
+				continue
+			}
+			result.FlowsChecked++
+			if intent.Expect != IntentExpectDeny {
+				continue
+			}
+			violate(IntentViolation{
+				Source:      sourceKey,
+				Destination: destKey,
+				Port:        int32(port.Port),
+				Protocol:    strings.ToUpper(port.Protocol),
+				Evidence:    IntentEvidenceFlow,
+				Detail:      fmt.Sprintf("traffic observed, %.0f bytes/sec, last seen %s", port.BytesPerSec, flow.LastSeen.Format(time.RFC3339)),
+			})
+		}
+	}
+
+	result.Satisfied = result.ViolationCount == 0
+	return result
+}
+
+// intentPort is a destination port an intent is evaluated on
+type intentPort struct {
+	Port     int32
+	Protocol corev1.Protocol
+}
+
+// intentPorts lists the ports to evaluate: the intent's port, or every port the
+// destination declares, or port 0 (only rules allowing all ports) if it declares
+// none. A deny intent without a port also covers every port the rules applying to
+// the pair name.
+func intentPorts(intent ConnectivityIntent, evaluator *simulator.PolicyEvaluator, source, destination *corev1.Pod) []intentPort {
+	if intent.Port != 0 {
+		return []intentPort{{Port: intent.Port, Protocol: intentProtocol(intent)}}
+	}
+
+	var ports []intentPort
+	for _, container := range destination.Spec.Containers {
+		for _, containerPort := range container.Ports {
+			protocol := containerPort.Protocol
+			if protocol == "" {
+				protocol = corev1.ProtocolTCP
+			}
+			if intent.Protocol != "" && protocol != corev1.Protocol(intent.Protocol) {
+				continue
+			}
+			ports = appendIntentPort(ports, intentPort{Port: containerPort.ContainerPort, Protocol: protocol})
+		}
+	}
+	if intent.Expect == IntentExpectDeny {
+		for _, port := range rulePorts(evaluator, source, destination) {
+			if intent.Protocol != "" && port.Protocol != corev1.Protocol(intent.Protocol) {
+				continue
+			}
+			ports = appendIntentPort(ports, port)
+		}
+	}
+	if len(ports) == 0 {
+		ports = append(ports, intentPort{Protocol: intentProtocol(intent)})
+	}
+	return ports
+}
+
+// rulePorts lists the port, the range start and end, or the resolved named port
+// of every port entry in the egress rules selecting source and the ingress rules
+// selecting destination, and port 0 for TCP for rules without ports. When an
+// egress and an ingress range overlap, the later start lies in both, so
+// evaluating these ports finds any port the pair is allowed on.
+func rulePorts(evaluator *simulator.PolicyEvaluator, source, destination *corev1.Pod) []intentPort {
+	var ports []intentPort
+	add := func(rulePorts []networkingv1.NetworkPolicyPort) {
+		if len(rulePorts) == 0 {
+			ports = appendIntentPort(ports, intentPort{Protocol: corev1.ProtocolTCP})
+			return
+		}
+		for _, rulePort := range rulePorts {
+			protocol := corev1.ProtocolTCP
+			if rulePort.Protocol != nil {
+				protocol = *rulePort.Protocol
+			}
+			switch {
+			case rulePort.Port == nil:
+				ports = appendIntentPort(ports, intentPort{Protocol: protocol})
+			case rulePort.Port.Type == intstr.String:
+				if number := simulator.ResolveNamedPort(destination, rulePort.Port.StrVal, protocol); number != 0 {
+					ports = appendIntentPort(ports, intentPort{Port: number, Protocol: protocol})
+				}
+			default:
+				ports = appendIntentPort(ports, intentPort{Port: rulePort.Port.IntVal, Protocol: protocol})
+				if rulePort.EndPort != nil && *rulePort.EndPort > rulePort.Port.IntVal {
+					ports = appendIntentPort(ports, intentPort{Port: *rulePort.EndPort, Protocol: protocol})
+				}
+			}
+		}
+	}
+
+	for _, policy := range evaluator.Policies() {
+		if simulator.PolicyHasType(policy, networkingv1.PolicyTypeEgress) && evaluator.SelectsPod(policy, source) {
+			for _, rule := range policy.Spec.Egress {
+				add(rule.Ports)
+			}
+		}
+		if simulator.PolicyHasType(policy, networkingv1.PolicyTypeIngress) && evaluator.SelectsPod(policy, destination) {
+			for _, rule := range policy.Spec.Ingress {
+				add(rule.Ports)
+			}
+		}
+	}
+	return ports
+}
+
+func appendIntentPort(ports []intentPort, port intentPort) []intentPort {
+	for _, p := range ports {
+		if p == port {
+			return ports
+		}
+	}
+	return append(ports, port)
+}
+
+// probeDestination matches a probe target to the intent's destination pods, either
+// directly or through a service selecting them
+func probeDestination(intent ConnectivityIntent, probe prober.ProbeResult, destinations, destinationIPs map[string]*corev1.Pod, services []*corev1.Service) (string, bool) {
+	if probe.TargetSvc == "" {
+		pod, ok := destinations[fmt.Sprintf("%s/%s", probe.TargetNS, probe.TargetPod)]
+		if !ok {
+			pod, ok = destinationIPs[probe.TargetIP]
+		}
+		if !ok || (intent.Port != 0 && probe.TargetPort != intent.Port) {
+			return "", false
+		}
+		return fmt.Sprintf("%s/%s", pod.Namespace, pod.Name), true
+	}
+
+	for _, svc := range services {
+		if svc.Namespace != probe.TargetNS || svc.Name != probe.TargetSvc || len(svc.Spec.Selector) == 0 {
+			continue
+		}
+		if intent.Port != 0 && !servicePortTargets(svc, probe.TargetPort, intent.Port) {
+			return "", false
+		}
+		for _, pod := range destinations {
+			if pod.Namespace == svc.Namespace && labelsMatch(pod.Labels, svc.Spec.Selector) {
+				return fmt.Sprintf("service/%s/%s", svc.Namespace, svc.Name), true
+			}
+		}
+	}
+	return "", false
+}
+
+// servicePortTargets reports whether the service port forwards to the target port
+func servicePortTargets(svc *corev1.Service, servicePort, targetPort int32) bool {
+	for _, port := range svc.Spec.Ports {
+		if port.Port != servicePort {
+			continue
+		}
+		if port.TargetPort.IntValue() == 0 {
+			return port.Port == targetPort
+		}
+		return int32(port.TargetPort.IntValue()) == targetPort
+	}
+	return false
+}
+
+func labelsMatch(podLabels, selector map[string]string) bool {
+	for key, value := range selector {
+		if podLabels[key] != value {
+			return false
+		}
+	}
+	return true
+}
+
+// describeIntentDecision explains which side of a connection decided it
+func describeIntentDecision(decision simulator.PolicyDecision) string {
+	if decision.Allowed {
+		var allowedBy []string
+		for _, verdict := range append(decision.Egress, decision.Ingress...) {
+			if verdict.Allowed {
+				allowedBy = append(allowedBy, fmt.Sprintf("%s rule %d", verdict.Policy, verdict.Rule))
+			}
+		}
+		if !decision.IngressIsolated && !decision.EgressIsolated {
+			return "allowed, no policy isolates either pod"
+		}
+		if !decision.IngressIsolated {
+			allowedBy = append(allowedBy, "destination not isolated for ingress")
+		}
+		if !decision.EgressIsolated {
+			allowedBy = append(allowedBy, "source not isolated for egress")
+		}
+		return fmt.Sprintf("allowed by %s", strings.Join(allowedBy, ", "))
+	}
+
+	var deniedBy []string
+	if !decision.EgressAllowed() {
+		deniedBy = append(deniedBy, fmt.Sprintf("egress (%s)", verdictPolicies(decision.Egress)))
+	}
+	if !decision.IngressAllowed() {
+		deniedBy = append(deniedBy, fmt.Sprintf("ingress (%s)", verdictPolicies(decision.Ingress)))
+	}
+	return fmt.Sprintf("denied on %s", strings.Join(deniedBy, " and "))
+}
+
+func verdictPolicies(verdicts []simulator.PolicyVerdict) string {
+	policies := make([]string, 0, len(verdicts))
+	for _, verdict := range verdicts {
+		policies = append(policies, verdict.Policy)
+	}
+	return strings.Join(policies, ", ")
+}
+
+func sortedKeys(pods map[string]*corev1.Pod) []string {
+	keys := make([]string, 0, len(pods))
+	for key := range pods {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,266 @@
+package analyzer
+
+import (
+	"testing"
+
+	"github.com/christine33-creator/k8-network-visualizer/pkg/prober"
+	"github.com/christine33-creator/k8-network-visualizer/pkg/simulator"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func testPod(namespace, name, ip string, podLabels map[string]string, ports ...corev1.ContainerPort) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name, Labels: podLabels},
+		Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "app", Ports: ports}}},
+		Status:     corev1.PodStatus{Phase: corev1.PodRunning, PodIP: ip},
+	}
+}
+
+func testPolicy(namespace, name string, selector map[string]string, types ...networkingv1.PolicyType) *networkingv1.NetworkPolicy {
+	return &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+		Spec: networkingv1.NetworkPolicySpec{
+			PodSelector: metav1.LabelSelector{MatchLabels: selector},
+			PolicyTypes: types,
+		},
+	}
+}
+
+func withIngress(policy *networkingv1.NetworkPolicy, rules ...networkingv1.NetworkPolicyIngressRule) *networkingv1.NetworkPolicy {
+	policy.Spec.Ingress = append(policy.Spec.Ingress, rules...)
+	return policy
+}
+
+func withEgress(policy *networkingv1.NetworkPolicy, rules ...networkingv1.NetworkPolicyEgressRule) *networkingv1.NetworkPolicy {
+	policy.Spec.Egress = append(policy.Spec.Egress, rules...)
+	return policy
+}
+
+func podPeer(podLabels map[string]string) networkingv1.NetworkPolicyPeer {
+	return networkingv1.NetworkPolicyPeer{PodSelector: &metav1.LabelSelector{MatchLabels: podLabels}}
+}
+
+func policyPort(protocol corev1.Protocol, port intstr.IntOrString, endPort int32) networkingv1.NetworkPolicyPort {
+	rulePort := networkingv1.NetworkPolicyPort{Protocol: &protocol, Port: &port}
+	if endPort != 0 {
+		rulePort.EndPort = &endPort
+	}
+	return rulePort
+}
+
+func TestCheckIntentPolicyPorts(t *testing.T) {
+	web := map[string]string{"app": "web"}
+	api := map[string]string{"app": "api"}
+	frontend := testPod("shop", "web-1", "10.0.0.1", web)
+	undeclared := testPod("shop", "api-1", "10.0.0.2", api)
+	declared := testPod("shop", "api-1", "10.0.0.2", api, corev1.ContainerPort{Name: "http", ContainerPort: 8080})
+
+	denyAll := ConnectivityIntent{
+		Name:   "web-never-reaches-api",
+		From:   IntentEndpoint{Namespace: "shop", Labels: web},
+		To:     IntentEndpoint{Namespace: "shop", Labels: api},
+		Expect: IntentExpectDeny,
+	}
+	denyUDP := denyAll
+	denyUDP.Protocol = string(corev1.ProtocolUDP)
+	denyPort := denyAll
+	denyPort.Port = 9090
+
+	tests := []struct {
+		name        string
+		intent      ConnectivityIntent
+		destination *corev1.Pod
+		policies    []*networkingv1.NetworkPolicy
+		violations  []intentPort
+	}{
+		{
+			name:        "ingress deny-all holds",
+			intent:      denyAll,
+			destination: undeclared,
+			policies: []*networkingv1.NetworkPolicy{
+				testPolicy("shop", "deny-all", api, networkingv1.PolicyTypeIngress),
+			},
+		},
+		{
+			name:        "port opened to a pod without declared ports",
+			intent:      denyAll,
+			destination: undeclared,
+			policies: []*networkingv1.NetworkPolicy{
+				withIngress(testPolicy("shop", "api", api, networkingv1.PolicyTypeIngress),
+					networkingv1.NetworkPolicyIngressRule{
+						From:  []networkingv1.NetworkPolicyPeer{podPeer(web)},
+						Ports: []networkingv1.NetworkPolicyPort{policyPort(corev1.ProtocolTCP, intstr.FromInt(8080), 0)},
+					}),
+			},
+			violations: []intentPort{{Port: 8080, Protocol: corev1.ProtocolTCP}},
+		},
+		{
+			name:        "port opened to another peer",
+			intent:      denyAll,
+			destination: undeclared,
+			policies: []*networkingv1.NetworkPolicy{
+				withIngress(testPolicy("shop", "api", api, networkingv1.PolicyTypeIngress),
+					networkingv1.NetworkPolicyIngressRule{
+						From:  []networkingv1.NetworkPolicyPeer{podPeer(map[string]string{"app": "admin"})},
+						Ports: []networkingv1.NetworkPolicyPort{policyPort(corev1.ProtocolTCP, intstr.FromInt(8080), 0)},
+					}),
+			},
+		},
+		{
+			name:        "overlapping egress and ingress ranges",
+			intent:      denyAll,
+			destination: undeclared,
+			policies: []*networkingv1.NetworkPolicy{
+				withIngress(testPolicy("shop", "api", api, networkingv1.PolicyTypeIngress),
+					networkingv1.NetworkPolicyIngressRule{
+						Ports: []networkingv1.NetworkPolicyPort{policyPort(corev1.ProtocolTCP, intstr.FromInt(9000), 9100)},
+					}),
+				withEgress(testPolicy("shop", "web", web, networkingv1.PolicyTypeEgress),
+					networkingv1.NetworkPolicyEgressRule{
+						Ports: []networkingv1.NetworkPolicyPort{policyPort(corev1.ProtocolTCP, intstr.FromInt(9050), 9200)},
+					}),
+			},
+			violations: []intentPort{{Port: 9100, Protocol: corev1.ProtocolTCP}, {Port: 9050, Protocol: corev1.ProtocolTCP}},
+		},
+		{
+			name:        "disjoint egress and ingress ports",
+			intent:      denyAll,
+			destination: undeclared,
+			policies: []*networkingv1.NetworkPolicy{
+				withIngress(testPolicy("shop", "api", api, networkingv1.PolicyTypeIngress),
+					networkingv1.NetworkPolicyIngressRule{
+						Ports: []networkingv1.NetworkPolicyPort{policyPort(corev1.ProtocolTCP, intstr.FromInt(8080), 0)},
+					}),
+				withEgress(testPolicy("shop", "web", web, networkingv1.PolicyTypeEgress),
+					networkingv1.NetworkPolicyEgressRule{
+						Ports: []networkingv1.NetworkPolicyPort{policyPort(corev1.ProtocolTCP, intstr.FromInt(443), 0)},
+					}),
+			},
+		},
+		{
+			name:        "named port resolved on the destination",
+			intent:      denyAll,
+			destination: declared,
+			policies: []*networkingv1.NetworkPolicy{
+				withIngress(testPolicy("shop", "api", api, networkingv1.PolicyTypeIngress),
+					networkingv1.NetworkPolicyIngressRule{
+						Ports: []networkingv1.NetworkPolicyPort{policyPort(corev1.ProtocolTCP, intstr.FromString("http"), 0)},
+					}),
+			},
+			violations: []intentPort{{Port: 8080, Protocol: corev1.ProtocolTCP}},
+		},
+		{
+			name:        "protocol filter skips other protocols",
+			intent:      denyUDP,
+			destination: undeclared,
+			policies: []*networkingv1.NetworkPolicy{
+				withIngress(testPolicy("shop", "api", api, networkingv1.PolicyTypeIngress),
+					networkingv1.NetworkPolicyIngressRule{
+						Ports: []networkingv1.NetworkPolicyPort{policyPort(corev1.ProtocolTCP, intstr.FromInt(8080), 0)},
+					}),
+			},
+		},
+		{
+			name:        "intent port only checks that port",
+			intent:      denyPort,
+			destination: undeclared,
+			policies: []*networkingv1.NetworkPolicy{
+				withIngress(testPolicy("shop", "api", api, networkingv1.PolicyTypeIngress),
+					networkingv1.NetworkPolicyIngressRule{
+						Ports: []networkingv1.NetworkPolicyPort{policyPort(corev1.ProtocolTCP, intstr.FromInt(8080), 0)},
+					}),
+			},
+		},
+		{
+			name:        "unisolated pods without declared ports",
+			intent:      denyAll,
+			destination: undeclared,
+			violations:  []intentPort{{Port: 0, Protocol: corev1.ProtocolTCP}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			evaluator := simulator.NewPolicyEvaluator(tt.policies, nil)
+			result := checkIntent(tt.intent, evaluator, []*corev1.Pod{frontend, tt.destination}, nil, nil, nil)
+
+			var got []intentPort
+			for _, v := range result.Violations {
+				if v.Evidence != IntentEvidencePolicy {
+					t.Errorf("violation evidence = %q, want %q", v.Evidence, IntentEvidencePolicy)
+				}
+				got = append(got, intentPort{Port: v.Port, Protocol: corev1.Protocol(v.Protocol)})
+			}
+			if len(got) != len(tt.violations) {
+				t.Fatalf("violations on %v, want %v", got, tt.violations)
+			}
+			for i := range got {
+				if got[i] != tt.violations[i] {
+					t.Errorf("violations on %v, want %v", got, tt.violations)
+					break
+				}
+			}
+			if result.Satisfied != (len(tt.violations) == 0) {
+				t.Errorf("Satisfied = %v with %d violations", result.Satisfied, len(tt.violations))
+			}
+		})
+	}
+}
+
+func TestCheckIntentProbeEvidence(t *testing.T) {
+	web := map[string]string{"app": "web"}
+	api := map[string]string{"app": "api"}
+	pods := []*corev1.Pod{
+		testPod("shop", "web-1", "10.0.0.1", web),
+		testPod("shop", "api-1", "10.0.0.2", api),
+	}
+	deny := ConnectivityIntent{
+		Name:   "web-never-reaches-api",
+		From:   IntentEndpoint{Namespace: "shop", Labels: web},
+		To:     IntentEndpoint{Namespace: "shop", Labels: api},
+		Port:   8080,
+		Expect: IntentExpectDeny,
+	}
+	// Every pair is denied by policy, so only probes can violate the intent
+	evaluator := simulator.NewPolicyEvaluator([]*networkingv1.NetworkPolicy{
+		testPolicy("shop", "deny-all", api, networkingv1.PolicyTypeIngress),
+	}, nil)
+
+	tests := []struct {
+		name       string
+		execMode   string
+		success    bool
+		checked    int
+		violations int
+	}{
+		{"exec probe connected", prober.ExecModeExec, true, 1, 1},
+		{"exec probe refused", prober.ExecModeExec, false, 1, 0},
+		{"local probe connected", prober.ExecModeLocal, true, 0, 0},
+		{"agent probe connected", prober.ExecModeAgent, true, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			probe := prober.ProbeResult{
+				SourcePod:  "web-1",
+				SourceNS:   "shop",
+				TargetPod:  "api-1",
+				TargetNS:   "shop",
+				TargetIP:   "10.0.0.2",
+				TargetPort: 8080,
+				ProbeType:  prober.ProbeTypeTCP,
+				Success:    tt.success,
+				ExecMode:   tt.execMode,
+			}
+			result := checkIntent(deny, evaluator, pods, nil, []prober.ProbeResult{probe}, nil)
+			if result.ProbesChecked != tt.checked {
+				t.Errorf("ProbesChecked = %d, want %d", result.ProbesChecked, tt.checked)
+			}
+			if result.ViolationCount != tt.violations {
+				t.Errorf("ViolationCount = %d, want %d: %+v", result.ViolationCount, tt.violations, result.Violations)
+			}
+		})
+	}
+}
@@ -0,0 +1,669 @@
+package analyzer
+
+import (
+	"fmt"
+	"net"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/christine33-creator/k8-network-visualizer/pkg/collector"
+	"github.com/christine33-creator/k8-network-visualizer/pkg/simulator"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// Policy lint checks
+const (
+	LintShadowedRule           = "shadowed_rule"
+	LintRedundantRule          = "redundant_rule"
+	LintUnusedPodSelector      = "unused_pod_selector"
+	LintEmptyNamespaceSelector = "empty_namespace_selector"
+	LintIPBlockPodCIDR         = "ipblock_overlaps_pod_cidr"
+	LintMissingDNSEgress       = "missing_dns_egress"
+)
+
+// Cluster DNS pods, as deployed by kubeadm and most distributions
+const (
+	dnsNamespace = "kube-system"
+	dnsAppLabel  = "k8s-app"
+	dnsAppValue  = "kube-dns"
+	dnsPort      = 53
+)
+
+// LintFinding is a single problem found in a NetworkPolicy
+type LintFinding struct {
+	Check       string
+	Severity    IssueSeverity
+	Policy      string // namespace/name
+	Direction   string // ingress or egress, empty for policy-wide findings
+	Rules       []int  // indices of the offending rules within the direction
+	Title       string
+	Description string
+	Suggestions []string
+	Details     map[string]interface{}
+}
+
+// PolicyLinter statically checks NetworkPolicies for rules that never take
+// effect and for common mistakes
+type PolicyLinter struct {
+	policies   []*networkingv1.NetworkPolicy
+	pods       []*corev1.Pod
+	namespaces []*corev1.Namespace
+	podCIDRs   []*net.IPNet
+	dnsPods    []*corev1.Pod
+	evaluator  *simulator.PolicyEvaluator
+}
+
+// NewPolicyLinter creates a linter over the cluster's policies, pods and namespaces.
+// podCIDRs are the cluster pod network ranges, typically taken from node specs.
+func NewPolicyLinter(policies []*networkingv1.NetworkPolicy, pods []*corev1.Pod, namespaces []*corev1.Namespace, podCIDRs []*net.IPNet) *PolicyLinter {
+	sorted := make([]*networkingv1.NetworkPolicy, len(policies))
+	copy(sorted, policies)
+	sort.Slice(sorted, func(i, j int) bool {
+		return simulator.PolicyKey(sorted[i]) < simulator.PolicyKey(sorted[j])
+	})
+
+	namespaceLabels := make(map[string]map[string]string, len(namespaces))
+	for _, ns := range namespaces {
+		namespaceLabels[ns.Name] = ns.Labels
+	}
+
+	return &PolicyLinter{
+		policies:   sorted,
+		pods:       pods,
+		namespaces: namespaces,
+		podCIDRs:   podCIDRs,
+		dnsPods:    clusterDNSPods(pods),
+		evaluator:  simulator.NewPolicyEvaluator(sorted, namespaceLabels),
+	}
+}
+
+// clusterDNSPods returns the kube-dns/CoreDNS pods, or a stand-in with their usual
+// namespace, labels and named ports when none are known
+func clusterDNSPods(pods []*corev1.Pod) []*corev1.Pod {
+	var dnsPods []*corev1.Pod
+	for _, pod := range pods {
+		if pod.Namespace == dnsNamespace && pod.Labels[dnsAppLabel] == dnsAppValue {
+			dnsPods = append(dnsPods, pod)
+		}
+	}
+	if len(dnsPods) > 0 {
+		return dnsPods
+	}
+	return []*corev1.Pod{{
+		ObjectMeta: metav1.ObjectMeta{Namespace: dnsNamespace, Labels: map[string]string{dnsAppLabel: dnsAppValue}},
+		Spec: corev1.PodSpec{Containers: []corev1.Container{{
+			Name: "coredns",
+			Ports: []corev1.ContainerPort{
+				{Name: "dns", ContainerPort: dnsPort, Protocol: corev1.ProtocolUDP},
+				{Name: "dns-tcp", ContainerPort: dnsPort, Protocol: corev1.ProtocolTCP},
+			},
+		}}},
+	}}
+}
+
+// Lint runs every check and returns the findings
+func (l *PolicyLinter) Lint() []LintFinding {
+	findings := []LintFinding{}
+	findings = append(findings, l.lintShadowedRules(networkingv1.PolicyTypeIngress)...)
+	findings = append(findings, l.lintShadowedRules(networkingv1.PolicyTypeEgress)...)
+	for _, policy := range l.policies {
+		findings = append(findings, l.lintPodSelector(policy)...)
+		findings = append(findings, l.lintNamespaceSelectors(policy)...)
+		findings = append(findings, l.lintIPBlocks(policy)...)
+		findings = append(findings, l.lintDNSEgress(policy)...)
+	}
+	return findings
+}
+
+// policyRule is a rule reduced to its peers and ports, common to both directions
+type policyRule struct {
+	policy *networkingv1.NetworkPolicy
+	index  int
+	peers  []networkingv1.NetworkPolicyPeer
+	ports  []networkingv1.NetworkPolicyPort
+}
+
+func (r policyRule) key() string {
+	return fmt.Sprintf("%s[%d]", simulator.PolicyKey(r.policy), r.index)
+}
+
+func policyRules(policy *networkingv1.NetworkPolicy, policyType networkingv1.PolicyType) []policyRule {
+	var rules []policyRule
+	if !simulator.PolicyHasType(policy, policyType) {
+		return rules
+	}
+	if policyType == networkingv1.PolicyTypeIngress {
+		for i, rule := range policy.Spec.Ingress {
+			rules = append(rules, policyRule{policy: policy, index: i, peers: rule.From, ports: rule.Ports})
+		}
+	} else {
+		for i, rule := range policy.Spec.Egress {
+			rules = append(rules, policyRule{policy: policy, index: i, peers: rule.To, ports: rule.Ports})
+		}
+	}
+	return rules
+}
+
+// lintShadowedRules finds rules that allow nothing beyond another rule applying to the
+// same pods, either in the same policy or in a policy with an identical podSelector
+func (l *PolicyLinter) lintShadowedRules(policyType networkingv1.PolicyType) []LintFinding {
+	findings := []LintFinding{}
+	direction := strings.ToLower(string(policyType))
+
+	// Group rules by the pods they apply to
+	groups := make(map[string][]policyRule)
+	var groupKeys []string
+	for _, policy := range l.policies {
+		groupKey := fmt.Sprintf("%s/%s", policy.Namespace, metav1.FormatLabelSelector(&policy.Spec.PodSelector))
+		if _, ok := groups[groupKey]; !ok {
+			groupKeys = append(groupKeys, groupKey)
+		}
+		groups[groupKey] = append(groups[groupKey], policyRules(policy, policyType)...)
+	}
+
+	for _, groupKey := range groupKeys {
+		rules := groups[groupKey]
+		for i, rule := range rules {
+			for j, other := range rules {
+				if i == j || !ruleCovers(other, rule) {
+					continue
+				}
+				// Of two identical rules only the later one is redundant
+				equivalent := ruleCovers(rule, other)
+				if equivalent && j > i {
+					continue
+				}
+
+				finding := LintFinding{
+					Check:     LintShadowedRule,
+					Severity:  SeverityLow,
+					Policy:    simulator.PolicyKey(rule.policy),
+					Direction: direction,
+					Rules:     []int{rule.index},
+					Title:     fmt.Sprintf("Shadowed %s rule in NetworkPolicy %s", direction, simulator.PolicyKey(rule.policy)),
+					Description: fmt.Sprintf("The %s rule %s allows nothing that %s does not already allow",
+						direction, rule.key(), other.key()),
+					Suggestions: []string{
+						"Remove the shadowed rule or narrow the broader rule if it is too permissive",
+					},
+					Details: map[string]interface{}{
+						"covered_by":      simulator.PolicyKey(other.policy),
+						"covered_by_rule": other.index,
+					},
+				}
+				if equivalent {
+					finding.Check = LintRedundantRule
+					finding.Title = fmt.Sprintf("Duplicate %s rule in NetworkPolicy %s", direction, simulator.PolicyKey(rule.policy))
+					finding.Description = fmt.Sprintf("The %s rule %s is identical in effect to %s",
+						direction, rule.key(), other.key())
+				}
+				findings = append(findings, finding)
+				break
+			}
+		}
+	}
+
+	return findings
+}
+
+// lintPodSelector flags policies that select no pods
+func (l *PolicyLinter) lintPodSelector(policy *networkingv1.NetworkPolicy) []LintFinding {
+	for _, pod := range l.pods {
+		if l.evaluator.SelectsPod(policy, pod) {
+			return nil
+		}
+	}
+	return []LintFinding{{
+		Check:       LintUnusedPodSelector,
+		Severity:    SeverityLow,
+		Policy:      simulator.PolicyKey(policy),
+		Rules:       []int{},
+		Title:       fmt.Sprintf("NetworkPolicy %s selects no pods", simulator.PolicyKey(policy)),
+		Description: fmt.Sprintf("podSelector %q matches no pods in namespace %s", metav1.FormatLabelSelector(&policy.Spec.PodSelector), policy.Namespace),
+		Suggestions: []string{
+			"Check the podSelector labels against the workload's pod template",
+			"Delete the policy if the workload was removed",
+		},
+		Details: map[string]interface{}{
+			"pod_selector": metav1.FormatLabelSelector(&policy.Spec.PodSelector),
+		},
+	}}
+}
+
+// lintNamespaceSelectors flags rules whose namespaceSelector matches no namespace
+func (l *PolicyLinter) lintNamespaceSelectors(policy *networkingv1.NetworkPolicy) []LintFinding {
+	findings := []LintFinding{}
+	if len(l.namespaces) == 0 {
+		return findings
+	}
+
+	for _, policyType := range []networkingv1.PolicyType{networkingv1.PolicyTypeIngress, networkingv1.PolicyTypeEgress} {
+		direction := strings.ToLower(string(policyType))
+		var rules []int
+		var selectors []string
+		for _, rule := range policyRules(policy, policyType) {
+			for _, peer := range rule.peers {
+				if peer.NamespaceSelector == nil || l.namespaceSelectorMatches(peer.NamespaceSelector) {
+					continue
+				}
+				rules = append(rules, rule.index)
+				selectors = append(selectors, metav1.FormatLabelSelector(peer.NamespaceSelector))
+				break
+			}
+		}
+		if len(rules) == 0 {
+			continue
+		}
+		findings = append(findings, LintFinding{
+			Check:       LintEmptyNamespaceSelector,
+			Severity:    SeverityMedium,
+			Policy:      simulator.PolicyKey(policy),
+			Direction:   direction,
+			Rules:       rules,
+			Title:       fmt.Sprintf("namespaceSelector matches no namespaces in NetworkPolicy %s", simulator.PolicyKey(policy)),
+			Description: fmt.Sprintf("%d %s rules use a namespaceSelector that matches no namespace, so those peers are never allowed", len(rules), direction),
+			Suggestions: []string{
+				"Check the selector against the namespace labels (kubectl get ns --show-labels)",
+				"Use the kubernetes.io/metadata.name label to select a namespace by name",
+			},
+			Details: map[string]interface{}{
+				"namespace_selectors": selectors,
+			},
+		})
+	}
+
+	return findings
+}
+
+func (l *PolicyLinter) namespaceSelectorMatches(selector *metav1.LabelSelector) bool {
+	for _, ns := range l.namespaces {
+		if l.evaluator.NamespaceMatches(selector, ns.Name) {
+			return true
+		}
+	}
+	return false
+}
+
+// lintIPBlocks flags ipBlocks that cover pod IPs; pod traffic should be selected by
+// labels since pod IPs change and some CNIs ignore ipBlocks for pod traffic
+func (l *PolicyLinter) lintIPBlocks(policy *networkingv1.NetworkPolicy) []LintFinding {
+	findings := []LintFinding{}
+
+	for _, policyType := range []networkingv1.PolicyType{networkingv1.PolicyTypeIngress, networkingv1.PolicyTypeEgress} {
+		direction := strings.ToLower(string(policyType))
+		var rules []int
+		var cidrs []string
+		for _, rule := range policyRules(policy, policyType) {
+			for _, peer := range rule.peers {
+				if peer.IPBlock == nil || !l.overlapsPodNetwork(peer.IPBlock) {
+					continue
+				}
+				rules = append(rules, rule.index)
+				cidrs = append(cidrs, peer.IPBlock.CIDR)
+				break
+			}
+		}
+		if len(rules) == 0 {
+			continue
+		}
+		findings = append(findings, LintFinding{
+			Check:       LintIPBlockPodCIDR,
+			Severity:    SeverityMedium,
+			Policy:      simulator.PolicyKey(policy),
+			Direction:   direction,
+			Rules:       rules,
+			Title:       fmt.Sprintf("ipBlock overlaps the pod network in NetworkPolicy %s", simulator.PolicyKey(policy)),
+			Description: fmt.Sprintf("%d %s rules use ipBlocks (%s) that cover pod IPs", len(rules), direction, strings.Join(cidrs, ", ")),
+			Suggestions: []string{
+				"Select pods with podSelector/namespaceSelector instead of their IPs",
+				"Add the pod CIDR to the ipBlock's except list if only external traffic is intended",
+			},
+			Details: map[string]interface{}{
+				"cidrs": cidrs,
+			},
+		})
+	}
+
+	return findings
+}
+
+func (l *PolicyLinter) overlapsPodNetwork(block *networkingv1.IPBlock) bool {
+	_, blockNet, err := net.ParseCIDR(block.CIDR)
+	if err != nil {
+		return false
+	}
+	for _, podCIDR := range l.podCIDRs {
+		if cidrsOverlap(blockNet, podCIDR) && !exceptsCover(block, podCIDR) {
+			return true
+		}
+	}
+	// Not every CNI records pod CIDRs on nodes, so also check running pod IPs
+	for _, pod := range l.pods {
+		if !pod.Spec.HostNetwork && pod.Status.PodIP != "" && simulator.IPBlockContains(block, pod.Status.PodIP) {
+			return true
+		}
+	}
+	return false
+}
+
+// lintDNSEgress flags egress policies that leave their pods unable to reach the
+// cluster DNS pods on UDP or TCP port 53. TCP carries truncated answers.
+func (l *PolicyLinter) lintDNSEgress(policy *networkingv1.NetworkPolicy) []LintFinding {
+	protocols := []corev1.Protocol{corev1.ProtocolUDP, corev1.ProtocolTCP}
+	if !simulator.PolicyHasType(policy, networkingv1.PolicyTypeEgress) ||
+		(l.policyAllowsDNS(policy, corev1.ProtocolUDP) && l.policyAllowsDNS(policy, corev1.ProtocolTCP)) {
+		return nil
+	}
+
+	// Egress is the union of all policies, so DNS may be allowed elsewhere
+	selected := 0
+	blocked := make(map[corev1.Protocol]bool)
+	for _, pod := range l.pods {
+		if !l.evaluator.SelectsPod(policy, pod) {
+			continue
+		}
+		podBlocked := false
+		for _, protocol := range protocols {
+			allowed := false
+			for _, other := range l.policies {
+				if simulator.PolicyHasType(other, networkingv1.PolicyTypeEgress) && l.evaluator.SelectsPod(other, pod) && l.policyAllowsDNS(other, protocol) {
+					allowed = true
+					break
+				}
+			}
+			if !allowed {
+				blocked[protocol] = true
+				podBlocked = true
+			}
+		}
+		if podBlocked {
+			selected++
+		}
+	}
+	if selected == 0 {
+		return nil
+	}
+
+	var blockedProtocols []string
+	for _, protocol := range protocols {
+		if blocked[protocol] {
+			blockedProtocols = append(blockedProtocols, string(protocol))
+		}
+	}
+	severity := SeverityHigh
+	title := fmt.Sprintf("NetworkPolicy %s blocks DNS", simulator.PolicyKey(policy))
+	consequence := "name resolution will fail"
+	if !blocked[corev1.ProtocolUDP] {
+		severity = SeverityMedium
+		title = fmt.Sprintf("NetworkPolicy %s blocks DNS over TCP", simulator.PolicyKey(policy))
+		consequence = "lookups with truncated answers will fail"
+	}
+
+	rules := []int{}
+	for i := range policy.Spec.Egress {
+		rules = append(rules, i)
+	}
+	return []LintFinding{{
+		Check:     LintMissingDNSEgress,
+		Severity:  severity,
+		Policy:    simulator.PolicyKey(policy),
+		Direction: "egress",
+		Rules:     rules,
+		Title:     title,
+		Description: fmt.Sprintf("Egress from %d selected pods is restricted and no policy allows %s port 53 to the cluster DNS pods, so %s",
+			selected, strings.Join(blockedProtocols, " or "), consequence),
+		Suggestions: []string{
+			"Add an egress rule allowing UDP and TCP port 53 to kube-dns in kube-system",
+		},
+		Details: map[string]interface{}{
+			"pods_without_dns": selected,
+			"protocols":        blockedProtocols,
+		},
+	}}
+}
+
+// policyAllowsDNS reports whether any egress rule allows the protocol on port 53
+// to a cluster DNS pod
+func (l *PolicyLinter) policyAllowsDNS(policy *networkingv1.NetworkPolicy, protocol corev1.Protocol) bool {
+	for _, rule := range policy.Spec.Egress {
+		for _, dnsPod := range l.dnsPods {
+			if l.evaluator.EgressRuleAllows(policy, rule, dnsPod, dnsPort, protocol) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ruleCovers reports whether outer allows every connection inner allows
+func ruleCovers(outer, inner policyRule) bool {
+	return peersCover(outer.peers, inner.peers) && portsCover(outer.ports, inner.ports)
+}
+
+func peersCover(outer, inner []networkingv1.NetworkPolicyPeer) bool {
+	if len(outer) == 0 {
+		return true
+	}
+	if len(inner) == 0 {
+		return false
+	}
+	for _, innerPeer := range inner {
+		covered := false
+		for _, outerPeer := range outer {
+			if peerCovers(outerPeer, innerPeer) {
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			return false
+		}
+	}
+	return true
+}
+
+func peerCovers(outer, inner networkingv1.NetworkPolicyPeer) bool {
+	if reflect.DeepEqual(outer, inner) {
+		return true
+	}
+	if outer.IPBlock != nil || inner.IPBlock != nil {
+		return outer.IPBlock != nil && inner.IPBlock != nil && ipBlockCovers(outer.IPBlock, inner.IPBlock)
+	}
+
+	// A nil namespaceSelector means the policy's own namespace
+	switch {
+	case outer.NamespaceSelector == nil && inner.NamespaceSelector != nil:
+		return false
+	case outer.NamespaceSelector != nil && inner.NamespaceSelector == nil:
+		if len(outer.NamespaceSelector.MatchLabels) > 0 || len(outer.NamespaceSelector.MatchExpressions) > 0 {
+			return false
+		}
+	case outer.NamespaceSelector != nil && inner.NamespaceSelector != nil:
+		if !selectorCovers(outer.NamespaceSelector, inner.NamespaceSelector) {
+			return false
+		}
+	}
+
+	// A nil podSelector means every pod in the selected namespaces
+	if outer.PodSelector == nil {
+		return true
+	}
+	if inner.PodSelector == nil {
+		return len(outer.PodSelector.MatchLabels) == 0 && len(outer.PodSelector.MatchExpressions) == 0
+	}
+	return selectorCovers(outer.PodSelector, inner.PodSelector)
+}
+
+// selectorCovers reports whether everything inner selects is also selected by outer.
+// Only matchLabels subsets are recognized; other selectors must be identical.
+func selectorCovers(outer, inner *metav1.LabelSelector) bool {
+	if len(outer.MatchExpressions) > 0 {
+		return reflect.DeepEqual(outer, inner)
+	}
+	for key, value := range outer.MatchLabels {
+		if inner.MatchLabels[key] != value {
+			return false
+		}
+	}
+	return true
+}
+
+func ipBlockCovers(outer, inner *networkingv1.IPBlock) bool {
+	_, outerNet, err := net.ParseCIDR(outer.CIDR)
+	if err != nil {
+		return false
+	}
+	_, innerNet, err := net.ParseCIDR(inner.CIDR)
+	if err != nil {
+		return false
+	}
+	outerSize, outerBits := outerNet.Mask.Size()
+	innerSize, innerBits := innerNet.Mask.Size()
+	if outerBits != innerBits || innerSize < outerSize || !outerNet.Contains(innerNet.IP) {
+		return false
+	}
+
+	// Exceptions in outer must not cut into inner unless inner excludes them too
+	for _, except := range outer.Except {
+		_, exceptNet, err := net.ParseCIDR(except)
+		if err != nil || !cidrsOverlap(exceptNet, innerNet) {
+			continue
+		}
+		if !exceptsCover(inner, exceptNet) {
+			return false
+		}
+	}
+	return true
+}
+
+// exceptsCover reports whether the block's except list fully excludes cidr
+func exceptsCover(block *networkingv1.IPBlock, cidr *net.IPNet) bool {
+	size, _ := cidr.Mask.Size()
+	for _, except := range block.Except {
+		_, exceptNet, err := net.ParseCIDR(except)
+		if err != nil {
+			continue
+		}
+		exceptSize, _ := exceptNet.Mask.Size()
+		if exceptSize <= size && exceptNet.Contains(cidr.IP) {
+			return true
+		}
+	}
+	return false
+}
+
+func cidrsOverlap(a, b *net.IPNet) bool {
+	return a.Contains(b.IP) || b.Contains(a.IP)
+}
+
+// portsCover reports whether outer's ports include every port in inner
+func portsCover(outer, inner []networkingv1.NetworkPolicyPort) bool {
+	if len(outer) == 0 {
+		return true
+	}
+	if len(inner) == 0 {
+		return false
+	}
+	for _, innerPort := range inner {
+		covered := false
+		for _, outerPort := range outer {
+			if portCovers(outerPort, innerPort) {
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			return false
+		}
+	}
+	return true
+}
+
+func portCovers(outer, inner networkingv1.NetworkPolicyPort) bool {
+	if portProtocol(outer) != portProtocol(inner) {
+		return false
+	}
+	if outer.Port == nil {
+		return true
+	}
+	if inner.Port == nil {
+		return false
+	}
+	if outer.Port.Type == intstr.String || inner.Port.Type == intstr.String {
+		return outer.Port.Type == inner.Port.Type && outer.Port.StrVal == inner.Port.StrVal
+	}
+	outerEnd, innerEnd := outer.Port.IntVal, inner.Port.IntVal
+	if outer.EndPort != nil {
+		outerEnd = *outer.EndPort
+	}
+	if inner.EndPort != nil {
+		innerEnd = *inner.EndPort
+	}
+	return inner.Port.IntVal >= outer.Port.IntVal && innerEnd <= outerEnd
+}
+
+func portProtocol(port networkingv1.NetworkPolicyPort) corev1.Protocol {
+	if port.Protocol == nil {
+		return corev1.ProtocolTCP
+	}
+	return *port.Protocol
+}
+
+// clusterPodCIDRs returns the pod CIDRs allocated to nodes
+func clusterPodCIDRs(nodes []*corev1.Node) []*net.IPNet {
+	seen := make(map[string]bool)
+	var cidrs []*net.IPNet
+	for _, node := range nodes {
+		for _, cidr := range append([]string{node.Spec.PodCIDR}, node.Spec.PodCIDRs...) {
+			if cidr == "" || seen[cidr] {
+				continue
+			}
+			seen[cidr] = true
+			if _, ipNet, err := net.ParseCIDR(cidr); err == nil {
+				cidrs = append(cidrs, ipNet)
+			}
+		}
+	}
+	return cidrs
+}
+
+// lintNetworkPolicies runs the policy linter and reports its findings as issues
+func (a *Analyzer) lintNetworkPolicies(collector *collector.Collector) {
+	linter := NewPolicyLinter(
+		collector.GetNetworkPolicies(),
+		collector.GetPods(),
+		collector.GetNamespaces(),
+		clusterPodCIDRs(collector.GetNodes()),
+	)
+
+	for _, finding := range linter.Lint() {
+		details := map[string]interface{}{
+			"check":  finding.Check,
+			"policy": finding.Policy,
+			"rules":  finding.Rules,
+		}
+		if finding.Direction != "" {
+			details["direction"] = finding.Direction
+		}
+		for key, value := range finding.Details {
+			details[key] = value
+		}
+
+		a.addIssue(NetworkIssue{
+			ID:          a.generateIssueID(),
+			Type:        IssueTypePolicy,
+			Severity:    finding.Severity,
+			Title:       finding.Title,
+			Description: finding.Description,
+			Affected:    []string{finding.Policy},
+			Suggestions: finding.Suggestions,
+			Details:     details,
+			Timestamp:   time.Now(),
+		})
+	}
+}
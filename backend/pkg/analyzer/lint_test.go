@@ -0,0 +1,260 @@
+package analyzer
+
+import (
+	"fmt"
+	"net"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// lintResult is the part of a finding the lint tests compare
+type lintResult struct {
+	check     string
+	severity  IssueSeverity
+	policy    string
+	direction string
+	rules     string
+}
+
+func lintResults(findings []LintFinding) []lintResult {
+	var results []lintResult
+	for _, finding := range findings {
+		results = append(results, lintResult{
+			check:     finding.Check,
+			severity:  finding.Severity,
+			policy:    finding.Policy,
+			direction: finding.Direction,
+			rules:     fmt.Sprint(finding.Rules),
+		})
+	}
+	return results
+}
+
+func namespacePeer(namespaceLabels, podLabels map[string]string) networkingv1.NetworkPolicyPeer {
+	peer := networkingv1.NetworkPolicyPeer{NamespaceSelector: &metav1.LabelSelector{MatchLabels: namespaceLabels}}
+	if podLabels != nil {
+		peer.PodSelector = &metav1.LabelSelector{MatchLabels: podLabels}
+	}
+	return peer
+}
+
+func ipBlockPeer(cidr string, except ...string) networkingv1.NetworkPolicyPeer {
+	return networkingv1.NetworkPolicyPeer{IPBlock: &networkingv1.IPBlock{CIDR: cidr, Except: except}}
+}
+
+func TestPolicyLinter(t *testing.T) {
+	web := map[string]string{"app": "web"}
+	api := map[string]string{"app": "api"}
+	kubeDNS := map[string]string{"k8s-app": "kube-dns"}
+	kubeSystem := map[string]string{"kubernetes.io/metadata.name": "kube-system"}
+
+	pods := []*corev1.Pod{
+		testPod("shop", "web-1", "10.244.1.10", web),
+		testPod("shop", "api-1", "10.244.1.11", api),
+		testPod("kube-system", "coredns-1", "10.244.0.5", kubeDNS,
+			corev1.ContainerPort{Name: "dns", ContainerPort: 53, Protocol: corev1.ProtocolUDP},
+			corev1.ContainerPort{Name: "dns-tcp", ContainerPort: 53, Protocol: corev1.ProtocolTCP}),
+	}
+	namespaces := []*corev1.Namespace{
+		{ObjectMeta: metav1.ObjectMeta{Name: "shop", Labels: map[string]string{"team": "shop"}}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "kube-system"}},
+	}
+	_, podCIDR, _ := net.ParseCIDR("10.244.0.0/16")
+
+	dnsRule := networkingv1.NetworkPolicyEgressRule{
+		To: []networkingv1.NetworkPolicyPeer{namespacePeer(kubeSystem, kubeDNS)},
+		Ports: []networkingv1.NetworkPolicyPort{
+			policyPort(corev1.ProtocolUDP, intstr.FromInt(53), 0),
+			policyPort(corev1.ProtocolTCP, intstr.FromInt(53), 0),
+		},
+	}
+	apiRule := networkingv1.NetworkPolicyEgressRule{
+		To:    []networkingv1.NetworkPolicyPeer{podPeer(api)},
+		Ports: []networkingv1.NetworkPolicyPort{policyPort(corev1.ProtocolTCP, intstr.FromInt(8080), 0)},
+	}
+	webIngress := func(ports ...networkingv1.NetworkPolicyPort) networkingv1.NetworkPolicyIngressRule {
+		return networkingv1.NetworkPolicyIngressRule{From: []networkingv1.NetworkPolicyPeer{podPeer(web)}, Ports: ports}
+	}
+
+	tests := []struct {
+		name     string
+		policies []*networkingv1.NetworkPolicy
+		pods     []*corev1.Pod
+		podCIDRs []*net.IPNet
+		want     []lintResult
+	}{
+		{
+			name: "clean policies",
+			policies: []*networkingv1.NetworkPolicy{
+				withIngress(testPolicy("shop", "api", api, networkingv1.PolicyTypeIngress),
+					webIngress(policyPort(corev1.ProtocolTCP, intstr.FromInt(8080), 0))),
+				withEgress(testPolicy("shop", "web", web, networkingv1.PolicyTypeEgress), apiRule, dnsRule),
+			},
+		},
+		{
+			name: "rule shadowed by a broader rule",
+			policies: []*networkingv1.NetworkPolicy{
+				withIngress(testPolicy("shop", "api", api, networkingv1.PolicyTypeIngress),
+					webIngress(policyPort(corev1.ProtocolTCP, intstr.FromInt(8080), 0)),
+					webIngress(policyPort(corev1.ProtocolTCP, intstr.FromInt(8000), 8100))),
+			},
+			want: []lintResult{{LintShadowedRule, SeverityLow, "shop/api", "ingress", "[0]"}},
+		},
+		{
+			name: "rule shadowed by a policy with the same podSelector",
+			policies: []*networkingv1.NetworkPolicy{
+				withIngress(testPolicy("shop", "api", api, networkingv1.PolicyTypeIngress),
+					webIngress(policyPort(corev1.ProtocolTCP, intstr.FromInt(8080), 0))),
+				withIngress(testPolicy("shop", "api-open", api, networkingv1.PolicyTypeIngress),
+					networkingv1.NetworkPolicyIngressRule{}),
+			},
+			want: []lintResult{{LintShadowedRule, SeverityLow, "shop/api", "ingress", "[0]"}},
+		},
+		{
+			name: "duplicate rules",
+			policies: []*networkingv1.NetworkPolicy{
+				withIngress(testPolicy("shop", "api", api, networkingv1.PolicyTypeIngress),
+					webIngress(policyPort(corev1.ProtocolTCP, intstr.FromInt(8080), 0)),
+					webIngress(policyPort(corev1.ProtocolTCP, intstr.FromInt(8080), 0))),
+			},
+			want: []lintResult{{LintRedundantRule, SeverityLow, "shop/api", "ingress", "[1]"}},
+		},
+		{
+			name: "other protocol is not shadowed",
+			policies: []*networkingv1.NetworkPolicy{
+				withIngress(testPolicy("shop", "api", api, networkingv1.PolicyTypeIngress),
+					webIngress(policyPort(corev1.ProtocolUDP, intstr.FromInt(8080), 0)),
+					webIngress(policyPort(corev1.ProtocolTCP, intstr.FromInt(8000), 8100))),
+			},
+		},
+		{
+			name: "podSelector matches no pods",
+			policies: []*networkingv1.NetworkPolicy{
+				testPolicy("shop", "gone", map[string]string{"app": "gone"}, networkingv1.PolicyTypeIngress),
+			},
+			want: []lintResult{{LintUnusedPodSelector, SeverityLow, "shop/gone", "", "[]"}},
+		},
+		{
+			name: "namespaceSelector matches no namespace",
+			policies: []*networkingv1.NetworkPolicy{
+				withIngress(testPolicy("shop", "api", api, networkingv1.PolicyTypeIngress),
+					networkingv1.NetworkPolicyIngressRule{From: []networkingv1.NetworkPolicyPeer{
+						namespacePeer(map[string]string{"team": "payments"}, nil),
+					}},
+					networkingv1.NetworkPolicyIngressRule{From: []networkingv1.NetworkPolicyPeer{
+						namespacePeer(map[string]string{"team": "shop"}, nil),
+					}}),
+			},
+			want: []lintResult{{LintEmptyNamespaceSelector, SeverityMedium, "shop/api", "ingress", "[0]"}},
+		},
+		{
+			name: "ipBlock overlaps the node pod CIDR",
+			policies: []*networkingv1.NetworkPolicy{
+				withIngress(testPolicy("shop", "api", api, networkingv1.PolicyTypeIngress),
+					networkingv1.NetworkPolicyIngressRule{From: []networkingv1.NetworkPolicyPeer{ipBlockPeer("192.168.0.0/16")}},
+					networkingv1.NetworkPolicyIngressRule{From: []networkingv1.NetworkPolicyPeer{ipBlockPeer("10.0.0.0/8")}}),
+			},
+			pods:     []*corev1.Pod{testPod("shop", "api-1", "", api)},
+			podCIDRs: []*net.IPNet{podCIDR},
+			want:     []lintResult{{LintIPBlockPodCIDR, SeverityMedium, "shop/api", "ingress", "[1]"}},
+		},
+		{
+			name: "ipBlock excepting the pod CIDR",
+			policies: []*networkingv1.NetworkPolicy{
+				withIngress(testPolicy("shop", "api", api, networkingv1.PolicyTypeIngress),
+					networkingv1.NetworkPolicyIngressRule{From: []networkingv1.NetworkPolicyPeer{ipBlockPeer("10.0.0.0/8", "10.244.0.0/16")}}),
+			},
+			podCIDRs: []*net.IPNet{podCIDR},
+		},
+		{
+			name: "ipBlock covers running pod IPs without node pod CIDRs",
+			policies: []*networkingv1.NetworkPolicy{
+				withIngress(testPolicy("shop", "api", api, networkingv1.PolicyTypeIngress),
+					networkingv1.NetworkPolicyIngressRule{From: []networkingv1.NetworkPolicyPeer{ipBlockPeer("10.244.1.0/24")}}),
+			},
+			want: []lintResult{{LintIPBlockPodCIDR, SeverityMedium, "shop/api", "ingress", "[0]"}},
+		},
+		{
+			name: "egress without DNS",
+			policies: []*networkingv1.NetworkPolicy{
+				withEgress(testPolicy("shop", "web", web, networkingv1.PolicyTypeEgress), apiRule),
+			},
+			want: []lintResult{{LintMissingDNSEgress, SeverityHigh, "shop/web", "egress", "[0]"}},
+		},
+		{
+			name: "all ports to pods that are not cluster DNS",
+			policies: []*networkingv1.NetworkPolicy{
+				withEgress(testPolicy("shop", "web", web, networkingv1.PolicyTypeEgress),
+					networkingv1.NetworkPolicyEgressRule{To: []networkingv1.NetworkPolicyPeer{podPeer(api)}}),
+			},
+			want: []lintResult{{LintMissingDNSEgress, SeverityHigh, "shop/web", "egress", "[0]"}},
+		},
+		{
+			name: "DNS over UDP only",
+			policies: []*networkingv1.NetworkPolicy{
+				withEgress(testPolicy("shop", "web", web, networkingv1.PolicyTypeEgress), apiRule,
+					networkingv1.NetworkPolicyEgressRule{
+						To:    []networkingv1.NetworkPolicyPeer{namespacePeer(kubeSystem, kubeDNS)},
+						Ports: []networkingv1.NetworkPolicyPort{policyPort(corev1.ProtocolUDP, intstr.FromInt(53), 0)},
+					}),
+			},
+			want: []lintResult{{LintMissingDNSEgress, SeverityMedium, "shop/web", "egress", "[0 1]"}},
+		},
+		{
+			name: "DNS through named ports and all destinations",
+			policies: []*networkingv1.NetworkPolicy{
+				withEgress(testPolicy("shop", "web", web, networkingv1.PolicyTypeEgress), apiRule,
+					networkingv1.NetworkPolicyEgressRule{Ports: []networkingv1.NetworkPolicyPort{
+						policyPort(corev1.ProtocolUDP, intstr.FromString("dns"), 0),
+						policyPort(corev1.ProtocolTCP, intstr.FromString("dns-tcp"), 0),
+					}}),
+			},
+		},
+		{
+			name: "DNS to the kube-dns pod IP",
+			policies: []*networkingv1.NetworkPolicy{
+				withEgress(testPolicy("shop", "web", web, networkingv1.PolicyTypeEgress), apiRule,
+					networkingv1.NetworkPolicyEgressRule{
+						To: []networkingv1.NetworkPolicyPeer{ipBlockPeer("10.244.0.5/32")},
+						Ports: []networkingv1.NetworkPolicyPort{
+							policyPort(corev1.ProtocolUDP, intstr.FromInt(53), 0),
+							policyPort(corev1.ProtocolTCP, intstr.FromInt(53), 0),
+						},
+					}),
+			},
+			podCIDRs: []*net.IPNet{},
+			want:     []lintResult{{LintIPBlockPodCIDR, SeverityMedium, "shop/web", "egress", "[1]"}},
+		},
+		{
+			name: "DNS allowed by another policy",
+			policies: []*networkingv1.NetworkPolicy{
+				withEgress(testPolicy("shop", "web", web, networkingv1.PolicyTypeEgress), apiRule),
+				withEgress(testPolicy("shop", "web-dns", web, networkingv1.PolicyTypeEgress), dnsRule),
+			},
+		},
+		{
+			name: "DNS rule without known DNS pods",
+			policies: []*networkingv1.NetworkPolicy{
+				withEgress(testPolicy("shop", "web", web, networkingv1.PolicyTypeEgress), apiRule, dnsRule),
+			},
+			pods: []*corev1.Pod{testPod("shop", "web-1", "10.244.1.10", web)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clusterPods := tt.pods
+			if clusterPods == nil {
+				clusterPods = pods
+			}
+			got := lintResults(NewPolicyLinter(tt.policies, clusterPods, namespaces, tt.podCIDRs).Lint())
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("findings = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
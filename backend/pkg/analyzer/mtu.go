@@ -0,0 +1,115 @@
+package analyzer
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/christine33-creator/k8-network-visualizer/pkg/prober"
+)
+
+// updateMTUEdges records the path MTU discovered between nodes on their
+// node-to-node edges, with pod network results under a "pod_" prefix
+func (a *Analyzer) updateMTUEdges(p *prober.Prober) {
+	for _, path := range p.PathMTUs() {
+		if path.PathMTU == 0 {
+			continue
+		}
+		prefix := ""
+		if path.Network == prober.MTUNetworkPod {
+			prefix = "pod_"
+		}
+		properties := map[string]string{
+			prefix + "path_mtu":     strconv.Itoa(path.PathMTU),
+			prefix + "mtu_mismatch": strconv.FormatBool(path.Mismatch),
+			prefix + "mtu_limit":    path.Limit, // cleared once the interface is the limit again
+		}
+		if path.InterfaceMTU > 0 {
+			properties[prefix+"interface_mtu"] = strconv.Itoa(path.InterfaceMTU)
+		}
+		a.graphEngine.UpdateEdgeProperties("node/"+path.SourceNode, "node/"+path.TargetNode, properties)
+	}
+}
+
+// analyzePathMTU reports paths between nodes that carry smaller packets than
+// the interface they leave through sends. Node pairs with the same shortfall
+// are reported together, since one misconfigured MTU usually affects them all.
+func (a *Analyzer) analyzePathMTU(p *prober.Prober) {
+	type mismatch struct {
+		path  prober.PathMTU
+		pairs []string
+		nodes map[string]bool
+	}
+	groups := make(map[string]*mismatch)
+	var order []string
+	for _, path := range p.PathMTUs() {
+		if !path.Mismatch {
+			continue
+		}
+		key := fmt.Sprintf("%s %d/%d %s", path.Network, path.PathMTU, path.InterfaceMTU, path.Limit)
+		group, ok := groups[key]
+		if !ok {
+			group = &mismatch{path: path, nodes: make(map[string]bool)}
+			groups[key] = group
+			order = append(order, key)
+		}
+		group.pairs = append(group.pairs, fmt.Sprintf("node/%s -> node/%s", path.SourceNode, path.TargetNode))
+		group.nodes["node/"+path.SourceNode] = true
+		group.nodes["node/"+path.TargetNode] = true
+	}
+
+	for _, key := range order {
+		group := groups[key]
+		path := group.path
+		affected := make([]string, 0, len(group.nodes))
+		for node := range group.nodes {
+			affected = append(affected, node)
+		}
+		sort.Strings(affected)
+
+		target := group.pairs[0]
+		if len(group.pairs) > 1 {
+			target = fmt.Sprintf("%d node pairs", len(group.pairs))
+		}
+		severity := SeverityMedium
+		description := fmt.Sprintf("The %s network path for %s carries packets of up to %d bytes, less than the %d-byte MTU of %s. A hop reports the smaller MTU, so TCP recovers through path MTU discovery after retransmitting; traffic that ignores ICMP errors fails.",
+			path.Network, target, path.PathMTU, path.InterfaceMTU, path.Interface)
+		if path.Limit == prober.MTULimitBlackhole {
+			severity = SeverityHigh
+			description = fmt.Sprintf("The %s network path for %s drops packets larger than %d bytes without an ICMP error, although %s sends up to %d bytes. Large transfers over this path hang while small requests succeed.",
+				path.Network, target, path.PathMTU, path.Interface, path.InterfaceMTU)
+		}
+
+		suggestions := []string{
+			fmt.Sprintf("Make the %s network MTU consistent with the path: lower it to %d bytes or raise the MTU of the network between the nodes", path.Network, path.PathMTU),
+		}
+		if path.Network == prober.MTUNetworkPod {
+			suggestions = append(suggestions, "Set the CNI MTU to the node path MTU minus the encapsulation overhead: 50 bytes for VXLAN, 20 for IP-in-IP, 60 to 80 for WireGuard, more for Geneve with options")
+		}
+		if path.Limit == prober.MTULimitBlackhole {
+			suggestions = append(suggestions,
+				"Allow ICMP fragmentation needed (type 3 code 4) and ICMPv6 packet too big through node firewalls and cloud security groups",
+				"Clamp the TCP MSS to the path MTU where traffic leaves the overlay")
+		}
+
+		a.addIssue(NetworkIssue{
+			ID:          a.generateIssueID(),
+			Type:        IssueTypeConfiguration,
+			Severity:    severity,
+			Title:       fmt.Sprintf("Path MTU Mismatch: %s", target),
+			Description: description,
+			Affected:    affected,
+			Suggestions: suggestions,
+			Details: map[string]interface{}{
+				"network":       path.Network,
+				"path_mtu":      path.PathMTU,
+				"interface_mtu": path.InterfaceMTU,
+				"interface":     path.Interface,
+				"limit":         path.Limit,
+				"node_pairs":    group.pairs,
+			},
+			Timestamp: time.Now(),
+		})
+	}
+}
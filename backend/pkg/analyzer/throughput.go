@@ -0,0 +1,24 @@
+package analyzer
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/christine33-creator/k8-network-visualizer/pkg/prober"
+)
+
+// updateThroughputEdges records the latest throughput test between two
+// endpoints on their edge. Tests only run on request, so the edge keeps the
+// last measurement and when it was taken.
+func (a *Analyzer) updateThroughputEdges(p *prober.Prober) {
+	for _, result := range p.ThroughputResults() {
+		properties := map[string]string{
+			"throughput_mbps":        strconv.FormatFloat(result.Throughput.Mbps, 'f', 1, 64),
+			"throughput_measured_at": result.MeasuredAt.UTC().Format(time.RFC3339),
+		}
+		if result.Throughput.Retransmits >= 0 {
+			properties["throughput_retransmits"] = strconv.FormatInt(result.Throughput.Retransmits, 10)
+		}
+		a.graphEngine.UpdateEdgeProperties(result.Source, result.Target, properties)
+	}
+}
@@ -0,0 +1,150 @@
+package analyzer
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/christine33-creator/k8-network-visualizer/pkg/prober"
+)
+
+// DefaultCertExpiryWindow is how far ahead certificate expiry is reported
+const DefaultCertExpiryWindow = 30 * 24 * time.Hour
+
+// certExpiryUrgent raises the severity of certificates expiring sooner
+const certExpiryUrgent = 7 * 24 * time.Hour
+
+// SetCertExpiryWindow sets how far ahead of expiry TLS certificates are
+// reported
+func (a *Analyzer) SetCertExpiryWindow(window time.Duration) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.certExpiry = window
+}
+
+func (a *Analyzer) certExpiryWindow() time.Duration {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	if a.certExpiry == 0 {
+		return DefaultCertExpiryWindow
+	}
+	return a.certExpiry
+}
+
+// analyzeTLS reports certificates that have expired or expire within the
+// window, and certificates that cover neither the Service's DNS names nor the
+// server name sent. Each target port is judged on its latest handshake.
+func (a *Analyzer) analyzeTLS(p *prober.Prober) {
+	latest := make(map[string]ProbeResult)
+	var targets []string
+	for _, result := range p.GetRecentResults(5 * time.Minute) {
+		if result.ProbeType != prober.ProbeTypeTLS || result.TLS == nil || result.TLS.NotAfter.IsZero() {
+			continue
+		}
+		key := probeTargetKey(result)
+		previous, ok := latest[key]
+		if !ok {
+			targets = append(targets, key)
+		}
+		if !ok || !result.Timestamp.Before(previous.Timestamp) {
+			latest[key] = result
+		}
+	}
+
+	window := a.certExpiryWindow()
+	for _, target := range targets {
+		result := latest[target]
+		info := result.TLS
+		affected := []string{target}
+		if result.TargetSvc != "" {
+			affected = []string{fmt.Sprintf("%s/%s", result.TargetNS, result.TargetSvc)}
+		}
+		details := map[string]interface{}{
+			"subject":     info.Subject,
+			"issuer":      info.Issuer,
+			"dns_names":   info.DNSNames,
+			"not_after":   info.NotAfter.Format(time.RFC3339),
+			"chain_valid": info.ChainValid,
+			"target_ip":   result.TargetIP,
+		}
+		if info.ChainError != "" {
+			details["chain_error"] = info.ChainError
+		}
+
+		remaining := time.Until(info.NotAfter)
+		switch {
+		case remaining <= 0:
+			a.addIssue(NetworkIssue{
+				ID:          a.generateIssueID(),
+				Type:        IssueTypeConfiguration,
+				Severity:    SeverityCritical,
+				Title:       fmt.Sprintf("TLS Certificate Expired: %s", target),
+				Description: fmt.Sprintf("The certificate served on %s expired on %s; clients that verify it will refuse to connect.", target, info.NotAfter.Format(time.RFC3339)),
+				Affected:    affected,
+				Suggestions: []string{
+					"Renew the certificate and restart or reload the pods serving it",
+					"If cert-manager issues it, check the Certificate and CertificateRequest status",
+				},
+				Details:   details,
+				Timestamp: time.Now(),
+			})
+		case remaining <= window:
+			severity := SeverityMedium
+			if remaining <= certExpiryUrgent {
+				severity = SeverityHigh
+			}
+			a.addIssue(NetworkIssue{
+				ID:          a.generateIssueID(),
+				Type:        IssueTypeConfiguration,
+				Severity:    severity,
+				Title:       fmt.Sprintf("TLS Certificate Expiring: %s", target),
+				Description: fmt.Sprintf("The certificate served on %s expires in %d days, on %s.", target, int(remaining.Hours()/24), info.NotAfter.Format(time.RFC3339)),
+				Affected:    affected,
+				Suggestions: []string{
+					"Renew the certificate before it expires",
+					"Check that automatic renewal, such as cert-manager, is working for this certificate",
+					"Make sure the pods reload the certificate after renewal",
+				},
+				Details:   details,
+				Timestamp: time.Now(),
+			})
+		}
+
+		// Only names the probe knew to expect can mismatch
+		if len(info.ServiceNames) == 0 && info.ServerName == "" {
+			continue
+		}
+		if info.NameCovered || info.SNIMatch {
+			continue
+		}
+		expected := info.ServiceNames
+		if len(expected) == 0 {
+			expected = []string{info.ServerName}
+		}
+		details["expected_names"] = expected
+		details["server_name"] = info.ServerName
+		a.addIssue(NetworkIssue{
+			ID:          a.generateIssueID(),
+			Type:        IssueTypeConfiguration,
+			Severity:    SeverityHigh,
+			Title:       fmt.Sprintf("TLS Certificate Name Mismatch: %s", target),
+			Description: fmt.Sprintf("The certificate served on %s covers %s but not %s; clients that verify the hostname will refuse to connect.", target, certNames(info), expected[0]),
+			Affected:    affected,
+			Suggestions: []string{
+				fmt.Sprintf("Add %s to the certificate's subject alternative names", expected[0]),
+				fmt.Sprintf("If clients use another name, set the %s annotation on the Service", prober.AnnotationTLSServerName),
+				"Check that the pod serves the right certificate for the server name (SNI) it is sent",
+			},
+			Details:   details,
+			Timestamp: time.Now(),
+		})
+	}
+}
+
+// certNames lists the names a certificate covers for an issue description
+func certNames(info *prober.TLSInfo) string {
+	if len(info.DNSNames) == 0 {
+		return fmt.Sprintf("only its subject %q", info.Subject)
+	}
+	return strings.Join(info.DNSNames, ", ")
+}
@@ -0,0 +1,622 @@
+package analyzer
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/christine33-creator/k8-network-visualizer/pkg/collector"
+	"github.com/christine33-creator/k8-network-visualizer/pkg/graph"
+	"github.com/christine33-creator/k8-network-visualizer/pkg/prober"
+	"github.com/christine33-creator/k8-network-visualizer/pkg/simulator"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// Hops a path trace walks through
+const (
+	TraceHopSource    = "source"
+	TraceHopService   = "service"
+	TraceHopEndpoints = "endpoints"
+	TraceHopPod       = "pod"
+	TraceHopEgress    = "egress_policy"
+	TraceHopIngress   = "ingress_policy"
+	TraceHopProbe     = "probe"
+	TraceHopFlow      = "flow"
+)
+
+// Outcomes of a trace step
+const (
+	TraceStatusPass = "pass"
+	TraceStatusFail = "fail"
+	TraceStatusWarn = "warn"
+	TraceStatusInfo = "info" // evidence is missing, neither pass nor fail
+)
+
+// TraceStep explains one hop of a path trace
+type TraceStep struct {
+	Hop      string                 `json:"hop"`
+	Resource string                 `json:"resource"`
+	Backend  string                 `json:"backend,omitempty"` // set on steps that apply to one backend pod
+	Status   string                 `json:"status"`
+	Message  string                 `json:"message"`
+	Details  map[string]interface{} `json:"details,omitempty"`
+}
+
+// PathTrace explains whether a pod can reach a service or pod, hop by hop
+type PathTrace struct {
+	From      string      `json:"from"`
+	To        string      `json:"to"`
+	Port      int32       `json:"port"`
+	Protocol  string      `json:"protocol"`
+	Reachable bool        `json:"reachable"`
+	Summary   string      `json:"summary"`
+	Steps     []TraceStep `json:"steps"`
+	TracedAt  time.Time   `json:"traced_at"`
+}
+
+// traceBackend is a pod the destination resolves to, with the port traffic arrives on
+type traceBackend struct {
+	key   string
+	pod   *corev1.Pod
+	port  int32
+	ready bool
+}
+
+// TracePath explains whether the from pod (namespace/name) can reach to, a service
+// or pod given as namespace/name:port. The port may be omitted for services with
+// a single port. Each hop becomes a step, and backends are traced individually.
+func (a *Analyzer) TracePath(from, to string, protocol corev1.Protocol, collector *collector.Collector, p *prober.Prober) (*PathTrace, error) {
+	sourceNS, sourceName, err := splitTraceKey(from)
+	if err != nil {
+		return nil, fmt.Errorf("invalid from %q: %w", from, err)
+	}
+	target, portValue := to, ""
+	if i := strings.LastIndex(to, ":"); i >= 0 {
+		target, portValue = to[:i], to[i+1:]
+	}
+	targetNS, targetName, err := splitTraceKey(target)
+	if err != nil {
+		return nil, fmt.Errorf("invalid to %q: %w", to, err)
+	}
+	if protocol == "" {
+		protocol = corev1.ProtocolTCP
+	}
+
+	trace := &PathTrace{
+		From:     from,
+		To:       to,
+		Protocol: string(protocol),
+		Steps:    []TraceStep{},
+		TracedAt: time.Now(),
+	}
+
+	podsByKey := make(map[string]*corev1.Pod)
+	for _, pod := range collector.GetPods() {
+		podsByKey[fmt.Sprintf("%s/%s", pod.Namespace, pod.Name)] = pod
+	}
+
+	// Source pod
+	source, ok := podsByKey[fmt.Sprintf("%s/%s", sourceNS, sourceName)]
+	if !ok {
+		return nil, fmt.Errorf("pod %s/%s not found", sourceNS, sourceName)
+	}
+	trace.addStep(sourceStep(source))
+
+	// Destination: a service, or a pod when no service has the name
+	var backends []traceBackend
+	var service *corev1.Service
+	for _, svc := range collector.GetServices() {
+		if svc.Namespace == targetNS && svc.Name == targetName {
+			service = svc
+			break
+		}
+	}
+	if service != nil {
+		servicePort, err := resolveServicePort(service, portValue, protocol)
+		if err != nil {
+			return nil, err
+		}
+		trace.Port = servicePort.Port
+		trace.addStep(a.serviceStep(service, servicePort))
+		var step TraceStep
+		backends, step = serviceBackends(service, servicePort, collector.GetEndpoints(), podsByKey)
+		trace.addStep(step)
+	} else {
+		pod, ok := podsByKey[fmt.Sprintf("%s/%s", targetNS, targetName)]
+		if !ok {
+			return nil, fmt.Errorf("no service or pod %s/%s", targetNS, targetName)
+		}
+		port, err := resolvePodPort(pod, portValue, protocol)
+		if err != nil {
+			return nil, err
+		}
+		trace.Port = port
+		backends = []traceBackend{{key: fmt.Sprintf("%s/%s", pod.Namespace, pod.Name), pod: pod, port: port, ready: podReady(pod)}}
+	}
+
+	// Per-backend readiness and policy verdicts
+	namespaceLabels := make(map[string]map[string]string)
+	for _, ns := range collector.GetNamespaces() {
+		namespaceLabels[ns.Name] = ns.Labels
+	}
+	evaluator := simulator.NewPolicyEvaluator(collector.GetNetworkPolicies(), namespaceLabels)
+
+	sourceRunning := source.Status.Phase == corev1.PodRunning && source.Status.PodIP != ""
+	reachable := 0
+	for _, backend := range backends {
+		trace.addStep(backendStep(backend))
+		decision := evaluator.Evaluate(simulator.Connection{
+			Source:      source,
+			Destination: backend.pod,
+			Port:        backend.port,
+			Protocol:    protocol,
+		})
+		for _, step := range policySteps(source, backend, decision) {
+			trace.addStep(step)
+		}
+		if sourceRunning && backend.ready && decision.Allowed {
+			reachable++
+		}
+	}
+
+	// Evidence from probes and observed flows
+	trace.addStep(probeStep(source, service, backends, trace.Port, p))
+	for _, step := range a.flowSteps(source, backends, protocol) {
+		trace.addStep(step)
+	}
+
+	trace.Reachable = reachable > 0
+	switch {
+	case len(backends) == 0:
+		trace.Summary = fmt.Sprintf("%s has no backends, so %s cannot reach it", to, from)
+	case trace.Reachable:
+		trace.Summary = fmt.Sprintf("%s can reach %s through %d of %d backends", from, to, reachable, len(backends))
+	default:
+		trace.Summary = fmt.Sprintf("%s cannot reach %s: %s", from, to, firstFailure(trace.Steps))
+	}
+	return trace, nil
+}
+
+func (t *PathTrace) addStep(step TraceStep) {
+	t.Steps = append(t.Steps, step)
+}
+
+func splitTraceKey(key string) (string, string, error) {
+	parts := strings.Split(key, "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", fmt.Errorf("expected namespace/name")
+	}
+	return parts[0], parts[1], nil
+}
+
+func sourceStep(pod *corev1.Pod) TraceStep {
+	step := TraceStep{
+		Hop:      TraceHopSource,
+		Resource: fmt.Sprintf("pod/%s/%s", pod.Namespace, pod.Name),
+		Status:   TraceStatusPass,
+		Message:  fmt.Sprintf("Source pod is %s with IP %s on node %s", pod.Status.Phase, pod.Status.PodIP, pod.Spec.NodeName),
+		Details: map[string]interface{}{
+			"phase":        string(pod.Status.Phase),
+			"ip":           pod.Status.PodIP,
+			"node":         pod.Spec.NodeName,
+			"host_network": pod.Spec.HostNetwork,
+		},
+	}
+	if pod.Status.Phase != corev1.PodRunning || pod.Status.PodIP == "" {
+		step.Status = TraceStatusFail
+		step.Message = fmt.Sprintf("Source pod is %s and cannot send traffic", pod.Status.Phase)
+	} else if pod.Spec.HostNetwork {
+		step.Status = TraceStatusWarn
+		step.Message += "; it uses the host network, so NetworkPolicies do not apply to it"
+	}
+	return step
+}
+
+// resolveServicePort picks the service port named or numbered by value
+func resolveServicePort(svc *corev1.Service, value string, protocol corev1.Protocol) (corev1.ServicePort, error) {
+	var candidates []corev1.ServicePort
+	for _, port := range svc.Spec.Ports {
+		portProtocol := port.Protocol
+		if portProtocol == "" {
+			portProtocol = corev1.ProtocolTCP
+		}
+		if portProtocol != protocol {
+			continue
+		}
+		if value == "" || value == port.Name || value == strconv.Itoa(int(port.Port)) {
+			candidates = append(candidates, port)
+		}
+	}
+	switch {
+	case len(candidates) == 1:
+		return candidates[0], nil
+	case len(candidates) == 0 && value == "":
+		return corev1.ServicePort{}, fmt.Errorf("service %s/%s has no %s ports", svc.Namespace, svc.Name, protocol)
+	case len(candidates) == 0:
+		return corev1.ServicePort{}, fmt.Errorf("service %s/%s has no %s port %s", svc.Namespace, svc.Name, protocol, value)
+	default:
+		return corev1.ServicePort{}, fmt.Errorf("service %s/%s has %d ports, specify one", svc.Namespace, svc.Name, len(candidates))
+	}
+}
+
+// resolvePodPort resolves a numbered or named container port; pods with a single
+// declared port may omit it
+func resolvePodPort(pod *corev1.Pod, value string, protocol corev1.Protocol) (int32, error) {
+	if value == "" {
+		var ports []int32
+		for _, container := range pod.Spec.Containers {
+			for _, port := range container.Ports {
+				ports = append(ports, port.ContainerPort)
+			}
+		}
+		if len(ports) != 1 {
+			return 0, fmt.Errorf("pod %s/%s declares %d ports, specify one", pod.Namespace, pod.Name, len(ports))
+		}
+		return ports[0], nil
+	}
+	if number, err := strconv.Atoi(value); err == nil {
+		if number <= 0 || number > 65535 {
+			return 0, fmt.Errorf("invalid port %d", number)
+		}
+		return int32(number), nil
+	}
+	if port := simulator.ResolveNamedPort(pod, value, protocol); port != 0 {
+		return port, nil
+	}
+	return 0, fmt.Errorf("pod %s/%s has no %s port named %s", pod.Namespace, pod.Name, protocol, value)
+}
+
+// serviceStep checks the service in the graph and describes its port mapping
+func (a *Analyzer) serviceStep(svc *corev1.Service, port corev1.ServicePort) TraceStep {
+	nodeID := fmt.Sprintf("service/%s/%s", svc.Namespace, svc.Name)
+	step := TraceStep{
+		Hop:      TraceHopService,
+		Resource: nodeID,
+		Status:   TraceStatusPass,
+		Message: fmt.Sprintf("Service %s (%s) forwards port %d to target port %s",
+			svc.Name, svc.Spec.ClusterIP, port.Port, port.TargetPort.String()),
+		Details: map[string]interface{}{
+			"type":        string(svc.Spec.Type),
+			"cluster_ip":  svc.Spec.ClusterIP,
+			"port":        port.Port,
+			"target_port": port.TargetPort.String(),
+			"selector":    svc.Spec.Selector,
+		},
+	}
+
+	node, ok := a.graphEngine.GetNodeByID(nodeID)
+	if !ok {
+		step.Status = TraceStatusWarn
+		step.Message += "; it is not in the topology graph yet"
+	} else if node.Health != graph.HealthHealthy {
+		step.Status = TraceStatusWarn
+		step.Message += fmt.Sprintf("; graph health is %s", node.Health)
+	}
+	if svc.Spec.ClusterIP == corev1.ClusterIPNone {
+		step.Message = fmt.Sprintf("Headless service %s resolves directly to pod IPs on port %s", svc.Name, port.TargetPort.String())
+	}
+	if len(svc.Spec.Selector) == 0 {
+		step.Status = TraceStatusWarn
+		step.Message += "; it has no selector, so its endpoints are managed manually"
+	}
+	return step
+}
+
+// serviceBackends resolves the service's endpoints to pods and the target port each
+// receives traffic on. Named target ports can differ per endpoint subset.
+func serviceBackends(svc *corev1.Service, port corev1.ServicePort, endpoints []*corev1.Endpoints, podsByKey map[string]*corev1.Pod) ([]traceBackend, TraceStep) {
+	step := TraceStep{
+		Hop:      TraceHopEndpoints,
+		Resource: fmt.Sprintf("endpoints/%s/%s", svc.Namespace, svc.Name),
+		Status:   TraceStatusPass,
+	}
+
+	var ep *corev1.Endpoints
+	for _, candidate := range endpoints {
+		if candidate.Namespace == svc.Namespace && candidate.Name == svc.Name {
+			ep = candidate
+			break
+		}
+	}
+	if ep == nil {
+		step.Status = TraceStatusFail
+		step.Message = "No Endpoints object exists for the service"
+		return nil, step
+	}
+
+	var backends []traceBackend
+	var external []string
+	ready, notReady := 0, 0
+	for _, subset := range ep.Subsets {
+		targetPort := int32(0)
+		for _, epPort := range subset.Ports {
+			if epPort.Name == port.Name {
+				targetPort = epPort.Port
+				break
+			}
+		}
+		if targetPort == 0 {
+			continue
+		}
+
+		addresses := []struct {
+			addresses []corev1.EndpointAddress
+			ready     bool
+		}{{subset.Addresses, true}, {subset.NotReadyAddresses, false}}
+		for _, group := range addresses {
+			for _, address := range group.addresses {
+				if group.ready {
+					ready++
+				} else {
+					notReady++
+				}
+				if address.TargetRef == nil || address.TargetRef.Kind != "Pod" {
+					external = append(external, address.IP)
+					continue
+				}
+				key := fmt.Sprintf("%s/%s", address.TargetRef.Namespace, address.TargetRef.Name)
+				pod, ok := podsByKey[key]
+				if !ok {
+					external = append(external, address.IP)
+					continue
+				}
+				backends = append(backends, traceBackend{key: key, pod: pod, port: targetPort, ready: group.ready})
+			}
+		}
+	}
+
+	step.Details = map[string]interface{}{
+		"ready":     ready,
+		"not_ready": notReady,
+	}
+	if len(external) > 0 {
+		step.Details["untraced_addresses"] = external
+	}
+	switch {
+	case ready == 0 && notReady == 0:
+		step.Status = TraceStatusFail
+		step.Message = fmt.Sprintf("No endpoints serve port %s; check that the selector matches running pods", servicePortName(port))
+	case ready == 0:
+		step.Status = TraceStatusFail
+		step.Message = fmt.Sprintf("All %d endpoints are not ready", notReady)
+	case notReady > 0:
+		step.Status = TraceStatusWarn
+		step.Message = fmt.Sprintf("%d ready and %d not ready endpoints", ready, notReady)
+	default:
+		step.Message = fmt.Sprintf("%d ready endpoints", ready)
+	}
+	return backends, step
+}
+
+func servicePortName(port corev1.ServicePort) string {
+	if port.Name != "" {
+		return port.Name
+	}
+	return strconv.Itoa(int(port.Port))
+}
+
+func backendStep(backend traceBackend) TraceStep {
+	pod := backend.pod
+	step := TraceStep{
+		Hop:      TraceHopPod,
+		Resource: fmt.Sprintf("pod/%s", backend.key),
+		Backend:  backend.key,
+		Status:   TraceStatusPass,
+		Message:  fmt.Sprintf("Pod is ready with IP %s, listening on port %d", pod.Status.PodIP, backend.port),
+		Details: map[string]interface{}{
+			"phase": string(pod.Status.Phase),
+			"ip":    pod.Status.PodIP,
+			"node":  pod.Spec.NodeName,
+			"port":  backend.port,
+		},
+	}
+	if !backend.ready {
+		step.Status = TraceStatusFail
+		step.Message = fmt.Sprintf("Pod is %s and not ready, so it receives no service traffic", pod.Status.Phase)
+	}
+	if !podDeclaresPort(pod, backend.port) {
+		if step.Status == TraceStatusPass {
+			step.Status = TraceStatusWarn
+		}
+		step.Message += fmt.Sprintf("; no container declares port %d", backend.port)
+	}
+	return step
+}
+
+func podReady(pod *corev1.Pod) bool {
+	if pod.Status.Phase != corev1.PodRunning {
+		return false
+	}
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == corev1.PodReady {
+			return condition.Status == corev1.ConditionTrue
+		}
+	}
+	return true
+}
+
+func podDeclaresPort(pod *corev1.Pod, port int32) bool {
+	for _, container := range pod.Spec.Containers {
+		for _, containerPort := range container.Ports {
+			if containerPort.ContainerPort == port {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// policySteps explains the egress and ingress side of a policy decision
+func policySteps(source *corev1.Pod, backend traceBackend, decision simulator.PolicyDecision) []TraceStep {
+	steps := []TraceStep{}
+	sides := []struct {
+		hop      string
+		pod      *corev1.Pod
+		isolated bool
+		verdicts []simulator.PolicyVerdict
+	}{
+		{TraceHopEgress, source, decision.EgressIsolated, decision.Egress},
+		{TraceHopIngress, backend.pod, decision.IngressIsolated, decision.Ingress},
+	}
+
+	for _, side := range sides {
+		direction := strings.TrimSuffix(side.hop, "_policy")
+		if !side.isolated {
+			steps = append(steps, TraceStep{
+				Hop:      side.hop,
+				Resource: fmt.Sprintf("pod/%s/%s", side.pod.Namespace, side.pod.Name),
+				Backend:  backend.key,
+				Status:   TraceStatusPass,
+				Message:  fmt.Sprintf("No NetworkPolicy selects the pod for %s, so all %s traffic is allowed", direction, direction),
+			})
+			continue
+		}
+		for _, verdict := range side.verdicts {
+			step := TraceStep{
+				Hop:      side.hop,
+				Resource: fmt.Sprintf("networkpolicy/%s", verdict.Policy),
+				Backend:  backend.key,
+				Details: map[string]interface{}{
+					"policy": verdict.Policy,
+					"rule":   verdict.Rule,
+				},
+			}
+			if verdict.Allowed {
+				step.Status = TraceStatusPass
+				step.Message = fmt.Sprintf("Rule %d allows %s traffic on port %d", verdict.Rule, direction, backend.port)
+			} else {
+				step.Status = TraceStatusFail
+				step.Message = fmt.Sprintf("No %s rule matches port %d from this peer", direction, backend.port)
+				// Another selecting policy may still allow the traffic, since policies add up
+				if anyVerdictAllowed(side.verdicts) {
+					step.Status = TraceStatusInfo
+					step.Message += ", but another policy allows it"
+				}
+			}
+			steps = append(steps, step)
+		}
+	}
+	return steps
+}
+
+func anyVerdictAllowed(verdicts []simulator.PolicyVerdict) bool {
+	for _, verdict := range verdicts {
+		if verdict.Allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// probeStep reports the latest probe between the source and the destination
+func probeStep(source *corev1.Pod, service *corev1.Service, backends []traceBackend, servicePort int32, p *prober.Prober) TraceStep {
+	step := TraceStep{
+		Hop:      TraceHopProbe,
+		Resource: fmt.Sprintf("pod/%s/%s", source.Namespace, source.Name),
+		Status:   TraceStatusInfo,
+		Message:  "No recent probe between these endpoints",
+	}
+	if p == nil {
+		return step
+	}
+
+	backendPorts := make(map[string]int32, len(backends))
+	for _, backend := range backends {
+		backendPorts[backend.key] = backend.port
+	}
+
+	var latest *prober.ProbeResult
+	for _, result := range p.GetResults() {
+		if result.SourceNS != source.Namespace || result.SourcePod != source.Name {
+			continue
+		}
+		matches := false
+		if service != nil && result.TargetSvc == service.Name && result.TargetNS == service.Namespace {
+			matches = result.TargetPort == servicePort
+		} else if port, ok := backendPorts[fmt.Sprintf("%s/%s", result.TargetNS, result.TargetPod)]; ok && result.TargetSvc == "" {
+			matches = result.TargetPort == port
+		}
+		if matches && (latest == nil || result.Timestamp.After(latest.Timestamp)) {
+			r := result
+			latest = &r
+		}
+	}
+	if latest == nil {
+		return step
+	}
+
+	step.Details = map[string]interface{}{
+		"target_ip":  latest.TargetIP,
+		"port":       latest.TargetPort,
+		"latency_ms": latest.Latency,
+		"timestamp":  latest.Timestamp,
+	}
+	if latest.Success {
+		step.Status = TraceStatusPass
+		step.Message = fmt.Sprintf("%s probe to %s:%d succeeded in %dms at %s", latest.ProbeType, latest.TargetIP, latest.TargetPort, latest.Latency, latest.Timestamp.Format(time.RFC3339))
+	} else if latest.HealthMismatch() {
+		step.Status = TraceStatusWarn
+		step.Message = fmt.Sprintf("%s probe to %s:%d connected but the health check failed at %s: %s", latest.ProbeType, latest.TargetIP, latest.TargetPort, latest.Timestamp.Format(time.RFC3339), latest.Error)
+	} else {
+		step.Status = TraceStatusFail
+		step.Message = fmt.Sprintf("%s probe to %s:%d failed at %s: %s", latest.ProbeType, latest.TargetIP, latest.TargetPort, latest.Timestamp.Format(time.RFC3339), latest.Error)
+	}
+	return step
+}
+
+// flowSteps reports traffic the flow collector saw from the source to each backend
+func (a *Analyzer) flowSteps(source *corev1.Pod, backends []traceBackend, protocol corev1.Protocol) []TraceStep {
+	a.mu.RLock()
+	flowCollector := a.flowCollector
+	a.mu.RUnlock()
+	if flowCollector == nil {
+		return []TraceStep{{
+			Hop:      TraceHopFlow,
+			Resource: fmt.Sprintf("pod/%s/%s", source.Namespace, source.Name),
+			Status:   TraceStatusInfo,
+			Message:  "Flow collection is disabled",
+		}}
+	}
+
+	metrics := flowCollector.GetFlowMetrics()
+	steps := []TraceStep{}
+	for _, backend := range backends {
+		step := TraceStep{
+			Hop:      TraceHopFlow,
+			Resource: fmt.Sprintf("pod/%s", backend.key),
+			Backend:  backend.key,
+			Status:   TraceStatusInfo,
+			Message:  fmt.Sprintf("No traffic observed on port %d", backend.port),
+		}
+		for _, metric := range metrics {
+			if metric.SourceNamespace != source.Namespace || metric.SourcePod != source.Name ||
+				metric.DestNamespace != backend.pod.Namespace || metric.DestPod != backend.pod.Name {
+				continue
+			}
+			for _, port := range metric.Ports {
+				if int32(port.Port) != backend.port || !strings.EqualFold(port.Protocol, string(protocol)) {
+					continue
+				}
+				step.Status = TraceStatusPass
+				step.Message = fmt.Sprintf("Traffic observed on port %d: %.0f bytes/sec, last seen %s", backend.port, port.BytesPerSec, metric.LastSeen.Format(time.RFC3339))
+				step.Details = map[string]interface{}{
+					"bytes_per_sec":    port.BytesPerSec,
+					"connection_count": port.ConnectionCount,
+					"last_seen":        metric.LastSeen,
+				}
+			}
+		}
+		steps = append(steps, step)
+	}
+	return steps
+}
+
+func firstFailure(steps []TraceStep) string {
+	for _, step := range steps {
+		if step.Status == TraceStatusFail {
+			return step.Message
+		}
+	}
+	return "no backend is both ready and allowed by policy"
+}
@@ -26,6 +26,7 @@ type Collector struct {
 	services        map[string]*corev1.Service
 	endpoints       map[string]*corev1.Endpoints
 	nodes           map[string]*corev1.Node
+	namespaces      map[string]*corev1.Namespace
 	networkPolicies map[string]*networkingv1.NetworkPolicy
 	
 	// Informers
@@ -33,6 +34,7 @@ type Collector struct {
 	svcInformer    cache.SharedIndexInformer
 	epInformer     cache.SharedIndexInformer
 	nodeInformer   cache.SharedIndexInformer
+	nsInformer     cache.SharedIndexInformer
 	policyInformer cache.SharedIndexInformer
 }
 
@@ -45,6 +47,7 @@ func NewCollector(client *k8s.Client, namespace string) *Collector {
 		services:        make(map[string]*corev1.Service),
 		endpoints:       make(map[string]*corev1.Endpoints),
 		nodes:           make(map[string]*corev1.Node),
+		namespaces:      make(map[string]*corev1.Namespace),
 		networkPolicies: make(map[string]*networkingv1.NetworkPolicy),
 	}
 }
@@ -68,6 +71,10 @@ func (c *Collector) Start(ctx context.Context) error {
 		return fmt.Errorf("failed to setup node informer: %w", err)
 	}
 	
+	if err := c.setupNamespaceInformer(ctx); err != nil {
+		return fmt.Errorf("failed to setup namespace informer: %w", err)
+	}
+	
 	if err := c.setupNetworkPolicyInformer(ctx); err != nil {
 		return fmt.Errorf("failed to setup network policy informer: %w", err)
 	}
@@ -77,6 +84,7 @@ func (c *Collector) Start(ctx context.Context) error {
 	go c.svcInformer.Run(ctx.Done())
 	go c.epInformer.Run(ctx.Done())
 	go c.nodeInformer.Run(ctx.Done())
+	go c.nsInformer.Run(ctx.Done())
 	go c.policyInformer.Run(ctx.Done())
 	
 	// Wait for caches to sync
@@ -86,6 +94,7 @@ func (c *Collector) Start(ctx context.Context) error {
 		c.svcInformer.HasSynced,
 		c.epInformer.HasSynced,
 		c.nodeInformer.HasSynced,
+		c.nsInformer.HasSynced,
 		c.policyInformer.HasSynced,
 	) {
 		return fmt.Errorf("failed to sync caches")
@@ -259,6 +268,45 @@ func (c *Collector) setupNodeInformer(ctx context.Context) error {
 	return nil
 }
 
+func (c *Collector) setupNamespaceInformer(ctx context.Context) error {
+	listWatch := cache.NewListWatchFromClient(
+		c.client.Clientset().CoreV1().RESTClient(),
+		"namespaces",
+		metav1.NamespaceAll,
+		fields.Everything(),
+	)
+	
+	c.nsInformer = cache.NewSharedIndexInformer(
+		listWatch,
+		&corev1.Namespace{},
+		time.Minute,
+		cache.Indexers{},
+	)
+	
+	c.nsInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			ns := obj.(*corev1.Namespace)
+			c.mu.Lock()
+			c.namespaces[ns.Name] = ns
+			c.mu.Unlock()
+		},
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			ns := newObj.(*corev1.Namespace)
+			c.mu.Lock()
+			c.namespaces[ns.Name] = ns
+			c.mu.Unlock()
+		},
+		DeleteFunc: func(obj interface{}) {
+			ns := obj.(*corev1.Namespace)
+			c.mu.Lock()
+			delete(c.namespaces, ns.Name)
+			c.mu.Unlock()
+		},
+	})
+	
+	return nil
+}
+
 func (c *Collector) setupNetworkPolicyInformer(ctx context.Context) error {
 	listWatch := cache.NewListWatchFromClient(
 		c.client.Clientset().NetworkingV1().RESTClient(),
@@ -349,6 +397,18 @@ func (c *Collector) GetNodes() []*corev1.Node {
 	return nodes
 }
 
+// GetNamespaces returns all collected namespaces
+func (c *Collector) GetNamespaces() []*corev1.Namespace {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	
+	namespaces := make([]*corev1.Namespace, 0, len(c.namespaces))
+	for _, ns := range c.namespaces {
+		namespaces = append(namespaces, ns)
+	}
+	return namespaces
+}
+
 // GetNetworkPolicies returns all collected network policies
 func (c *Collector) GetNetworkPolicies() []*networkingv1.NetworkPolicy {
 	c.mu.RLock()
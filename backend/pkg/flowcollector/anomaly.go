@@ -147,15 +147,14 @@ func (ad *AnomalyDetector) AnalyzeFlows(flows []*Flow, metrics map[string]*FlowM
 	// 4. Detect high error rates
 	newAnomalies = append(newAnomalies, ad.detectHighErrorRate(metrics)...)
 	
-	// TODO: Re-enable after fixing type compatibility
 	// 5. Detect port scanning
-	//newAnomalies = append(newAnomalies, ad.detectPortScanning(flows)...)
+	newAnomalies = append(newAnomalies, ad.detectPortScanning(flows)...)
 	
 	// 6. Detect potential data exfiltration
-	//newAnomalies = append(newAnomalies, ad.detectDataExfiltration(metrics)...)
+	newAnomalies = append(newAnomalies, ad.detectDataExfiltration(metrics)...)
 	
 	// 7. Detect DNS anomalies
-	//newAnomalies = append(newAnomalies, ad.detectDNSAnomalies(flows)...)
+	newAnomalies = append(newAnomalies, ad.detectDNSAnomalies(flows)...)
 	
 	// Store anomalies
 	ad.anomalies = append(ad.anomalies, newAnomalies...)
@@ -404,22 +403,23 @@ func (ad *AnomalyDetector) detectHighErrorRate(metrics map[string]*FlowMetric) [
 }
 
 // detectPortScanning detects port scanning behavior
-func (ad *AnomalyDetector) detectPortScanning(flows []NetworkFlow) []Anomaly {
+func (ad *AnomalyDetector) detectPortScanning(flows []*Flow) []Anomaly {
 	anomalies := make([]Anomaly, 0)
 	
 	// Track unique dest ports per source in last minute
-	portsBySource := make(map[string]map[uint32]bool)
+	portsBySource := make(map[string]map[int]bool)
 	cutoff := time.Now().Add(-1 * time.Minute)
 	
 	for _, flow := range flows {
-		if flow.Timestamp.Before(cutoff) {
+		if flow.Timestamp.Before(cutoff) || flow.SourcePod == "" || flow.IsReply {
 			continue
 		}
 		
-		if _, exists := portsBySource[flow.SourcePod]; !exists {
-			portsBySource[flow.SourcePod] = make(map[uint32]bool)
+		sourcePod := fmt.Sprintf("%s/%s", flow.SourceNamespace, flow.SourcePod)
+		if _, exists := portsBySource[sourcePod]; !exists {
+			portsBySource[sourcePod] = make(map[int]bool)
 		}
-		portsBySource[flow.SourcePod][flow.DestPort] = true
+		portsBySource[sourcePod][flow.DestPort] = true
 	}
 	
 	// Check for port scans
@@ -451,21 +451,24 @@ func (ad *AnomalyDetector) detectPortScanning(flows []NetworkFlow) []Anomaly {
 }
 
 // detectDataExfiltration detects potential data exfiltration
-func (ad *AnomalyDetector) detectDataExfiltration(metrics []FlowMetrics) []Anomaly {
+func (ad *AnomalyDetector) detectDataExfiltration(metrics map[string]*FlowMetric) []Anomaly {
 	anomalies := make([]Anomaly, 0)
 	
 	for _, metric := range metrics {
 		// Check for high outbound traffic
 		if metric.BytesPerSec > float64(ad.exfilThreshold) {
+			sourcePod := fmt.Sprintf("%s/%s", metric.SourceNamespace, metric.SourcePod)
+			destPod := fmt.Sprintf("%s/%s", metric.DestNamespace, metric.DestPod)
+			
 			anomaly := Anomaly{
-				ID:          fmt.Sprintf("exfil-%s-%s-%d", metric.SourceID, metric.DestID, time.Now().Unix()),
+				ID:          fmt.Sprintf("exfil-%s-%s-%d", sourcePod, destPod, time.Now().Unix()),
 				Type:        AnomalyDataExfiltration,
 				Severity:    "critical",
 				Title:       "Potential Data Exfiltration",
 				Description: fmt.Sprintf("Very high outbound traffic from %s to %s: %.2f MB/s", 
-					metric.SourceID, metric.DestID, metric.BytesPerSec/1024/1024),
-				SourcePod:   metric.SourceID,
-				DestPod:     metric.DestID,
+					sourcePod, destPod, metric.BytesPerSec/1024/1024),
+				SourcePod:   sourcePod,
+				DestPod:     destPod,
 				Evidence: Evidence{
 					CurrentValue: metric.BytesPerSec,
 					Threshold:    float64(ad.exfilThreshold),
@@ -484,7 +487,7 @@ func (ad *AnomalyDetector) detectDataExfiltration(metrics []FlowMetrics) []Anoma
 }
 
 // detectDNSAnomalies detects DNS-related anomalies
-func (ad *AnomalyDetector) detectDNSAnomalies(flows []NetworkFlow) []Anomaly {
+func (ad *AnomalyDetector) detectDNSAnomalies(flows []*Flow) []Anomaly {
 	anomalies := make([]Anomaly, 0)
 	
 	// Track DNS queries per pod
@@ -492,11 +495,11 @@ func (ad *AnomalyDetector) detectDNSAnomalies(flows []NetworkFlow) []Anomaly {
 	cutoff := time.Now().Add(-1 * time.Minute)
 	
 	for _, flow := range flows {
-		if flow.Timestamp.Before(cutoff) || flow.L7Protocol != string(ProtocolDNS) {
+		if flow.Timestamp.Before(cutoff) || flow.L7Protocol != string(ProtocolDNS) || flow.IsReply || flow.SourcePod == "" {
 			continue
 		}
 		
-		dnsQueriesByPod[flow.SourcePod]++
+		dnsQueriesByPod[fmt.Sprintf("%s/%s", flow.SourceNamespace, flow.SourcePod)]++
 	}
 	
 	// Check for excessive DNS queries
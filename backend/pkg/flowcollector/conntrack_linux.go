@@ -0,0 +1,147 @@
+//go:build linux
+
+package flowcollector
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log"
+	"syscall"
+	"time"
+)
+
+// ctnetlink multicast groups, as the legacy bind bitmask
+const (
+	nfGroupConntrackNew     = 1 << 0
+	nfGroupConntrackDestroy = 1 << 2
+)
+
+const (
+	netlinkBufferSize  = 1 << 16
+	netlinkEventBuffer = 4 << 20         // socket receive buffer for bursts of events
+	netlinkDumpTimeout = 5 * time.Second // per read while dumping
+	netlinkEventPoll   = 1 * time.Second // how often the event reader checks for cancellation
+)
+
+// NetlinkConntrackSource reads the conntrack table over ctnetlink, without
+// userspace tools. Dumping and subscribing to events need CAP_NET_ADMIN.
+type NetlinkConntrackSource struct{}
+
+// NewNetlinkConntrackSource checks that ctnetlink can be dumped and returns a
+// source for it
+func NewNetlinkConntrackSource() (*NetlinkConntrackSource, error) {
+	source := &NetlinkConntrackSource{}
+	if _, err := source.Dump(); err != nil {
+		return nil, err
+	}
+	return source, nil
+}
+
+// Name describes the source
+func (s *NetlinkConntrackSource) Name() string {
+	return "netlink"
+}
+
+// Dump requests every conntrack entry of every address family
+func (s *NetlinkConntrackSource) Dump() ([]*Flow, error) {
+	fd, err := openNetlink(0, netlinkDumpTimeout)
+	if err != nil {
+		return nil, err
+	}
+	defer syscall.Close(fd)
+
+	request := make([]byte, nlmsgHeaderLen+nfnlHeaderLen)
+	binary.NativeEndian.PutUint32(request[0:4], uint32(len(request)))
+	binary.NativeEndian.PutUint16(request[4:6], nfnlSubsysCTNetlink<<8|ctMsgGet)
+	binary.NativeEndian.PutUint16(request[6:8], syscall.NLM_F_REQUEST|syscall.NLM_F_DUMP)
+	binary.NativeEndian.PutUint32(request[8:12], uint32(time.Now().Unix())) // sequence
+	request[16] = syscall.AF_UNSPEC                                         // nfgenmsg: all families, version 0
+	if err := syscall.Sendto(fd, request, 0, &syscall.SockaddrNetlink{Family: syscall.AF_NETLINK}); err != nil {
+		return nil, fmt.Errorf("requesting conntrack dump: %w", err)
+	}
+
+	var flows []*Flow
+	buf := make([]byte, netlinkBufferSize)
+	for {
+		n, _, err := syscall.Recvfrom(fd, buf, 0)
+		if err != nil {
+			return nil, fmt.Errorf("reading conntrack dump: %w", err)
+		}
+		events, done, err := parseConntrackMessages(buf[:n])
+		if err != nil {
+			return nil, err
+		}
+		for _, event := range events {
+			flows = append(flows, event.Flow)
+		}
+		if done {
+			return flows, nil
+		}
+	}
+}
+
+// Events subscribes to new and destroyed connections. Events the socket
+// cannot buffer are lost; the collector's periodic dumps catch up on them.
+func (s *NetlinkConntrackSource) Events(ctx context.Context) (<-chan ConntrackEvent, error) {
+	fd, err := openNetlink(nfGroupConntrackNew|nfGroupConntrackDestroy, netlinkEventPoll)
+	if err != nil {
+		return nil, err
+	}
+	syscall.SetsockoptInt(fd, syscall.SOL_SOCKET, syscall.SO_RCVBUF, netlinkEventBuffer) // best effort
+
+	events := make(chan ConntrackEvent, 256)
+	go func() {
+		defer close(events)
+		defer syscall.Close(fd)
+
+		buf := make([]byte, netlinkBufferSize)
+		for ctx.Err() == nil {
+			n, _, err := syscall.Recvfrom(fd, buf, 0)
+			if errors.Is(err, syscall.EAGAIN) || errors.Is(err, syscall.EINTR) {
+				continue // poll timeout
+			}
+			if errors.Is(err, syscall.ENOBUFS) {
+				log.Println("Conntrack events overflowed the netlink socket; some were lost")
+				continue
+			}
+			if err != nil {
+				log.Printf("Error reading conntrack events: %v", err)
+				return
+			}
+
+			parsed, _, err := parseConntrackMessages(buf[:n])
+			if err != nil {
+				log.Printf("Error parsing conntrack events: %v", err)
+			}
+			for _, event := range parsed {
+				select {
+				case events <- event:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return events, nil
+}
+
+// openNetlink opens a netfilter netlink socket joined to the given groups,
+// whose reads time out after timeout
+func openNetlink(groups uint32, timeout time.Duration) (int, error) {
+	fd, err := syscall.Socket(syscall.AF_NETLINK, syscall.SOCK_RAW|syscall.SOCK_CLOEXEC, syscall.NETLINK_NETFILTER)
+	if err != nil {
+		return -1, fmt.Errorf("opening netfilter netlink socket: %w", err)
+	}
+	if err := syscall.Bind(fd, &syscall.SockaddrNetlink{Family: syscall.AF_NETLINK, Groups: groups}); err != nil {
+		syscall.Close(fd)
+		return -1, fmt.Errorf("binding netfilter netlink socket: %w", err)
+	}
+	tv := syscall.NsecToTimeval(timeout.Nanoseconds())
+	if err := syscall.SetsockoptTimeval(fd, syscall.SOL_SOCKET, syscall.SO_RCVTIMEO, &tv); err != nil {
+		syscall.Close(fd)
+		return -1, err
+	}
+	return fd, nil
+}
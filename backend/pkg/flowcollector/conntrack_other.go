@@ -0,0 +1,32 @@
+//go:build !linux
+
+package flowcollector
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+)
+
+// NetlinkConntrackSource is only implemented on Linux
+type NetlinkConntrackSource struct{}
+
+// NewNetlinkConntrackSource fails outside Linux
+func NewNetlinkConntrackSource() (*NetlinkConntrackSource, error) {
+	return nil, fmt.Errorf("ctnetlink is not supported on %s", runtime.GOOS)
+}
+
+// Name describes the source
+func (s *NetlinkConntrackSource) Name() string {
+	return "netlink"
+}
+
+// Dump fails outside Linux
+func (s *NetlinkConntrackSource) Dump() ([]*Flow, error) {
+	return nil, fmt.Errorf("ctnetlink is not supported on %s", runtime.GOOS)
+}
+
+// Events fails outside Linux
+func (s *NetlinkConntrackSource) Events(ctx context.Context) (<-chan ConntrackEvent, error) {
+	return nil, ErrConntrackEventsUnsupported
+}
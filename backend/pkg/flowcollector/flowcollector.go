@@ -7,6 +7,7 @@ import (
 	"sync"
 	"time"
 
+	flowpb "github.com/cilium/cilium/api/v1/flow"
 	observer "github.com/cilium/cilium/api/v1/observer"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
@@ -36,404 +37,414 @@ const (
 	ProtocolUnknown Protocol = "UNKNOWN"
 )
 
-// NetworkFlow represents a captured network flow
-type NetworkFlow struct {
-	ID               string            `json:"id"`
-	SourcePod        string            `json:"source_pod"`
-	SourceIP         string            `json:"source_ip"`
-	SourcePort       uint32            `json:"source_port"`
-	SourceNamespace  string            `json:"source_namespace"`
-	DestPod          string            `json:"dest_pod"`
-	DestIP           string            `json:"dest_ip"`
-	DestPort         uint32            `json:"dest_port"`
-	DestNamespace    string            `json:"dest_namespace"`
-	Protocol         Protocol          `json:"protocol"`
-	FlowType         FlowType          `json:"flow_type"`
-	BytesSent        uint64            `json:"bytes_sent"`
-	PacketsSent      uint64            `json:"packets_sent"`
-	Direction        string            `json:"direction"` // ingress, egress
-	IsReply          bool              `json:"is_reply"`
-	Verdict          string            `json:"verdict"` // FORWARDED, DROPPED, ERROR
-	DropReason       string            `json:"drop_reason,omitempty"`
-	L7Protocol       string            `json:"l7_protocol,omitempty"`
-	L7Details        map[string]string `json:"l7_details,omitempty"`
-	Timestamp        time.Time         `json:"timestamp"`
-	TraceObservation string            `json:"trace_observation,omitempty"`
-}
-
-// FlowMetrics represents aggregated flow metrics
-type FlowMetrics struct {
-	SourceID        string    `json:"source_id"`
-	DestID          string    `json:"dest_id"`
-	BytesPerSec     float64   `json:"bytes_per_sec"`
-	PacketsPerSec   float64   `json:"packets_per_sec"`
-	ConnectionCount int64     `json:"connection_count"`
-	ErrorRate       float64   `json:"error_rate"`
-	AvgLatency      float64   `json:"avg_latency_ms,omitempty"`
-	Protocol        Protocol  `json:"protocol"`
-	LastSeen        time.Time `json:"last_seen"`
-}
+// Hubble collector settings
+const (
+	hubbleDetectTimeout = 5 * time.Second  // for the ServerStatus call that detects Hubble Relay
+	hubbleBackoffMin    = 1 * time.Second  // before reopening a broken flow stream
+	hubbleBackoffMax    = 30 * time.Second // backoff doubles up to this while the stream keeps failing
+	hubbleMaxFlows      = 10000
+	hubbleMetricWindow  = 60 * time.Second
+)
 
-// FlowCollector collects network flows from Cilium Hubble or eBPF
+// FlowCollector collects network flows from Cilium Hubble Relay. It follows
+// the relay's flow stream and reopens it with backoff when it breaks; flows
+// sent while the stream is down are not replayed.
 type FlowCollector struct {
 	hubbleAddr   string
+	dialOptions  []grpc.DialOption
 	conn         *grpc.ClientConn
 	client       observer.ObserverClient
-	
+
 	mu           sync.RWMutex
-	flows        []NetworkFlow
-	flowMetrics  map[string]*FlowMetrics // key: source_id->dest_id
-	
+	flows        []*Flow
 	maxFlows     int
 	metricWindow time.Duration
-	
+
+	// Stream state for GetStats
+	connected    bool
+	received     int64
+	restarts     int
+	lastError    string
+
 	// Callbacks for real-time updates
-	onFlowCallback func(flow NetworkFlow)
+	onFlowCallback func(flow *Flow)
+
+	ctx    context.Context
+	cancel context.CancelFunc
 }
 
-// NewFlowCollector creates a new flow collector
-func NewFlowCollector(hubbleAddr string) *FlowCollector {
+// NewFlowCollector creates a Hubble flow collector for the relay at
+// hubbleAddr. Dial options are applied after the default plaintext transport,
+// so they can add TLS or dial an in-process server.
+func NewFlowCollector(hubbleAddr string, dialOptions ...grpc.DialOption) *FlowCollector {
+	ctx, cancel := context.WithCancel(context.Background())
+
 	return &FlowCollector{
 		hubbleAddr:   hubbleAddr,
-		flows:        make([]NetworkFlow, 0),
-		flowMetrics:  make(map[string]*FlowMetrics),
-		maxFlows:     10000, // Keep last 10k flows
-		metricWindow: 60 * time.Second,
+		dialOptions:  append([]grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())}, dialOptions...),
+		flows:        make([]*Flow, 0),
+		maxFlows:     hubbleMaxFlows,
+		metricWindow: hubbleMetricWindow,
+		ctx:          ctx,
+		cancel:       cancel,
 	}
 }
 
 // SetOnFlowCallback sets a callback for real-time flow notifications
-func (fc *FlowCollector) SetOnFlowCallback(callback func(flow NetworkFlow)) {
+func (fc *FlowCollector) SetOnFlowCallback(callback func(flow *Flow)) {
 	fc.mu.Lock()
 	defer fc.mu.Unlock()
 	fc.onFlowCallback = callback
 }
 
-// Connect establishes connection to Hubble relay
+// Connect dials Hubble Relay and asks for its status, which fails unless a
+// Hubble observer answers at the address
 func (fc *FlowCollector) Connect(ctx context.Context) error {
 	log.Printf("Connecting to Hubble at %s...", fc.hubbleAddr)
-	
-	conn, err := grpc.DialContext(
-		ctx,
-		fc.hubbleAddr,
-		grpc.WithTransportCredentials(insecure.NewCredentials()),
-		grpc.WithBlock(),
-	)
+
+	conn, err := grpc.DialContext(ctx, fc.hubbleAddr, fc.dialOptions...)
 	if err != nil {
 		return fmt.Errorf("failed to connect to Hubble: %w", err)
 	}
-	
+	client := observer.NewObserverClient(conn)
+	if _, err := client.ServerStatus(ctx, &observer.ServerStatusRequest{}); err != nil {
+		conn.Close()
+		return fmt.Errorf("no Hubble observer at %s: %w", fc.hubbleAddr, err)
+	}
+
 	fc.conn = conn
-	fc.client = observer.NewObserverClient(conn)
-	
+	fc.client = client
+
 	log.Printf("Successfully connected to Hubble")
 	return nil
 }
 
-// Start begins collecting flows
-func (fc *FlowCollector) Start(ctx context.Context) error {
+// Start begins following the flow stream in the background
+func (fc *FlowCollector) Start() error {
 	if fc.client == nil {
 		return fmt.Errorf("not connected to Hubble - call Connect() first")
 	}
-	
-	// Create flow request - watch all flows
-	req := &observer.GetFlowsRequest{
-		Follow: true, // Stream flows in real-time
-		Whitelist: []*observer.FlowFilter{
-			{}, // Empty filter = all flows
-		},
-	}
-	
-	log.Println("Starting flow collection...")
-	stream, err := fc.client.GetFlows(ctx, req)
-	if err != nil {
-		return fmt.Errorf("failed to get flows: %w", err)
+
+	log.Println("Starting Hubble flow collection...")
+	go fc.run()
+	return nil
+}
+
+// Stop halts flow collection and closes the Hubble connection
+func (fc *FlowCollector) Stop() {
+	log.Println("Stopping Hubble flow collector...")
+	fc.cancel()
+	if fc.conn != nil {
+		fc.conn.Close()
 	}
-	
-	// Start metric aggregation goroutine
-	go fc.aggregateMetrics(ctx)
-	
-	// Process flows
+}
+
+// run follows the flow stream until the collector stops, reopening it after
+// a growing delay each time it breaks. The delay starts over once a reopened
+// stream delivers flows.
+func (fc *FlowCollector) run() {
+	backoff := hubbleBackoffMin
 	for {
+		received, err := fc.follow()
+		if fc.ctx.Err() != nil {
+			log.Println("Flow collection stopped")
+			return
+		}
+		if received {
+			backoff = hubbleBackoffMin
+		}
+
+		fc.mu.Lock()
+		fc.connected = false
+		fc.restarts++
+		fc.lastError = err.Error()
+		fc.mu.Unlock()
+		log.Printf("Hubble flow stream from %s broke: %v; reopening in %v", fc.hubbleAddr, err, backoff)
+
 		select {
-		case <-ctx.Done():
+		case <-time.After(backoff):
+		case <-fc.ctx.Done():
 			log.Println("Flow collection stopped")
-			return ctx.Err()
-		default:
+			return
 		}
-		
+		backoff *= 2
+		if backoff > hubbleBackoffMax {
+			backoff = hubbleBackoffMax
+		}
+	}
+}
+
+// follow opens the flow stream and collects from it until it breaks,
+// reporting whether any flows arrived
+func (fc *FlowCollector) follow() (bool, error) {
+	// Following without a starting point streams new flows only
+	stream, err := fc.client.GetFlows(fc.ctx, &observer.GetFlowsRequest{Follow: true})
+	if err != nil {
+		return false, fmt.Errorf("failed to get flows: %w", err)
+	}
+
+	fc.mu.Lock()
+	fc.connected = true
+	fc.mu.Unlock()
+
+	received := false
+	for {
 		response, err := stream.Recv()
 		if err != nil {
-			log.Printf("Error receiving flow: %v", err)
-			return err
+			return received, err
 		}
-		
+
 		flow := fc.parseHubbleFlow(response)
-		if flow != nil {
-			fc.addFlow(*flow)
-			
-			// Trigger callback if set
-			fc.mu.RLock()
-			callback := fc.onFlowCallback
-			fc.mu.RUnlock()
-			
-			if callback != nil {
-				go callback(*flow)
-			}
+		if flow == nil {
+			continue // node status and lost-event notices carry no flow
 		}
+		received = true
+		fc.addFlow(flow)
 	}
 }
 
-// parseHubbleFlow converts Hubble flow to our NetworkFlow structure
-func (fc *FlowCollector) parseHubbleFlow(response *observer.GetFlowsResponse) *NetworkFlow {
+// parseHubbleFlow converts a Hubble flow to the common Flow structure
+func (fc *FlowCollector) parseHubbleFlow(response *observer.GetFlowsResponse) *Flow {
 	hubbleFlow := response.GetFlow()
 	if hubbleFlow == nil {
 		return nil
 	}
-	
-	flow := &NetworkFlow{
-		ID:        fmt.Sprintf("%d", hubbleFlow.GetTime().GetSeconds()),
-		Timestamp: time.Unix(hubbleFlow.GetTime().GetSeconds(), int64(hubbleFlow.GetTime().GetNanos())),
-		Verdict:   hubbleFlow.GetVerdict().String(),
+
+	flow := &Flow{
+		Timestamp:   time.Unix(hubbleFlow.GetTime().GetSeconds(), int64(hubbleFlow.GetTime().GetNanos())),
+		FlowType:    string(FlowTypeL3L4),
+		Verdict:     hubbleFlow.GetVerdict().String(),
+		Protocol:    string(ProtocolUnknown),
+		PacketsSent: 1, // each Hubble flow is one observed packet or L7 event; it carries no byte count
 	}
-	
+
 	// IP addresses
 	if ip := hubbleFlow.GetIP(); ip != nil {
 		flow.SourceIP = ip.GetSource()
 		flow.DestIP = ip.GetDestination()
 	}
-	
-	// Source endpoint information
+
+	// Source and destination pods
 	if src := hubbleFlow.GetSource(); src != nil {
 		flow.SourceNamespace = src.GetNamespace()
-		if len(src.GetPodName()) > 0 {
-			flow.SourcePod = fmt.Sprintf("%s/%s", src.GetNamespace(), src.GetPodName())
-		}
+		flow.SourcePod = src.GetPodName()
 	}
-	
-	// Destination endpoint information
 	if dst := hubbleFlow.GetDestination(); dst != nil {
 		flow.DestNamespace = dst.GetNamespace()
-		if len(dst.GetPodName()) > 0 {
-			flow.DestPod = fmt.Sprintf("%s/%s", dst.GetNamespace(), dst.GetPodName())
-		}
+		flow.DestPod = dst.GetPodName()
 	}
-	
+
 	// L4 protocol and ports
 	if l4 := hubbleFlow.GetL4(); l4 != nil {
 		if tcp := l4.GetTCP(); tcp != nil {
-			flow.Protocol = ProtocolTCP
-			flow.SourcePort = tcp.GetSourcePort()
-			flow.DestPort = tcp.GetDestinationPort()
+			flow.Protocol = string(ProtocolTCP)
+			flow.SourcePort = int(tcp.GetSourcePort())
+			flow.DestPort = int(tcp.GetDestinationPort())
 		} else if udp := l4.GetUDP(); udp != nil {
-			flow.Protocol = ProtocolUDP
-			flow.SourcePort = udp.GetSourcePort()
-			flow.DestPort = udp.GetDestinationPort()
-		} else if icmp := l4.GetICMPv4(); icmp != nil {
-			flow.Protocol = ProtocolICMP
+			flow.Protocol = string(ProtocolUDP)
+			flow.SourcePort = int(udp.GetSourcePort())
+			flow.DestPort = int(udp.GetDestinationPort())
+		} else if l4.GetICMPv4() != nil || l4.GetICMPv6() != nil {
+			flow.Protocol = string(ProtocolICMP)
 		}
 	}
-	
+
 	// L7 protocol
 	if l7 := hubbleFlow.GetL7(); l7 != nil {
-		flow.FlowType = FlowTypeL7
-		flow.L7Details = make(map[string]string)
-		
-		if http := l7.GetHttp(); http != nil {
+		flow.FlowType = string(FlowTypeL7)
+		if l7.GetHttp() != nil {
 			if flow.DestPort == 443 {
 				flow.L7Protocol = string(ProtocolHTTPS)
 			} else {
 				flow.L7Protocol = string(ProtocolHTTP)
 			}
-			flow.L7Details["method"] = http.GetMethod()
-			flow.L7Details["url"] = http.GetUrl()
-			flow.L7Details["code"] = fmt.Sprintf("%d", http.GetCode())
-		} else if dns := l7.GetDns(); dns != nil {
+		} else if l7.GetDns() != nil {
 			flow.L7Protocol = string(ProtocolDNS)
-			flow.L7Details["query"] = dns.GetQuery()
 		}
-	} else if hubbleFlow.GetIsReply() != nil {
-		flow.FlowType = FlowTypeL3L4
 	}
-	
-	// Flow direction
-	flow.IsReply = hubbleFlow.GetIsReply() != nil && hubbleFlow.GetIsReply().GetValue()
-	if hubbleFlow.GetTrafficDirection().String() == "INGRESS" {
+
+	// Flow direction, relative to the endpoint the flow was observed at
+	flow.IsReply = hubbleFlow.GetIsReply().GetValue()
+	if hubbleFlow.GetTrafficDirection() == flowpb.TrafficDirection_INGRESS {
 		flow.Direction = "ingress"
 	} else {
 		flow.Direction = "egress"
 	}
-	
+
 	// Drop information
-	if hubbleFlow.GetDropReason() != 0 {
-		flow.FlowType = FlowTypeDrop
-		flow.DropReason = hubbleFlow.GetDropReasonDesc().String()
-		if flow.DropReason == "" {
-			flow.DropReason = fmt.Sprintf("Reason code: %d", hubbleFlow.GetDropReason())
+	if hubbleFlow.GetVerdict() == flowpb.Verdict_DROPPED {
+		flow.FlowType = string(FlowTypeDrop)
+		reason := hubbleFlow.GetDropReasonDesc()
+		if reason == flowpb.DropReason_POLICY_DENIED {
+			flow.FlowType = string(FlowTypePolicyDeny)
+		}
+		if reason != flowpb.DropReason_DROP_REASON_UNKNOWN {
+			flow.DropReason = reason.String()
 		}
 	}
-	
-	// Packet/byte counts (if available in summary)
-	if summary := hubbleFlow.GetSummary(); summary != "" {
-		flow.TraceObservation = summary
-	}
-	
-	// Default packet count
-	flow.PacketsSent = 1
-	flow.BytesSent = 0 // Hubble doesn't always provide byte count per flow
-	
+
+	flow.ID = fmt.Sprintf("%s:%d->%s:%d-%s",
+		flow.SourceIP, flow.SourcePort,
+		flow.DestIP, flow.DestPort,
+		flow.Protocol)
+
 	return flow
 }
 
 // addFlow adds a flow to the collection
-func (fc *FlowCollector) addFlow(flow NetworkFlow) {
+func (fc *FlowCollector) addFlow(flow *Flow) {
 	fc.mu.Lock()
-	defer fc.mu.Unlock()
-	
-	// Add to flow list
 	fc.flows = append(fc.flows, flow)
-	
-	// Trim if exceeds max
 	if len(fc.flows) > fc.maxFlows {
 		fc.flows = fc.flows[len(fc.flows)-fc.maxFlows:]
 	}
-	
-	// Update metrics
-	fc.updateMetrics(flow)
-}
+	fc.received++
+	callback := fc.onFlowCallback
+	fc.mu.Unlock()
 
-// updateMetrics updates aggregated metrics for a flow
-func (fc *FlowCollector) updateMetrics(flow NetworkFlow) {
-	// Skip if source or dest is empty
-	if flow.SourcePod == "" || flow.DestPod == "" {
-		return
-	}
-	
-	key := fmt.Sprintf("%s->%s", flow.SourcePod, flow.DestPod)
-	
-	metric, exists := fc.flowMetrics[key]
-	if !exists {
-		metric = &FlowMetrics{
-			SourceID:        flow.SourcePod,
-			DestID:          flow.DestPod,
-			Protocol:        flow.Protocol,
-			ConnectionCount: 0,
-			LastSeen:        flow.Timestamp,
-		}
-		fc.flowMetrics[key] = metric
-	}
-	
-	metric.ConnectionCount++
-	metric.LastSeen = flow.Timestamp
-	
-	// Update error rate
-	if flow.Verdict == "DROPPED" || flow.Verdict == "ERROR" {
-		metric.ErrorRate = (metric.ErrorRate*float64(metric.ConnectionCount-1) + 1.0) / float64(metric.ConnectionCount)
-	} else {
-		metric.ErrorRate = metric.ErrorRate * float64(metric.ConnectionCount-1) / float64(metric.ConnectionCount)
+	if callback != nil {
+		go callback(flow)
 	}
 }
 
-// aggregateMetrics periodically calculates bytes/sec and packets/sec
-func (fc *FlowCollector) aggregateMetrics(ctx context.Context) {
-	ticker := time.NewTicker(5 * time.Second)
-	defer ticker.Stop()
-	
-	for {
-		select {
-		case <-ctx.Done():
-			return
-		case <-ticker.C:
-			fc.calculateRates()
-		}
+// GetFlows returns the most recent flows
+func (fc *FlowCollector) GetFlows(limit int) []*Flow {
+	fc.mu.RLock()
+	defer fc.mu.RUnlock()
+
+	if limit <= 0 || limit > len(fc.flows) {
+		limit = len(fc.flows)
 	}
+
+	flows := make([]*Flow, limit)
+	copy(flows, fc.flows[len(fc.flows)-limit:])
+	return flows
 }
 
-// calculateRates calculates throughput rates
-func (fc *FlowCollector) calculateRates() {
-	fc.mu.Lock()
-	defer fc.mu.Unlock()
-	
-	now := time.Now()
-	windowStart := now.Add(-fc.metricWindow)
-	
-	// Reset metrics
-	for key := range fc.flowMetrics {
-		fc.flowMetrics[key].BytesPerSec = 0
-		fc.flowMetrics[key].PacketsPerSec = 0
+// GetFlowMetrics aggregates the flows of the last minute by pod pair. Rates
+// count observed packets, since Hubble flows carry no byte counts. Only
+// forwarded flows count as traffic; drops show in the pair's error rate, and
+// pairs whose flows were all dropped are left out.
+func (fc *FlowCollector) GetFlowMetrics() map[string]*FlowMetric {
+	return fc.GetFlowMetricsSince(time.Now().Add(-fc.metricWindow))
+}
+
+// GetFlowMetricsSince aggregates the flows seen at or after since by pod pair,
+// averaging rates over that period, or over the flows still kept when they
+// start later
+func (fc *FlowCollector) GetFlowMetricsSince(since time.Time) map[string]*FlowMetric {
+	fc.mu.RLock()
+	defer fc.mu.RUnlock()
+
+	cutoff := since
+	start := since
+	if len(fc.flows) > 0 && fc.flows[0].Timestamp.After(start) {
+		start = fc.flows[0].Timestamp
 	}
-	
-	// Aggregate flows within window
+	seconds := time.Since(start).Seconds()
+	if seconds < 1 {
+		seconds = 1
+	}
+	connections := make(map[string]*Flow)
+	observed := make(map[string]int)
+	dropped := make(map[string]int)
+
 	for _, flow := range fc.flows {
-		if flow.Timestamp.Before(windowStart) {
+		if flow.Timestamp.Before(cutoff) || flow.IsReply || flow.SourcePod == "" || flow.DestPod == "" {
+			continue
+		}
+		key := fmt.Sprintf("%s/%s->%s/%s",
+			flow.SourceNamespace, flow.SourcePod,
+			flow.DestNamespace, flow.DestPod)
+		if flow.Verdict != flowpb.Verdict_FORWARDED.String() {
+			observed[key]++
+			dropped[key]++
 			continue
 		}
-		
-		key := fmt.Sprintf("%s->%s", flow.SourcePod, flow.DestPod)
-		
-		if metric, exists := fc.flowMetrics[key]; exists {
-			metric.BytesPerSec += float64(flow.BytesSent)
-			metric.PacketsPerSec += float64(flow.PacketsSent)
+		// Hubble reports a forwarded packet between two pods on both sides;
+		// count it where it arrives
+		if flow.Direction != "ingress" {
+			continue
 		}
-	}
-	
-	// Normalize by window duration
-	windowSeconds := fc.metricWindow.Seconds()
-	for _, metric := range fc.flowMetrics {
-		metric.BytesPerSec /= windowSeconds
-		metric.PacketsPerSec /= windowSeconds
-	}
-}
+		observed[key]++
 
-// GetFlows returns recent flows
-func (fc *FlowCollector) GetFlows(limit int) []NetworkFlow {
-	fc.mu.RLock()
-	defer fc.mu.RUnlock()
-	
-	if limit <= 0 || limit > len(fc.flows) {
-		limit = len(fc.flows)
+		connection, ok := connections[flow.ID]
+		if !ok {
+			copied := *flow
+			copied.PacketsSent = 0
+			connection = &copied
+			connections[flow.ID] = connection
+		}
+		connection.PacketsSent++
+		connection.PacketsPerSec = float64(connection.PacketsSent) / seconds
+		if flow.Timestamp.After(connection.Timestamp) {
+			connection.Timestamp = flow.Timestamp
+		}
 	}
-	
-	// Return most recent flows
-	start := len(fc.flows) - limit
-	flows := make([]NetworkFlow, limit)
-	copy(flows, fc.flows[start:])
-	
-	return flows
-}
 
-// GetFlowMetrics returns aggregated flow metrics
-func (fc *FlowCollector) GetFlowMetrics() []FlowMetrics {
-	fc.mu.RLock()
-	defer fc.mu.RUnlock()
-	
-	metrics := make([]FlowMetrics, 0, len(fc.flowMetrics))
-	for _, metric := range fc.flowMetrics {
-		metrics = append(metrics, *metric)
+	metrics := make(map[string]*FlowMetric)
+	for _, flow := range connections {
+		key := fmt.Sprintf("%s/%s->%s/%s",
+			flow.SourceNamespace, flow.SourcePod,
+			flow.DestNamespace, flow.DestPod)
+
+		metric, ok := metrics[key]
+		if ok {
+			metric.PacketsPerSec += flow.PacketsPerSec
+			metric.ConnectionCount++
+			if flow.Timestamp.After(metric.LastSeen) {
+				metric.LastSeen = flow.Timestamp
+			}
+		} else {
+			metric = &FlowMetric{
+				SourcePod:       flow.SourcePod,
+				SourceNamespace: flow.SourceNamespace,
+				DestPod:         flow.DestPod,
+				DestNamespace:   flow.DestNamespace,
+				PacketsPerSec:   flow.PacketsPerSec,
+				ConnectionCount: 1,
+				ErrorRate:       float64(dropped[key]) / float64(observed[key]),
+				Protocol:        flow.Protocol,
+				LastSeen:        flow.Timestamp,
+				IsActive:        true,
+			}
+			metrics[key] = metric
+		}
+		addPortMetric(metric, flow)
 	}
-	
+
 	return metrics
 }
 
-// GetFlowsByPod returns flows for a specific pod
-func (fc *FlowCollector) GetFlowsByPod(podID string) []NetworkFlow {
+// GetFlowsByPod returns the flows to or from a pod
+func (fc *FlowCollector) GetFlowsByPod(namespace, name string) []*Flow {
 	fc.mu.RLock()
 	defer fc.mu.RUnlock()
-	
-	flows := make([]NetworkFlow, 0)
+
+	flows := make([]*Flow, 0)
 	for _, flow := range fc.flows {
-		if flow.SourcePod == podID || flow.DestPod == podID {
+		if (flow.SourceNamespace == namespace && flow.SourcePod == name) ||
+			(flow.DestNamespace == namespace && flow.DestPod == name) {
 			flows = append(flows, flow)
 		}
 	}
-	
+
 	return flows
 }
 
-// Close closes the Hubble connection
-func (fc *FlowCollector) Close() error {
-	if fc.conn != nil {
-		return fc.conn.Close()
+// GetStats returns collector statistics
+func (fc *FlowCollector) GetStats() map[string]interface{} {
+	fc.mu.RLock()
+	defer fc.mu.RUnlock()
+
+	return map[string]interface{}{
+		"recent_flows":    len(fc.flows),
+		"flows_received":  fc.received,
+		"hubble_addr":     fc.hubbleAddr,
+		"connected":       fc.connected,
+		"stream_restarts": fc.restarts,
+		"last_error":      fc.lastError,
+		"collector_type":  "cilium (hubble relay)",
+		"cni_agnostic":    false,
 	}
-	return nil
 }
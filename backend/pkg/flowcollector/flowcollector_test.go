@@ -0,0 +1,292 @@
+package flowcollector
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	flowpb "github.com/cilium/cilium/api/v1/flow"
+	observer "github.com/cilium/cilium/api/v1/observer"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// fakeObserver serves one batch of flows per GetFlows call and then ends the
+// stream; once the batches run out, streams stay open until cancelled
+type fakeObserver struct {
+	observer.UnimplementedObserverServer
+
+	mu      sync.Mutex
+	batches [][]*observer.GetFlowsResponse
+	calls   int
+}
+
+func (o *fakeObserver) ServerStatus(context.Context, *observer.ServerStatusRequest) (*observer.ServerStatusResponse, error) {
+	return &observer.ServerStatusResponse{}, nil
+}
+
+func (o *fakeObserver) GetFlows(req *observer.GetFlowsRequest, stream observer.Observer_GetFlowsServer) error {
+	o.mu.Lock()
+	call := o.calls
+	o.calls++
+	o.mu.Unlock()
+
+	if call >= len(o.batches) {
+		<-stream.Context().Done()
+		return nil
+	}
+	for _, response := range o.batches[call] {
+		if err := stream.Send(response); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (o *fakeObserver) callCount() int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.calls
+}
+
+// serveObserver serves an observer over an in-memory connection and returns
+// the dial option that reaches it
+func serveObserver(t *testing.T, srv observer.ObserverServer) grpc.DialOption {
+	t.Helper()
+	listener := bufconn.Listen(1 << 20)
+	server := grpc.NewServer()
+	if srv != nil {
+		observer.RegisterObserverServer(server, srv)
+	}
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+	return grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+		return listener.DialContext(ctx)
+	})
+}
+
+// hubbleFlow builds a forwarded TCP flow between two pods
+func hubbleFlow(at time.Time, sourcePod, destPod string, sourcePort, destPort uint32, direction flowpb.TrafficDirection) *observer.GetFlowsResponse {
+	return &observer.GetFlowsResponse{ResponseTypes: &observer.GetFlowsResponse_Flow{Flow: &flowpb.Flow{
+		Time:             timestamppb.New(at),
+		Verdict:          flowpb.Verdict_FORWARDED,
+		IP:               &flowpb.IP{Source: "10.0.0.1", Destination: "10.0.0.2"},
+		L4:               &flowpb.Layer4{Protocol: &flowpb.Layer4_TCP{TCP: &flowpb.TCP{SourcePort: sourcePort, DestinationPort: destPort}}},
+		Source:           &flowpb.Endpoint{Namespace: "shop", PodName: sourcePod},
+		Destination:      &flowpb.Endpoint{Namespace: "shop", PodName: destPod},
+		TrafficDirection: direction,
+	}}}
+}
+
+func TestParseHubbleFlow(t *testing.T) {
+	at := time.Date(2024, 5, 1, 12, 0, 0, 500, time.UTC)
+	withFlow := func(flow *flowpb.Flow) *observer.GetFlowsResponse {
+		flow.Time = timestamppb.New(at)
+		return &observer.GetFlowsResponse{ResponseTypes: &observer.GetFlowsResponse_Flow{Flow: flow}}
+	}
+
+	tests := []struct {
+		name     string
+		response *observer.GetFlowsResponse
+		want     *Flow // nil when the response carries no flow
+	}{
+		{
+			name:     "tcp ingress",
+			response: hubbleFlow(at, "web", "api", 40000, 8080, flowpb.TrafficDirection_INGRESS),
+			want: &Flow{
+				ID: "10.0.0.1:40000->10.0.0.2:8080-TCP", SourcePod: "web", SourceNamespace: "shop", SourceIP: "10.0.0.1", SourcePort: 40000,
+				DestPod: "api", DestNamespace: "shop", DestIP: "10.0.0.2", DestPort: 8080, Protocol: "TCP",
+				FlowType: "l3_l4", Verdict: "FORWARDED", Direction: "ingress", PacketsSent: 1,
+			},
+		},
+		{
+			name: "udp egress reply",
+			response: withFlow(&flowpb.Flow{
+				Verdict:          flowpb.Verdict_FORWARDED,
+				IP:               &flowpb.IP{Source: "10.0.0.2", Destination: "10.0.0.1"},
+				L4:               &flowpb.Layer4{Protocol: &flowpb.Layer4_UDP{UDP: &flowpb.UDP{SourcePort: 53, DestinationPort: 41000}}},
+				IsReply:          wrapperspb.Bool(true),
+				TrafficDirection: flowpb.TrafficDirection_EGRESS,
+			}),
+			want: &Flow{
+				ID: "10.0.0.2:53->10.0.0.1:41000-UDP", SourceIP: "10.0.0.2", SourcePort: 53, DestIP: "10.0.0.1", DestPort: 41000,
+				Protocol: "UDP", FlowType: "l3_l4", Verdict: "FORWARDED", Direction: "egress", IsReply: true, PacketsSent: 1,
+			},
+		},
+		{
+			name: "icmp",
+			response: withFlow(&flowpb.Flow{
+				Verdict: flowpb.Verdict_FORWARDED,
+				IP:      &flowpb.IP{Source: "10.0.0.1", Destination: "10.0.0.2"},
+				L4:      &flowpb.Layer4{Protocol: &flowpb.Layer4_ICMPv4{ICMPv4: &flowpb.ICMPv4{Type: 8}}},
+			}),
+			want: &Flow{
+				ID: "10.0.0.1:0->10.0.0.2:0-ICMP", SourceIP: "10.0.0.1", DestIP: "10.0.0.2",
+				Protocol: "ICMP", FlowType: "l3_l4", Verdict: "FORWARDED", Direction: "egress", PacketsSent: 1,
+			},
+		},
+		{
+			name: "http on 443",
+			response: withFlow(&flowpb.Flow{
+				Verdict: flowpb.Verdict_FORWARDED,
+				IP:      &flowpb.IP{Source: "10.0.0.1", Destination: "10.0.0.2"},
+				L4:      &flowpb.Layer4{Protocol: &flowpb.Layer4_TCP{TCP: &flowpb.TCP{SourcePort: 40000, DestinationPort: 443}}},
+				L7:      &flowpb.Layer7{Record: &flowpb.Layer7_Http{Http: &flowpb.HTTP{Code: 200}}},
+			}),
+			want: &Flow{
+				ID: "10.0.0.1:40000->10.0.0.2:443-TCP", SourceIP: "10.0.0.1", SourcePort: 40000, DestIP: "10.0.0.2", DestPort: 443,
+				Protocol: "TCP", FlowType: "l7", L7Protocol: "HTTPS", Verdict: "FORWARDED", Direction: "egress", PacketsSent: 1,
+			},
+		},
+		{
+			name: "dns",
+			response: withFlow(&flowpb.Flow{
+				Verdict: flowpb.Verdict_FORWARDED,
+				IP:      &flowpb.IP{Source: "10.0.0.1", Destination: "10.0.0.10"},
+				L4:      &flowpb.Layer4{Protocol: &flowpb.Layer4_UDP{UDP: &flowpb.UDP{SourcePort: 41000, DestinationPort: 53}}},
+				L7:      &flowpb.Layer7{Record: &flowpb.Layer7_Dns{Dns: &flowpb.DNS{Query: "api.shop.svc.cluster.local."}}},
+			}),
+			want: &Flow{
+				ID: "10.0.0.1:41000->10.0.0.10:53-UDP", SourceIP: "10.0.0.1", SourcePort: 41000, DestIP: "10.0.0.10", DestPort: 53,
+				Protocol: "UDP", FlowType: "l7", L7Protocol: "DNS", Verdict: "FORWARDED", Direction: "egress", PacketsSent: 1,
+			},
+		},
+		{
+			name: "policy drop",
+			response: withFlow(&flowpb.Flow{
+				Verdict:          flowpb.Verdict_DROPPED,
+				DropReasonDesc:   flowpb.DropReason_POLICY_DENIED,
+				IP:               &flowpb.IP{Source: "10.0.0.1", Destination: "10.0.0.2"},
+				L4:               &flowpb.Layer4{Protocol: &flowpb.Layer4_TCP{TCP: &flowpb.TCP{SourcePort: 40000, DestinationPort: 5432}}},
+				TrafficDirection: flowpb.TrafficDirection_INGRESS,
+			}),
+			want: &Flow{
+				ID: "10.0.0.1:40000->10.0.0.2:5432-TCP", SourceIP: "10.0.0.1", SourcePort: 40000, DestIP: "10.0.0.2", DestPort: 5432,
+				Protocol: "TCP", FlowType: "policy_deny", Verdict: "DROPPED", DropReason: "POLICY_DENIED", Direction: "ingress", PacketsSent: 1,
+			},
+		},
+		{
+			name: "other drop",
+			response: withFlow(&flowpb.Flow{
+				Verdict:        flowpb.Verdict_DROPPED,
+				DropReasonDesc: flowpb.DropReason_CT_NO_MAP_FOUND,
+			}),
+			want: &Flow{
+				ID: ":0->:0-UNKNOWN", Protocol: "UNKNOWN", FlowType: "drop", Verdict: "DROPPED", DropReason: "CT_NO_MAP_FOUND", Direction: "egress", PacketsSent: 1,
+			},
+		},
+		{
+			name:     "no flow",
+			response: &observer.GetFlowsResponse{},
+		},
+	}
+
+	fc := NewFlowCollector("unused")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := fc.parseHubbleFlow(tt.response)
+			if tt.want == nil {
+				if got != nil {
+					t.Fatalf("parseHubbleFlow = %+v, want nil", got)
+				}
+				return
+			}
+			if got == nil {
+				t.Fatalf("parseHubbleFlow = nil, want %+v", tt.want)
+			}
+			tt.want.Timestamp = at
+			if !got.Timestamp.Equal(at) {
+				t.Errorf("Timestamp = %v, want %v", got.Timestamp, at)
+			}
+			got.Timestamp = at
+			if *got != *tt.want {
+				t.Errorf("parseHubbleFlow =\n%+v\nwant\n%+v", *got, *tt.want)
+			}
+		})
+	}
+}
+
+func TestCollectorFactoryDetectsHubble(t *testing.T) {
+	tests := []struct {
+		name     string
+		addr     string
+		server   observer.ObserverServer
+		wantType CollectorType
+	}{
+		{name: "hubble relay answers", addr: "hubble-relay:80", server: &fakeObserver{}, wantType: CollectorTypeCilium},
+		{name: "no observer service", addr: "hubble-relay:80", wantType: CollectorTypeUniversal},
+		{name: "no address", wantType: CollectorTypeUniversal},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			factory := NewCollectorFactory(context.Background())
+			factory.SetHubbleAddr(tt.addr, serveObserver(t, tt.server))
+
+			collector, collectorType, err := factory.CreateCollector()
+			if err != nil {
+				t.Fatalf("CreateCollector: %v", err)
+			}
+			defer collector.Stop()
+			if collectorType != tt.wantType {
+				t.Errorf("collector type = %s, want %s", collectorType, tt.wantType)
+			}
+		})
+	}
+}
+
+func TestFlowCollectorReopensBrokenStream(t *testing.T) {
+	now := time.Now()
+	fake := &fakeObserver{batches: [][]*observer.GetFlowsResponse{
+		{
+			hubbleFlow(now, "web", "api", 40000, 8080, flowpb.TrafficDirection_INGRESS),
+			hubbleFlow(now, "web", "api", 40000, 8080, flowpb.TrafficDirection_INGRESS),
+		},
+		{
+			hubbleFlow(now, "web", "api", 40001, 8080, flowpb.TrafficDirection_INGRESS),
+		},
+	}}
+
+	fc := NewFlowCollector("hubble-relay:80", serveObserver(t, fake))
+	ctx, cancel := context.WithTimeout(context.Background(), hubbleDetectTimeout)
+	defer cancel()
+	if err := fc.Connect(ctx); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	if err := fc.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	defer fc.Stop()
+
+	// The first stream ends after its flows; the collector reopens it after
+	// hubbleBackoffMin and follows the second one
+	start := time.Now()
+	deadline := start.Add(5 * time.Second)
+	for len(fc.GetFlows(0)) < 3 || fake.callCount() < 3 {
+		if time.Now().After(deadline) {
+			t.Fatalf("got %d flows over %d streams, want 3 flows and a third, open stream", len(fc.GetFlows(0)), fake.callCount())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if elapsed := time.Since(start); elapsed < 2*hubbleBackoffMin {
+		t.Errorf("reopened both streams in %v, want a %v backoff before each", elapsed, hubbleBackoffMin)
+	}
+
+	stats := fc.GetStats()
+	if stats["stream_restarts"] != 2 || stats["connected"] != true || stats["flows_received"] != int64(3) {
+		t.Errorf("stats = %v, want 2 restarts, connected and 3 flows received", stats)
+	}
+
+	metrics := fc.GetFlowMetrics()
+	metric, ok := metrics["shop/web->shop/api"]
+	if !ok {
+		t.Fatalf("metrics = %v, want shop/web->shop/api", metrics)
+	}
+	if metric.ConnectionCount != 2 || metric.ErrorRate != 0 || len(metric.Ports) != 1 || metric.Ports[0].Port != 8080 {
+		t.Errorf("metric = %+v, want 2 connections to port 8080 and no errors", metric)
+	}
+}
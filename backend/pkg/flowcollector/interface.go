@@ -3,7 +3,10 @@ package flowcollector
 import (
 	"context"
 	"fmt"
+	"log"
 	"time"
+
+	"google.golang.org/grpc"
 )
 
 // FlowCollectorInterface defines the common interface for all flow collectors
@@ -21,49 +24,81 @@ type FlowCollectorInterface interface {
 	// GetFlowMetrics returns aggregated flow metrics by pod pairs
 	GetFlowMetrics() map[string]*FlowMetric
 
+	// GetFlowMetricsSince aggregates only the flows seen at or after since,
+	// so older connections of a pair do not show in its ports and rates
+	GetFlowMetricsSince(since time.Time) map[string]*FlowMetric
+
 	// GetStats returns collector statistics
 	GetStats() map[string]interface{}
 }
 
 // Flow represents a network flow (common structure for all collectors)
 type Flow struct {
-	ID              string    `json:"id"`
-	SourcePod       string    `json:"source_pod"`
-	SourceIP        string    `json:"source_ip"`
-	SourcePort      int       `json:"source_port"`
-	SourceNamespace string    `json:"source_namespace"`
-	DestPod         string    `json:"dest_pod"`
-	DestIP          string    `json:"dest_ip"`
-	DestPort        int       `json:"dest_port"`
-	DestNamespace   string    `json:"dest_namespace"`
-	Protocol        string    `json:"protocol"`
-	FlowType        string    `json:"flow_type"`
-	BytesSent       int64     `json:"bytes_sent"`
-	PacketsSent     int64     `json:"packets_sent"`
-	BytesPerSec     float64   `json:"bytes_per_sec"`
-	PacketsPerSec   float64   `json:"packets_per_sec"`
-	Direction       string    `json:"direction"`
-	IsReply         bool      `json:"is_reply"`
-	Verdict         string    `json:"verdict"`
-	DropReason      string    `json:"drop_reason,omitempty"`
-	L7Protocol      string    `json:"l7_protocol,omitempty"`
-	Timestamp       time.Time `json:"timestamp"`
+	ID              string `json:"id"`
+	SourcePod       string `json:"source_pod"`
+	SourceIP        string `json:"source_ip"`
+	SourcePort      int    `json:"source_port"`
+	SourceNamespace string `json:"source_namespace"`
+	DestPod         string `json:"dest_pod"`
+	DestIP          string `json:"dest_ip"`
+	DestPort        int    `json:"dest_port"`
+	DestNamespace   string `json:"dest_namespace"`
+	// Conntrack's reply tuple, which differs from the original under NAT
+	ReplySourceIP   string  `json:"reply_source_ip,omitempty"`
+	ReplySourcePort int     `json:"reply_source_port,omitempty"`
+	ReplyDestIP     string  `json:"reply_dest_ip,omitempty"`
+	ReplyDestPort   int     `json:"reply_dest_port,omitempty"`
+	Protocol        string  `json:"protocol"`
+	State           string  `json:"state,omitempty"`       // TCP state from conntrack
+	TimeoutSec      int     `json:"timeout_sec,omitempty"` // until conntrack expires the entry
+	FlowType        string  `json:"flow_type"`
+	BytesSent       int64   `json:"bytes_sent"`
+	PacketsSent     int64   `json:"packets_sent"`
+	BytesReceived   int64   `json:"bytes_received,omitempty"`
+	PacketsReceived int64   `json:"packets_received,omitempty"`
+	BytesPerSec     float64 `json:"bytes_per_sec"`
+	PacketsPerSec   float64 `json:"packets_per_sec"`
+	// Rates of the reply direction, from conntrack's reply counters
+	BytesReceivedPerSec   float64   `json:"bytes_received_per_sec,omitempty"`
+	PacketsReceivedPerSec float64   `json:"packets_received_per_sec,omitempty"`
+	Direction             string    `json:"direction"`
+	IsReply               bool      `json:"is_reply"`
+	Verdict               string    `json:"verdict"`
+	DropReason            string    `json:"drop_reason,omitempty"`
+	L7Protocol            string    `json:"l7_protocol,omitempty"`
+	Timestamp             time.Time `json:"timestamp"`
 }
 
-// FlowMetric represents aggregated flow metrics between pod pairs
+// FlowMetric represents aggregated flow metrics between pod pairs. The rates
+// are of the original direction, source to destination; the received rates
+// are of the replies, where the collector sees them.
 type FlowMetric struct {
-	SourcePod       string    `json:"source_pod"`
-	SourceNamespace string    `json:"source_namespace"`
-	DestPod         string    `json:"dest_pod"`
-	DestNamespace   string    `json:"dest_namespace"`
-	BytesPerSec     float64   `json:"bytes_per_sec"`
-	PacketsPerSec   float64   `json:"packets_per_sec"`
-	ConnectionCount int       `json:"connection_count"`
-	ErrorRate       float64   `json:"error_rate"`
-	Protocol        string    `json:"protocol"`
-	LastSeen        time.Time `json:"last_seen"`
-	IsActive        bool      `json:"is_active"`
-	Direction       string    `json:"direction"`
+	SourcePod             string       `json:"source_pod"`
+	SourceNamespace       string       `json:"source_namespace"`
+	DestPod               string       `json:"dest_pod"`
+	DestNamespace         string       `json:"dest_namespace"`
+	BytesPerSec           float64      `json:"bytes_per_sec"`
+	PacketsPerSec         float64      `json:"packets_per_sec"`
+	BytesReceivedPerSec   float64      `json:"bytes_received_per_sec,omitempty"`
+	PacketsReceivedPerSec float64      `json:"packets_received_per_sec,omitempty"`
+	ConnectionCount       int          `json:"connection_count"`
+	ErrorRate             float64      `json:"error_rate"`
+	Protocol              string       `json:"protocol"`
+	LastSeen              time.Time    `json:"last_seen"`
+	IsActive              bool         `json:"is_active"`
+	Direction             string       `json:"direction"`
+	Ports                 []PortMetric `json:"ports,omitempty"`
+}
+
+// PortMetric breaks a pod pair's traffic down by destination port
+type PortMetric struct {
+	Port                  int     `json:"port"`
+	Protocol              string  `json:"protocol"`
+	BytesPerSec           float64 `json:"bytes_per_sec"`
+	PacketsPerSec         float64 `json:"packets_per_sec"`
+	BytesReceivedPerSec   float64 `json:"bytes_received_per_sec,omitempty"`
+	PacketsReceivedPerSec float64 `json:"packets_received_per_sec,omitempty"`
+	ConnectionCount       int     `json:"connection_count"`
 }
 
 // CollectorType represents the type of flow collector
@@ -78,7 +113,9 @@ const (
 
 // CollectorFactory creates the appropriate flow collector based on environment
 type CollectorFactory struct {
-	ctx context.Context
+	ctx               context.Context
+	hubbleAddr        string
+	hubbleDialOptions []grpc.DialOption
 }
 
 // NewCollectorFactory creates a new collector factory
@@ -86,13 +123,22 @@ func NewCollectorFactory(ctx context.Context) *CollectorFactory {
 	return &CollectorFactory{ctx: ctx}
 }
 
+// SetHubbleAddr sets the Hubble Relay address probed for the Cilium
+// collector; empty skips it. Dial options are passed on to NewFlowCollector.
+func (f *CollectorFactory) SetHubbleAddr(addr string, dialOptions ...grpc.DialOption) {
+	f.hubbleAddr = addr
+	f.hubbleDialOptions = dialOptions
+}
+
 // CreateCollector auto-detects and creates the best available flow collector
 // Priority: Universal (always works) -> Enhanced (if available)
 func (f *CollectorFactory) CreateCollector() (FlowCollectorInterface, CollectorType, error) {
 	// Try to detect enhanced collectors first
-	if collector, err := f.tryCreateCiliumCollector(); err == nil {
+	collector, err := f.tryCreateCiliumCollector()
+	if err == nil {
 		return collector, CollectorTypeCilium, nil
 	}
+	log.Printf("Cilium Hubble not used: %v", err)
 
 	if collector, err := f.tryCreateIstioCollector(); err == nil {
 		return collector, CollectorTypeIstio, nil
@@ -103,7 +149,7 @@ func (f *CollectorFactory) CreateCollector() (FlowCollectorInterface, CollectorT
 	}
 
 	// Fall back to universal collector (ALWAYS works)
-	collector := NewUniversalFlowCollector(UniversalFlowCollectorConfig{
+	collector = NewUniversalFlowCollector(UniversalFlowCollectorConfig{
 		MaxRecentFlows: 10000,
 		UpdateInterval: 5 * time.Second,
 	})
@@ -111,15 +157,20 @@ func (f *CollectorFactory) CreateCollector() (FlowCollectorInterface, CollectorT
 	return collector, CollectorTypeUniversal, nil
 }
 
-// tryCreateCiliumCollector attempts to create a Cilium Hubble collector
+// tryCreateCiliumCollector attempts to create a Cilium Hubble collector by
+// connecting to Hubble Relay
 func (f *CollectorFactory) tryCreateCiliumCollector() (FlowCollectorInterface, error) {
-	// Check if Hubble is available
-	// Try to connect to hubble-relay.kube-system.svc.cluster.local:80
-	// If connection fails, return error
-	
-	// TODO: Implement Cilium detection
-	// For now, return error to fall back to universal
-	return nil, fmt.Errorf("Cilium Hubble not detected")
+	if f.hubbleAddr == "" {
+		return nil, fmt.Errorf("no Hubble Relay address")
+	}
+
+	ctx, cancel := context.WithTimeout(f.ctx, hubbleDetectTimeout)
+	defer cancel()
+	collector := NewFlowCollector(f.hubbleAddr, f.hubbleDialOptions...)
+	if err := collector.Connect(ctx); err != nil {
+		return nil, err
+	}
+	return collector, nil
 }
 
 // tryCreateIstioCollector attempts to create an Istio metrics collector
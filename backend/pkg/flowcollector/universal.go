@@ -2,11 +2,11 @@ package flowcollector
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"net"
 	"os/exec"
-	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -21,6 +21,7 @@ type UniversalFlowCollector struct {
 	maxRecentFlows  int
 	podIPCache      map[string]PodInfo // IP -> Pod info mapping
 	updateInterval  time.Duration
+	conntrack       ConntrackSource
 	ctx             context.Context
 	cancel          context.CancelFunc
 }
@@ -36,7 +37,8 @@ type PodInfo struct {
 type UniversalFlowCollectorConfig struct {
 	MaxRecentFlows int
 	UpdateInterval time.Duration
-	K8sClient      interface{} // Optional: K8s client for pod IP resolution
+	K8sClient      interface{}     // Optional: K8s client for pod IP resolution
+	Conntrack      ConntrackSource // Optional: defaults to netlink, or the conntrack tool where netlink is denied
 }
 
 // NewUniversalFlowCollector creates a CNI-agnostic flow collector
@@ -57,6 +59,7 @@ func NewUniversalFlowCollector(config UniversalFlowCollectorConfig) *UniversalFl
 		maxRecentFlows: config.MaxRecentFlows,
 		podIPCache:     make(map[string]PodInfo),
 		updateInterval: config.UpdateInterval,
+		conntrack:      config.Conntrack,
 		ctx:            ctx,
 		cancel:         cancel,
 	}
@@ -71,14 +74,20 @@ func (c *UniversalFlowCollector) Start() error {
 		return fmt.Errorf("missing required capabilities: %w", err)
 	}
 
-	log.Println("✓ Universal flow collection using kernel conntrack")
+	c.mu.Lock()
+	if c.conntrack == nil {
+		c.conntrack = NewConntrackSource()
+	}
+	c.mu.Unlock()
+
+	log.Printf("✓ Universal flow collection using kernel conntrack (%s)", c.conntrack.Name())
 	log.Println("✓ Works with ANY CNI: Cilium, Calico, Flannel, Weave, or none")
 	log.Println("✓ No service mesh required")
 
 	// Start collection goroutines
 	go c.collectConntrackFlows()
+	go c.collectConntrackEvents()
 	go c.collectIptablesStats()
-	go c.aggregateFlows()
 
 	return nil
 }
@@ -91,15 +100,12 @@ func (c *UniversalFlowCollector) Stop() {
 
 // verifyCapabilities checks if we can access kernel networking info
 func (c *UniversalFlowCollector) verifyCapabilities() error {
-	// Check if conntrack is available
-	cmd := exec.Command("conntrack", "-L", "-o", "extended")
-	if err := cmd.Run(); err != nil {
-		log.Printf("Warning: conntrack not available or no permissions: %v", err)
-		log.Println("Tip: Run as privileged pod with NET_ADMIN capability")
-	}
+	// Conntrack is checked when its source is opened: netlink needs
+	// NET_ADMIN, and the conntrack tool or /proc/net/nf_conntrack is the
+	// fallback
 
 	// Check if iptables is available
-	cmd = exec.Command("iptables", "-L", "-n", "-v", "-x")
+	cmd := exec.Command("iptables", "-L", "-n", "-v", "-x")
 	if err := cmd.Run(); err != nil {
 		log.Printf("Warning: iptables not available: %v", err)
 	}
@@ -126,133 +132,88 @@ func (c *UniversalFlowCollector) collectConntrackFlows() {
 	}
 }
 
-// readConntrack parses kernel conntrack table for active connections
+// readConntrack records every connection in the kernel conntrack table and
+// expires the ones that are gone from it
 func (c *UniversalFlowCollector) readConntrack() error {
-	// Execute: conntrack -L -o extended
-	// This shows ALL active connections on the system
-	cmd := exec.Command("conntrack", "-L", "-o", "extended")
-	output, err := cmd.Output()
+	dumped := time.Now()
+	flows, err := c.conntrack.Dump()
 	if err != nil {
-		// Try alternative: read /proc/net/nf_conntrack directly
-		return c.readProcConntrack()
+		return err
 	}
 
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	lines := strings.Split(string(output), "\n")
-	for _, line := range lines {
-		if line == "" {
-			continue
-		}
+	seen := make(map[string]bool, len(flows))
+	for _, flow := range flows {
+		c.recordFlow(flow)
+		seen[flow.ID] = true
+	}
 
-		flow := c.parseConntrackLine(line)
-		if flow != nil {
-			c.flows[flow.ID] = flow
-			c.addRecentFlow(flow)
+	// Flows recorded from events after the dump started may not be in it
+	for id, flow := range c.flows {
+		if !seen[id] && flow.Timestamp.Before(dumped) {
+			delete(c.flows, id)
 		}
 	}
 
 	return nil
 }
 
-// readProcConntrack reads /proc/net/nf_conntrack as fallback
-// This file exists on all Linux systems with conntrack enabled
-func (c *UniversalFlowCollector) readProcConntrack() error {
-	cmd := exec.Command("cat", "/proc/net/nf_conntrack")
-	output, err := cmd.Output()
+// collectConntrackEvents records connections as the kernel starts and stops
+// tracking them, so short-lived ones between two dumps are seen too. Sources
+// without events leave the collector to its periodic dumps.
+func (c *UniversalFlowCollector) collectConntrackEvents() {
+	events, err := c.conntrack.Events(c.ctx)
+	if errors.Is(err, ErrConntrackEventsUnsupported) {
+		return
+	}
 	if err != nil {
-		return fmt.Errorf("cannot read conntrack table: %w", err)
+		log.Printf("Conntrack events unavailable, reading periodic dumps only: %v", err)
+		return
 	}
 
-	c.mu.Lock()
-	defer c.mu.Unlock()
-
-	lines := strings.Split(string(output), "\n")
-	for _, line := range lines {
-		if line == "" {
-			continue
-		}
-
-		flow := c.parseProcConntrackLine(line)
-		if flow != nil {
-			c.flows[flow.ID] = flow
-			c.addRecentFlow(flow)
+	for event := range events {
+		c.mu.Lock()
+		c.recordFlow(event.Flow) // a destroyed connection's final counters
+		if event.Type == ConntrackEventDestroy {
+			delete(c.flows, event.Flow.ID)
 		}
+		c.mu.Unlock()
 	}
-
-	return nil
 }
 
-// parseConntrackLine parses conntrack command output
-// Format: tcp 6 431999 ESTABLISHED src=10.244.0.5 dst=10.244.0.6 sport=45678 dport=8080 ...
-func (c *UniversalFlowCollector) parseConntrackLine(line string) *Flow {
-	fields := strings.Fields(line)
-	if len(fields) < 8 {
-		return nil
-	}
-
-	flow := &Flow{
-		Timestamp: time.Now(),
-	}
-
-	// Parse protocol
-	if len(fields) > 0 {
-		flow.Protocol = strings.ToUpper(fields[0])
-	}
+// recordFlow stores a snapshot of a connection. Conntrack counters are totals
+// over the connection's lifetime, so rates in each direction come from how
+// much they grew since the previous snapshot of the same flow; a connection
+// seen for the first time has no rate yet. Callers hold c.mu.
+func (c *UniversalFlowCollector) recordFlow(flow *Flow) {
+	c.resolveFlowPods(flow)
 
-	// Parse IPs and ports from key=value pairs
-	for _, field := range fields {
-		parts := strings.Split(field, "=")
-		if len(parts) != 2 {
-			continue
+	if previous, ok := c.flows[flow.ID]; ok {
+		elapsed := flow.Timestamp.Sub(previous.Timestamp).Seconds()
+		bytes := flow.BytesSent - previous.BytesSent
+		packets := flow.PacketsSent - previous.PacketsSent
+		bytesReceived := flow.BytesReceived - previous.BytesReceived
+		packetsReceived := flow.PacketsReceived - previous.PacketsReceived
+		if bytes < 0 || packets < 0 || bytesReceived < 0 || packetsReceived < 0 {
+			// The tuple was reused by a new connection
+			bytes, packets = flow.BytesSent, flow.PacketsSent
+			bytesReceived, packetsReceived = flow.BytesReceived, flow.PacketsReceived
 		}
-
-		key, value := parts[0], parts[1]
-		switch key {
-		case "src":
-			flow.SourceIP = value
-		case "dst":
-			flow.DestIP = value
-		case "sport":
-			if port, err := strconv.Atoi(value); err == nil {
-				flow.SourcePort = port
-			}
-		case "dport":
-			if port, err := strconv.Atoi(value); err == nil {
-				flow.DestPort = port
-			}
-		case "bytes":
-			if bytes, err := strconv.ParseInt(value, 10, 64); err == nil {
-				flow.BytesSent = bytes
-			}
-		case "packets":
-			if packets, err := strconv.ParseInt(value, 10, 64); err == nil {
-				flow.PacketsSent = packets
-			}
+		if elapsed > 0 {
+			flow.BytesPerSec = float64(bytes) / elapsed
+			flow.PacketsPerSec = float64(packets) / elapsed
+			flow.BytesReceivedPerSec = float64(bytesReceived) / elapsed
+			flow.PacketsReceivedPerSec = float64(packetsReceived) / elapsed
+		} else {
+			flow.BytesPerSec, flow.PacketsPerSec = previous.BytesPerSec, previous.PacketsPerSec
+			flow.BytesReceivedPerSec, flow.PacketsReceivedPerSec = previous.BytesReceivedPerSec, previous.PacketsReceivedPerSec
 		}
 	}
 
-	// Resolve IPs to pod names
-	c.resolveFlowPods(flow)
-
-	// Generate flow ID
-	flow.ID = fmt.Sprintf("%s:%d->%s:%d-%s",
-		flow.SourceIP, flow.SourcePort,
-		flow.DestIP, flow.DestPort,
-		flow.Protocol)
-
-	flow.Verdict = "ACCEPT" // Conntrack only shows accepted flows
-	flow.IsReply = false
-	flow.Direction = "egress"
-
-	return flow
-}
-
-// parseProcConntrackLine parses /proc/net/nf_conntrack format
-func (c *UniversalFlowCollector) parseProcConntrackLine(line string) *Flow {
-	// Format: ipv4 2 tcp 6 431999 ESTABLISHED src=10.244.0.5 dst=10.244.0.6 sport=45678 dport=8080 ...
-	return c.parseConntrackLine(line) // Same parser works for both
+	c.flows[flow.ID] = flow
+	c.addRecentFlow(flow)
 }
 
 // collectIptablesStats reads iptables packet/byte counters
@@ -306,34 +267,6 @@ func (c *UniversalFlowCollector) readIptablesStats() error {
 	return nil
 }
 
-// aggregateFlows combines data from different sources and calculates rates
-func (c *UniversalFlowCollector) aggregateFlows() {
-	ticker := time.NewTicker(c.updateInterval)
-	defer ticker.Stop()
-
-	lastRun := time.Now()
-
-	for {
-		select {
-		case <-c.ctx.Done():
-			return
-		case <-ticker.C:
-			now := time.Now()
-			elapsed := now.Sub(lastRun).Seconds()
-
-			c.mu.Lock()
-			// Calculate rates (bytes/sec, packets/sec)
-			for _, flow := range c.flows {
-				flow.BytesPerSec = float64(flow.BytesSent) / elapsed
-				flow.PacketsPerSec = float64(flow.PacketsSent) / elapsed
-			}
-			c.mu.Unlock()
-
-			lastRun = now
-		}
-	}
-}
-
 // resolveFlowPods maps IPs to pod names using cached pod information
 func (c *UniversalFlowCollector) resolveFlowPods(flow *Flow) {
 	// Check cache for source IP
@@ -345,10 +278,14 @@ func (c *UniversalFlowCollector) resolveFlowPods(flow *Flow) {
 		flow.SourceNamespace = "unknown"
 	}
 
-	// Check cache for dest IP
+	// Check cache for dest IP, then for the reply source: connections to a
+	// Service are DNATed to a backend pod, which answers from its own IP
 	if podInfo, ok := c.podIPCache[flow.DestIP]; ok {
 		flow.DestPod = podInfo.Name
 		flow.DestNamespace = podInfo.Namespace
+	} else if podInfo, ok := c.podIPCache[flow.ReplySourceIP]; ok {
+		flow.DestPod = podInfo.Name
+		flow.DestNamespace = podInfo.Namespace
 	} else {
 		flow.DestPod = flow.DestIP // Fallback to IP
 		flow.DestNamespace = "unknown"
@@ -378,40 +315,82 @@ func (c *UniversalFlowCollector) GetFlows(limit int) []*Flow {
 
 // GetFlowMetrics aggregates flow data by pod pairs
 func (c *UniversalFlowCollector) GetFlowMetrics() map[string]*FlowMetric {
+	return c.GetFlowMetricsSince(time.Time{})
+}
+
+// GetFlowMetricsSince aggregates the connections last seen at or after since
+// by pod pairs
+func (c *UniversalFlowCollector) GetFlowMetricsSince(since time.Time) map[string]*FlowMetric {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 
 	metrics := make(map[string]*FlowMetric)
 
 	for _, flow := range c.flows {
+		if flow.Timestamp.Before(since) {
+			continue
+		}
 		key := fmt.Sprintf("%s/%s->%s/%s",
 			flow.SourceNamespace, flow.SourcePod,
 			flow.DestNamespace, flow.DestPod)
 
-		if metric, ok := metrics[key]; ok {
+		metric, ok := metrics[key]
+		if ok {
 			metric.BytesPerSec += flow.BytesPerSec
 			metric.PacketsPerSec += flow.PacketsPerSec
+			metric.BytesReceivedPerSec += flow.BytesReceivedPerSec
+			metric.PacketsReceivedPerSec += flow.PacketsReceivedPerSec
 			metric.ConnectionCount++
-			metric.LastSeen = flow.Timestamp
+			if flow.Timestamp.After(metric.LastSeen) {
+				metric.LastSeen = flow.Timestamp
+			}
 		} else {
-			metrics[key] = &FlowMetric{
-				SourcePod:       flow.SourcePod,
-				SourceNamespace: flow.SourceNamespace,
-				DestPod:         flow.DestPod,
-				DestNamespace:   flow.DestNamespace,
-				BytesPerSec:     flow.BytesPerSec,
-				PacketsPerSec:   flow.PacketsPerSec,
-				ConnectionCount: 1,
-				Protocol:        flow.Protocol,
-				LastSeen:        flow.Timestamp,
-				IsActive:        true,
+			metric = &FlowMetric{
+				SourcePod:             flow.SourcePod,
+				SourceNamespace:       flow.SourceNamespace,
+				DestPod:               flow.DestPod,
+				DestNamespace:         flow.DestNamespace,
+				BytesPerSec:           flow.BytesPerSec,
+				PacketsPerSec:         flow.PacketsPerSec,
+				BytesReceivedPerSec:   flow.BytesReceivedPerSec,
+				PacketsReceivedPerSec: flow.PacketsReceivedPerSec,
+				ConnectionCount:       1,
+				Protocol:              flow.Protocol,
+				LastSeen:              flow.Timestamp,
+				IsActive:              true,
 			}
+			metrics[key] = metric
 		}
+		addPortMetric(metric, flow)
 	}
 
 	return metrics
 }
 
+// addPortMetric accumulates a flow into its pod pair's per-port breakdown
+func addPortMetric(metric *FlowMetric, flow *Flow) {
+	for i := range metric.Ports {
+		port := &metric.Ports[i]
+		if port.Port == flow.DestPort && port.Protocol == flow.Protocol {
+			port.BytesPerSec += flow.BytesPerSec
+			port.PacketsPerSec += flow.PacketsPerSec
+			port.BytesReceivedPerSec += flow.BytesReceivedPerSec
+			port.PacketsReceivedPerSec += flow.PacketsReceivedPerSec
+			port.ConnectionCount++
+			return
+		}
+	}
+	metric.Ports = append(metric.Ports, PortMetric{
+		Port:                  flow.DestPort,
+		Protocol:              flow.Protocol,
+		BytesPerSec:           flow.BytesPerSec,
+		PacketsPerSec:         flow.PacketsPerSec,
+		BytesReceivedPerSec:   flow.BytesReceivedPerSec,
+		PacketsReceivedPerSec: flow.PacketsReceivedPerSec,
+		ConnectionCount:       1,
+	})
+}
+
 // addRecentFlow adds a flow to the recent flows list
 func (c *UniversalFlowCollector) addRecentFlow(flow *Flow) {
 	c.recentFlows = append(c.recentFlows, flow)
@@ -425,10 +404,16 @@ func (c *UniversalFlowCollector) GetStats() map[string]interface{} {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 
+	conntrackSource := "not started"
+	if c.conntrack != nil {
+		conntrackSource = c.conntrack.Name()
+	}
+
 	return map[string]interface{}{
 		"active_flows":  len(c.flows),
 		"recent_flows":  len(c.recentFlows),
 		"pod_ip_cache":  len(c.podIPCache),
+		"conntrack_source": conntrackSource,
 		"collector_type": "universal (conntrack + iptables)",
 		"cni_agnostic":  true,
 	}
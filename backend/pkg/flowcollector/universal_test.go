@@ -0,0 +1,100 @@
+package flowcollector
+
+import (
+	"testing"
+	"time"
+)
+
+// conntrackSnapshot is a TCP connection from web to api as one conntrack dump
+// shows it
+func conntrackSnapshot(at time.Time, sent, received int64) *Flow {
+	flow := &Flow{
+		Protocol:        "TCP",
+		SourceIP:        "10.244.0.5",
+		SourcePort:      45678,
+		DestIP:          "10.96.0.20",
+		DestPort:        80,
+		ReplySourceIP:   "10.244.1.7",
+		ReplySourcePort: 8080,
+		ReplyDestIP:     "10.244.0.5",
+		ReplyDestPort:   45678,
+		BytesSent:       sent,
+		PacketsSent:     sent / 100,
+		BytesReceived:   received,
+		PacketsReceived: received / 1000,
+		Timestamp:       at,
+	}
+	finishConntrackFlow(flow)
+	return flow
+}
+
+func TestRecordFlowRates(t *testing.T) {
+	start := time.Now()
+	tests := []struct {
+		name         string
+		snapshots    []*Flow
+		wantSent     float64 // bytes per second
+		wantReceived float64
+	}{
+		{
+			name:      "first snapshot has no rate",
+			snapshots: []*Flow{conntrackSnapshot(start, 1000, 50000)},
+		},
+		{
+			name: "rates from counter growth in each direction",
+			snapshots: []*Flow{
+				conntrackSnapshot(start, 1000, 50000),
+				conntrackSnapshot(start.Add(2*time.Second), 3000, 250000),
+			},
+			wantSent:     1000,
+			wantReceived: 100000,
+		},
+		{
+			name: "reused tuple counts from zero",
+			snapshots: []*Flow{
+				conntrackSnapshot(start, 1000, 50000),
+				conntrackSnapshot(start.Add(2*time.Second), 3000, 250000),
+				conntrackSnapshot(start.Add(4*time.Second), 400, 2000),
+			},
+			wantSent:     200,
+			wantReceived: 1000,
+		},
+		{
+			name: "same snapshot time keeps the previous rate",
+			snapshots: []*Flow{
+				conntrackSnapshot(start, 1000, 50000),
+				conntrackSnapshot(start.Add(2*time.Second), 3000, 250000),
+				conntrackSnapshot(start.Add(2*time.Second), 3000, 250000),
+			},
+			wantSent:     1000,
+			wantReceived: 100000,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewUniversalFlowCollector(UniversalFlowCollectorConfig{})
+			c.podIPCache["10.244.0.5"] = PodInfo{Name: "web", Namespace: "shop"}
+			c.podIPCache["10.244.1.7"] = PodInfo{Name: "api", Namespace: "shop"}
+			for _, snapshot := range tt.snapshots {
+				c.recordFlow(snapshot)
+			}
+
+			flow := tt.snapshots[len(tt.snapshots)-1]
+			if flow.BytesPerSec != tt.wantSent || flow.BytesReceivedPerSec != tt.wantReceived {
+				t.Errorf("sent, received = %v, %v bytes/sec, want %v, %v", flow.BytesPerSec, flow.BytesReceivedPerSec, tt.wantSent, tt.wantReceived)
+			}
+
+			metric, ok := c.GetFlowMetrics()["shop/web->shop/api"]
+			if !ok {
+				t.Fatalf("no metric for shop/web->shop/api in %v", c.GetFlowMetrics())
+			}
+			if metric.BytesPerSec != tt.wantSent || metric.BytesReceivedPerSec != tt.wantReceived {
+				t.Errorf("pair bytes, received = %v, %v per sec, want %v, %v", metric.BytesPerSec, metric.BytesReceivedPerSec, tt.wantSent, tt.wantReceived)
+			}
+			if len(metric.Ports) != 1 || metric.Ports[0].BytesPerSec != tt.wantSent || metric.Ports[0].BytesReceivedPerSec != tt.wantReceived {
+				t.Errorf("ports = %+v, want port 80 at %v bytes/sec, %v received", metric.Ports, tt.wantSent, tt.wantReceived)
+			}
+		})
+	}
+}
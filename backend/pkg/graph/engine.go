@@ -2,6 +2,7 @@ package graph
 
 import (
 	"fmt"
+	"math"
 	"sync"
 	"time"
 
@@ -52,6 +53,7 @@ type GraphEdge struct {
 	Health     HealthStatus      `json:"health"`
 	Latency    int64             `json:"latency_ms,omitempty"`
 	PacketLoss float64           `json:"packet_loss,omitempty"`
+	ProbeStats *ProbeStats       `json:"probe_stats,omitempty"`
 	// Flow metrics
 	FlowData   *FlowData         `json:"flow_data,omitempty"`
 }
@@ -68,6 +70,19 @@ type FlowData struct {
 	Direction       string  `json:"direction"` // bidirectional, ingress, egress
 }
 
+// ProbeStats summarises the connectivity probes along an edge over a window
+type ProbeStats struct {
+	Window       string   `json:"window"`
+	ProbeTypes   []string `json:"probe_types"`
+	Probes       int      `json:"probes"`
+	SuccessRatio float64  `json:"success_ratio"`
+	P50          float64  `json:"p50_ms"`
+	P90          float64  `json:"p90_ms"`
+	P99          float64  `json:"p99_ms"`
+	Jitter       float64  `json:"jitter_ms"`
+	LastSeen     string   `json:"last_seen"`
+}
+
 // HealthStatus represents the health of a node or edge
 type HealthStatus string
 
@@ -346,6 +361,21 @@ func (e *Engine) UpdateNodeHealth(nodeID string, health HealthStatus) {
 	}
 }
 
+// UpdateNodeProperties merges properties into a node
+func (e *Engine) UpdateNodeProperties(nodeID string, properties map[string]string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if node, exists := e.nodes[nodeID]; exists {
+		if node.Properties == nil {
+			node.Properties = make(map[string]string)
+		}
+		for key, value := range properties {
+			node.Properties[key] = value
+		}
+	}
+}
+
 // UpdateEdgeHealth updates the health status of an edge
 func (e *Engine) UpdateEdgeHealth(edgeID string, health HealthStatus, latency int64) {
 	e.mu.Lock()
@@ -388,6 +418,64 @@ func (e *Engine) UpdateEdgeFlowData(sourceID, targetID string, flowData *FlowDat
 	}
 }
 
+// UpdateEdgeProbeStats records probe statistics on a connection edge, creating
+// it if needed. The edge's latency becomes the median and its health follows
+// the success ratio and the 90th percentile.
+func (e *Engine) UpdateEdgeProbeStats(sourceID, targetID string, stats *ProbeStats) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	edgeID := fmt.Sprintf("%s->%s", sourceID, targetID)
+	edge, exists := e.edges[edgeID]
+	if !exists {
+		edge = &GraphEdge{
+			ID:     edgeID,
+			Source: sourceID,
+			Target: targetID,
+			Type:   EdgeTypeConnection,
+		}
+		e.edges[edgeID] = edge
+	}
+
+	edge.ProbeStats = stats
+	edge.Latency = int64(math.Round(stats.P50))
+	switch {
+	case stats.SuccessRatio == 0:
+		edge.Health = HealthFailed
+	case stats.SuccessRatio < 1 || stats.P90 > 100:
+		edge.Health = HealthDegraded
+	default:
+		edge.Health = HealthHealthy
+	}
+}
+
+// UpdateEdgeProperties merges properties into a connection edge, creating it
+// if needed
+func (e *Engine) UpdateEdgeProperties(sourceID, targetID string, properties map[string]string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	edgeID := fmt.Sprintf("%s->%s", sourceID, targetID)
+	edge, exists := e.edges[edgeID]
+	if !exists {
+		edge = &GraphEdge{
+			ID:     edgeID,
+			Source: sourceID,
+			Target: targetID,
+			Type:   EdgeTypeConnection,
+			Health: HealthUnknown,
+		}
+		e.edges[edgeID] = edge
+	}
+
+	if edge.Properties == nil {
+		edge.Properties = make(map[string]string)
+	}
+	for key, value := range properties {
+		edge.Properties[key] = value
+	}
+}
+
 // GetActiveFlows returns edges with active flow data
 func (e *Engine) GetActiveFlows() []GraphEdge {
 	e.mu.RLock()
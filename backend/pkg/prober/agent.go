@@ -0,0 +1,319 @@
+package prober
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Probe agent protocol
+//
+// A probe agent runs on every node and holds two long-lived HTTP streams open
+// to the central server's AgentHub, both newline-delimited JSON:
+//
+//	GET  /assignments?node=NAME  server to agent, one AgentMessage per line
+//	POST /results?node=NAME      agent to server, one ProbeResult per line
+//
+// Both requests carry the AgentProtocolHeader. The server sends heartbeats on
+// the assignment stream so a silent connection is noticed; the agent reconnects
+// both streams whenever either of them ends.
+
+// AgentProtocolVersion is bumped on incompatible protocol changes
+const AgentProtocolVersion = "1"
+
+// AgentProtocolHeader carries the protocol version on agent requests
+const AgentProtocolHeader = "X-Netvis-Agent-Protocol"
+
+// Agent message types sent on the assignment stream
+const (
+	AgentMessageAssignment = "assignment"
+	AgentMessageHeartbeat  = "heartbeat"
+)
+
+const (
+	agentHeartbeatInterval = 15 * time.Second
+	agentIdleTimeout       = 3 * agentHeartbeatInterval // no message for this long drops the session
+	agentWorkers           = 8                          // probes an agent runs concurrently
+)
+
+// agentReconnectDelay is how long an agent waits before reconnecting; tests
+// shorten it
+var agentReconnectDelay = 5 * time.Second
+
+// ProbeAssignment asks an agent to run one probe. Assignments without a source
+// pod probe from the node itself, as node-to-node probes do.
+type ProbeAssignment struct {
+	SourcePod  string     `json:"source_pod,omitempty"`
+	SourceNS   string     `json:"source_namespace,omitempty"`
+	Container  string     `json:"container,omitempty"` // source container for exec mode
+	TargetPod  string     `json:"target_pod,omitempty"`
+	TargetNS   string     `json:"target_namespace,omitempty"`
+	TargetSvc  string     `json:"target_service,omitempty"`
+	TargetNode string     `json:"target_node,omitempty"`
+	TargetIP   string     `json:"target_ip"`
+	TargetPort int32      `json:"target_port"`
+	ProbeType  string     `json:"probe_type"`
+	Spec       *ProbeSpec `json:"spec,omitempty"`  // path, expected status or gRPC service for health probes, payload for UDP
+	Count      int        `json:"count,omitempty"` // echo requests for ICMP probes
+	TimeoutMs  int64      `json:"timeout_ms"`
+	Duration   int64      `json:"duration_ms,omitempty"` // how long throughput tests send
+	LimitMbps  float64    `json:"limit_mbps,omitempty"`  // paces throughput tests
+	RunID      string     `json:"run_id,omitempty"`      // the on-demand run a result belongs to
+}
+
+// AgentMessage is one line of the assignment stream
+type AgentMessage struct {
+	Type       string           `json:"type"`
+	Assignment *ProbeAssignment `json:"assignment,omitempty"`
+}
+
+// Agent runs probe assignments from the central server for pods on its node
+type Agent struct {
+	server string
+	node   string
+	prober *Prober
+	client *http.Client
+}
+
+// NewAgent creates an agent for the given node that talks to the AgentHub at
+// server. Probes are dialed through p, so its exec mode applies.
+func NewAgent(server, node string, p *Prober) *Agent {
+	return &Agent{
+		server: strings.TrimSuffix(server, "/"),
+		node:   node,
+		prober: p,
+		client: &http.Client{},
+	}
+}
+
+// Run serves assignments until the context is cancelled, reconnecting to the
+// server after any failure
+func (a *Agent) Run(ctx context.Context) error {
+	for {
+		err := a.session(ctx)
+		if ctx.Err() != nil {
+			return nil
+		}
+		log.Printf("Probe agent on %s disconnected from %s: %v", a.node, a.server, err)
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(agentReconnectDelay):
+		}
+	}
+}
+
+// session opens both streams and runs assignments until either of them ends
+func (a *Agent) session(parent context.Context) error {
+	ctx, cancel := context.WithCancel(parent)
+	defer cancel()
+
+	// Results stream: encoded into a pipe that is the body of a long-lived POST
+	bodyReader, bodyWriter := io.Pipe()
+	resultsReq, err := a.request(ctx, http.MethodPost, "/results", bodyReader)
+	if err != nil {
+		return err
+	}
+	resultsReq.Header.Set("Content-Type", "application/x-ndjson")
+	resultsErr := make(chan error, 1)
+	go func() {
+		resp, err := a.client.Do(resultsReq)
+		if err == nil {
+			resp.Body.Close()
+			err = fmt.Errorf("results stream closed by server: %s", resp.Status)
+		}
+		bodyReader.CloseWithError(err)
+		resultsErr <- err
+		cancel()
+	}()
+
+	// Assignment stream
+	assignReq, err := a.request(ctx, http.MethodGet, "/assignments", nil)
+	if err != nil {
+		bodyWriter.Close()
+		return err
+	}
+	resp, err := a.client.Do(assignReq)
+	if err != nil {
+		bodyWriter.Close()
+		return fmt.Errorf("assignment stream: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		bodyWriter.Close()
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("assignment stream: %s: %s", resp.Status, strings.TrimSpace(string(message)))
+	}
+	log.Printf("Probe agent on %s connected to %s", a.node, a.server)
+
+	results := make(chan ProbeResult)
+	written := make(chan struct{})
+	go func() {
+		defer close(written)
+		encoder := json.NewEncoder(bodyWriter)
+		failed := false
+		for result := range results {
+			if failed {
+				continue
+			}
+			if err := encoder.Encode(result); err != nil {
+				failed = true
+				cancel()
+			}
+		}
+	}()
+
+	// Drop the session if the server goes quiet for longer than a few heartbeats
+	idleExpired := make(chan struct{})
+	idle := time.AfterFunc(agentIdleTimeout, func() {
+		close(idleExpired)
+		cancel()
+	})
+	defer idle.Stop()
+
+	var wg sync.WaitGroup
+	workers := make(chan struct{}, agentWorkers)
+	decoder := json.NewDecoder(resp.Body)
+	var streamErr error
+	for {
+		var msg AgentMessage
+		if err := decoder.Decode(&msg); err != nil {
+			streamErr = err
+			break
+		}
+		idle.Reset(agentIdleTimeout)
+		if msg.Type != AgentMessageAssignment || msg.Assignment == nil {
+			continue
+		}
+
+		select {
+		case workers <- struct{}{}:
+		case <-ctx.Done():
+			continue
+		}
+		wg.Add(1)
+		go func(assignment ProbeAssignment) {
+			defer wg.Done()
+			defer func() { <-workers }()
+			result := a.runAssignment(assignment)
+			select {
+			case results <- result:
+			case <-ctx.Done():
+			}
+		}(*msg.Assignment)
+	}
+
+	// Whichever stream failed first is the reason the session ended
+	select {
+	case err := <-resultsErr:
+		resultsErr <- err
+		streamErr = err
+	case <-idleExpired:
+		streamErr = fmt.Errorf("no message from server for %v", agentIdleTimeout)
+	default:
+		if errors.Is(streamErr, io.EOF) {
+			streamErr = errors.New("assignment stream closed by server")
+		}
+	}
+
+	cancel()
+	wg.Wait()
+	close(results)
+	<-written
+	bodyWriter.Close()
+	<-resultsErr
+	return streamErr
+}
+
+func (a *Agent) request(ctx context.Context, method, path string, body io.Reader) (*http.Request, error) {
+	target := fmt.Sprintf("%s%s?node=%s", a.server, path, url.QueryEscape(a.node))
+	req, err := http.NewRequestWithContext(ctx, method, target, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set(AgentProtocolHeader, AgentProtocolVersion)
+	return req, nil
+}
+
+// runAssignment performs one assigned probe
+func (a *Agent) runAssignment(assignment ProbeAssignment) ProbeResult {
+	result := ProbeResult{
+		Timestamp:  time.Now(),
+		SourcePod:  assignment.SourcePod,
+		SourceNS:   assignment.SourceNS,
+		TargetPod:  assignment.TargetPod,
+		TargetNS:   assignment.TargetNS,
+		TargetSvc:  assignment.TargetSvc,
+		TargetNode: assignment.TargetNode,
+		TargetIP:   assignment.TargetIP,
+		TargetPort: assignment.TargetPort,
+		ProbeType:  assignment.ProbeType,
+		Node:       a.node,
+		ExecMode:   ExecModeAgent,
+		RunID:      assignment.RunID,
+	}
+
+	timeout := time.Duration(assignment.TimeoutMs) * time.Millisecond
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+
+	spec := ProbeSpec{Type: assignment.ProbeType}
+	if assignment.Spec != nil {
+		spec = *assignment.Spec
+	}
+
+	switch assignment.ProbeType {
+	case ProbeTypeHTTP:
+		checkHTTP(&result, spec, timeout)
+		return result
+	case ProbeTypeGRPC:
+		checkGRPC(&result, spec, timeout)
+		return result
+	case ProbeTypeUDP:
+		checkUDP(&result, spec, timeout)
+		return result
+	case ProbeTypeTLS:
+		checkTLS(&result, spec, timeout, a.prober.tlsRootPool())
+		return result
+	case ProbeTypeMTU:
+		checkMTU(&result, timeout)
+		return result
+	case ProbeTypeThroughput:
+		result.OnDemand = true
+		checkThroughput(&result, time.Duration(assignment.Duration)*time.Millisecond, assignment.LimitMbps)
+		return result
+	case ProbeTypeICMP:
+		count := assignment.Count
+		if count <= 0 {
+			count = DefaultICMPCount
+		}
+		a.prober.detectPacketLoss(assignment.TargetIP, count).apply(&result)
+		return result
+	case ProbeTypeTCP:
+	default:
+		result.Error = fmt.Sprintf("probe type %q is not supported by the agent", assignment.ProbeType)
+		return result
+	}
+
+	var dialed dialResult
+	if assignment.SourcePod != "" {
+		dialed = a.prober.dialFrom(assignment.SourceNS, assignment.SourcePod, assignment.Container, assignment.TargetIP, assignment.TargetPort, timeout)
+	} else {
+		dialed = localDial(assignment.TargetIP, assignment.TargetPort, timeout)
+	}
+	if dialed.mode == ExecModeLocal {
+		dialed.mode = ExecModeAgent
+	}
+	dialed.apply(&result)
+	return result
+}
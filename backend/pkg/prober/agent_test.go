@@ -0,0 +1,177 @@
+package prober
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// waitFor polls until condition holds or the timeout passes
+func waitFor(t *testing.T, timeout time.Duration, what string, condition func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(timeout)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// resultFor returns the latest result recorded for a probe type and target
+func resultFor(p *Prober, probeType, targetIP string, port int32) (ProbeResult, bool) {
+	results := p.GetResults()
+	for i := len(results) - 1; i >= 0; i-- {
+		result := results[i]
+		if result.ProbeType == probeType && result.TargetIP == targetIP && result.TargetPort == port {
+			return result, true
+		}
+	}
+	return ProbeResult{}, false
+}
+
+func hasNode(hub *AgentHub, node string) bool {
+	for _, connected := range hub.Nodes() {
+		if connected == node {
+			return true
+		}
+	}
+	return false
+}
+
+func TestAgentRunsAssignedProbes(t *testing.T) {
+	defer func(delay time.Duration) { agentReconnectDelay = delay }(agentReconnectDelay)
+	agentReconnectDelay = 50 * time.Millisecond
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+	port := int32(listener.Addr().(*net.TCPAddr).Port)
+
+	central := NewProber(nil)
+	hub := central.EnableAgents()
+	server := httptest.NewServer(hub.Handler())
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan error, 1)
+	go func() { stopped <- NewAgent(server.URL, "node-a", NewProber(nil)).Run(ctx) }()
+	defer func() {
+		cancel()
+		if err := <-stopped; err != nil {
+			t.Errorf("Run: %v", err)
+		}
+	}()
+
+	waitFor(t, 5*time.Second, "the agent to connect", func() bool { return hasNode(hub, "node-a") })
+
+	tests := []struct {
+		name       string
+		assignment ProbeAssignment
+		wantOK     bool // whether the probe must succeed; ICMP needs privileges it may lack
+	}{
+		{
+			name: "tcp",
+			assignment: ProbeAssignment{
+				SourcePod: "web", SourceNS: "shop", TargetPod: "api", TargetNS: "shop",
+				TargetIP: "127.0.0.1", TargetPort: port, ProbeType: ProbeTypeTCP, TimeoutMs: 1000,
+			},
+			wantOK: true,
+		},
+		{
+			name: "icmp",
+			assignment: ProbeAssignment{
+				TargetNode: "node-b", TargetIP: "127.0.0.1", ProbeType: ProbeTypeICMP, Count: 1, TimeoutMs: 1000,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !hub.Assign("node-a", tt.assignment) {
+				t.Fatalf("Assign returned false for a connected node")
+			}
+			var result ProbeResult
+			waitFor(t, 5*time.Second, "the probe result", func() bool {
+				var ok bool
+				result, ok = resultFor(central, tt.assignment.ProbeType, tt.assignment.TargetIP, tt.assignment.TargetPort)
+				return ok
+			})
+			if result.ExecMode != ExecModeAgent {
+				t.Errorf("ExecMode = %q, want %q", result.ExecMode, ExecModeAgent)
+			}
+			if result.Node != "node-a" {
+				t.Errorf("Node = %q, want node-a", result.Node)
+			}
+			if result.SourcePod != tt.assignment.SourcePod || result.TargetNode != tt.assignment.TargetNode {
+				t.Errorf("result %+v does not describe assignment %+v", result, tt.assignment)
+			}
+			if tt.wantOK && !result.Success {
+				t.Errorf("Success = false (%s), want true", result.Error)
+			}
+		})
+	}
+
+	if agents := hub.Agents(); len(agents) != 1 || agents[0].Assigned != 2 || agents[0].Results != 2 {
+		t.Errorf("Agents() = %+v, want node-a with 2 assigned and 2 results", agents)
+	}
+
+	// Drop both streams; the agent reconnects and keeps taking assignments
+	server.CloseClientConnections()
+	waitFor(t, 5*time.Second, "the hub to drop the agent", func() bool { return !hasNode(hub, "node-a") })
+	if hub.Assign("node-a", ProbeAssignment{TargetIP: "127.0.0.1", ProbeType: ProbeTypeTCP}) {
+		t.Errorf("Assign returned true with no agent connected")
+	}
+	waitFor(t, 5*time.Second, "the agent to reconnect", func() bool { return hasNode(hub, "node-a") })
+
+	reconnected := ProbeAssignment{TargetIP: "127.0.0.1", TargetPort: port, ProbeType: ProbeTypeTCP, TimeoutMs: 1000, RunID: "after-reconnect"}
+	if !hub.Assign("node-a", reconnected) {
+		t.Fatalf("Assign returned false after the agent reconnected")
+	}
+	waitFor(t, 5*time.Second, "a result after reconnecting", func() bool {
+		result, ok := resultFor(central, ProbeTypeTCP, "127.0.0.1", port)
+		return ok && result.RunID == "after-reconnect"
+	})
+	result, _ := resultFor(central, ProbeTypeTCP, "127.0.0.1", port)
+	if result.ExecMode != ExecModeAgent || !result.Success {
+		t.Errorf("result after reconnecting = %+v, want a successful agent probe", result)
+	}
+}
+
+func TestAgentHubRejectsOtherProtocolVersions(t *testing.T) {
+	hub := NewProber(nil).EnableAgents()
+	server := httptest.NewServer(hub.Handler())
+	defer server.Close()
+
+	agent := NewAgent(server.URL, "node-a", NewProber(nil))
+	req, err := agent.request(context.Background(), http.MethodGet, "/assignments", nil)
+	if err != nil {
+		t.Fatalf("request: %v", err)
+	}
+	req.Header.Set(AgentProtocolHeader, "0")
+	resp, err := agent.client.Do(req)
+	if err != nil {
+		t.Fatalf("GET /assignments: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("status = %d, want 400 for an unsupported protocol version", resp.StatusCode)
+	}
+	if len(hub.Nodes()) != 0 {
+		t.Errorf("Nodes() = %v, want none", hub.Nodes())
+	}
+}
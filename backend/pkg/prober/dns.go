@@ -0,0 +1,260 @@
+package prober
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ProbeTypeDNS is recorded for DNS resolution probes
+const ProbeTypeDNS = "DNS"
+
+// DNS probe defaults
+const (
+	DefaultClusterDomain = "cluster.local"
+	dnsProbeTimeout      = 2 * time.Second
+	maxDNSProbes         = 500 // queries per probe cycle
+	dnsProbeWorkers      = 8   // queries in flight at once
+)
+
+// DNSConfig controls DNS resolution probes
+type DNSConfig struct {
+	Server        string   // host:port of the cluster DNS server; empty to use the kube-dns Service
+	ClusterDomain string   // defaults to cluster.local
+	ExternalNames []string // names outside the cluster to resolve through the cluster DNS
+}
+
+// dnsQuery is one DNS question a probe cycle asks
+type dnsQuery struct {
+	name      string
+	qtype     uint16
+	service   *corev1.Service // nil for external names
+	expectAny bool            // an empty answer is not a failure
+}
+
+// SetDNSConfig configures DNS resolution probes
+func (p *Prober) SetDNSConfig(config DNSConfig) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.dnsConf = config
+}
+
+// dnsServer returns the DNS server to query: the configured one, otherwise the
+// kube-dns Service that CoreDNS runs behind
+func (p *Prober) dnsServer(ctx context.Context) (string, error) {
+	p.mu.RLock()
+	server := p.dnsConf.Server
+	p.mu.RUnlock()
+	if server != "" {
+		if _, _, err := net.SplitHostPort(server); err != nil {
+			server = net.JoinHostPort(server, "53")
+		}
+		return server, nil
+	}
+	if p.client == nil {
+		return "", fmt.Errorf("no DNS server configured")
+	}
+
+	svc, err := p.client.Clientset().CoreV1().Services("kube-system").Get(ctx, "kube-dns", metav1.GetOptions{})
+	if err != nil {
+		return "", fmt.Errorf("looking up kube-dns service: %w", err)
+	}
+	if svc.Spec.ClusterIP == "" || svc.Spec.ClusterIP == corev1.ClusterIPNone {
+		return "", fmt.Errorf("kube-dns service has no cluster IP")
+	}
+	port := int32(53)
+	for _, servicePort := range svc.Spec.Ports {
+		if servicePort.Protocol == corev1.ProtocolUDP {
+			port = servicePort.Port
+			break
+		}
+	}
+	return net.JoinHostPort(svc.Spec.ClusterIP, strconv.Itoa(int(port))), nil
+}
+
+// probeDNS resolves every Service's records, SRV records for its named ports and
+// the configured external names through the cluster DNS server. Queries that
+// have not answered by the deadline are skipped, like the cycle's other probes.
+func (p *Prober) probeDNS(ctx context.Context, services []corev1.Service, deadline time.Time) {
+	ctx, cancel := context.WithDeadline(ctx, deadline)
+	defer cancel()
+
+	server, err := p.dnsServer(ctx)
+	if err != nil {
+		fmt.Printf("Skipping DNS probes: %v\n", err)
+		return
+	}
+
+	p.mu.RLock()
+	config := p.dnsConf
+	p.mu.RUnlock()
+
+	queries := dnsQueries(services, config)
+	if len(queries) > maxDNSProbes {
+		queries = queries[:maxDNSProbes]
+	}
+
+	skipped := 0
+	var skippedMu sync.Mutex
+	queue := make(chan dnsQuery)
+	var wg sync.WaitGroup
+	for i := 0; i < dnsProbeWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for query := range queue {
+				start := time.Now()
+				result, err := resolve(ctx, server, query)
+				// A query cut off by the deadline says nothing about the DNS server
+				if err != nil && cutOffByDeadline(err, start, deadline) {
+					skippedMu.Lock()
+					skipped++
+					skippedMu.Unlock()
+					continue
+				}
+				p.addResult(result)
+			}
+		}()
+	}
+	for _, query := range queries {
+		queue <- query
+	}
+	close(queue)
+	wg.Wait()
+	if skipped > 0 {
+		fmt.Printf("Skipped %d of %d DNS probes that did not finish in time\n", skipped, len(queries))
+	}
+}
+
+// cutOffByDeadline reports whether a query started at start failed because the
+// cycle deadline ended it. The socket deadline is the earlier of the query
+// timeout and the cycle deadline, and can fire before the context is marked done.
+func cutOffByDeadline(err error, start, deadline time.Time) bool {
+	if !time.Now().Before(deadline) {
+		return true
+	}
+	if !deadline.Before(start.Add(dnsProbeTimeout)) {
+		return false // the query's own timeout came first: the server did not answer
+	}
+	var netErr net.Error
+	return errors.Is(err, os.ErrDeadlineExceeded) || errors.Is(err, context.DeadlineExceeded) ||
+		(errors.As(err, &netErr) && netErr.Timeout())
+}
+
+// dnsQueries lists the questions for one probe cycle. ClusterIP Services must
+// answer A; headless Services answer one A per ready endpoint, which may be
+// none; ExternalName Services answer a CNAME.
+func dnsQueries(services []corev1.Service, config DNSConfig) []dnsQuery {
+	domain := strings.Trim(config.ClusterDomain, ".")
+	if domain == "" {
+		domain = DefaultClusterDomain
+	}
+
+	var queries []dnsQuery
+	for i := range services {
+		svc := &services[i]
+		name := fmt.Sprintf("%s.%s.svc.%s.", svc.Name, svc.Namespace, domain)
+		switch {
+		case svc.Spec.Type == corev1.ServiceTypeExternalName:
+			queries = append(queries, dnsQuery{name: name, qtype: dns.TypeCNAME, service: svc})
+			continue
+		case svc.Spec.ClusterIP == corev1.ClusterIPNone:
+			queries = append(queries, dnsQuery{name: name, qtype: dns.TypeA, service: svc, expectAny: true})
+		case svc.Spec.ClusterIP != "":
+			if net.ParseIP(svc.Spec.ClusterIP).To4() == nil {
+				queries = append(queries, dnsQuery{name: name, qtype: dns.TypeAAAA, service: svc})
+			} else {
+				queries = append(queries, dnsQuery{name: name, qtype: dns.TypeA, service: svc})
+			}
+		default:
+			continue
+		}
+
+		for _, port := range svc.Spec.Ports {
+			if port.Name == "" {
+				continue
+			}
+			protocol := strings.ToLower(string(port.Protocol))
+			if protocol == "" {
+				protocol = "tcp"
+			}
+			queries = append(queries, dnsQuery{
+				name:      fmt.Sprintf("_%s._%s.%s", port.Name, protocol, name),
+				qtype:     dns.TypeSRV,
+				service:   svc,
+				expectAny: svc.Spec.ClusterIP == corev1.ClusterIPNone,
+			})
+		}
+	}
+
+	for _, external := range config.ExternalNames {
+		external = strings.TrimSpace(external)
+		if external == "" {
+			continue
+		}
+		queries = append(queries, dnsQuery{name: dns.Fqdn(external), qtype: dns.TypeA})
+	}
+	return queries
+}
+
+// resolve sends one query and records the response code, answer count and
+// latency. Truncated UDP answers are retried over TCP. The error is the
+// exchange's, nil when the server answered.
+func resolve(ctx context.Context, server string, query dnsQuery) (ProbeResult, error) {
+	host, port, _ := net.SplitHostPort(server)
+	portNumber, _ := strconv.Atoi(port)
+	result := ProbeResult{
+		Timestamp:  time.Now(),
+		TargetIP:   host,
+		TargetPort: int32(portNumber),
+		ProbeType:  ProbeTypeDNS,
+		Query:      query.name,
+		QueryType:  dns.TypeToString[query.qtype],
+		ExecMode:   ExecModeLocal,
+	}
+	if query.service != nil {
+		result.TargetSvc = query.service.Name
+		result.TargetNS = query.service.Namespace
+	}
+
+	msg := new(dns.Msg)
+	msg.SetQuestion(query.name, query.qtype)
+	client := &dns.Client{Timeout: dnsProbeTimeout}
+	start := time.Now()
+	resp, rtt, err := client.ExchangeContext(ctx, msg, server)
+	if err == nil && resp.Truncated {
+		client.Net = "tcp"
+		resp, rtt, err = client.ExchangeContext(ctx, msg, server)
+	}
+	if err != nil {
+		result.Latency = time.Since(start).Milliseconds()
+		result.Success = false
+		result.Error = err.Error()
+		return result, err
+	}
+	result.Latency = rtt.Milliseconds()
+
+	result.Rcode = dns.RcodeToString[resp.Rcode]
+	result.Answers = len(resp.Answer)
+	switch {
+	case resp.Rcode != dns.RcodeSuccess:
+		result.Success = false
+		result.Error = fmt.Sprintf("%s for %s %s", result.Rcode, result.QueryType, query.name)
+	case result.Answers == 0 && !query.expectAny:
+		result.Success = false
+		result.Error = fmt.Sprintf("no %s records for %s", result.QueryType, query.name)
+	default:
+		result.Success = true
+	}
+	return result, nil
+}
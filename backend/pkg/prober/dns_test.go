@@ -0,0 +1,114 @@
+package prober
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// serveDNS answers A queries for the ClusterIP Services' names, holds queries
+// for names starting with "slow" until stop is closed, and returns the server
+// address
+func serveDNS(t *testing.T, stop chan struct{}) string {
+	t.Helper()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	handler := dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
+		question := r.Question[0]
+		if len(question.Name) >= 4 && question.Name[:4] == "slow" {
+			<-stop
+		}
+		reply := new(dns.Msg)
+		reply.SetReply(r)
+		if question.Qtype == dns.TypeA {
+			reply.Answer = append(reply.Answer, &dns.A{
+				Hdr: dns.RR_Header{Name: question.Name, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 30},
+				A:   net.ParseIP("10.96.0.10"),
+			})
+		}
+		w.WriteMsg(reply)
+	})
+	server := &dns.Server{PacketConn: conn, Handler: handler}
+	go server.ActivateAndServe()
+	t.Cleanup(func() { server.Shutdown() })
+	return conn.LocalAddr().String()
+}
+
+func clusterIPService(namespace, name string) corev1.Service {
+	return corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+		Spec:       corev1.ServiceSpec{ClusterIP: "10.96.0.10"},
+	}
+}
+
+func TestProbeDNSStopsAtDeadline(t *testing.T) {
+	stop := make(chan struct{})
+	defer close(stop)
+
+	p := NewProber(nil)
+	p.SetDNSConfig(DNSConfig{Server: serveDNS(t, stop)})
+
+	var services []corev1.Service
+	for _, name := range []string{"web", "api", "slow-a", "slow-b"} {
+		services = append(services, clusterIPService("shop", name))
+	}
+
+	start := time.Now()
+	p.probeDNS(context.Background(), services, start.Add(500*time.Millisecond))
+	if elapsed := time.Since(start); elapsed > 1500*time.Millisecond {
+		t.Errorf("probeDNS took %v, want it to stop at the 500ms deadline", elapsed)
+	}
+
+	answered := make(map[string]bool)
+	for _, result := range p.GetResults() {
+		if result.ProbeType != ProbeTypeDNS {
+			continue
+		}
+		if !result.Success {
+			t.Errorf("recorded failed query %s: %s", result.Query, result.Error)
+		}
+		answered[result.TargetSvc] = true
+	}
+	if !answered["web"] || !answered["api"] {
+		t.Errorf("answered = %v, want web and api", answered)
+	}
+	if answered["slow-a"] || answered["slow-b"] {
+		t.Errorf("answered = %v, want the queries cut off by the deadline skipped", answered)
+	}
+}
+
+func TestCutOffByDeadline(t *testing.T) {
+	now := time.Now()
+	timeout := &net.OpError{Op: "read", Net: "udp", Err: os.ErrDeadlineExceeded}
+	tests := []struct {
+		name     string
+		err      error
+		start    time.Time
+		deadline time.Time
+		want     bool
+	}{
+		{"deadline passed", errors.New("connection refused"), now.Add(-time.Second), now.Add(-time.Millisecond), true},
+		{"socket timeout at the cycle deadline", timeout, now, now.Add(time.Second), true},
+		{"wrapped deadline exceeded", fmt.Errorf("exchange: %w", os.ErrDeadlineExceeded), now, now.Add(time.Second), true},
+		{"context deadline", context.DeadlineExceeded, now, now.Add(time.Second), true},
+		{"query timeout before the cycle deadline", timeout, now.Add(-dnsProbeTimeout), now.Add(time.Minute), false},
+		{"refused before the deadline", errors.New("connection refused"), now, now.Add(time.Second), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := cutOffByDeadline(tt.err, tt.start, tt.deadline); got != tt.want {
+				t.Errorf("cutOffByDeadline() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
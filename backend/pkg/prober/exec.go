@@ -0,0 +1,225 @@
+package prober
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/remotecommand"
+)
+
+// Probe execution modes, recorded on every ProbeResult
+const (
+	ExecModeLocal = "local" // dialed from the visualizer's own network namespace
+	ExecModeExec  = "exec"  // dialed inside the source pod through pods/exec
+	ExecModeAgent = "agent" // dialed by the probe agent on the source pod's node
+)
+
+// defaultContainerAnnotation names the container kubectl exec uses by default
+const defaultContainerAnnotation = "kubectl.kubernetes.io/default-container"
+
+// execProbeMarker prefixes the result line printed by the exec dial script
+const execProbeMarker = "netvis-probe"
+
+// execDialScript connects to %[1]s port %[2]d with a %[3]d second timeout using
+// whatever the container has: bash's /dev/tcp under timeout, or nc. Exit status
+// 127 means neither is available. Timing is taken inside the pod when date
+// supports %%N.
+const execDialScript = `s=$(date +%%s%%N 2>/dev/null)
+if command -v bash >/dev/null 2>&1 && command -v timeout >/dev/null 2>&1; then
+  out=$(timeout %[3]d bash -c 'exec 3<>/dev/tcp/%[1]s/%[2]d' 2>&1)
+elif command -v nc >/dev/null 2>&1; then
+  out=$(nc -z -w %[3]d %[1]s %[2]d 2>&1)
+else
+  echo "no bash with timeout, or nc, in container"
+  exit 127
+fi
+rc=$?
+e=$(date +%%s%%N 2>/dev/null)
+echo "$out"
+echo "` + execProbeMarker + ` $rc $s $e"
+`
+
+// dialResult is the outcome of one connection attempt made for a source pod
+type dialResult struct {
+	latency  time.Duration
+	mode     string
+	err      error
+	fallback string // why a probe in exec mode was dialed locally
+}
+
+// apply records the dial outcome on a probe result
+func (d dialResult) apply(result *ProbeResult) {
+	result.Latency = d.latency.Milliseconds()
+	result.ExecMode = d.mode
+	result.Fallback = d.fallback
+	if d.err != nil {
+		result.Success = false
+		result.Error = d.err.Error()
+	} else {
+		result.Success = true
+	}
+}
+
+// SetExecMode selects where probes are dialed from. In exec mode each dial runs
+// inside the source pod, so the source pod's egress policies are exercised; pods
+// that cannot be exec'd into, or lack bash and nc, fall back to local dialing.
+func (p *Prober) SetExecMode(mode string) error {
+	switch mode {
+	case ExecModeLocal, ExecModeExec:
+	default:
+		return fmt.Errorf("unknown probe exec mode %q", mode)
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.execMode = mode
+	return nil
+}
+
+// ExecMode returns the configured probe execution mode
+func (p *Prober) ExecMode() string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.execMode == "" {
+		return ExecModeLocal
+	}
+	return p.execMode
+}
+
+// dial makes a TCP connection attempt to ip:port on behalf of the source pod
+func (p *Prober) dial(source corev1.Pod, ip string, port int32, timeout time.Duration) dialResult {
+	return p.dialFrom(source.Namespace, source.Name, execContainer(source), ip, port, timeout)
+}
+
+// dialFrom is dial for a source pod known only by name and exec container
+func (p *Prober) dialFrom(namespace, name, container, ip string, port int32, timeout time.Duration) dialResult {
+	if p.ExecMode() == ExecModeExec && p.client != nil {
+		result, err := p.execDial(namespace, name, container, ip, port, timeout)
+		if err == nil {
+			return result
+		}
+		local := localDial(ip, port, timeout)
+		local.fallback = err.Error()
+		return local
+	}
+	return localDial(ip, port, timeout)
+}
+
+func localDial(ip string, port int32, timeout time.Duration) dialResult {
+	start := time.Now()
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort(ip, strconv.Itoa(int(port))), timeout)
+	result := dialResult{latency: time.Since(start), mode: ExecModeLocal, err: err}
+	if err == nil {
+		conn.Close()
+	}
+	return result
+}
+
+// execDial runs the dial script in the source pod. The returned error means the
+// probe could not be run in the pod at all, not that the connection failed.
+func (p *Prober) execDial(namespace, name, container, ip string, port int32, timeout time.Duration) (dialResult, error) {
+	if net.ParseIP(ip) == nil {
+		return dialResult{}, fmt.Errorf("invalid target IP %q", ip)
+	}
+	if container == "" {
+		return dialResult{}, fmt.Errorf("pod has no containers")
+	}
+	seconds := int(timeout.Seconds())
+	if seconds < 1 {
+		seconds = 1
+	}
+
+	req := p.client.Clientset().CoreV1().RESTClient().Post().
+		Resource("pods").
+		Namespace(namespace).
+		Name(name).
+		SubResource("exec").
+		VersionedParams(&corev1.PodExecOptions{
+			Container: container,
+			Command:   []string{"sh", "-c", fmt.Sprintf(execDialScript, ip, port, seconds)},
+			Stdout:    true,
+			Stderr:    true,
+		}, scheme.ParameterCodec)
+
+	executor, err := remotecommand.NewSPDYExecutor(p.client.Config(), "POST", req.URL())
+	if err != nil {
+		return dialResult{}, fmt.Errorf("exec into %s/%s: %w", namespace, name, err)
+	}
+
+	// Leave room for the exec round trip on top of the dial timeout
+	ctx, cancel := context.WithTimeout(context.Background(), timeout+10*time.Second)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	start := time.Now()
+	err = executor.StreamWithContext(ctx, remotecommand.StreamOptions{Stdout: &stdout, Stderr: &stderr})
+	elapsed := time.Since(start)
+	if err != nil {
+		detail := strings.TrimSpace(stdout.String() + " " + stderr.String())
+		if detail == "" {
+			detail = err.Error()
+		}
+		return dialResult{}, fmt.Errorf("exec into %s/%s container %s: %s", namespace, name, container, detail)
+	}
+
+	return parseExecDial(stdout.String(), elapsed)
+}
+
+// parseExecDial reads the script's result line: exit status, then start and end
+// time in nanoseconds when the container's date supports them
+func parseExecDial(output string, elapsed time.Duration) (dialResult, error) {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	last := strings.Fields(lines[len(lines)-1])
+	if len(last) < 2 || last[0] != execProbeMarker {
+		return dialResult{}, fmt.Errorf("unexpected probe output: %q", strings.TrimSpace(output))
+	}
+	rc, err := strconv.Atoi(last[1])
+	if err != nil {
+		return dialResult{}, fmt.Errorf("unexpected probe exit status %q", last[1])
+	}
+
+	result := dialResult{latency: elapsed, mode: ExecModeExec}
+	if len(last) == 4 {
+		startNs, startErr := strconv.ParseInt(last[2], 10, 64)
+		endNs, endErr := strconv.ParseInt(last[3], 10, 64)
+		if startErr == nil && endErr == nil && endNs >= startNs {
+			result.latency = time.Duration(endNs - startNs)
+		}
+	}
+
+	message := strings.TrimSpace(strings.Join(lines[:len(lines)-1], " "))
+	// 126 and 127 come from the shell when a tool could not be run at all, so
+	// nothing was dialed and the probe falls back
+	if rc == 126 || rc == 127 {
+		if message == "" {
+			message = fmt.Sprintf("exit status %d", rc)
+		}
+		return dialResult{}, fmt.Errorf("cannot run probe in pod: %s", message)
+	}
+	if rc != 0 {
+		if rc == 124 {
+			message = "connection timed out"
+		} else if message == "" {
+			message = fmt.Sprintf("connection failed (exit status %d)", rc)
+		}
+		result.err = fmt.Errorf("%s", message)
+	}
+	return result, nil
+}
+
+// execContainer picks the container kubectl exec would use
+func execContainer(pod corev1.Pod) string {
+	if name := pod.Annotations[defaultContainerAnnotation]; name != "" {
+		return name
+	}
+	if len(pod.Spec.Containers) == 0 {
+		return ""
+	}
+	return pod.Spec.Containers[0].Name
+}
@@ -0,0 +1,82 @@
+package prober
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseExecDial(t *testing.T) {
+	tests := []struct {
+		name        string
+		output      string
+		wantErr     string // execution error, so the probe falls back
+		wantDialErr string
+		wantLatency time.Duration
+	}{
+		{
+			name:        "connected",
+			output:      "\nnetvis-probe 0 1000000000 1002500000\n",
+			wantLatency: 2500 * time.Microsecond,
+		},
+		{
+			name:        "connected without nanosecond date",
+			output:      "\nnetvis-probe 0 %N %N\n",
+			wantLatency: time.Second,
+		},
+		{
+			name:        "refused",
+			output:      "bash: connect: Connection refused\nnetvis-probe 1 1 2\n",
+			wantDialErr: "bash: connect: Connection refused",
+			wantLatency: 1,
+		},
+		{
+			name:        "timed out",
+			output:      "\nnetvis-probe 124 1 2\n",
+			wantDialErr: "connection timed out",
+			wantLatency: 1,
+		},
+		{
+			name:    "command not found",
+			output:  "sh: timeout: not found\nnetvis-probe 127 1 2\n",
+			wantErr: "cannot run probe in pod: sh: timeout: not found",
+		},
+		{
+			name:    "not executable",
+			output:  "\nnetvis-probe 126 1 2\n",
+			wantErr: "cannot run probe in pod: exit status 126",
+		},
+		{
+			name:    "no result line",
+			output:  "no bash with timeout, or nc, in container\n",
+			wantErr: "unexpected probe output",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := parseExecDial(tt.output, time.Second)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseExecDial: %v", err)
+			}
+			if result.mode != ExecModeExec {
+				t.Errorf("mode = %q, want %q", result.mode, ExecModeExec)
+			}
+			if result.latency != tt.wantLatency {
+				t.Errorf("latency = %v, want %v", result.latency, tt.wantLatency)
+			}
+			if tt.wantDialErr == "" && result.err != nil {
+				t.Errorf("dial err = %v, want none", result.err)
+			}
+			if tt.wantDialErr != "" && (result.err == nil || result.err.Error() != tt.wantDialErr) {
+				t.Errorf("dial err = %v, want %q", result.err, tt.wantDialErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,253 @@
+package prober
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// Probe types recorded in ProbeResult.ProbeType
+const (
+	ProbeTypeTCP  = "TCP"
+	ProbeTypeHTTP = "HTTP"
+	ProbeTypeGRPC = "gRPC"
+)
+
+// Service annotations that override probe selection. Each may be suffixed with
+// "." and a port name to apply to that port only, e.g.
+// netvis.io/probe-path.metrics: /metrics
+const (
+	AnnotationProbeType    = "netvis.io/probe-type"    // tcp, http, grpc or tls
+	AnnotationProbePath    = "netvis.io/probe-path"    // HTTP request path
+	AnnotationExpectStatus = "netvis.io/expect-status" // e.g. "200", "200-299,404"
+	AnnotationGRPCService  = "netvis.io/grpc-service"  // service name for the gRPC health check
+)
+
+// DefaultProbePath is requested by HTTP probes without a path annotation. Any
+// response from it counts as reached unless an expected status is annotated.
+const DefaultProbePath = "/"
+
+// servingStatus is the gRPC health status a probe expects
+var servingStatus = healthpb.HealthCheckResponse_SERVING.String()
+
+// ProbeSpec describes how a Service port is probed
+type ProbeSpec struct {
+	Type         string   `json:"type"`
+	Path         string   `json:"path,omitempty"`
+	ExpectStatus string   `json:"expect_status,omitempty"`
+	GRPCService  string   `json:"grpc_service,omitempty"`
+	Payload      string   `json:"payload,omitempty"`       // UDP datagram to send
+	ExpectReply  string   `json:"expect_reply,omitempty"`  // UDP reply to expect; empty for none
+	ServerName   string   `json:"server_name,omitempty"`   // TLS server name to send
+	ServiceNames []string `json:"service_names,omitempty"` // Service DNS names the TLS certificate should cover
+	SelectedBy   string   `json:"selected_by"`             // annotation, appProtocol, port-name, port-number, protocol or default
+}
+
+// SelectProbe picks the probe for a Service port: an explicit annotation wins,
+// then the port's appProtocol, then its name (http, grpc, h2c, https and tls,
+// optionally followed by "-suffix"), then port 443 for TLS. UDP ports get a UDP
+// probe and everything else a TCP connect probe.
+func SelectProbe(svc corev1.Service, port corev1.ServicePort) ProbeSpec {
+	if port.Protocol == corev1.ProtocolUDP {
+		return selectUDPProbe(svc, port)
+	}
+	spec := ProbeSpec{Type: ProbeTypeTCP, SelectedBy: "default"}
+	if port.Protocol != "" && port.Protocol != corev1.ProtocolTCP {
+		return spec
+	}
+
+	if value := portAnnotation(svc, AnnotationProbeType, port.Name); value != "" {
+		if probeType := probeTypeFor(value); probeType != "" {
+			spec.Type, spec.SelectedBy = probeType, "annotation"
+		}
+	}
+	if spec.SelectedBy == "default" && port.AppProtocol != nil {
+		if probeType := probeTypeFor(*port.AppProtocol); probeType != "" {
+			spec.Type, spec.SelectedBy = probeType, "appProtocol"
+		}
+	}
+	if spec.SelectedBy == "default" && port.Name != "" {
+		prefix := strings.SplitN(port.Name, "-", 2)[0]
+		if probeType := probeTypeFor(prefix); probeType != "" {
+			spec.Type, spec.SelectedBy = probeType, "port-name"
+		}
+	}
+	if spec.SelectedBy == "default" && port.Port == tlsPort {
+		spec.Type, spec.SelectedBy = ProbeTypeTLS, "port-number"
+	}
+
+	switch spec.Type {
+	case ProbeTypeHTTP:
+		spec.Path = portAnnotation(svc, AnnotationProbePath, port.Name)
+		if spec.Path == "" {
+			spec.Path = DefaultProbePath
+		} else if !strings.HasPrefix(spec.Path, "/") {
+			spec.Path = "/" + spec.Path
+		}
+		spec.ExpectStatus = portAnnotation(svc, AnnotationExpectStatus, port.Name)
+	case ProbeTypeGRPC:
+		spec.GRPCService = portAnnotation(svc, AnnotationGRPCService, port.Name)
+	case ProbeTypeTLS:
+		spec.ServerName = portAnnotation(svc, AnnotationTLSServerName, port.Name)
+	}
+	return spec
+}
+
+// probeTypeFor maps an annotation value, appProtocol or port name prefix to a
+// probe type. h2c is treated as gRPC, which is what cleartext HTTP/2 ports
+// almost always serve.
+func probeTypeFor(value string) string {
+	switch strings.ToLower(strings.TrimPrefix(value, "kubernetes.io/")) {
+	case "tcp":
+		return ProbeTypeTCP
+	case "http", "http1", "ws":
+		return ProbeTypeHTTP
+	case "grpc", "h2c":
+		return ProbeTypeGRPC
+	case "https", "tls":
+		return ProbeTypeTLS
+	}
+	return ""
+}
+
+// portAnnotation reads a probe annotation, preferring the port-specific form
+func portAnnotation(svc corev1.Service, key, portName string) string {
+	if portName != "" {
+		if value, ok := svc.Annotations[key+"."+portName]; ok {
+			return strings.TrimSpace(value)
+		}
+	}
+	return strings.TrimSpace(svc.Annotations[key])
+}
+
+// HealthMismatch reports whether the probe reached its target but the health
+// check's answer was not the expected one
+func (r ProbeResult) HealthMismatch() bool {
+	return !r.Success && (r.StatusCode != 0 || r.GRPCStatus != "")
+}
+
+// checkHTTP sends a GET for the spec's path and compares the status code with
+// the expected ones, if any; without them any response counts. Redirects are
+// not followed.
+func checkHTTP(result *ProbeResult, spec ProbeSpec, timeout time.Duration) {
+	path := spec.Path
+	if path == "" {
+		path = DefaultProbePath
+	}
+	expect := spec.ExpectStatus
+	result.Path = path
+	result.Expected = expect
+
+	client := &http.Client{
+		Timeout: timeout,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	start := time.Now()
+	url := fmt.Sprintf("http://%s%s", net.JoinHostPort(result.TargetIP, strconv.Itoa(int(result.TargetPort))), path)
+	resp, err := client.Get(url)
+	result.Latency = time.Since(start).Milliseconds()
+	if err != nil {
+		result.Success = false
+		result.Error = err.Error()
+		return
+	}
+	resp.Body.Close()
+	result.StatusCode = resp.StatusCode
+	if expect == "" {
+		result.Success = true
+		return
+	}
+
+	matched, err := statusMatches(expect, resp.StatusCode)
+	switch {
+	case err != nil:
+		result.Success = false
+		result.Error = fmt.Sprintf("invalid expected status %q: %v", expect, err)
+	case !matched:
+		result.Success = false
+		result.Error = fmt.Sprintf("HTTP %d from %s, expected %s", resp.StatusCode, path, expect)
+	default:
+		result.Success = true
+	}
+}
+
+// statusMatches checks a status code against a comma-separated list of codes
+// and ranges such as "200-299,404"
+func statusMatches(expect string, code int) (bool, error) {
+	for _, part := range strings.Split(expect, ",") {
+		part = strings.TrimSpace(part)
+		low, high, isRange := strings.Cut(part, "-")
+		from, err := strconv.Atoi(strings.TrimSpace(low))
+		if err != nil {
+			return false, fmt.Errorf("bad status %q", part)
+		}
+		to := from
+		if isRange {
+			if to, err = strconv.Atoi(strings.TrimSpace(high)); err != nil || to < from {
+				return false, fmt.Errorf("bad status range %q", part)
+			}
+		}
+		if code >= from && code <= to {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// checkGRPC calls the standard gRPC health service and expects SERVING. A health
+// service that is missing or does not know the service name counts as reached,
+// with UNIMPLEMENTED or SERVICE_UNKNOWN noted as the gRPC status.
+func checkGRPC(result *ProbeResult, spec ProbeSpec, timeout time.Duration) {
+	result.Expected = servingStatus
+
+	start := time.Now()
+	address := net.JoinHostPort(result.TargetIP, strconv.Itoa(int(result.TargetPort)))
+	conn, err := grpc.Dial(address, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		result.Success = false
+		result.Error = err.Error()
+		result.Latency = time.Since(start).Milliseconds()
+		return
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	resp, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{Service: spec.GRPCService})
+	result.Latency = time.Since(start).Milliseconds()
+
+	if err != nil {
+		switch status.Code(err) {
+		case codes.NotFound:
+			result.Success = true
+			result.GRPCStatus = healthpb.HealthCheckResponse_SERVICE_UNKNOWN.String()
+		case codes.Unimplemented:
+			result.Success = true
+			result.GRPCStatus = "UNIMPLEMENTED"
+		default:
+			result.Success = false
+			result.Error = err.Error()
+		}
+		return
+	}
+
+	result.GRPCStatus = resp.Status.String()
+	result.Success = resp.Status == healthpb.HealthCheckResponse_SERVING
+	if !result.Success {
+		result.Error = fmt.Sprintf("Service status: %s", resp.Status.String())
+	}
+}
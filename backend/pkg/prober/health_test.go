@@ -0,0 +1,131 @@
+package prober
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// targetOf points a probe result at a listener's address
+func targetOf(t *testing.T, address string) ProbeResult {
+	t.Helper()
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		t.Fatalf("split %q: %v", address, err)
+	}
+	portNumber, _ := strconv.Atoi(port)
+	return ProbeResult{TargetIP: host, TargetPort: int32(portNumber)}
+}
+
+func TestSelectProbeHTTPDefaults(t *testing.T) {
+	port := corev1.ServicePort{Name: "http", Port: 80, Protocol: corev1.ProtocolTCP}
+
+	spec := SelectProbe(corev1.Service{}, port)
+	if spec.Type != ProbeTypeHTTP || spec.Path != "/" || spec.ExpectStatus != "" {
+		t.Errorf("unannotated spec = %+v, want HTTP on / with no expected status", spec)
+	}
+
+	annotated := corev1.Service{ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{
+		AnnotationProbePath:    "healthz",
+		AnnotationExpectStatus: "200",
+	}}}
+	spec = SelectProbe(annotated, port)
+	if spec.Path != "/healthz" || spec.ExpectStatus != "200" {
+		t.Errorf("annotated spec = %+v, want /healthz expecting 200", spec)
+	}
+}
+
+func TestCheckHTTP(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/healthz" {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		http.NotFound(w, r)
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name         string
+		spec         ProbeSpec
+		wantSuccess  bool
+		wantStatus   int
+		wantMismatch bool
+	}{
+		{name: "any response reaches by default", spec: ProbeSpec{}, wantSuccess: true, wantStatus: 404},
+		{name: "expected status matches", spec: ProbeSpec{Path: "/healthz", ExpectStatus: "200-299"}, wantSuccess: true, wantStatus: 200},
+		{name: "expected status differs", spec: ProbeSpec{ExpectStatus: "200"}, wantStatus: 404, wantMismatch: true},
+		{name: "expected status list", spec: ProbeSpec{ExpectStatus: "200, 404"}, wantSuccess: true, wantStatus: 404},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := targetOf(t, server.Listener.Addr().String())
+			checkHTTP(&result, tt.spec, time.Second)
+			if result.Success != tt.wantSuccess || result.StatusCode != tt.wantStatus {
+				t.Errorf("Success, StatusCode = %v, %d (%s), want %v, %d", result.Success, result.StatusCode, result.Error, tt.wantSuccess, tt.wantStatus)
+			}
+			if result.HealthMismatch() != tt.wantMismatch {
+				t.Errorf("HealthMismatch() = %v, want %v", result.HealthMismatch(), tt.wantMismatch)
+			}
+			if result.Expected != tt.spec.ExpectStatus {
+				t.Errorf("Expected = %q, want %q", result.Expected, tt.spec.ExpectStatus)
+			}
+		})
+	}
+}
+
+func TestCheckGRPC(t *testing.T) {
+	serve := func(t *testing.T, register func(*grpc.Server)) string {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("listen: %v", err)
+		}
+		server := grpc.NewServer()
+		register(server)
+		go server.Serve(listener)
+		t.Cleanup(server.Stop)
+		return listener.Addr().String()
+	}
+
+	healthServer := health.NewServer()
+	healthServer.SetServingStatus("shop.Cart", healthpb.HealthCheckResponse_NOT_SERVING)
+	withHealth := serve(t, func(s *grpc.Server) { healthpb.RegisterHealthServer(s, healthServer) })
+	withoutHealth := serve(t, func(*grpc.Server) {})
+
+	tests := []struct {
+		name         string
+		address      string
+		service      string
+		wantSuccess  bool
+		wantStatus   string
+		wantMismatch bool
+	}{
+		{name: "serving", address: withHealth, wantSuccess: true, wantStatus: "SERVING"},
+		{name: "not serving", address: withHealth, service: "shop.Cart", wantStatus: "NOT_SERVING", wantMismatch: true},
+		{name: "unknown service", address: withHealth, service: "shop.Unknown", wantSuccess: true, wantStatus: "SERVICE_UNKNOWN"},
+		{name: "no health service", address: withoutHealth, wantSuccess: true, wantStatus: "UNIMPLEMENTED"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := targetOf(t, tt.address)
+			checkGRPC(&result, ProbeSpec{GRPCService: tt.service}, 2*time.Second)
+			if result.Success != tt.wantSuccess || result.GRPCStatus != tt.wantStatus {
+				t.Errorf("Success, GRPCStatus = %v, %q (%s), want %v, %q", result.Success, result.GRPCStatus, result.Error, tt.wantSuccess, tt.wantStatus)
+			}
+			if result.HealthMismatch() != tt.wantMismatch {
+				t.Errorf("HealthMismatch() = %v, want %v", result.HealthMismatch(), tt.wantMismatch)
+			}
+		})
+	}
+}
@@ -0,0 +1,311 @@
+package prober
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Probe history defaults
+const (
+	DefaultHistoryRetention = time.Hour
+	DefaultStatsWindow      = 5 * time.Minute
+	maxPairSamples          = 2000 // per pair; the oldest are dropped first
+	historySweepInterval    = time.Minute
+)
+
+// probeSample is one probe of a pair as kept in the history
+type probeSample struct {
+	at        time.Time
+	latency   int64 // ms, or -1 when the probe measured no round trip
+	success   bool
+	probeType string
+}
+
+// pairHistory is the time series of probes from one source to one target
+type pairHistory struct {
+	source   string
+	target   string
+	targetIP string
+	samples  []probeSample
+}
+
+// probeHistory keeps the recent probes of every source and target pair so
+// that latency can be judged on percentiles rather than the last result
+type probeHistory struct {
+	retention time.Duration
+	pairs     map[string]*pairHistory
+	lastSweep time.Time
+	mu        sync.Mutex
+}
+
+// PairStats summarises the probes of one source and target pair over a window.
+// Sources and targets are named like graph nodes: pod/<namespace>/<name>,
+// service/<namespace>/<name> or node/<name>, or an IP for other targets.
+type PairStats struct {
+	Source       string    `json:"source"`
+	Target       string    `json:"target"`
+	TargetIP     string    `json:"target_ip"`
+	ProbeTypes   []string  `json:"probe_types"`
+	Window       string    `json:"window"`
+	Probes       int       `json:"probes"`
+	Successes    int       `json:"successes"`
+	SuccessRatio float64   `json:"success_ratio"`
+	Samples      int       `json:"latency_samples"` // probes with a measured round trip
+	P50          float64   `json:"p50_ms"`
+	P90          float64   `json:"p90_ms"`
+	P99          float64   `json:"p99_ms"`
+	Jitter       float64   `json:"jitter_ms"` // mean difference between consecutive latencies
+	LastSeen     time.Time `json:"last_seen"`
+	LastSuccess  bool      `json:"last_success"`
+}
+
+// StatsQuery selects the pairs and window of PairStats. A namespace/name
+// source or target matches a pod, or a pod or Service respectively.
+type StatsQuery struct {
+	Source    string        // empty for every source
+	Target    string        // empty for every target; may also be an IP
+	ProbeType string        // only count probes of this type; empty for all
+	Window    time.Duration // defaults to DefaultStatsWindow
+}
+
+// SetHistoryRetention sets how long each pair's probes are kept
+func (p *Prober) SetHistoryRetention(retention time.Duration) error {
+	if retention <= 0 {
+		return fmt.Errorf("history retention must be positive, got %v", retention)
+	}
+	p.history.mu.Lock()
+	defer p.history.mu.Unlock()
+	p.history.retention = retention
+	return nil
+}
+
+// HistoryRetention returns how long each pair's probes are kept
+func (p *Prober) HistoryRetention() time.Duration {
+	p.history.mu.Lock()
+	defer p.history.mu.Unlock()
+	return p.history.retentionLocked()
+}
+
+// PairStats returns latency percentiles, success ratio and jitter for the pairs
+// matching the query, sorted by source and target
+func (p *Prober) PairStats(query StatsQuery) ([]PairStats, error) {
+	window := query.Window
+	if window == 0 {
+		window = DefaultStatsWindow
+	}
+	if window < 0 {
+		return nil, fmt.Errorf("window must be positive, got %v", window)
+	}
+	if retention := p.HistoryRetention(); window > retention {
+		return nil, fmt.Errorf("window %v is longer than the %v of history kept", window, retention)
+	}
+	return p.history.stats(query, window), nil
+}
+
+// pairEndpoints names the source and target of a result, or returns false for
+// results that are not between a pod or node and a target, such as DNS queries
+func pairEndpoints(result ProbeResult) (source, target string, ok bool) {
+	switch {
+	case result.ProbeType == ProbeTypeDNS || result.ProbeType == ProbeTypeMTU:
+		return "", "", false
+	case result.SourcePod != "":
+		source = fmt.Sprintf("pod/%s/%s", result.SourceNS, result.SourcePod)
+	case result.Node != "":
+		source = fmt.Sprintf("node/%s", result.Node)
+	default:
+		return "", "", false
+	}
+
+	switch {
+	case result.TargetNode != "":
+		target = fmt.Sprintf("node/%s", result.TargetNode)
+	case result.TargetSvc != "":
+		target = fmt.Sprintf("service/%s/%s", result.TargetNS, result.TargetSvc)
+	case result.TargetPod != "":
+		target = fmt.Sprintf("pod/%s/%s", result.TargetNS, result.TargetPod)
+	case result.TargetIP != "":
+		target = result.TargetIP
+	default:
+		return "", "", false
+	}
+	return source, target, true
+}
+
+func (h *probeHistory) retentionLocked() time.Duration {
+	if h.retention == 0 {
+		return DefaultHistoryRetention
+	}
+	return h.retention
+}
+
+// add records a result in its pair's series
+func (h *probeHistory) add(result ProbeResult) {
+	source, target, ok := pairEndpoints(result)
+	if !ok {
+		return
+	}
+	sample := probeSample{
+		at:        result.Timestamp,
+		latency:   result.Latency,
+		success:   result.Success,
+		probeType: result.ProbeType,
+	}
+	if !result.Success || result.NoResponse {
+		sample.latency = -1 // a failure or a silent UDP port has no round trip to time
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.pairs == nil {
+		h.pairs = make(map[string]*pairHistory)
+	}
+	key := source + "->" + target
+	pair, ok := h.pairs[key]
+	if !ok {
+		pair = &pairHistory{source: source, target: target}
+		h.pairs[key] = pair
+	}
+	pair.targetIP = result.TargetIP
+
+	// Agents report asynchronously, so keep the series ordered by probe time
+	i := len(pair.samples)
+	for i > 0 && pair.samples[i-1].at.After(sample.at) {
+		i--
+	}
+	pair.samples = append(pair.samples, probeSample{})
+	copy(pair.samples[i+1:], pair.samples[i:])
+	pair.samples[i] = sample
+	if len(pair.samples) > maxPairSamples {
+		pair.samples = pair.samples[len(pair.samples)-maxPairSamples:]
+	}
+
+	now := time.Now()
+	if now.Sub(h.lastSweep) >= historySweepInterval {
+		h.sweepLocked(now)
+	}
+}
+
+// sweepLocked drops samples past the retention and pairs left empty
+func (h *probeHistory) sweepLocked(now time.Time) {
+	h.lastSweep = now
+	cutoff := now.Add(-h.retentionLocked())
+	for key, pair := range h.pairs {
+		expired := sort.Search(len(pair.samples), func(i int) bool { return !pair.samples[i].at.Before(cutoff) })
+		if expired == len(pair.samples) {
+			delete(h.pairs, key)
+			continue
+		}
+		pair.samples = pair.samples[expired:]
+	}
+}
+
+// stats summarises the matching pairs' samples within the window
+func (h *probeHistory) stats(query StatsQuery, window time.Duration) []PairStats {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	now := time.Now()
+	h.sweepLocked(now)
+	cutoff := now.Add(-window)
+	var stats []PairStats
+	for _, pair := range h.pairs {
+		if !matchesEndpoint(pair.source, "", query.Source, false) ||
+			!matchesEndpoint(pair.target, pair.targetIP, query.Target, true) {
+			continue
+		}
+		start := sort.Search(len(pair.samples), func(i int) bool { return !pair.samples[i].at.Before(cutoff) })
+		var samples []probeSample
+		for _, sample := range pair.samples[start:] {
+			if query.ProbeType == "" || strings.EqualFold(sample.probeType, query.ProbeType) {
+				samples = append(samples, sample)
+			}
+		}
+		if len(samples) == 0 {
+			continue
+		}
+		summary := summarize(samples)
+		summary.Source = pair.source
+		summary.Target = pair.target
+		summary.TargetIP = pair.targetIP
+		summary.Window = window.String()
+		stats = append(stats, summary)
+	}
+
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Source != stats[j].Source {
+			return stats[i].Source < stats[j].Source
+		}
+		return stats[i].Target < stats[j].Target
+	})
+	return stats
+}
+
+// matchesEndpoint reports whether a pair's source or target is the one queried.
+// namespace/name matches a pod, or for targets also a Service.
+func matchesEndpoint(id, ip, query string, target bool) bool {
+	switch {
+	case query == "" || query == id:
+		return true
+	case ip != "" && net.ParseIP(query) != nil:
+		return net.ParseIP(query).Equal(net.ParseIP(ip))
+	case strings.Count(query, "/") == 1:
+		return id == "pod/"+query || (target && id == "service/"+query)
+	}
+	return false
+}
+
+// summarize computes a pair's statistics from its samples, oldest first
+func summarize(samples []probeSample) PairStats {
+	stats := PairStats{Probes: len(samples)}
+	types := make(map[string]bool)
+	var latencies []float64
+	var jitter float64
+	for _, sample := range samples {
+		if sample.success {
+			stats.Successes++
+		}
+		if !types[sample.probeType] {
+			types[sample.probeType] = true
+			stats.ProbeTypes = append(stats.ProbeTypes, sample.probeType)
+		}
+		if sample.latency < 0 {
+			continue
+		}
+		latency := float64(sample.latency)
+		if len(latencies) > 0 {
+			jitter += math.Abs(latency - latencies[len(latencies)-1])
+		}
+		latencies = append(latencies, latency)
+	}
+	last := samples[len(samples)-1]
+	stats.LastSeen = last.at
+	stats.LastSuccess = last.success
+	stats.SuccessRatio = float64(stats.Successes) / float64(stats.Probes)
+	sort.Strings(stats.ProbeTypes)
+
+	stats.Samples = len(latencies)
+	if len(latencies) > 1 {
+		stats.Jitter = jitter / float64(len(latencies)-1)
+	}
+	sort.Float64s(latencies)
+	stats.P50 = percentile(latencies, 50)
+	stats.P90 = percentile(latencies, 90)
+	stats.P99 = percentile(latencies, 99)
+	return stats
+}
+
+// percentile interpolates linearly between the closest ranks of sorted values
+func percentile(sorted []float64, pct float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := pct / 100 * float64(len(sorted)-1)
+	lower := int(math.Floor(rank))
+	upper := int(math.Ceil(rank))
+	return sorted[lower] + (sorted[upper]-sorted[lower])*(rank-float64(lower))
+}
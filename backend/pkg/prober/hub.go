@@ -0,0 +1,248 @@
+package prober
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// NodeProbePort is the kubelet port, which every node listens on; node-to-node
+// probes connect to it
+const NodeProbePort = 10250
+
+// agentQueueSize bounds the assignments waiting for one agent; a full queue
+// sends further probes for that node back to the central prober
+const agentQueueSize = 1024
+
+// AgentStatus describes a connected probe agent
+type AgentStatus struct {
+	Node        string    `json:"node"`
+	ConnectedAt time.Time `json:"connected_at"`
+	Assigned    int       `json:"assigned"`
+	Results     int       `json:"results"`
+	LastResult  time.Time `json:"last_result,omitempty"`
+}
+
+// AgentHub is the central end of the probe agent protocol. It hands probes for
+// pods on a node to that node's agent and records the results they stream back.
+type AgentHub struct {
+	prober *Prober
+	agents map[string]*agentConn
+	mu     sync.RWMutex
+}
+
+// agentConn is one connected agent's assignment stream
+type agentConn struct {
+	queue    chan ProbeAssignment
+	replaced chan struct{} // closed when the same node reconnects
+	status   AgentStatus
+}
+
+// EnableAgents creates the prober's AgentHub. From then on probes whose source
+// pod runs on a node with a connected agent are run by that agent.
+func (p *Prober) EnableAgents() *AgentHub {
+	hub := &AgentHub{
+		prober: p,
+		agents: make(map[string]*agentConn),
+	}
+	p.mu.Lock()
+	p.agents = hub
+	p.mu.Unlock()
+	return hub
+}
+
+// Handler serves the agent protocol, plus the connected agents' status at "/"
+func (h *AgentHub) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/assignments", h.assignmentsHandler)
+	mux.HandleFunc("/results", h.resultsHandler)
+	mux.HandleFunc("/", h.statusHandler)
+	return mux
+}
+
+// Assign queues a probe for the agent on node. It returns false when no agent is
+// connected for the node or its queue is full.
+func (h *AgentHub) Assign(node string, assignment ProbeAssignment) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	conn, ok := h.agents[node]
+	if !ok {
+		return false
+	}
+	select {
+	case conn.queue <- assignment:
+		conn.status.Assigned++
+		return true
+	default:
+		return false
+	}
+}
+
+// Nodes returns the nodes with a connected agent
+func (h *AgentHub) Nodes() []string {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	nodes := make([]string, 0, len(h.agents))
+	for node := range h.agents {
+		nodes = append(nodes, node)
+	}
+	sort.Strings(nodes)
+	return nodes
+}
+
+// Agents returns the status of every connected agent
+func (h *AgentHub) Agents() []AgentStatus {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	agents := make([]AgentStatus, 0, len(h.agents))
+	for _, conn := range h.agents {
+		agents = append(agents, conn.status)
+	}
+	sort.Slice(agents, func(i, j int) bool {
+		return agents[i].Node < agents[j].Node
+	})
+	return agents
+}
+
+func (h *AgentHub) register(node string) *agentConn {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if old, ok := h.agents[node]; ok {
+		close(old.replaced)
+	}
+	conn := &agentConn{
+		queue:    make(chan ProbeAssignment, agentQueueSize),
+		replaced: make(chan struct{}),
+		status:   AgentStatus{Node: node, ConnectedAt: time.Now()},
+	}
+	h.agents[node] = conn
+	return conn
+}
+
+func (h *AgentHub) unregister(node string, conn *agentConn) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.agents[node] == conn {
+		delete(h.agents, node)
+	}
+}
+
+func (h *AgentHub) recordResult(node string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if conn, ok := h.agents[node]; ok {
+		conn.status.Results++
+		conn.status.LastResult = time.Now()
+	}
+}
+
+// agentNode validates an agent request and returns the node it comes from
+func agentNode(w http.ResponseWriter, r *http.Request, method string) (string, bool) {
+	if r.Method != method {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return "", false
+	}
+	if version := r.Header.Get(AgentProtocolHeader); version != AgentProtocolVersion {
+		http.Error(w, fmt.Sprintf("unsupported agent protocol version %q, server speaks %q", version, AgentProtocolVersion), http.StatusBadRequest)
+		return "", false
+	}
+	node := r.URL.Query().Get("node")
+	if node == "" {
+		http.Error(w, "node parameter is required", http.StatusBadRequest)
+		return "", false
+	}
+	return node, true
+}
+
+// assignmentsHandler streams assignments to an agent until it disconnects or
+// the same node connects again
+func (h *AgentHub) assignmentsHandler(w http.ResponseWriter, r *http.Request) {
+	node, ok := agentNode(w, r, http.MethodGet)
+	if !ok {
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	conn := h.register(node)
+	defer h.unregister(node, conn)
+	log.Printf("Probe agent connected for node %s", node)
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(agentHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	encoder := json.NewEncoder(w)
+	for {
+		var msg AgentMessage
+		select {
+		case <-r.Context().Done():
+			log.Printf("Probe agent for node %s disconnected", node)
+			return
+		case <-conn.replaced:
+			return
+		case assignment := <-conn.queue:
+			msg = AgentMessage{Type: AgentMessageAssignment, Assignment: &assignment}
+		case <-heartbeat.C:
+			msg = AgentMessage{Type: AgentMessageHeartbeat}
+		}
+		if err := encoder.Encode(msg); err != nil {
+			return
+		}
+		flusher.Flush()
+	}
+}
+
+// resultsHandler records the results an agent streams back
+func (h *AgentHub) resultsHandler(w http.ResponseWriter, r *http.Request) {
+	node, ok := agentNode(w, r, http.MethodPost)
+	if !ok {
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	for {
+		var result ProbeResult
+		if err := decoder.Decode(&result); err != nil {
+			if errors.Is(err, io.EOF) {
+				w.WriteHeader(http.StatusNoContent)
+			} else if r.Context().Err() == nil {
+				http.Error(w, fmt.Sprintf("invalid probe result: %v", err), http.StatusBadRequest)
+			}
+			return
+		}
+		// The agent's connection, not the payload, decides which node ran it
+		result.Node = node
+		h.prober.addResult(result)
+		h.recordResult(node)
+	}
+}
+
+func (h *AgentHub) statusHandler(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(h.Agents()); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,186 @@
+package prober
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+// ProbeTypeICMP is recorded for ICMP echo probes
+const ProbeTypeICMP = "ICMP"
+
+// ICMP echo probe defaults
+const (
+	DefaultICMPCount = 5 // echo requests per probe
+	icmpInterval     = 200 * time.Millisecond
+	icmpReplyTimeout = time.Second
+)
+
+// icmpEchoID tells concurrent pings apart on raw sockets, which see every reply
+var icmpEchoID uint32
+
+// icmpEchoData is the payload of echo requests; replies must carry it back
+var icmpEchoData = []byte("netvis-probe")
+
+// echoStats is the outcome of one run of ICMP echo requests
+type echoStats struct {
+	sent     int
+	received int
+	rtt      time.Duration // average over the replies received
+	err      error
+}
+
+// apply records the echo outcome on a probe result. Partial loss still counts
+// as reachable; the loss is left for the analyzer to judge.
+func (e echoStats) apply(result *ProbeResult) {
+	result.Sent = e.sent
+	result.Received = e.received
+	if e.sent > 0 {
+		result.PacketLoss = float64(e.sent-e.received) / float64(e.sent) * 100
+	}
+	result.Latency = e.rtt.Milliseconds()
+	switch {
+	case e.err != nil:
+		result.Success = false
+		result.Error = e.err.Error()
+	case e.received == 0:
+		result.Success = false
+		result.Error = fmt.Sprintf("no echo replies to %d requests", e.sent)
+	default:
+		result.Success = true
+	}
+}
+
+// SetICMPCount sets how many echo requests each ICMP probe sends
+func (p *Prober) SetICMPCount(count int) error {
+	if count < 1 {
+		return fmt.Errorf("ICMP count must be at least 1, got %d", count)
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.icmpCount = count
+	return nil
+}
+
+// ICMPCount returns how many echo requests each ICMP probe sends
+func (p *Prober) ICMPCount() int {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.icmpCount == 0 {
+		return DefaultICMPCount
+	}
+	return p.icmpCount
+}
+
+// ping sends count echo requests to ip, one at a time
+func ping(ip string, count int) echoStats {
+	target := net.ParseIP(ip)
+	if target == nil {
+		return echoStats{err: fmt.Errorf("invalid target IP %q", ip)}
+	}
+	v4 := target.To4() != nil
+	conn, raw, err := listenICMP(v4)
+	if err != nil {
+		return echoStats{err: err}
+	}
+	defer conn.Close()
+
+	var request, reply icmp.Type = ipv6.ICMPTypeEchoRequest, ipv6.ICMPTypeEchoReply
+	protocol := 58 // ICMPv6
+	if v4 {
+		request, reply = ipv4.ICMPTypeEcho, ipv4.ICMPTypeEchoReply
+		protocol = 1
+	}
+	var destination net.Addr = &net.UDPAddr{IP: target}
+	if raw {
+		destination = &net.IPAddr{IP: target}
+	}
+
+	// Datagram sockets rewrite the ID to the socket's own, so only raw sockets
+	// need it to tell their replies apart
+	id := int(atomic.AddUint32(&icmpEchoID, 1) & 0xffff)
+	stats := echoStats{}
+	var total time.Duration
+	buf := make([]byte, 1500)
+	for seq := 0; seq < count; seq++ {
+		if seq > 0 {
+			time.Sleep(icmpInterval)
+		}
+		msg := icmp.Message{Type: request, Body: &icmp.Echo{ID: id, Seq: seq, Data: icmpEchoData}}
+		packet, err := msg.Marshal(nil)
+		if err != nil {
+			stats.err = err
+			break
+		}
+		sent := time.Now()
+		if _, err := conn.WriteTo(packet, destination); err != nil {
+			stats.err = fmt.Errorf("sending echo request: %w", err)
+			break
+		}
+		stats.sent++
+
+		conn.SetReadDeadline(sent.Add(icmpReplyTimeout))
+		for {
+			n, peer, err := conn.ReadFrom(buf)
+			if err != nil {
+				break // deadline passed: this request is lost
+			}
+			parsed, err := icmp.ParseMessage(protocol, buf[:n])
+			if err != nil || parsed.Type != reply {
+				continue
+			}
+			echo, ok := parsed.Body.(*icmp.Echo)
+			if !ok || echo.Seq != seq || (raw && echo.ID != id) || !peerIP(peer).Equal(target) {
+				continue
+			}
+			if !bytes.Equal(echo.Data, icmpEchoData) {
+				continue
+			}
+			stats.received++
+			total += time.Since(sent)
+			break
+		}
+	}
+	if stats.received > 0 {
+		stats.rtt = total / time.Duration(stats.received)
+	}
+	return stats
+}
+
+// listenICMP opens an unprivileged ICMP datagram socket, which Linux allows for
+// groups in net.ipv4.ping_group_range, and falls back to a raw socket, which
+// needs CAP_NET_RAW
+func listenICMP(v4 bool) (*icmp.PacketConn, bool, error) {
+	datagram, rawNetwork, address := "udp6", "ip6:ipv6-icmp", "::"
+	if v4 {
+		datagram, rawNetwork, address = "udp4", "ip4:icmp", "0.0.0.0"
+	}
+	if conn, err := icmp.ListenPacket(datagram, address); err == nil {
+		return conn, false, nil
+	}
+	conn, err := icmp.ListenPacket(rawNetwork, address)
+	if err != nil {
+		if os.IsPermission(err) {
+			return nil, false, fmt.Errorf("ICMP sockets need net.ipv4.ping_group_range or CAP_NET_RAW: %w", err)
+		}
+		return nil, false, err
+	}
+	return conn, true, nil
+}
+
+func peerIP(addr net.Addr) net.IP {
+	switch peer := addr.(type) {
+	case *net.UDPAddr:
+		return peer.IP
+	case *net.IPAddr:
+		return peer.IP
+	}
+	return nil
+}
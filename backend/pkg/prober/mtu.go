@@ -0,0 +1,409 @@
+package prober
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// ProbeTypeMTU is recorded for path MTU discovery probes
+const ProbeTypeMTU = "MTU"
+
+// Networks a node pair's path MTU is discovered over
+const (
+	MTUNetworkNode = "node" // between the nodes' internal IPs
+	MTUNetworkPod  = "pod"  // to a pod on the other node, through the CNI's overlay or routes
+)
+
+// Ways a path stops carrying larger packets
+const (
+	MTULimitFragNeeded = "frag-needed" // a hop reported its MTU, so path MTU discovery works
+	MTULimitBlackhole  = "blackhole"   // larger packets vanished without an ICMP error
+)
+
+// Path MTU discovery defaults
+const (
+	DefaultMTUInterval = 10 * time.Minute
+	mtuFloorV4         = 576  // every IPv4 path must carry this much
+	mtuFloorV6         = 1280 // every IPv6 path must carry this much
+	mtuCeiling         = 9000 // searched up to when the interface MTU is unknown
+	mtuMaxPacket       = 65535
+	mtuAttempts        = 2 // lost echoes before a size is judged too big
+	mtuStaleCycles     = 3 // intervals after which a path's result is dropped
+)
+
+// MTUInfo is the outcome of path MTU discovery. Sizes include the IP header.
+type MTUInfo struct {
+	PathMTU      int    `json:"path_mtu"`                // largest packet that got through with DF set
+	InterfaceMTU int    `json:"interface_mtu,omitempty"` // MTU of the interface holding the probe's source address
+	Interface    string `json:"interface,omitempty"`
+	Limit        string `json:"limit,omitempty"` // why larger packets did not get through; empty when the interface was the limit
+}
+
+// PathMTU is the latest path MTU discovered from one node to another
+type PathMTU struct {
+	SourceNode   string    `json:"source_node"`
+	TargetNode   string    `json:"target_node"`
+	Network      string    `json:"network"` // node or pod
+	TargetIP     string    `json:"target_ip"`
+	TargetPod    string    `json:"target_pod,omitempty"` // namespace/name probed over the pod network
+	PathMTU      int       `json:"path_mtu,omitempty"`
+	InterfaceMTU int       `json:"interface_mtu,omitempty"`
+	Interface    string    `json:"interface,omitempty"`
+	Limit        string    `json:"limit,omitempty"`
+	Mismatch     bool      `json:"mismatch"` // the path carries less than the interface sends
+	Error        string    `json:"error,omitempty"`
+	MeasuredAt   time.Time `json:"measured_at"`
+}
+
+// mtuState schedules path MTU discovery and keeps each path's latest result,
+// which would otherwise soon be pushed out of the prober's recent results
+type mtuState struct {
+	interval  time.Duration
+	lastRound time.Time
+	paths     map[string]ProbeResult
+	mu        sync.Mutex
+}
+
+// SetMTUInterval sets how often agents rediscover the path MTU between nodes.
+// Zero disables path MTU discovery.
+func (p *Prober) SetMTUInterval(interval time.Duration) error {
+	if interval < 0 {
+		return fmt.Errorf("MTU interval must not be negative, got %v", interval)
+	}
+	p.mtu.mu.Lock()
+	defer p.mtu.mu.Unlock()
+	p.mtu.interval = interval
+	return nil
+}
+
+// PathMTUs returns the latest path MTU for each node pair and network, sorted
+// by source node, target node and network
+func (p *Prober) PathMTUs() []PathMTU {
+	p.mtu.mu.Lock()
+	defer p.mtu.mu.Unlock()
+
+	paths := make([]PathMTU, 0, len(p.mtu.paths))
+	for _, result := range p.mtu.paths {
+		path := PathMTU{
+			SourceNode: result.Node,
+			TargetNode: result.TargetNode,
+			Network:    MTUNetworkNode,
+			TargetIP:   result.TargetIP,
+			Error:      result.Error,
+			MeasuredAt: result.Timestamp,
+		}
+		if result.TargetPod != "" {
+			path.Network = MTUNetworkPod
+			path.TargetPod = result.TargetNS + "/" + result.TargetPod
+		}
+		if info := result.MTU; info != nil {
+			path.PathMTU = info.PathMTU
+			path.InterfaceMTU = info.InterfaceMTU
+			path.Interface = info.Interface
+			path.Limit = info.Limit
+			path.Mismatch = info.InterfaceMTU > 0 && info.PathMTU < min(info.InterfaceMTU, mtuMaxPacket)
+		}
+		paths = append(paths, path)
+	}
+	sort.Slice(paths, func(i, j int) bool {
+		if paths[i].SourceNode != paths[j].SourceNode {
+			return paths[i].SourceNode < paths[j].SourceNode
+		}
+		if paths[i].TargetNode != paths[j].TargetNode {
+			return paths[i].TargetNode < paths[j].TargetNode
+		}
+		return paths[i].Network < paths[j].Network
+	})
+	return paths
+}
+
+// due reports whether a round of path MTU discovery should start now, and if
+// so marks it started
+func (m *mtuState) due(now time.Time) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.interval == 0 || now.Sub(m.lastRound) < m.interval {
+		return false
+	}
+	m.lastRound = now
+	return true
+}
+
+// record keeps an agent's MTU result as its path's latest and drops paths not
+// measured for several intervals, such as those to removed nodes
+func (m *mtuState) record(result ProbeResult) {
+	if result.Node == "" || result.TargetNode == "" {
+		return
+	}
+	key := fmt.Sprintf("%s->%s %s", result.Node, result.TargetNode, MTUNetworkNode)
+	if result.TargetPod != "" {
+		key = fmt.Sprintf("%s->%s %s", result.Node, result.TargetNode, MTUNetworkPod)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.paths == nil {
+		m.paths = make(map[string]ProbeResult)
+	}
+	m.paths[key] = result
+	if m.interval > 0 {
+		cutoff := result.Timestamp.Add(-mtuStaleCycles * m.interval)
+		for key, path := range m.paths {
+			if path.Timestamp.Before(cutoff) {
+				delete(m.paths, key)
+			}
+		}
+	}
+}
+
+// assignMTUProbes asks each agent to discover the path MTU to every other node,
+// over the node network to its internal IP and over the pod network to one of
+// its pods. Agents run with the host's network, so pod network probes leave
+// through the CNI's overlay or routes rather than a pod's own interface.
+func (p *Prober) assignMTUProbes(agents *AgentHub, sources []string, nodes []corev1.Node, pods []corev1.Pod) {
+	podOnNode := make(map[string]corev1.Pod)
+	for _, pod := range pods {
+		if pod.Spec.HostNetwork || pod.Spec.NodeName == "" || pod.Status.PodIP == "" {
+			continue
+		}
+		// The same pod each round, so a path's results stay comparable
+		current, ok := podOnNode[pod.Spec.NodeName]
+		if !ok || pod.Namespace+"/"+pod.Name < current.Namespace+"/"+current.Name {
+			podOnNode[pod.Spec.NodeName] = pod
+		}
+	}
+
+	timeout := int64(icmpReplyTimeout / time.Millisecond)
+	for _, source := range sources {
+		for _, node := range nodes {
+			if node.Name == source {
+				continue
+			}
+			if ip := nodeInternalIP(node); ip != "" {
+				agents.Assign(source, ProbeAssignment{
+					TargetNode: node.Name,
+					TargetIP:   ip,
+					ProbeType:  ProbeTypeMTU,
+					TimeoutMs:  timeout,
+				})
+			}
+			if pod, ok := podOnNode[node.Name]; ok {
+				agents.Assign(source, ProbeAssignment{
+					TargetPod:  pod.Name,
+					TargetNS:   pod.Namespace,
+					TargetNode: node.Name,
+					TargetIP:   pod.Status.PodIP,
+					ProbeType:  ProbeTypeMTU,
+					TimeoutMs:  timeout,
+				})
+			}
+		}
+	}
+}
+
+// checkMTU discovers the path MTU to the result's target. The probe succeeds
+// when the smallest size every path must carry gets an echo reply; its round
+// trip is the probe's latency.
+func checkMTU(result *ProbeResult, replyTimeout time.Duration) {
+	info, rtt, err := discoverPathMTU(result.TargetIP, replyTimeout)
+	result.Latency = rtt.Milliseconds()
+	if err != nil {
+		result.Success = false
+		result.Error = err.Error()
+		return
+	}
+	result.MTU = info
+	result.Success = true
+}
+
+// mtuOutcome is what happened to an echo request of one size
+type mtuOutcome int
+
+const (
+	mtuFits    mtuOutcome = iota // an echo reply came back
+	mtuRefused                   // the kernel refused to send it, knowing the path MTU is smaller
+	mtuLost                      // no reply
+)
+
+// dfEcho sends don't-fragment echo requests of a chosen size to one target
+type dfEcho struct {
+	conn     net.PacketConn
+	raw      bool
+	target   net.IP
+	dest     net.Addr
+	request  icmp.Type
+	reply    icmp.Type
+	protocol int
+	overhead int // IP and ICMP header bytes
+	id       int
+	seq      int
+	timeout  time.Duration
+}
+
+// discoverPathMTU binary searches for the largest don't-fragment echo request
+// that reaches ip, between the size every path must carry and the MTU of the
+// interface it leaves through. A hop that reports a smaller MTU makes the
+// kernel refuse larger sends from then on; a hop that drops them silently is
+// only noticed by the missing replies.
+func discoverPathMTU(ip string, replyTimeout time.Duration) (*MTUInfo, time.Duration, error) {
+	target := net.ParseIP(ip)
+	if target == nil {
+		return nil, 0, fmt.Errorf("invalid target IP %q", ip)
+	}
+	v4 := target.To4() != nil
+	conn, raw, err := listenDF(v4)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer conn.Close()
+
+	echo := &dfEcho{
+		conn:     conn,
+		raw:      raw,
+		target:   target,
+		dest:     &net.UDPAddr{IP: target},
+		request:  ipv6.ICMPTypeEchoRequest,
+		reply:    ipv6.ICMPTypeEchoReply,
+		protocol: 58, // ICMPv6
+		overhead: 40 + 8,
+		id:       int(atomic.AddUint32(&icmpEchoID, 1) & 0xffff),
+		timeout:  replyTimeout,
+	}
+	floor := mtuFloorV6
+	if v4 {
+		echo.request, echo.reply, echo.protocol, echo.overhead = ipv4.ICMPTypeEcho, ipv4.ICMPTypeEchoReply, 1, 20+8
+		floor = mtuFloorV4
+	}
+	if raw {
+		echo.dest = &net.IPAddr{IP: target}
+	}
+
+	info := &MTUInfo{}
+	ceiling := mtuCeiling
+	if iface, err := routeInterface(target); err == nil {
+		info.Interface = iface.Name
+		info.InterfaceMTU = iface.MTU
+		if iface.MTU >= floor {
+			ceiling = min(iface.MTU, mtuMaxPacket) // loopback MTUs exceed the largest IP packet
+		}
+	}
+
+	outcome, rtt, err := echo.send(floor)
+	if err != nil {
+		return nil, 0, err
+	}
+	if outcome != mtuFits {
+		return nil, 0, fmt.Errorf("no echo replies to %d-byte requests with DF set", floor)
+	}
+
+	// fits is the largest size known to get through; above, the smallest known not to
+	fits, above := floor, ceiling+1
+	var limit mtuOutcome
+	for fits+1 < above {
+		size := (fits + above) / 2
+		if fits == floor && above == ceiling+1 {
+			size = ceiling // most paths carry the full interface MTU
+		}
+		outcome, _, err := echo.send(size)
+		if err != nil {
+			return nil, rtt, err
+		}
+		if outcome == mtuFits {
+			fits = size
+		} else {
+			above, limit = size, outcome
+		}
+	}
+
+	info.PathMTU = fits
+	if fits < ceiling {
+		info.Limit = MTULimitBlackhole
+		if limit == mtuRefused {
+			info.Limit = MTULimitFragNeeded
+		}
+	}
+	return info, rtt, nil
+}
+
+// send tries an echo request of size bytes, headers included, up to
+// mtuAttempts times. A retry after a lost request also catches a hop's
+// fragmentation needed error, which the kernel applies to later sends.
+func (e *dfEcho) send(size int) (mtuOutcome, time.Duration, error) {
+	data := make([]byte, size-e.overhead)
+	copy(data, icmpEchoData)
+	buf := make([]byte, size+64)
+	for attempt := 0; attempt < mtuAttempts; attempt++ {
+		e.seq++
+		msg := icmp.Message{Type: e.request, Body: &icmp.Echo{ID: e.id, Seq: e.seq, Data: data}}
+		packet, err := msg.Marshal(nil)
+		if err != nil {
+			return mtuLost, 0, err
+		}
+		sent := time.Now()
+		if _, err := e.conn.WriteTo(packet, e.dest); err != nil {
+			if isMessageTooLong(err) {
+				return mtuRefused, 0, nil
+			}
+			return mtuLost, 0, fmt.Errorf("sending echo request: %w", err)
+		}
+
+		e.conn.SetReadDeadline(sent.Add(e.timeout))
+		for {
+			n, peer, err := e.conn.ReadFrom(buf)
+			if err != nil {
+				break // deadline passed: this request is lost
+			}
+			parsed, err := icmp.ParseMessage(e.protocol, buf[:n])
+			if err != nil || parsed.Type != e.reply {
+				continue
+			}
+			reply, ok := parsed.Body.(*icmp.Echo)
+			if !ok || reply.Seq != e.seq || (e.raw && reply.ID != e.id) || !peerIP(peer).Equal(e.target) {
+				continue
+			}
+			if !bytes.Equal(reply.Data, data) {
+				continue
+			}
+			return mtuFits, time.Since(sent), nil
+		}
+	}
+	return mtuLost, 0, nil
+}
+
+// routeInterface returns the interface holding the source address the host
+// picks for ip. Connecting a UDP socket picks the route without sending.
+func routeInterface(ip net.IP) (net.Interface, error) {
+	conn, err := net.Dial("udp", net.JoinHostPort(ip.String(), "9"))
+	if err != nil {
+		return net.Interface{}, err
+	}
+	local := conn.LocalAddr().(*net.UDPAddr).IP
+	conn.Close()
+
+	interfaces, err := net.Interfaces()
+	if err != nil {
+		return net.Interface{}, err
+	}
+	for _, iface := range interfaces {
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			if network, ok := addr.(*net.IPNet); ok && network.IP.Equal(local) {
+				return iface, nil
+			}
+		}
+	}
+	return net.Interface{}, fmt.Errorf("no interface has source address %s", local)
+}
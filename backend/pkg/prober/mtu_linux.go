@@ -0,0 +1,54 @@
+//go:build linux
+
+package prober
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+)
+
+// listenDF opens an ICMP socket whose packets carry the don't-fragment bit, so
+// that echo requests larger than the path MTU are dropped or refused rather
+// than fragmented. Like listenICMP it prefers an unprivileged datagram socket
+// and falls back to a raw one.
+func listenDF(v4 bool) (net.PacketConn, bool, error) {
+	family, protocol, level, option, value := syscall.AF_INET6, syscall.IPPROTO_ICMPV6, syscall.IPPROTO_IPV6, syscall.IPV6_MTU_DISCOVER, syscall.IPV6_PMTUDISC_DO
+	if v4 {
+		family, protocol, level, option, value = syscall.AF_INET, syscall.IPPROTO_ICMP, syscall.IPPROTO_IP, syscall.IP_MTU_DISCOVER, syscall.IP_PMTUDISC_DO
+	}
+	if conn, err := dfSocket(family, syscall.SOCK_DGRAM, protocol, level, option, value); err == nil {
+		return conn, false, nil
+	}
+	conn, err := dfSocket(family, syscall.SOCK_RAW, protocol, level, option, value)
+	if err != nil {
+		if os.IsPermission(err) {
+			return nil, false, fmt.Errorf("ICMP sockets need net.ipv4.ping_group_range or CAP_NET_RAW: %w", err)
+		}
+		return nil, false, err
+	}
+	return conn, true, nil
+}
+
+func dfSocket(family, sotype, protocol, level, option, value int) (net.PacketConn, error) {
+	fd, err := syscall.Socket(family, sotype|syscall.SOCK_CLOEXEC, protocol)
+	if err != nil {
+		return nil, os.NewSyscallError("socket", err)
+	}
+	if err := syscall.SetsockoptInt(fd, level, option, value); err != nil {
+		syscall.Close(fd)
+		return nil, os.NewSyscallError("setsockopt", err)
+	}
+	// FilePacketConn duplicates the descriptor, so the file is closed either way
+	file := os.NewFile(uintptr(fd), "netvis-mtu")
+	defer file.Close()
+	return net.FilePacketConn(file)
+}
+
+// isMessageTooLong reports whether a send was refused for exceeding the MTU the
+// kernel knows for the path
+func isMessageTooLong(err error) bool {
+	return errors.Is(err, syscall.EMSGSIZE)
+}
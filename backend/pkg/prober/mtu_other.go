@@ -0,0 +1,18 @@
+//go:build !linux
+
+package prober
+
+import (
+	"fmt"
+	"net"
+	"runtime"
+)
+
+// listenDF is only implemented on Linux, where the probe agents run
+func listenDF(v4 bool) (net.PacketConn, bool, error) {
+	return nil, false, fmt.Errorf("path MTU discovery is not supported on %s", runtime.GOOS)
+}
+
+func isMessageTooLong(err error) bool {
+	return false
+}
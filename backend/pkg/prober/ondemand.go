@@ -0,0 +1,424 @@
+package prober
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// On-demand probe limits
+const (
+	maxOnDemandProbes  = 100 // probes per request, over all ports and repetitions
+	maxOnDemandTimeout = 30 * time.Second
+	defaultOnDemandMs  = 5000
+	maxProbeRuns       = 100 // finished runs kept for lookup by ID
+	probeRunRetention  = 10 * time.Minute
+)
+
+// Probe run states
+const (
+	ProbeRunRunning = "running"
+	ProbeRunDone    = "done"
+)
+
+// ProbeRequest asks for probes to run now from a source pod. The target is a
+// pod or Service as namespace/name, optionally followed by ":port", or an
+// IP address with an optional port.
+type ProbeRequest struct {
+	Source    string `json:"source"`               // namespace/pod
+	Target    string `json:"target"`               // namespace/name[:port], IP or IP:port
+	Port      int32  `json:"port,omitempty"`       // overrides a port in Target
+	Type      string `json:"type,omitempty"`       // TCP, UDP, ICMP, HTTP, gRPC or TLS; chosen per port when empty
+	Count     int    `json:"count,omitempty"`      // repetitions, or echo requests for ICMP
+	TimeoutMs int64  `json:"timeout_ms,omitempty"` // per probe
+	Async     bool   `json:"async,omitempty"`      // return a run ID at once instead of waiting
+}
+
+// ProbeRun is the state and results of an on-demand probe request
+type ProbeRun struct {
+	ID         string        `json:"id"`
+	Request    ProbeRequest  `json:"request"`
+	Status     string        `json:"status"`
+	Success    bool          `json:"success"` // every probe succeeded
+	Results    []ProbeResult `json:"results"`
+	StartedAt  time.Time     `json:"started_at"`
+	FinishedAt time.Time     `json:"finished_at,omitempty"`
+
+	done chan struct{} // closed when the run finishes
+}
+
+// onDemandProbe is one resolved probe of a request
+type onDemandProbe struct {
+	result ProbeResult // target fields filled in
+	spec   ProbeSpec
+}
+
+// probeRuns keeps on-demand runs for lookup by ID
+type probeRuns struct {
+	runs  map[string]*ProbeRun
+	order []string
+	count int
+	mu    sync.Mutex
+}
+
+// RunProbe resolves a probe request and runs it. Synchronous requests return
+// once every probe has finished; async ones return the running run, whose
+// results GetProbeRun reports later. On-demand probes are always run by this
+// process, never handed to node agents, and are recorded with the cycle's
+// results. Results name the source pod only when they were dialed inside it.
+func (p *Prober) RunProbe(ctx context.Context, req ProbeRequest) (*ProbeRun, error) {
+	probes, source, err := p.resolveProbeRequest(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	run := p.runs.start(req)
+	execute := func() {
+		timeout := time.Duration(req.TimeoutMs) * time.Millisecond
+		if timeout <= 0 {
+			timeout = defaultOnDemandMs * time.Millisecond
+		}
+		var results []ProbeResult
+		for _, probe := range probes {
+			result := p.runOnDemand(source, probe, req.Count, timeout)
+			result.Verdict = p.expectedVerdict(result)
+			p.addResult(result)
+			results = append(results, result)
+		}
+		p.runs.finish(run.ID, results)
+	}
+
+	if req.Async {
+		go execute()
+		return p.runs.get(run.ID), nil
+	}
+	execute()
+	return p.runs.get(run.ID), nil
+}
+
+// GetProbeRun returns an on-demand run by ID
+func (p *Prober) GetProbeRun(id string) (*ProbeRun, bool) {
+	run := p.runs.get(id)
+	return run, run != nil
+}
+
+// resolveProbeRequest validates a request and expands it into one probe per
+// target port and repetition
+func (p *Prober) resolveProbeRequest(ctx context.Context, req ProbeRequest) ([]onDemandProbe, corev1.Pod, error) {
+	var source corev1.Pod
+	if p.client == nil {
+		return nil, source, fmt.Errorf("no Kubernetes client")
+	}
+	probeType, err := onDemandType(req.Type)
+	if err != nil {
+		return nil, source, err
+	}
+	if req.Count < 0 || req.TimeoutMs < 0 {
+		return nil, source, fmt.Errorf("count and timeout_ms must not be negative")
+	}
+	if time.Duration(req.TimeoutMs)*time.Millisecond > maxOnDemandTimeout {
+		return nil, source, fmt.Errorf("timeout_ms must be at most %d", maxOnDemandTimeout.Milliseconds())
+	}
+
+	sourceNS, sourceName, ok := strings.Cut(req.Source, "/")
+	if !ok || sourceNS == "" || sourceName == "" {
+		return nil, source, fmt.Errorf("source must be namespace/pod, got %q", req.Source)
+	}
+	pod, err := p.client.Clientset().CoreV1().Pods(sourceNS).Get(ctx, sourceName, metav1.GetOptions{})
+	if err != nil {
+		return nil, source, fmt.Errorf("source pod %s: %w", req.Source, err)
+	}
+	source = *pod
+	if source.Status.Phase != corev1.PodRunning {
+		return nil, source, fmt.Errorf("source pod %s is %s, not running", req.Source, source.Status.Phase)
+	}
+
+	targets, err := p.resolveProbeTarget(ctx, req.Target, req.Port, probeType)
+	if err != nil {
+		return nil, source, err
+	}
+
+	repeat := req.Count
+	if repeat < 1 || probeType == ProbeTypeICMP {
+		repeat = 1 // ICMP sends Count echo requests in one probe
+	}
+	if len(targets)*repeat > maxOnDemandProbes {
+		return nil, source, fmt.Errorf("request expands to %d probes, the limit is %d", len(targets)*repeat, maxOnDemandProbes)
+	}
+	var probes []onDemandProbe
+	for i := 0; i < repeat; i++ {
+		probes = append(probes, targets...)
+	}
+	return probes, source, nil
+}
+
+// resolveProbeTarget finds the ports to probe on a Service, pod or address
+func (p *Prober) resolveProbeTarget(ctx context.Context, target string, port int32, probeType string) ([]onDemandProbe, error) {
+	name, portValue := target, ""
+	if host, value, err := net.SplitHostPort(target); err == nil {
+		name, portValue = host, value
+	} else if i := strings.LastIndex(target, ":"); i >= 0 && strings.Contains(target, "/") {
+		name, portValue = target[:i], target[i+1:]
+	}
+	if port == 0 && portValue != "" {
+		parsed, err := strconv.ParseInt(portValue, 10, 32)
+		if err != nil || parsed <= 0 || parsed > 65535 {
+			return nil, fmt.Errorf("invalid port %q in target %q", portValue, target)
+		}
+		port = int32(parsed)
+	}
+
+	// A bare address
+	if ip := net.ParseIP(name); ip != nil {
+		if port == 0 && probeType != ProbeTypeICMP {
+			return nil, fmt.Errorf("a port is required to probe %s", name)
+		}
+		if probeType == "" {
+			probeType = ProbeTypeTCP
+		}
+		return []onDemandProbe{{
+			result: ProbeResult{TargetIP: ip.String(), TargetPort: port},
+			spec:   ProbeSpec{Type: probeType},
+		}}, nil
+	}
+
+	namespace, objectName, ok := strings.Cut(name, "/")
+	if !ok || namespace == "" || objectName == "" {
+		return nil, fmt.Errorf("target must be namespace/name, IP or IP:port, got %q", target)
+	}
+	clientset := p.client.Clientset()
+
+	// A Service, or a pod when no Service has the name
+	svc, err := clientset.CoreV1().Services(namespace).Get(ctx, objectName, metav1.GetOptions{})
+	if err == nil {
+		if svc.Spec.ClusterIP == "" || svc.Spec.ClusterIP == corev1.ClusterIPNone {
+			return nil, fmt.Errorf("service %s is headless; probe one of its pods instead", name)
+		}
+		if probeType == ProbeTypeICMP {
+			return nil, fmt.Errorf("service %s has a virtual IP that does not answer ICMP; probe one of its pods instead", name)
+		}
+		var probes []onDemandProbe
+		for _, servicePort := range svc.Spec.Ports {
+			if port != 0 && servicePort.Port != port {
+				continue
+			}
+			spec := SelectProbe(*svc, servicePort)
+			if probeType != "" && probeType != spec.Type {
+				spec = ProbeSpec{Type: probeType, SelectedBy: "request"}
+			}
+			if spec.Type == ProbeTypeTLS {
+				spec = p.tlsSpec(*svc, spec)
+			}
+			probes = append(probes, onDemandProbe{
+				result: ProbeResult{TargetSvc: svc.Name, TargetNS: svc.Namespace, TargetIP: svc.Spec.ClusterIP, TargetPort: servicePort.Port},
+				spec:   spec,
+			})
+		}
+		if len(probes) == 0 {
+			return nil, fmt.Errorf("service %s has no port %d", name, port)
+		}
+		return probes, nil
+	}
+
+	pod, podErr := clientset.CoreV1().Pods(namespace).Get(ctx, objectName, metav1.GetOptions{})
+	if podErr != nil {
+		return nil, fmt.Errorf("no service or pod %s", name)
+	}
+	if pod.Status.PodIP == "" {
+		return nil, fmt.Errorf("pod %s has no IP", name)
+	}
+	base := ProbeResult{TargetPod: pod.Name, TargetNS: pod.Namespace, TargetIP: pod.Status.PodIP}
+	if probeType == ProbeTypeICMP {
+		return []onDemandProbe{{result: base, spec: ProbeSpec{Type: ProbeTypeICMP}}}, nil
+	}
+
+	var probes []onDemandProbe
+	if port != 0 {
+		result := base
+		result.TargetPort = port
+		if probeType == "" {
+			probeType = ProbeTypeTCP
+			for _, container := range pod.Spec.Containers {
+				for _, containerPort := range container.Ports {
+					if containerPort.ContainerPort == port && containerPort.Protocol == corev1.ProtocolUDP {
+						probeType = ProbeTypeUDP
+					}
+				}
+			}
+		}
+		return []onDemandProbe{{result: result, spec: ProbeSpec{Type: probeType}}}, nil
+	}
+	for _, container := range pod.Spec.Containers {
+		for _, containerPort := range container.Ports {
+			portType := probeType
+			if portType == "" {
+				portType = ProbeTypeTCP
+				if containerPort.Protocol == corev1.ProtocolUDP {
+					portType = ProbeTypeUDP
+				}
+			}
+			result := base
+			result.TargetPort = containerPort.ContainerPort
+			probes = append(probes, onDemandProbe{result: result, spec: ProbeSpec{Type: portType}})
+		}
+	}
+	if len(probes) == 0 {
+		return nil, fmt.Errorf("pod %s declares no container ports; give a port", name)
+	}
+	return probes, nil
+}
+
+// onDemandType normalises a requested probe type
+func onDemandType(value string) (string, error) {
+	if value == "" {
+		return "", nil
+	}
+	for _, probeType := range []string{ProbeTypeTCP, ProbeTypeUDP, ProbeTypeICMP, ProbeTypeHTTP, ProbeTypeGRPC, ProbeTypeTLS} {
+		if strings.EqualFold(value, probeType) {
+			return probeType, nil
+		}
+	}
+	return "", fmt.Errorf("unknown probe type %q, expected TCP, UDP, ICMP, HTTP, gRPC or TLS", value)
+}
+
+// runOnDemand runs one resolved probe. Only TCP probes in exec mode run from
+// the source pod; the others are dialed locally and recorded without a source.
+func (p *Prober) runOnDemand(source corev1.Pod, probe onDemandProbe, count int, timeout time.Duration) ProbeResult {
+	result := probe.result
+	result.Timestamp = time.Now()
+	result.SourcePod = source.Name
+	result.SourceNS = source.Namespace
+	result.ProbeType = probe.spec.Type
+	result.OnDemand = true
+
+	switch probe.spec.Type {
+	case ProbeTypeHTTP:
+		result.ExecMode = ExecModeLocal
+		checkHTTP(&result, probe.spec, timeout)
+	case ProbeTypeGRPC:
+		result.ExecMode = ExecModeLocal
+		checkGRPC(&result, probe.spec, timeout)
+	case ProbeTypeUDP:
+		result.ExecMode = ExecModeLocal
+		checkUDP(&result, probe.spec, timeout)
+	case ProbeTypeTLS:
+		result.ExecMode = ExecModeLocal
+		checkTLS(&result, probe.spec, timeout, p.tlsRootPool())
+	case ProbeTypeICMP:
+		if count < 1 {
+			count = p.ICMPCount()
+		}
+		result.ExecMode = ExecModeLocal
+		p.detectPacketLoss(result.TargetIP, count).apply(&result)
+	default:
+		p.dial(source, result.TargetIP, result.TargetPort, timeout).apply(&result)
+	}
+
+	// Only probes dialed inside the pod tested its network; the rest came from
+	// this process and are not attributed to it
+	if result.ExecMode != ExecModeExec {
+		result.SourcePod = ""
+		result.SourceNS = ""
+	}
+	return result
+}
+
+func (r *probeRuns) start(req ProbeRequest) *ProbeRun {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.runs == nil {
+		r.runs = make(map[string]*ProbeRun)
+	}
+	r.prune()
+	r.count++
+	run := &ProbeRun{
+		ID:        fmt.Sprintf("run-%d-%d", time.Now().Unix(), r.count),
+		Request:   req,
+		Status:    ProbeRunRunning,
+		Results:   []ProbeResult{},
+		StartedAt: time.Now(),
+		done:      make(chan struct{}),
+	}
+	r.runs[run.ID] = run
+	r.order = append(r.order, run.ID)
+	return run
+}
+
+func (r *probeRuns) finish(id string, results []ProbeResult) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	run, ok := r.runs[id]
+	if !ok || run.Status != ProbeRunRunning {
+		return
+	}
+	run.Results = results
+	run.Status = ProbeRunDone
+	run.Success = true
+	for _, result := range results {
+		if !result.Success {
+			run.Success = false
+		}
+	}
+	run.FinishedAt = time.Now()
+	close(run.done)
+}
+
+// wait blocks until the run finishes or the context ends
+func (r *probeRuns) wait(ctx context.Context, id string) {
+	r.mu.Lock()
+	run, ok := r.runs[id]
+	r.mu.Unlock()
+	if !ok {
+		return
+	}
+	select {
+	case <-run.done:
+	case <-ctx.Done():
+	}
+}
+
+// get returns a copy of the run so callers never see it change
+func (r *probeRuns) get(id string) *ProbeRun {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	run, ok := r.runs[id]
+	if !ok {
+		return nil
+	}
+	copied := *run
+	copied.Results = append([]ProbeResult{}, run.Results...)
+	return &copied
+}
+
+// prune drops finished runs past their retention, and the oldest finished ones
+// beyond maxProbeRuns
+func (r *probeRuns) prune() {
+	cutoff := time.Now().Add(-probeRunRetention)
+	finished := 0
+	for _, id := range r.order {
+		if r.runs[id].Status != ProbeRunRunning {
+			finished++
+		}
+	}
+	kept := r.order[:0]
+	for _, id := range r.order {
+		run := r.runs[id]
+		if run.Status != ProbeRunRunning && (run.FinishedAt.Before(cutoff) || finished > maxProbeRuns) {
+			delete(r.runs, id)
+			finished--
+			continue
+		}
+		kept = append(kept, id)
+	}
+	r.order = kept
+}
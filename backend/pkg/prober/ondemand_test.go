@@ -0,0 +1,46 @@
+package prober
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestRunOnDemandLeavesLocalProbesUnattributed(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+	port := int32(listener.Addr().(*net.TCPAddr).Port)
+
+	source := corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "shop", Name: "web"}}
+	p := NewProber(nil)
+	for _, probeType := range []string{ProbeTypeTCP, ProbeTypeHTTP, ProbeTypeTLS} {
+		t.Run(probeType, func(t *testing.T) {
+			probe := onDemandProbe{
+				result: ProbeResult{TargetIP: "127.0.0.1", TargetPort: port},
+				spec:   ProbeSpec{Type: probeType},
+			}
+			result := p.runOnDemand(source, probe, 1, time.Second)
+			if result.ExecMode != ExecModeLocal {
+				t.Errorf("ExecMode = %q, want %q", result.ExecMode, ExecModeLocal)
+			}
+			if result.SourcePod != "" || result.SourceNS != "" {
+				t.Errorf("source = %s/%s, want none for a probe dialed locally", result.SourceNS, result.SourcePod)
+			}
+		})
+	}
+}
@@ -0,0 +1,168 @@
+package prober
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/christine33-creator/k8-network-visualizer/pkg/simulator"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// Verdicts current NetworkPolicies give a probe's path
+const (
+	VerdictAllowed = "allowed"
+	VerdictDenied  = "denied"
+	VerdictPartial = "partial" // a Service whose backends are treated differently
+)
+
+// policyView is the cluster state probes are checked against, refreshed each
+// cycle
+type policyView struct {
+	evaluator *simulator.PolicyEvaluator
+	pods      map[string]*corev1.Pod     // running pods by namespace/name
+	services  map[string]*corev1.Service // by namespace/name
+}
+
+// refreshPolicies rebuilds the policy view from the cycle's pods and Services
+// and the current NetworkPolicies. Without read access to NetworkPolicies
+// probes are left untagged.
+func (p *Prober) refreshPolicies(ctx context.Context, pods []corev1.Pod, services []corev1.Service) {
+	policies, err := p.client.Clientset().NetworkingV1().NetworkPolicies("").List(ctx, metav1.ListOptions{})
+	if err != nil {
+		fmt.Printf("Failed to list network policies: %v\n", err)
+		p.mu.Lock()
+		p.policies = nil
+		p.mu.Unlock()
+		return
+	}
+	namespaceLabels := make(map[string]map[string]string)
+	if namespaces, err := p.client.Clientset().CoreV1().Namespaces().List(ctx, metav1.ListOptions{}); err == nil {
+		for _, ns := range namespaces.Items {
+			namespaceLabels[ns.Name] = ns.Labels
+		}
+	}
+
+	view := &policyView{
+		pods:     make(map[string]*corev1.Pod, len(pods)),
+		services: make(map[string]*corev1.Service, len(services)),
+	}
+	policyList := make([]*networkingv1.NetworkPolicy, 0, len(policies.Items))
+	for i := range policies.Items {
+		policyList = append(policyList, &policies.Items[i])
+	}
+	view.evaluator = simulator.NewPolicyEvaluator(policyList, namespaceLabels)
+	for i := range pods {
+		view.pods[pods[i].Namespace+"/"+pods[i].Name] = &pods[i]
+	}
+	for i := range services {
+		view.services[services[i].Namespace+"/"+services[i].Name] = &services[i]
+	}
+
+	p.mu.Lock()
+	p.policies = view
+	p.mu.Unlock()
+}
+
+// expectedVerdict returns what current NetworkPolicies say about a probe's
+// path. Only TCP probes dialed inside the source pod go through its egress
+// policies and reach the target as that pod, so other probes get no verdict.
+func (p *Prober) expectedVerdict(result ProbeResult) string {
+	if result.ExecMode != ExecModeExec || result.ProbeType != ProbeTypeTCP || result.SourcePod == "" {
+		return ""
+	}
+
+	p.mu.RLock()
+	view := p.policies
+	p.mu.RUnlock()
+	if view == nil {
+		return ""
+	}
+	source := view.pods[result.SourceNS+"/"+result.SourcePod]
+	if source == nil {
+		return ""
+	}
+
+	switch {
+	case result.TargetSvc != "":
+		svc := view.services[result.TargetNS+"/"+result.TargetSvc]
+		if svc == nil {
+			return ""
+		}
+		return view.serviceVerdict(source, svc, result.TargetPort)
+	case result.TargetPod != "":
+		target := view.pods[result.TargetNS+"/"+result.TargetPod]
+		if target == nil {
+			return ""
+		}
+		return verdictOf(view.evaluator.Allowed(simulator.Connection{
+			Source:      source,
+			Destination: target,
+			Port:        result.TargetPort,
+			Protocol:    corev1.ProtocolTCP,
+		}))
+	}
+	return ""
+}
+
+// serviceVerdict evaluates the path to each of the Service's backends on the
+// target port behind the probed Service port
+func (v *policyView) serviceVerdict(source *corev1.Pod, svc *corev1.Service, port int32) string {
+	if len(svc.Spec.Selector) == 0 {
+		return "" // backends are managed outside the selector
+	}
+	var servicePort *corev1.ServicePort
+	for i := range svc.Spec.Ports {
+		protocol := svc.Spec.Ports[i].Protocol
+		if svc.Spec.Ports[i].Port == port && (protocol == "" || protocol == corev1.ProtocolTCP) {
+			servicePort = &svc.Spec.Ports[i]
+			break
+		}
+	}
+	if servicePort == nil {
+		return ""
+	}
+
+	selector := labels.SelectorFromSet(svc.Spec.Selector)
+	allowed, denied := 0, 0
+	for _, pod := range v.pods {
+		if pod.Namespace != svc.Namespace || !selector.Matches(labels.Set(pod.Labels)) {
+			continue
+		}
+		targetPort := servicePort.TargetPort.IntVal
+		switch {
+		case servicePort.TargetPort.Type == intstr.String:
+			targetPort = simulator.ResolveNamedPort(pod, servicePort.TargetPort.StrVal, corev1.ProtocolTCP)
+			if targetPort == 0 {
+				continue // this backend does not serve the port
+			}
+		case targetPort == 0:
+			targetPort = servicePort.Port
+		}
+		if v.evaluator.Allowed(simulator.Connection{Source: source, Destination: pod, Port: targetPort, Protocol: corev1.ProtocolTCP}) {
+			allowed++
+		} else {
+			denied++
+		}
+	}
+
+	switch {
+	case allowed > 0 && denied > 0:
+		return VerdictPartial
+	case allowed > 0:
+		return VerdictAllowed
+	case denied > 0:
+		return VerdictDenied
+	}
+	return ""
+}
+
+func verdictOf(allowed bool) string {
+	if allowed {
+		return VerdictAllowed
+	}
+	return VerdictDenied
+}
@@ -2,18 +2,15 @@ package prober
 
 import (
 	"context"
+	"crypto/x509"
 	"fmt"
-	"net"
-	"net/http"
 	"sync"
 	"time"
 
+	"github.com/christine33-creator/k8-network-visualizer/pkg/flowcollector"
 	"github.com/christine33-creator/k8-network-visualizer/pkg/k8s"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials/insecure"
-	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 )
 
 // ProbeResult represents the result of a connectivity probe
@@ -24,51 +21,94 @@ type ProbeResult struct {
 	TargetPod   string    `json:"target_pod,omitempty"`
 	TargetNS    string    `json:"target_namespace,omitempty"`
 	TargetSvc   string    `json:"target_service,omitempty"`
+	TargetNode  string    `json:"target_node,omitempty"` // For node-to-node and path MTU probes
 	TargetIP    string    `json:"target_ip"`
 	TargetPort  int32     `json:"target_port"`
-	ProbeType   string    `json:"probe_type"` // TCP, HTTP, gRPC
+	ProbeType   string    `json:"probe_type"` // TCP, UDP, ICMP, HTTP, gRPC, TLS, MTU, DNS
 	Success     bool      `json:"success"`
 	Latency     int64     `json:"latency_ms"`
 	PacketLoss  float64   `json:"packet_loss"` // Percentage of packet loss
+	Sent        int       `json:"packets_sent,omitempty"` // For ICMP probes
+	Received    int       `json:"packets_received,omitempty"` // For ICMP probes
+	NoResponse  bool      `json:"no_response,omitempty"` // UDP probe got neither a reply nor port unreachable
 	Error       string    `json:"error,omitempty"`
 	StatusCode  int       `json:"status_code,omitempty"` // For HTTP probes
+	Path        string    `json:"path,omitempty"` // For HTTP probes
+	GRPCStatus  string    `json:"grpc_status,omitempty"` // Serving status for gRPC probes
+	Expected    string    `json:"expected,omitempty"` // Expected HTTP status codes or gRPC serving status
+	Query       string    `json:"query,omitempty"` // For DNS probes
+	QueryType   string    `json:"query_type,omitempty"` // For DNS probes: A, AAAA, SRV, CNAME
+	Rcode       string    `json:"rcode,omitempty"` // For DNS probes: NOERROR, NXDOMAIN, SERVFAIL...
+	Answers     int       `json:"answers,omitempty"` // For DNS probes
+	TLS         *TLSInfo  `json:"tls,omitempty"` // For TLS probes that completed a handshake
+	MTU         *MTUInfo  `json:"mtu,omitempty"` // For path MTU probes
+	Throughput  *ThroughputInfo `json:"throughput,omitempty"` // For throughput tests
+	ExecMode    string    `json:"exec_mode"` // Where the probe was dialed from
+	Fallback    string    `json:"exec_fallback,omitempty"` // Why an exec probe fell back to a local dial
+	Node        string    `json:"node,omitempty"` // Node whose agent ran the probe
+	OnDemand    bool      `json:"on_demand,omitempty"` // Requested through RunProbe rather than scheduled
+	Verdict     string    `json:"expected_verdict,omitempty"` // allowed, denied or partial under current NetworkPolicies
+	RunID       string    `json:"run_id,omitempty"` // On-demand run an agent's result belongs to
 }
 
 // Prober performs connectivity probes between pods
 type Prober struct {
-	client  *k8s.Client
-	results []ProbeResult
-	mu      sync.RWMutex
+	client        *k8s.Client
+	results       []ProbeResult
+	execMode      string
+	dnsConf       DNSConfig
+	icmpCount     int
+	schedConf     SchedulerConfig
+	coverage      CoverageStats
+	flowCollector flowcollector.FlowCollectorInterface
+	runs          probeRuns
+	history       probeHistory
+	policies      *policyView
+	tlsRoots      *x509.CertPool
+	mtu           mtuState
+	throughput    throughputState
+	agents        *AgentHub
+	mu            sync.RWMutex
 }
 
 // NewProber creates a new prober instance
 func NewProber(client *k8s.Client) *Prober {
 	return &Prober{
-		client:  client,
-		results: make([]ProbeResult, 0),
+		client:    client,
+		results:   make([]ProbeResult, 0),
+		schedConf: DefaultSchedulerConfig(),
+		mtu:       mtuState{interval: DefaultMTUInterval},
 	}
 }
 
-// StartProbing starts the probing process
+// StartProbing starts the probing process. Each cycle after the first starts
+// after a random delay of up to the configured jitter, and skips the probes it
+// cannot start before the next tick.
 func (p *Prober) StartProbing(ctx context.Context, interval time.Duration) {
 	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 
 	// Run initial probe immediately
-	p.runProbes(ctx)
+	p.runProbes(ctx, time.Now().Add(interval))
 
 	for {
 		select {
-		case <-ticker.C:
-			p.runProbes(ctx)
+		case tick := <-ticker.C:
+			select {
+			case <-time.After(p.jitter(interval)):
+			case <-ctx.Done():
+				return
+			}
+			p.runProbes(ctx, tick.Add(interval))
 		case <-ctx.Done():
 			return
 		}
 	}
 }
 
-// runProbes executes all probes
-func (p *Prober) runProbes(ctx context.Context) {
+// runProbes executes all probes. Service and pod-to-pod probes not started by
+// the deadline, and DNS probes not answered by it, are skipped.
+func (p *Prober) runProbes(ctx context.Context, deadline time.Time) {
 	// Get all pods and services
 	pods, err := p.client.Clientset().CoreV1().Pods("").List(ctx, metav1.ListOptions{})
 	if err != nil {
@@ -82,28 +122,38 @@ func (p *Prober) runProbes(ctx context.Context) {
 		return
 	}
 
-	// Probe service endpoints
+	var running []corev1.Pod
+	for _, pod := range pods.Items {
+		if pod.Status.Phase == corev1.PodRunning && pod.Status.PodIP != "" {
+			running = append(running, pod)
+		}
+	}
+	var probed []corev1.Service
 	for _, svc := range services.Items {
 		if svc.Spec.ClusterIP == "None" || svc.Spec.ClusterIP == "" {
 			continue // Skip headless services for now
 		}
+		probed = append(probed, svc)
+	}
 
-		for _, port := range svc.Spec.Ports {
-			// Find pods that can reach this service
-			for _, pod := range pods.Items {
-				if pod.Status.Phase != corev1.PodRunning {
-					continue
-				}
+	// Tag probes with the verdict of the current NetworkPolicies
+	p.refreshPolicies(ctx, running, probed)
 
-				// Perform probe
-				result := p.probeTCP(pod, svc, port)
-				p.addResult(result)
-			}
-		}
-	}
+	// Resolve Service records through the cluster DNS alongside the other
+	// probes, so that both share the cycle's window
+	var dnsDone sync.WaitGroup
+	dnsDone.Add(1)
+	go func() {
+		defer dnsDone.Done()
+		p.probeDNS(ctx, services.Items, deadline)
+	}()
+
+	// Probe service endpoints and pod-to-pod connectivity on the worker pool
+	p.scheduleTasks(ctx, running, probed, deadline)
+	dnsDone.Wait()
 
-	// Probe pod-to-pod connectivity (sample)
-	p.probePodToPod(ctx, pods.Items)
+	// Probe node-to-node connectivity and path MTU from each agent
+	p.probeNodes(ctx, running)
 }
 
 // probeTCP performs a TCP connectivity probe
@@ -119,27 +169,32 @@ func (p *Prober) probeTCP(sourcePod corev1.Pod, targetSvc corev1.Service, port c
 		ProbeType:  "TCP",
 	}
 
-	// Simulate TCP probe (in real implementation, this would be done from within the cluster)
-	start := time.Now()
-	
-	address := fmt.Sprintf("%s:%d", targetSvc.Spec.ClusterIP, port.Port)
-	conn, err := net.DialTimeout("tcp", address, 5*time.Second)
-	
-	result.Latency = time.Since(start).Milliseconds()
-	
-	if err != nil {
-		result.Success = false
-		result.Error = err.Error()
-	} else {
-		result.Success = true
-		conn.Close()
+	dialed := p.dial(sourcePod, targetSvc.Spec.ClusterIP, port.Port, 5*time.Second)
+	dialed.apply(&result)
+
+	return result
+}
+
+// probeHTTP performs an HTTP health probe
+func (p *Prober) probeHTTP(sourcePod corev1.Pod, targetSvc corev1.Service, port corev1.ServicePort, spec ProbeSpec) ProbeResult {
+	result := ProbeResult{
+		Timestamp:  time.Now(),
+		SourcePod:  sourcePod.Name,
+		SourceNS:   sourcePod.Namespace,
+		TargetSvc:  targetSvc.Name,
+		TargetNS:   targetSvc.Namespace,
+		TargetIP:   targetSvc.Spec.ClusterIP,
+		TargetPort: port.Port,
+		ProbeType:  ProbeTypeHTTP,
+		ExecMode:   ExecModeLocal, // dialed by the visualizer's own client
 	}
 
+	checkHTTP(&result, spec, 5*time.Second)
 	return result
 }
 
-// probeHTTP performs an HTTP connectivity probe
-func (p *Prober) probeHTTP(sourcePod corev1.Pod, targetSvc corev1.Service, port corev1.ServicePort) ProbeResult {
+// probeTLS performs a TLS handshake with the service port
+func (p *Prober) probeTLS(sourcePod corev1.Pod, targetSvc corev1.Service, port corev1.ServicePort, spec ProbeSpec) ProbeResult {
 	result := ProbeResult{
 		Timestamp:  time.Now(),
 		SourcePod:  sourcePod.Name,
@@ -148,98 +203,172 @@ func (p *Prober) probeHTTP(sourcePod corev1.Pod, targetSvc corev1.Service, port
 		TargetNS:   targetSvc.Namespace,
 		TargetIP:   targetSvc.Spec.ClusterIP,
 		TargetPort: port.Port,
-		ProbeType:  "HTTP",
+		ProbeType:  ProbeTypeTLS,
+		ExecMode:   ExecModeLocal, // handshaken by the visualizer's own client
 	}
 
-	// Create HTTP client with timeout
-	client := &http.Client{
-		Timeout: 5 * time.Second,
+	checkTLS(&result, spec, 5*time.Second, p.tlsRootPool())
+	return result
+}
+
+// probeUDP sends a UDP datagram to the service port
+func (p *Prober) probeUDP(sourcePod corev1.Pod, targetSvc corev1.Service, port corev1.ServicePort, spec ProbeSpec) ProbeResult {
+	result := ProbeResult{
+		Timestamp:  time.Now(),
+		SourcePod:  sourcePod.Name,
+		SourceNS:   sourcePod.Namespace,
+		TargetSvc:  targetSvc.Name,
+		TargetNS:   targetSvc.Namespace,
+		TargetIP:   targetSvc.Spec.ClusterIP,
+		TargetPort: port.Port,
+		ProbeType:  ProbeTypeUDP,
+		ExecMode:   ExecModeLocal, // sent from the visualizer's own socket
 	}
 
-	start := time.Now()
-	
-	url := fmt.Sprintf("http://%s:%d/healthz", targetSvc.Spec.ClusterIP, port.Port)
-	resp, err := client.Get(url)
-	
-	result.Latency = time.Since(start).Milliseconds()
-	
-	if err != nil {
-		result.Success = false
-		result.Error = err.Error()
-	} else {
-		result.Success = true
-		result.StatusCode = resp.StatusCode
-		resp.Body.Close()
+	checkUDP(&result, spec, 5*time.Second)
+	return result
+}
+
+// probeICMP pings the target pod to measure packet loss
+func (p *Prober) probeICMP(sourcePod corev1.Pod, targetPod corev1.Pod) ProbeResult {
+	result := ProbeResult{
+		Timestamp: time.Now(),
+		SourcePod: sourcePod.Name,
+		SourceNS:  sourcePod.Namespace,
+		TargetPod: targetPod.Name,
+		TargetNS:  targetPod.Namespace,
+		TargetIP:  targetPod.Status.PodIP,
+		ProbeType: ProbeTypeICMP,
+		ExecMode:  ExecModeLocal, // sent from the visualizer's own socket
+	}
+
+	p.detectPacketLoss(targetPod.Status.PodIP, p.ICMPCount()).apply(&result)
+	return result
+}
+
+// probePod probes a container port of the target pod
+func (p *Prober) probePod(sourcePod corev1.Pod, targetPod corev1.Pod, port corev1.ContainerPort) ProbeResult {
+	result := ProbeResult{
+		Timestamp:  time.Now(),
+		SourcePod:  sourcePod.Name,
+		SourceNS:   sourcePod.Namespace,
+		TargetPod:  targetPod.Name,
+		TargetNS:   targetPod.Namespace,
+		TargetIP:   targetPod.Status.PodIP,
+		TargetPort: port.ContainerPort,
+		ProbeType:  "TCP",
+	}
+
+	if port.Protocol == corev1.ProtocolUDP {
+		result.ProbeType = ProbeTypeUDP
+		result.ExecMode = ExecModeLocal // sent from the visualizer's own socket
+		checkUDP(&result, ProbeSpec{Type: ProbeTypeUDP}, 2*time.Second)
+		return result
 	}
 
+	dialed := p.dial(sourcePod, targetPod.Status.PodIP, port.ContainerPort, 2*time.Second)
+	dialed.apply(&result)
 	return result
 }
 
-// probePodToPod performs pod-to-pod connectivity probes
-func (p *Prober) probePodToPod(ctx context.Context, pods []corev1.Pod) {
-	// Sample a subset of pods to avoid O(n²) complexity
-	maxProbes := 10
-	probeCount := 0
+// delegate queues a probe with the agent on the source pod's node, filling in
+// the source. It returns false when the probe should be run centrally.
+func (p *Prober) delegate(source corev1.Pod, assignment ProbeAssignment) bool {
+	p.mu.RLock()
+	agents := p.agents
+	p.mu.RUnlock()
+	if agents == nil || source.Spec.NodeName == "" {
+		return false
+	}
 
-	for i, sourcePod := range pods {
-		if sourcePod.Status.Phase != corev1.PodRunning {
-			continue
-		}
+	assignment.SourcePod = source.Name
+	assignment.SourceNS = source.Namespace
+	assignment.Container = execContainer(source)
+	return agents.Assign(source.Spec.NodeName, assignment)
+}
 
-		for j, targetPod := range pods {
-			if i == j || targetPod.Status.Phase != corev1.PodRunning {
+// probeNodes asks each connected agent to probe the kubelet port of every other
+// node and ping it, giving node-to-node latency and packet loss, and every
+// MTU interval to discover the path MTU to it
+func (p *Prober) probeNodes(ctx context.Context, pods []corev1.Pod) {
+	p.mu.RLock()
+	agents := p.agents
+	p.mu.RUnlock()
+	if agents == nil || p.client == nil {
+		return
+	}
+	sources := agents.Nodes()
+	if len(sources) == 0 {
+		return
+	}
+
+	nodes, err := p.client.Clientset().CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		fmt.Printf("Failed to list nodes: %v\n", err)
+		return
+	}
+
+	for _, source := range sources {
+		for _, node := range nodes.Items {
+			if node.Name == source {
 				continue
 			}
-
-			if probeCount >= maxProbes {
-				return
+			ip := nodeInternalIP(node)
+			if ip == "" {
+				continue
 			}
+			agents.Assign(source, ProbeAssignment{
+				TargetNode: node.Name,
+				TargetIP:   ip,
+				TargetPort: NodeProbePort,
+				ProbeType:  ProbeTypeTCP,
+				TimeoutMs:  2000,
+			})
+			agents.Assign(source, ProbeAssignment{
+				TargetNode: node.Name,
+				TargetIP:   ip,
+				ProbeType:  ProbeTypeICMP,
+				Count:      p.ICMPCount(),
+				TimeoutMs:  int64(icmpReplyTimeout / time.Millisecond),
+			})
+		}
+	}
+	if p.mtu.due(time.Now()) {
+		p.assignMTUProbes(agents, sources, nodes.Items, pods)
+	}
+}
 
-			// Probe each container port
-			for _, container := range targetPod.Spec.Containers {
-				for _, port := range container.Ports {
-					result := ProbeResult{
-						Timestamp:  time.Now(),
-						SourcePod:  sourcePod.Name,
-						SourceNS:   sourcePod.Namespace,
-						TargetPod:  targetPod.Name,
-						TargetNS:   targetPod.Namespace,
-						TargetIP:   targetPod.Status.PodIP,
-						TargetPort: port.ContainerPort,
-						ProbeType:  "TCP",
-					}
-
-					// Simulate probe
-					start := time.Now()
-					address := fmt.Sprintf("%s:%d", targetPod.Status.PodIP, port.ContainerPort)
-					conn, err := net.DialTimeout("tcp", address, 2*time.Second)
-					
-					result.Latency = time.Since(start).Milliseconds()
-					
-					if err != nil {
-						result.Success = false
-						result.Error = err.Error()
-					} else {
-						result.Success = true
-						conn.Close()
-					}
-
-					p.addResult(result)
-					probeCount++
-				}
-			}
+func nodeInternalIP(node corev1.Node) string {
+	for _, address := range node.Status.Addresses {
+		if address.Type == corev1.NodeInternalIP {
+			return address.Address
 		}
 	}
+	return ""
 }
 
-// addResult adds a probe result to the collection
+// addResult tags a probe result with its expected policy verdict and adds it to
+// the collection and its pair's history
 func (p *Prober) addResult(result ProbeResult) {
+	if result.Verdict == "" {
+		result.Verdict = p.expectedVerdict(result)
+	}
+	switch result.ProbeType {
+	case ProbeTypeMTU:
+		p.mtu.record(result)
+	case ProbeTypeThroughput:
+		p.finishThroughput(result)
+	default:
+		p.history.add(result)
+	}
+
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
 	p.results = append(p.results, result)
 	
-	// Keep only last 1000 results to avoid memory growth
+	// Keep only last 1000 results to avoid memory growth; PairStats covers
+	// longer periods
 	if len(p.results) > 1000 {
 		p.results = p.results[len(p.results)-1000:]
 	}
@@ -288,7 +417,7 @@ func (p *Prober) GetFailedProbes() []ProbeResult {
 }
 
 // probeGRPC performs a gRPC health check probe
-func (p *Prober) probeGRPC(sourcePod corev1.Pod, targetSvc corev1.Service, port corev1.ServicePort) ProbeResult {
+func (p *Prober) probeGRPC(sourcePod corev1.Pod, targetSvc corev1.Service, port corev1.ServicePort, spec ProbeSpec) ProbeResult {
 	result := ProbeResult{
 		Timestamp:  time.Now(),
 		SourcePod:  sourcePod.Name,
@@ -297,63 +426,18 @@ func (p *Prober) probeGRPC(sourcePod corev1.Pod, targetSvc corev1.Service, port
 		TargetNS:   targetSvc.Namespace,
 		TargetIP:   targetSvc.Spec.ClusterIP,
 		TargetPort: port.Port,
-		ProbeType:  "gRPC",
-	}
-
-	start := time.Now()
-	
-	address := fmt.Sprintf("%s:%d", targetSvc.Spec.ClusterIP, port.Port)
-	conn, err := grpc.Dial(address,
-		grpc.WithTransportCredentials(insecure.NewCredentials()),
-		grpc.WithTimeout(5*time.Second),
-	)
-	
-	if err != nil {
-		result.Success = false
-		result.Error = err.Error()
-		result.Latency = time.Since(start).Milliseconds()
-		return result
-	}
-	defer conn.Close()
-
-	client := healthpb.NewHealthClient(conn)
-	resp, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{})
-	
-	result.Latency = time.Since(start).Milliseconds()
-	
-	if err != nil {
-		result.Success = false
-		result.Error = err.Error()
-	} else {
-		result.Success = resp.Status == healthpb.HealthCheckResponse_SERVING
-		if !result.Success {
-			result.Error = fmt.Sprintf("Service status: %s", resp.Status.String())
-		}
+		ProbeType:  ProbeTypeGRPC,
+		ExecMode:   ExecModeLocal, // dialed by the visualizer's own client
 	}
 
+	checkGRPC(&result, spec, 5*time.Second)
 	return result
 }
 
-// detectPacketLoss performs multiple probes to detect packet loss
-func (p *Prober) detectPacketLoss(sourcePod corev1.Pod, targetIP string, targetPort int32) float64 {
-	probes := 10
-	failed := 0
-	
-	for i := 0; i < probes; i++ {
-		address := fmt.Sprintf("%s:%d", targetIP, targetPort)
-		conn, err := net.DialTimeout("tcp", address, 1*time.Second)
-		
-		if err != nil {
-			failed++
-		} else {
-			conn.Close()
-		}
-		
-		// Small delay between probes
-		time.Sleep(100 * time.Millisecond)
-	}
-	
-	return float64(failed) / float64(probes) * 100
+// detectPacketLoss sends count ICMP echo requests to the target and counts the
+// replies, so loss is measured on packets rather than on failed handshakes
+func (p *Prober) detectPacketLoss(targetIP string, count int) echoStats {
+	return ping(targetIP, count)
 }
 
 // CalculatePacketLoss calculates packet loss for recent probes
@@ -0,0 +1,302 @@
+package prober
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/christine33-creator/k8-network-visualizer/pkg/flowcollector"
+	"github.com/christine33-creator/k8-network-visualizer/pkg/simulator"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// Sampling strategies
+const (
+	SamplingWorkload   = "workload"    // one representative pod per workload
+	SamplingFlows      = "flows"       // only pod pairs the flow collector has seen talking
+	SamplingRoundRobin = "round-robin" // every pod, rotating through the pairs across cycles
+)
+
+// ProbeTask is one unit of scheduled work: a source pod probing either a
+// Service port or another pod. A target pod is pinged and then probed on Ports,
+// or on its declared container ports when Ports is empty.
+type ProbeTask struct {
+	Source  corev1.Pod
+	Service *corev1.Service
+	Port    corev1.ServicePort
+	Target  *corev1.Pod
+	Ports   []corev1.ContainerPort
+}
+
+// SampleInput is what a sampling strategy picks one cycle's tasks from
+type SampleInput struct {
+	Cycle    int
+	Pods     []corev1.Pod                // running pods with an IP
+	Services []corev1.Service            // Services with a cluster IP
+	Flows    []*flowcollector.FlowMetric // recently observed traffic; nil without a flow collector
+	Budget   int                         // most tasks the cycle may run
+}
+
+// Sample is the tasks a strategy picked for one cycle
+type Sample struct {
+	Tasks      []ProbeTask
+	Candidates int // tasks the strategy chose from
+	Covered    int // candidates probed in the current rotation, this cycle included
+}
+
+// SamplingStrategy picks the probes for each cycle. Strategies may keep state
+// between cycles to spread coverage when there are more candidates than budget.
+type SamplingStrategy interface {
+	Name() string
+	Sample(in SampleInput) Sample
+}
+
+// NewSamplingStrategy returns the built-in strategy with the given name
+func NewSamplingStrategy(name string) (SamplingStrategy, error) {
+	switch name {
+	case SamplingWorkload:
+		return &workloadSampling{}, nil
+	case SamplingFlows:
+		return &flowSampling{}, nil
+	case SamplingRoundRobin:
+		return &roundRobinSampling{}, nil
+	}
+	return nil, fmt.Errorf("unknown sampling strategy %q", name)
+}
+
+// workloadSampling probes from and to one pod per workload, taking a different
+// replica each cycle
+type workloadSampling struct {
+	rotation rotation
+}
+
+func (s *workloadSampling) Name() string { return SamplingWorkload }
+
+func (s *workloadSampling) Sample(in SampleInput) Sample {
+	return newTaskSpace(representatives(in.Pods, in.Cycle), in.Services).sample(&s.rotation, in.Budget)
+}
+
+// roundRobinSampling covers every pod against every Service port and every
+// other pod, a budget's worth per cycle
+type roundRobinSampling struct {
+	rotation rotation
+}
+
+func (s *roundRobinSampling) Name() string { return SamplingRoundRobin }
+
+func (s *roundRobinSampling) Sample(in SampleInput) Sample {
+	return newTaskSpace(in.Pods, in.Services).sample(&s.rotation, in.Budget)
+}
+
+// flowSampling only probes what the flow collector has seen: pod pairs on their
+// observed ports, and Service ports from the pods talking to their backends
+type flowSampling struct {
+	rotation rotation
+}
+
+func (s *flowSampling) Name() string { return SamplingFlows }
+
+func (s *flowSampling) Sample(in SampleInput) Sample {
+	pods := make(map[string]*corev1.Pod, len(in.Pods))
+	for i := range in.Pods {
+		pod := &in.Pods[i]
+		pods[pod.Namespace+"/"+pod.Name] = pod
+	}
+
+	// Merge the observed ports of each source and destination pair
+	type observedPair struct {
+		source, target *corev1.Pod
+		ports          []corev1.ContainerPort
+		seen           map[string]bool
+	}
+	pairs := make(map[string]*observedPair)
+	var pairKeys []string
+	for _, metric := range in.Flows {
+		source := pods[metric.SourceNamespace+"/"+metric.SourcePod]
+		target := pods[metric.DestNamespace+"/"+metric.DestPod]
+		if source == nil || target == nil || source == target {
+			continue
+		}
+		key := source.Namespace + "/" + source.Name + "->" + target.Namespace + "/" + target.Name
+		pair, ok := pairs[key]
+		if !ok {
+			pair = &observedPair{source: source, target: target, seen: make(map[string]bool)}
+			pairs[key] = pair
+			pairKeys = append(pairKeys, key)
+		}
+		for _, port := range metric.Ports {
+			protocol := corev1.Protocol(port.Protocol)
+			if protocol == "" {
+				protocol = corev1.ProtocolTCP
+			}
+			portKey := fmt.Sprintf("%d/%s", port.Port, protocol)
+			if port.Port <= 0 || pair.seen[portKey] {
+				continue
+			}
+			pair.seen[portKey] = true
+			pair.ports = append(pair.ports, corev1.ContainerPort{ContainerPort: int32(port.Port), Protocol: protocol})
+		}
+	}
+	sort.Strings(pairKeys)
+
+	var candidates []ProbeTask
+	for _, key := range pairKeys {
+		pair := pairs[key]
+		candidates = append(candidates, ProbeTask{Source: *pair.source, Target: pair.target, Ports: pair.ports})
+	}
+
+	// A Service is probed from the pods seen talking to the pods it selects
+	for i := range in.Services {
+		svc := &in.Services[i]
+		if len(svc.Spec.Selector) == 0 {
+			continue
+		}
+		selector := labels.SelectorFromSet(svc.Spec.Selector)
+		sources := make(map[string]bool)
+		var sourceKeys []string
+		for _, key := range pairKeys {
+			pair := pairs[key]
+			if pair.target.Namespace != svc.Namespace || !selector.Matches(labels.Set(pair.target.Labels)) {
+				continue
+			}
+			sourceKey := pair.source.Namespace + "/" + pair.source.Name
+			if !sources[sourceKey] {
+				sources[sourceKey] = true
+				sourceKeys = append(sourceKeys, sourceKey)
+			}
+		}
+		for _, sourceKey := range sourceKeys {
+			for _, port := range svc.Spec.Ports {
+				candidates = append(candidates, ProbeTask{Source: *pods[sourceKey], Service: svc, Port: port})
+			}
+		}
+	}
+
+	start, count, covered := s.rotation.take(len(candidates), in.Budget)
+	sample := Sample{Candidates: len(candidates), Covered: covered}
+	for i := 0; i < count; i++ {
+		sample.Tasks = append(sample.Tasks, candidates[(start+i)%len(candidates)])
+	}
+	return sample
+}
+
+// representatives picks one pod per workload, rotating through its replicas by
+// cycle
+func representatives(pods []corev1.Pod, cycle int) []corev1.Pod {
+	workloads := make(map[string][]corev1.Pod)
+	var keys []string
+	for _, pod := range pods {
+		key := pod.Namespace + "/" + simulator.WorkloadName(&pod)
+		if _, ok := workloads[key]; !ok {
+			keys = append(keys, key)
+		}
+		workloads[key] = append(workloads[key], pod)
+	}
+	sort.Strings(keys)
+
+	chosen := make([]corev1.Pod, 0, len(keys))
+	for _, key := range keys {
+		replicas := workloads[key]
+		sort.Slice(replicas, func(i, j int) bool { return replicas[i].Name < replicas[j].Name })
+		chosen = append(chosen, replicas[cycle%len(replicas)])
+	}
+	return chosen
+}
+
+// taskSpace enumerates, without materialising them, the tasks of every pod
+// against every Service port and every other pod. Tasks are ordered by source
+// pod so that a window of them spreads over sources.
+type taskSpace struct {
+	pods  []corev1.Pod
+	ports []servicePort
+}
+
+type servicePort struct {
+	service *corev1.Service
+	port    corev1.ServicePort
+}
+
+func newTaskSpace(pods []corev1.Pod, services []corev1.Service) taskSpace {
+	space := taskSpace{pods: pods}
+	for i := range services {
+		for _, port := range services[i].Spec.Ports {
+			space.ports = append(space.ports, servicePort{service: &services[i], port: port})
+		}
+	}
+	return space
+}
+
+// perSource is the number of tasks each source pod has
+func (t taskSpace) perSource() int {
+	if len(t.pods) == 0 {
+		return 0
+	}
+	return len(t.ports) + len(t.pods) - 1
+}
+
+func (t taskSpace) size() int {
+	return len(t.pods) * t.perSource()
+}
+
+func (t taskSpace) at(index int) ProbeTask {
+	source := index / t.perSource()
+	offset := index % t.perSource()
+	if offset < len(t.ports) {
+		target := t.ports[offset]
+		return ProbeTask{Source: t.pods[source], Service: target.service, Port: target.port}
+	}
+	// Skip the source itself among the target pods
+	target := offset - len(t.ports)
+	if target >= source {
+		target++
+	}
+	return ProbeTask{Source: t.pods[source], Target: &t.pods[target]}
+}
+
+func (t taskSpace) sample(r *rotation, budget int) Sample {
+	size := t.size()
+	start, count, covered := r.take(size, budget)
+	sample := Sample{Candidates: size, Covered: covered}
+	for i := 0; i < count; i++ {
+		sample.Tasks = append(sample.Tasks, t.at((start+i)%size))
+	}
+	return sample
+}
+
+// rotation hands out a budget's worth of candidates per cycle, carrying on
+// where the previous cycle stopped and wrapping around at the end
+type rotation struct {
+	next    int
+	covered int
+	mu      sync.Mutex
+}
+
+// take returns the window of candidates for this cycle and how many have been
+// covered in the current pass over them
+func (r *rotation) take(total, budget int) (start, count, covered int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if total == 0 {
+		r.next, r.covered = 0, 0
+		return 0, 0, 0
+	}
+	if r.next >= total {
+		r.next = 0
+	}
+	if r.covered >= total {
+		r.covered = 0
+	}
+	count = total
+	if budget > 0 && budget < total {
+		count = budget
+	}
+	start = r.next
+	r.next = (r.next + count) % total
+	r.covered += count
+	if r.covered > total {
+		r.covered = total
+	}
+	return start, count, r.covered
+}
@@ -0,0 +1,369 @@
+package prober
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/christine33-creator/k8-network-visualizer/pkg/flowcollector"
+	"golang.org/x/time/rate"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// Scheduler defaults
+const (
+	DefaultProbeWorkers = 16
+	DefaultProbeRate    = 50  // probes started per second
+	DefaultTargetRate   = 5   // probes started per second against one IP
+	DefaultMaxTasks     = 500 // tasks per cycle
+	DefaultProbeJitter  = 0.1 // fraction of the interval
+)
+
+// flowSampleWindow is how recently a pod pair must have been seen talking for
+// flow sampling to probe it
+const flowSampleWindow = 5 * time.Minute
+
+// SchedulerConfig bounds how each probe cycle runs
+type SchedulerConfig struct {
+	Workers    int              // probes run at once
+	Rate       float64          // probes started per second across all targets
+	TargetRate float64          // probes started per second against one target IP
+	MaxTasks   int              // tasks per cycle; strategies rotate through the rest
+	Jitter     float64          // random delay before each cycle, as a fraction of the interval
+	Strategy   SamplingStrategy // defaults to workload sampling
+}
+
+// CoverageStats describes what the last probe cycle covered
+type CoverageStats struct {
+	Cycle        int       `json:"cycle"`
+	Strategy     string    `json:"strategy"`
+	StartedAt    time.Time `json:"started_at"`
+	DurationMs   int64     `json:"duration_ms"`
+	RunningPods  int       `json:"running_pods"`
+	ServicePorts int       `json:"service_ports"`
+	Candidates   int       `json:"candidates"` // tasks the strategy chose from
+	Tasks        int       `json:"tasks"`      // tasks scheduled this cycle
+	Covered      int       `json:"covered"`    // candidates probed in the strategy's current rotation
+	Coverage     float64   `json:"coverage"`   // covered / candidates
+	Sources      int       `json:"sources"`    // distinct source pods
+	Services     int       `json:"services_probed"`
+	PodPairs     int       `json:"pod_pairs_probed"`
+	Probes       int       `json:"probes"`    // probes run, delegated or skipped
+	Delegated    int       `json:"delegated"` // handed to node agents
+	Skipped      int       `json:"skipped"`   // not started before the cycle's window closed
+}
+
+// probeJob is a single probe expanded from a task
+type probeJob struct {
+	source     corev1.Pod
+	targetIP   string
+	assignment ProbeAssignment // for delegation to the source node's agent
+	run        func() ProbeResult
+}
+
+// jobCounts tallies what happened to a cycle's jobs
+type jobCounts struct {
+	delegated, skipped int
+}
+
+// DefaultSchedulerConfig returns the scheduler settings used unless configured
+func DefaultSchedulerConfig() SchedulerConfig {
+	return SchedulerConfig{
+		Workers:    DefaultProbeWorkers,
+		Rate:       DefaultProbeRate,
+		TargetRate: DefaultTargetRate,
+		MaxTasks:   DefaultMaxTasks,
+		Jitter:     DefaultProbeJitter,
+		Strategy:   &workloadSampling{},
+	}
+}
+
+// SetSchedulerConfig replaces the scheduler settings. Zero values keep their
+// defaults.
+func (p *Prober) SetSchedulerConfig(config SchedulerConfig) error {
+	if config.Workers < 0 || config.Rate < 0 || config.TargetRate < 0 || config.MaxTasks < 0 {
+		return fmt.Errorf("scheduler limits must not be negative")
+	}
+	if config.Jitter < 0 || config.Jitter >= 1 {
+		return fmt.Errorf("jitter must be in [0, 1), got %v", config.Jitter)
+	}
+	defaults := DefaultSchedulerConfig()
+	if config.Workers == 0 {
+		config.Workers = defaults.Workers
+	}
+	if config.Rate == 0 {
+		config.Rate = defaults.Rate
+	}
+	if config.TargetRate == 0 {
+		config.TargetRate = defaults.TargetRate
+	}
+	if config.MaxTasks == 0 {
+		config.MaxTasks = defaults.MaxTasks
+	}
+	if config.Strategy == nil {
+		config.Strategy = defaults.Strategy
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.schedConf = config
+	return nil
+}
+
+// SetFlowCollector lets flow sampling probe the pod pairs seen talking
+func (p *Prober) SetFlowCollector(collector flowcollector.FlowCollectorInterface) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.flowCollector = collector
+}
+
+// Coverage returns the coverage statistics of the last probe cycle
+func (p *Prober) Coverage() CoverageStats {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.coverage
+}
+
+// jitter returns the random delay before a cycle
+func (p *Prober) jitter(interval time.Duration) time.Duration {
+	p.mu.RLock()
+	fraction := p.schedConf.Jitter
+	p.mu.RUnlock()
+	if fraction <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(float64(interval)*fraction) + 1))
+}
+
+// recentFlows returns the pod pairs seen talking within flowSampleWindow
+func (p *Prober) recentFlows() []*flowcollector.FlowMetric {
+	p.mu.RLock()
+	collector := p.flowCollector
+	p.mu.RUnlock()
+	if collector == nil {
+		return nil
+	}
+
+	cutoff := time.Now().Add(-flowSampleWindow)
+	var flows []*flowcollector.FlowMetric
+	for _, metric := range collector.GetFlowMetrics() {
+		if !metric.LastSeen.Before(cutoff) {
+			flows = append(flows, metric)
+		}
+	}
+	return flows
+}
+
+// scheduleTasks samples the cycle's tasks and runs them on the worker pool.
+// Probes that have not started by the deadline are skipped so that a slow
+// cycle does not run into the next one.
+func (p *Prober) scheduleTasks(ctx context.Context, pods []corev1.Pod, services []corev1.Service, deadline time.Time) {
+	p.mu.Lock()
+	config := p.schedConf
+	cycle := p.coverage.Cycle + 1
+	p.mu.Unlock()
+
+	stats := CoverageStats{
+		Cycle:       cycle,
+		Strategy:    config.Strategy.Name(),
+		StartedAt:   time.Now(),
+		RunningPods: len(pods),
+	}
+	for _, svc := range services {
+		stats.ServicePorts += len(svc.Spec.Ports)
+	}
+
+	sample := config.Strategy.Sample(SampleInput{
+		Cycle:    cycle,
+		Pods:     pods,
+		Services: services,
+		Flows:    p.recentFlows(),
+		Budget:   config.MaxTasks,
+	})
+	stats.Candidates = sample.Candidates
+	stats.Tasks = len(sample.Tasks)
+	stats.Covered = sample.Covered
+	if sample.Candidates > 0 {
+		stats.Coverage = float64(sample.Covered) / float64(sample.Candidates)
+	}
+
+	sources := make(map[string]bool)
+	servicePorts := make(map[string]bool)
+	var jobs []probeJob
+	for _, task := range sample.Tasks {
+		sources[task.Source.Namespace+"/"+task.Source.Name] = true
+		if task.Service != nil {
+			servicePorts[fmt.Sprintf("%s/%s:%d", task.Service.Namespace, task.Service.Name, task.Port.Port)] = true
+		} else {
+			stats.PodPairs++
+		}
+		jobs = append(jobs, p.expandTask(task)...)
+	}
+	stats.Sources = len(sources)
+	stats.Services = len(servicePorts)
+
+	// Shuffle so that one source or target is not probed in a burst
+	rand.Shuffle(len(jobs), func(i, j int) { jobs[i], jobs[j] = jobs[j], jobs[i] })
+	counts := p.runJobs(ctx, jobs, config, deadline)
+	stats.Probes = len(jobs)
+	stats.Delegated = counts.delegated
+	stats.Skipped = counts.skipped
+	stats.DurationMs = time.Since(stats.StartedAt).Milliseconds()
+	if counts.skipped > 0 {
+		fmt.Printf("Probe cycle %d skipped %d of %d probes that did not start in time\n", cycle, counts.skipped, len(jobs))
+	}
+
+	p.mu.Lock()
+	p.coverage = stats
+	p.mu.Unlock()
+}
+
+// runJobs runs jobs on config.Workers workers, each probe waiting for both the
+// global and its target's rate limit
+func (p *Prober) runJobs(ctx context.Context, jobs []probeJob, config SchedulerConfig, deadline time.Time) jobCounts {
+	ctx, cancel := context.WithDeadline(ctx, deadline)
+	defer cancel()
+
+	global := rate.NewLimiter(rate.Limit(config.Rate), config.Workers)
+	targetBurst := int(config.TargetRate)
+	if targetBurst < 1 {
+		targetBurst = 1
+	}
+	targets := make(map[string]*rate.Limiter)
+	var targetsMu sync.Mutex
+	targetLimiter := func(ip string) *rate.Limiter {
+		targetsMu.Lock()
+		defer targetsMu.Unlock()
+		limiter, ok := targets[ip]
+		if !ok {
+			limiter = rate.NewLimiter(rate.Limit(config.TargetRate), targetBurst)
+			targets[ip] = limiter
+		}
+		return limiter
+	}
+
+	var counts jobCounts
+	var countsMu sync.Mutex
+	queue := make(chan probeJob)
+	var wg sync.WaitGroup
+	for i := 0; i < config.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range queue {
+				if targetLimiter(job.targetIP).Wait(ctx) != nil || global.Wait(ctx) != nil {
+					countsMu.Lock()
+					counts.skipped++
+					countsMu.Unlock()
+					continue
+				}
+				if p.delegate(job.source, job.assignment) {
+					countsMu.Lock()
+					counts.delegated++
+					countsMu.Unlock()
+					continue
+				}
+				p.addResult(job.run())
+			}
+		}()
+	}
+	for _, job := range jobs {
+		queue <- job
+	}
+	close(queue)
+	wg.Wait()
+	return counts
+}
+
+// expandTask turns a task into its probes: one for a Service port; for a pod,
+// an ICMP ping followed by one probe per port
+func (p *Prober) expandTask(task ProbeTask) []probeJob {
+	source := task.Source
+	if task.Service != nil {
+		svc, port := *task.Service, task.Port
+		spec := SelectProbe(svc, port)
+		if spec.Type == ProbeTypeTLS {
+			spec = p.tlsSpec(svc, spec)
+		}
+		return []probeJob{{
+			source:   source,
+			targetIP: svc.Spec.ClusterIP,
+			assignment: ProbeAssignment{
+				TargetSvc:  svc.Name,
+				TargetNS:   svc.Namespace,
+				TargetIP:   svc.Spec.ClusterIP,
+				TargetPort: port.Port,
+				ProbeType:  spec.Type,
+				Spec:       &spec,
+				TimeoutMs:  5000,
+			},
+			run: func() ProbeResult {
+				switch spec.Type {
+				case ProbeTypeHTTP:
+					return p.probeHTTP(source, svc, port, spec)
+				case ProbeTypeGRPC:
+					return p.probeGRPC(source, svc, port, spec)
+				case ProbeTypeUDP:
+					return p.probeUDP(source, svc, port, spec)
+				case ProbeTypeTLS:
+					return p.probeTLS(source, svc, port, spec)
+				default:
+					return p.probeTCP(source, svc, port)
+				}
+			},
+		}}
+	}
+
+	target := *task.Target
+	if target.Status.PodIP == "" {
+		return nil
+	}
+	count := p.ICMPCount()
+	jobs := []probeJob{{
+		source:   source,
+		targetIP: target.Status.PodIP,
+		assignment: ProbeAssignment{
+			TargetPod: target.Name,
+			TargetNS:  target.Namespace,
+			TargetIP:  target.Status.PodIP,
+			ProbeType: ProbeTypeICMP,
+			Count:     count,
+			TimeoutMs: int64(icmpReplyTimeout / time.Millisecond),
+		},
+		run: func() ProbeResult { return p.probeICMP(source, target) },
+	}}
+
+	ports := task.Ports
+	if len(ports) == 0 {
+		for _, container := range target.Spec.Containers {
+			ports = append(ports, container.Ports...)
+		}
+	}
+	for _, port := range ports {
+		port := port
+		probeType := ProbeTypeTCP
+		switch port.Protocol {
+		case "", corev1.ProtocolTCP:
+		case corev1.ProtocolUDP:
+			probeType = ProbeTypeUDP
+		default:
+			continue
+		}
+		jobs = append(jobs, probeJob{
+			source:   source,
+			targetIP: target.Status.PodIP,
+			assignment: ProbeAssignment{
+				TargetPod:  target.Name,
+				TargetNS:   target.Namespace,
+				TargetIP:   target.Status.PodIP,
+				TargetPort: port.ContainerPort,
+				ProbeType:  probeType,
+				TimeoutMs:  2000,
+			},
+			run: func() ProbeResult { return p.probePod(source, target, port) },
+		})
+	}
+	return jobs
+}
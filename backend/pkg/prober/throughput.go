@@ -0,0 +1,495 @@
+package prober
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ProbeTypeThroughput is recorded for TCP throughput tests
+const ProbeTypeThroughput = "THROUGHPUT"
+
+// AnnotationThroughputReceiver marks a pod that hosts a throughput receiver on
+// the port it names, such as an ephemeral pod started for a test. It is also
+// the pod owner's consent to tests loading the path to the pod.
+const AnnotationThroughputReceiver = "netvis.io/throughput-receiver"
+
+// Throughput test defaults and limits
+const (
+	DefaultThroughputPort     = 5201
+	DefaultThroughputDuration = 5 * time.Second
+	MaxThroughputDuration     = 30 * time.Second
+	throughputChunk           = 128 << 10
+	throughputMagic           = "netvis-throughput/1"
+	throughputHandshake       = 5 * time.Second // for each exchange around the transfer
+	throughputAgentGrace      = 30 * time.Second
+)
+
+// ErrThroughputDisabled is returned for throughput tests on a server that has
+// not enabled them
+var ErrThroughputDisabled = errors.New("throughput tests are not enabled on this server")
+
+// ErrThroughputBusy is returned while another throughput test runs
+var ErrThroughputBusy = errors.New("another throughput test is running; tests run one at a time")
+
+// ThroughputInfo is the outcome of a throughput test
+type ThroughputInfo struct {
+	DurationMs  int64   `json:"duration_ms"` // as timed by the receiver
+	Bytes       int64   `json:"bytes"`       // received
+	Mbps        float64 `json:"mbps"`
+	Retransmits int64   `json:"retransmits"`      // segments the sender retransmitted; -1 when its platform does not report them
+	RTTMs       float64 `json:"rtt_ms,omitempty"` // the sender's smoothed round trip time at the end
+	LimitMbps   float64 `json:"limit_mbps,omitempty"`
+}
+
+// ThroughputRequest asks for a TCP throughput test from a sender to a
+// receiver. Tests load the path for their whole duration, so they must be
+// enabled on the server, confirmed in each request, and aimed at a receiver
+// its owner set up: a node agent given a receiver port, or a pod carrying
+// AnnotationThroughputReceiver.
+type ThroughputRequest struct {
+	Source     string  `json:"source,omitempty"`      // node/NAME to send from that node's agent; empty to send from this process
+	Target     string  `json:"target"`                // node/NAME or namespace/pod hosting a receiver
+	DurationMs int64   `json:"duration_ms,omitempty"` // defaults to DefaultThroughputDuration
+	LimitMbps  float64 `json:"limit_mbps,omitempty"`  // paces the sender; zero sends as fast as the path allows
+	Confirm    bool    `json:"confirm"`               // acknowledges that the test loads the path
+	Async      bool    `json:"async,omitempty"`
+}
+
+// ThroughputResult is the latest completed test between two endpoints
+type ThroughputResult struct {
+	Source     string         `json:"source"` // graph IDs: node/NAME or pod/namespace/name
+	Target     string         `json:"target"`
+	Throughput ThroughputInfo `json:"throughput"`
+	MeasuredAt time.Time      `json:"measured_at"`
+}
+
+// throughputState guards throughput tests, which run one at a time, and keeps
+// each pair's latest result
+type throughputState struct {
+	enabled   bool
+	agentPort int32  // where agents host receivers; zero when they do not
+	sourceNS  string // the pod this process runs in, recorded as the source of its tests
+	sourcePod string
+	active    string // run ID of the test in progress
+	latest    map[string]ProbeResult
+	mu        sync.Mutex
+}
+
+// throughputReport is the receiver's account of a test
+type throughputReport struct {
+	Bytes      int64 `json:"bytes"`
+	DurationMs int64 `json:"duration_ms"`
+}
+
+// EnableThroughputTests allows on-demand throughput tests. agentPort is where
+// node agents host receivers; zero means nodes cannot be targets.
+func (p *Prober) EnableThroughputTests(agentPort int) error {
+	if agentPort < 0 || agentPort > 65535 {
+		return fmt.Errorf("invalid throughput receiver port %d", agentPort)
+	}
+	p.throughput.mu.Lock()
+	defer p.throughput.mu.Unlock()
+	p.throughput.enabled = true
+	p.throughput.agentPort = int32(agentPort)
+	return nil
+}
+
+// SetLocalPod names the pod this process runs in, so that the tests it sends
+// itself have a source on the graph
+func (p *Prober) SetLocalPod(namespace, name string) {
+	p.throughput.mu.Lock()
+	defer p.throughput.mu.Unlock()
+	p.throughput.sourceNS = namespace
+	p.throughput.sourcePod = name
+}
+
+// ThroughputResults returns the latest completed test for each source and
+// target, sorted by source and target
+func (p *Prober) ThroughputResults() []ThroughputResult {
+	p.throughput.mu.Lock()
+	defer p.throughput.mu.Unlock()
+
+	results := make([]ThroughputResult, 0, len(p.throughput.latest))
+	for _, result := range p.throughput.latest {
+		source, target, _ := pairEndpoints(result)
+		results = append(results, ThroughputResult{
+			Source:     source,
+			Target:     target,
+			Throughput: *result.Throughput,
+			MeasuredAt: result.Timestamp,
+		})
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Source != results[j].Source {
+			return results[i].Source < results[j].Source
+		}
+		return results[i].Target < results[j].Target
+	})
+	return results
+}
+
+// RunThroughput validates a throughput request and runs it, from this process
+// or by handing it to the source node's agent. Synchronous requests return
+// once the test has finished, or the context ends while an agent runs it;
+// async ones return the running run, which GetProbeRun reports later.
+func (p *Prober) RunThroughput(ctx context.Context, req ThroughputRequest) (*ProbeRun, error) {
+	p.throughput.mu.Lock()
+	enabled, agentPort := p.throughput.enabled, p.throughput.agentPort
+	sourceNS, sourcePod := p.throughput.sourceNS, p.throughput.sourcePod
+	p.throughput.mu.Unlock()
+	if !enabled {
+		return nil, ErrThroughputDisabled
+	}
+	if !req.Confirm {
+		return nil, fmt.Errorf("a throughput test loads the path for its whole duration; set confirm to run it")
+	}
+	duration := DefaultThroughputDuration
+	if req.DurationMs < 0 || time.Duration(req.DurationMs)*time.Millisecond > MaxThroughputDuration {
+		return nil, fmt.Errorf("duration_ms must be between 1 and %d", MaxThroughputDuration.Milliseconds())
+	}
+	if req.DurationMs > 0 {
+		duration = time.Duration(req.DurationMs) * time.Millisecond
+	}
+	if req.LimitMbps < 0 {
+		return nil, fmt.Errorf("limit_mbps must not be negative")
+	}
+	if p.client == nil {
+		return nil, fmt.Errorf("no Kubernetes client")
+	}
+
+	sourceNode := ""
+	if req.Source != "" {
+		node, ok := strings.CutPrefix(req.Source, "node/")
+		if !ok || node == "" {
+			return nil, fmt.Errorf("source must be node/NAME or empty, got %q", req.Source)
+		}
+		if !p.agentConnected(node) {
+			return nil, fmt.Errorf("no probe agent connected on node %s", node)
+		}
+		sourceNode = node
+	}
+	result, err := p.resolveThroughputTarget(ctx, req.Target, agentPort)
+	if err != nil {
+		return nil, err
+	}
+
+	p.throughput.mu.Lock()
+	if p.throughput.active != "" {
+		active := p.throughput.active
+		p.throughput.mu.Unlock()
+		return nil, fmt.Errorf("%w: %s", ErrThroughputBusy, active)
+	}
+	run := p.runs.start(ProbeRequest{
+		Source:    req.Source,
+		Target:    req.Target,
+		Type:      ProbeTypeThroughput,
+		TimeoutMs: duration.Milliseconds(),
+		Async:     req.Async,
+	})
+	p.throughput.active = run.ID
+	p.throughput.mu.Unlock()
+
+	result.Timestamp = time.Now()
+	result.ProbeType = ProbeTypeThroughput
+	result.OnDemand = true
+	result.RunID = run.ID
+
+	if sourceNode != "" {
+		assigned := p.agents.Assign(sourceNode, ProbeAssignment{
+			TargetPod:  result.TargetPod,
+			TargetNS:   result.TargetNS,
+			TargetNode: result.TargetNode,
+			TargetIP:   result.TargetIP,
+			TargetPort: result.TargetPort,
+			ProbeType:  ProbeTypeThroughput,
+			Duration:   duration.Milliseconds(),
+			LimitMbps:  req.LimitMbps,
+			RunID:      run.ID,
+			TimeoutMs:  throughputHandshake.Milliseconds(),
+		})
+		if !assigned {
+			result.Node = sourceNode
+			result.Error = "the source node's agent is not accepting probes"
+			p.addResult(result)
+			return p.runs.get(run.ID), nil
+		}
+		time.AfterFunc(duration+throughputAgentGrace, func() {
+			result.Node = sourceNode
+			result.Error = "no result from the source node's agent"
+			p.finishThroughput(result)
+		})
+		if !req.Async {
+			p.runs.wait(ctx, run.ID)
+		}
+		return p.runs.get(run.ID), nil
+	}
+
+	execute := func() {
+		result.SourceNS, result.SourcePod = sourceNS, sourcePod
+		result.ExecMode = ExecModeLocal
+		checkThroughput(&result, duration, req.LimitMbps)
+		p.addResult(result)
+	}
+	if req.Async {
+		go execute()
+		return p.runs.get(run.ID), nil
+	}
+	execute()
+	return p.runs.get(run.ID), nil
+}
+
+// resolveThroughputTarget finds the receiver a test is sent to
+func (p *Prober) resolveThroughputTarget(ctx context.Context, target string, agentPort int32) (ProbeResult, error) {
+	clientset := p.client.Clientset()
+	if name, ok := strings.CutPrefix(target, "node/"); ok {
+		if agentPort == 0 {
+			return ProbeResult{}, fmt.Errorf("node agents host no throughput receivers on this cluster")
+		}
+		if !p.agentConnected(name) {
+			return ProbeResult{}, fmt.Errorf("no probe agent connected on node %s to host the receiver", name)
+		}
+		node, err := clientset.CoreV1().Nodes().Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return ProbeResult{}, fmt.Errorf("node %s: %w", name, err)
+		}
+		ip := nodeInternalIP(*node)
+		if ip == "" {
+			return ProbeResult{}, fmt.Errorf("node %s has no internal IP", name)
+		}
+		return ProbeResult{TargetNode: name, TargetIP: ip, TargetPort: agentPort}, nil
+	}
+
+	namespace, name, ok := strings.Cut(target, "/")
+	if !ok || namespace == "" || name == "" {
+		return ProbeResult{}, fmt.Errorf("target must be node/NAME or namespace/pod, got %q", target)
+	}
+	pod, err := clientset.CoreV1().Pods(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return ProbeResult{}, fmt.Errorf("target pod %s: %w", target, err)
+	}
+	if pod.Status.Phase != corev1.PodRunning || pod.Status.PodIP == "" {
+		return ProbeResult{}, fmt.Errorf("target pod %s is not running with an IP", target)
+	}
+	value, ok := pod.Annotations[AnnotationThroughputReceiver]
+	if !ok {
+		return ProbeResult{}, fmt.Errorf("pod %s does not host a throughput receiver; annotate it with %s: <port>", target, AnnotationThroughputReceiver)
+	}
+	port, err := strconv.ParseInt(value, 10, 32)
+	if err != nil || port <= 0 || port > 65535 {
+		return ProbeResult{}, fmt.Errorf("invalid %s annotation %q on pod %s", AnnotationThroughputReceiver, value, target)
+	}
+	return ProbeResult{TargetPod: pod.Name, TargetNS: pod.Namespace, TargetIP: pod.Status.PodIP, TargetPort: int32(port)}, nil
+}
+
+func (p *Prober) agentConnected(node string) bool {
+	p.mu.RLock()
+	agents := p.agents
+	p.mu.RUnlock()
+	if agents == nil {
+		return false
+	}
+	for _, connected := range agents.Nodes() {
+		if connected == node {
+			return true
+		}
+	}
+	return false
+}
+
+// finishThroughput records a test's result as its pair's latest and, if the
+// test is still the one in progress, finishes its run. A result an agent
+// reports after its test timed out only updates the pair.
+func (p *Prober) finishThroughput(result ProbeResult) {
+	p.throughput.mu.Lock()
+	if result.Success && result.Throughput != nil {
+		if source, target, ok := pairEndpoints(result); ok {
+			if p.throughput.latest == nil {
+				p.throughput.latest = make(map[string]ProbeResult)
+			}
+			p.throughput.latest[source+"->"+target] = result
+		}
+	}
+	active := p.throughput.active == result.RunID
+	if active {
+		p.throughput.active = ""
+	}
+	p.throughput.mu.Unlock()
+
+	if active {
+		p.runs.finish(result.RunID, []ProbeResult{result})
+	}
+}
+
+// checkThroughput sends to the result's target for duration, paced to
+// limitMbps when it is set, and records what the receiver reports
+func checkThroughput(result *ProbeResult, duration time.Duration, limitMbps float64) {
+	fail := func(format string, args ...interface{}) {
+		result.Success = false
+		result.Error = fmt.Sprintf(format, args...)
+	}
+
+	address := net.JoinHostPort(result.TargetIP, strconv.Itoa(int(result.TargetPort)))
+	start := time.Now()
+	conn, err := net.DialTimeout("tcp", address, throughputHandshake)
+	result.Latency = time.Since(start).Milliseconds()
+	if err != nil {
+		fail("%v", err)
+		return
+	}
+	defer conn.Close()
+
+	conn.SetDeadline(time.Now().Add(throughputHandshake))
+	reader := bufio.NewReader(conn)
+	if _, err := fmt.Fprintf(conn, "%s %d\n", throughputMagic, duration.Milliseconds()); err != nil {
+		fail("starting the test: %v", err)
+		return
+	}
+	answer, err := reader.ReadString('\n')
+	if err != nil {
+		fail("no answer from a throughput receiver: %v", err)
+		return
+	}
+	if answer = strings.TrimSpace(answer); answer != "ok" {
+		fail("the receiver refused the test: %s", answer)
+		return
+	}
+
+	chunk := make([]byte, throughputChunk)
+	sendStart := time.Now()
+	end := sendStart.Add(duration)
+	conn.SetDeadline(end) // a write blocked at the end of the test gives up
+	var sent int64
+	for time.Now().Before(end) {
+		if limitMbps > 0 {
+			due := sendStart.Add(time.Duration(float64(sent*8) / (limitMbps * 1e6) * float64(time.Second)))
+			if due.After(end) {
+				break
+			}
+			time.Sleep(time.Until(due))
+		}
+		n, err := conn.Write(chunk)
+		sent += int64(n)
+		if errors.Is(err, os.ErrDeadlineExceeded) {
+			break
+		}
+		if err != nil {
+			fail("sending: %v", err)
+			return
+		}
+	}
+
+	conn.SetDeadline(time.Now().Add(throughputHandshake))
+	if err := conn.(*net.TCPConn).CloseWrite(); err != nil {
+		fail("ending the test: %v", err)
+		return
+	}
+	var report throughputReport
+	if err := json.NewDecoder(reader).Decode(&report); err != nil {
+		fail("no report from the receiver: %v", err)
+		return
+	}
+
+	info := &ThroughputInfo{
+		DurationMs:  report.DurationMs,
+		Bytes:       report.Bytes,
+		Retransmits: -1,
+		LimitMbps:   limitMbps,
+	}
+	if report.DurationMs > 0 {
+		info.Mbps = float64(report.Bytes*8) / (float64(report.DurationMs) / 1000) / 1e6
+	}
+	if retransmits, rtt, ok := tcpStats(conn.(*net.TCPConn)); ok {
+		info.Retransmits = retransmits
+		info.RTTMs = float64(rtt.Microseconds()) / 1000
+	}
+	result.Throughput = info
+	result.Success = true
+}
+
+// ServeThroughput hosts a throughput receiver on addr until the context is
+// cancelled. It serves one test at a time, turning others away, and stops
+// reading from a sender that runs past the duration it announced.
+func ServeThroughput(ctx context.Context, addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	go func() {
+		<-ctx.Done()
+		listener.Close()
+	}()
+
+	var busy int32
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		if !atomic.CompareAndSwapInt32(&busy, 0, 1) {
+			conn.SetWriteDeadline(time.Now().Add(throughputHandshake))
+			fmt.Fprintf(conn, "busy with another test\n")
+			conn.Close()
+			continue
+		}
+		go func() {
+			defer atomic.StoreInt32(&busy, 0)
+			receiveThroughput(conn)
+		}()
+	}
+}
+
+// receiveThroughput serves one test: it checks the sender's header, discards
+// what it sends until it closes its side, and reports how much arrived
+func receiveThroughput(conn net.Conn) {
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(throughputHandshake))
+	reader := bufio.NewReader(conn)
+	header, err := reader.ReadString('\n')
+	if err != nil {
+		return
+	}
+	fields := strings.Fields(header)
+	if len(fields) != 2 || fields[0] != throughputMagic {
+		fmt.Fprintf(conn, "not a %s sender\n", throughputMagic)
+		return
+	}
+	durationMs, err := strconv.ParseInt(fields[1], 10, 64)
+	if err != nil || durationMs <= 0 || durationMs > MaxThroughputDuration.Milliseconds() {
+		fmt.Fprintf(conn, "duration must be between 1 and %d ms\n", MaxThroughputDuration.Milliseconds())
+		return
+	}
+	if _, err := fmt.Fprintf(conn, "ok\n"); err != nil {
+		return
+	}
+
+	start := time.Now()
+	conn.SetDeadline(start.Add(time.Duration(durationMs)*time.Millisecond + throughputHandshake))
+	received, err := io.Copy(io.Discard, reader)
+	elapsed := time.Since(start)
+	if err != nil {
+		log.Printf("Throughput test from %s ended early: %v", conn.RemoteAddr(), err)
+		return
+	}
+
+	conn.SetDeadline(time.Now().Add(throughputHandshake))
+	json.NewEncoder(conn).Encode(throughputReport{Bytes: received, DurationMs: elapsed.Milliseconds()})
+}
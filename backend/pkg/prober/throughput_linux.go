@@ -0,0 +1,30 @@
+//go:build linux && (amd64 || arm64)
+
+package prober
+
+import (
+	"net"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// tcpStats reads the sender's retransmitted segments and smoothed round trip
+// time from the kernel's TCP_INFO
+func tcpStats(conn *net.TCPConn) (retransmits int64, rtt time.Duration, ok bool) {
+	raw, err := conn.SyscallConn()
+	if err != nil {
+		return 0, 0, false
+	}
+	var info syscall.TCPInfo
+	var sockErr syscall.Errno
+	err = raw.Control(func(fd uintptr) {
+		size := uint32(unsafe.Sizeof(info))
+		_, _, sockErr = syscall.Syscall6(syscall.SYS_GETSOCKOPT, fd, syscall.SOL_TCP, syscall.TCP_INFO,
+			uintptr(unsafe.Pointer(&info)), uintptr(unsafe.Pointer(&size)), 0)
+	})
+	if err != nil || sockErr != 0 {
+		return 0, 0, false
+	}
+	return int64(info.Total_retrans), time.Duration(info.Rtt) * time.Microsecond, true
+}
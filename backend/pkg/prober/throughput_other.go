@@ -0,0 +1,13 @@
+//go:build !linux || !(amd64 || arm64)
+
+package prober
+
+import (
+	"net"
+	"time"
+)
+
+// tcpStats is only implemented on Linux, where the probe agents run
+func tcpStats(conn *net.TCPConn) (retransmits int64, rtt time.Duration, ok bool) {
+	return 0, 0, false
+}
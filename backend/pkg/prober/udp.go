@@ -0,0 +1,120 @@
+package prober
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// ProbeTypeUDP is recorded for UDP probes
+const ProbeTypeUDP = "UDP"
+
+// Service annotations for UDP ports. Like the other probe annotations they may
+// be suffixed with "." and a port name. Values starting with "hex:" are
+// hex-encoded bytes, anything else is sent or matched as text.
+const (
+	AnnotationUDPPayload = "netvis.io/udp-payload" // datagram to send, empty by default
+	AnnotationUDPExpect  = "netvis.io/udp-expect"  // "any", or bytes the reply must contain
+)
+
+// UDPExpectAny accepts any reply to a UDP probe
+const UDPExpectAny = "any"
+
+// udpUnreachableWait is how long a UDP probe that expects no reply waits for an
+// ICMP port unreachable before counting the port as open
+const udpUnreachableWait = time.Second
+
+// selectUDPProbe builds the probe for a UDP Service port
+func selectUDPProbe(svc corev1.Service, port corev1.ServicePort) ProbeSpec {
+	return ProbeSpec{
+		Type:        ProbeTypeUDP,
+		Payload:     portAnnotation(svc, AnnotationUDPPayload, port.Name),
+		ExpectReply: portAnnotation(svc, AnnotationUDPExpect, port.Name),
+		SelectedBy:  "protocol",
+	}
+}
+
+// checkUDP sends the spec's payload and waits for a reply. UDP has no handshake,
+// so without an expected reply the probe only fails on an ICMP port unreachable
+// and a silent port is recorded as open with NoResponse set.
+func checkUDP(result *ProbeResult, spec ProbeSpec, timeout time.Duration) {
+	result.Expected = spec.ExpectReply
+
+	payload, err := decodeUDPData(spec.Payload)
+	if err != nil {
+		result.Success = false
+		result.Error = fmt.Sprintf("invalid UDP payload %q: %v", spec.Payload, err)
+		return
+	}
+	var expect []byte
+	if spec.ExpectReply != "" && spec.ExpectReply != UDPExpectAny {
+		if expect, err = decodeUDPData(spec.ExpectReply); err != nil {
+			result.Success = false
+			result.Error = fmt.Sprintf("invalid expected UDP reply %q: %v", spec.ExpectReply, err)
+			return
+		}
+	}
+
+	wait := timeout
+	if spec.ExpectReply == "" && wait > udpUnreachableWait {
+		wait = udpUnreachableWait
+	}
+
+	start := time.Now()
+	conn, err := net.DialTimeout("udp", net.JoinHostPort(result.TargetIP, strconv.Itoa(int(result.TargetPort))), timeout)
+	if err != nil {
+		result.Success = false
+		result.Error = err.Error()
+		return
+	}
+	defer conn.Close()
+	conn.SetDeadline(start.Add(wait))
+
+	if _, err := conn.Write(payload); err != nil {
+		result.Success = false
+		result.Error = err.Error()
+		return
+	}
+	reply := make([]byte, 64*1024)
+	n, err := conn.Read(reply)
+	result.Latency = time.Since(start).Milliseconds()
+
+	switch {
+	case errors.Is(err, syscall.ECONNREFUSED):
+		result.Success = false
+		result.Error = "port unreachable"
+	case errors.Is(err, os.ErrDeadlineExceeded) && spec.ExpectReply == "":
+		// Open, or filtered without an ICMP error; there is no round trip to time
+		result.Success = true
+		result.NoResponse = true
+		result.Latency = 0
+	case errors.Is(err, os.ErrDeadlineExceeded):
+		result.Success = false
+		result.Error = fmt.Sprintf("no reply within %v", wait)
+	case err != nil:
+		result.Success = false
+		result.Error = err.Error()
+	case expect != nil && !bytes.Contains(reply[:n], expect):
+		result.Success = false
+		result.Error = fmt.Sprintf("reply of %d bytes does not contain %q", n, spec.ExpectReply)
+	default:
+		result.Success = true
+	}
+}
+
+// decodeUDPData reads a payload or expected reply annotation value
+func decodeUDPData(value string) ([]byte, error) {
+	if encoded, ok := strings.CutPrefix(value, "hex:"); ok {
+		return hex.DecodeString(strings.ReplaceAll(encoded, " ", ""))
+	}
+	return []byte(value), nil
+}
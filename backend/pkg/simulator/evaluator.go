@@ -0,0 +1,336 @@
+package simulator
+
+import (
+	"fmt"
+	"net"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// namespaceNameLabel is set automatically on every namespace by the API server
+const namespaceNameLabel = "kubernetes.io/metadata.name"
+
+// Connection describes a single connection attempt to be evaluated against NetworkPolicies.
+// Either side may be a pod or a bare IP (for traffic to or from outside the pod network).
+type Connection struct {
+	Source        *corev1.Pod
+	SourceIP      string
+	Destination   *corev1.Pod
+	DestinationIP string
+	Port          int32
+	Protocol      corev1.Protocol
+}
+
+// PolicyVerdict records how a single policy treated a connection
+type PolicyVerdict struct {
+	Policy  string `json:"policy"` // namespace/name
+	Allowed bool   `json:"allowed"`
+	Rule    int    `json:"rule"` // index of the first matching rule, -1 if none matched
+}
+
+// PolicyDecision is the result of evaluating a connection against all policies
+type PolicyDecision struct {
+	Allowed         bool            `json:"allowed"`
+	EgressIsolated  bool            `json:"egress_isolated"`
+	IngressIsolated bool            `json:"ingress_isolated"`
+	Egress          []PolicyVerdict `json:"egress,omitempty"`
+	Ingress         []PolicyVerdict `json:"ingress,omitempty"`
+}
+
+// EgressAllowed reports whether the source side lets the connection out
+func (d PolicyDecision) EgressAllowed() bool {
+	return !d.EgressIsolated || anyAllowed(d.Egress)
+}
+
+// IngressAllowed reports whether the destination side lets the connection in
+func (d PolicyDecision) IngressAllowed() bool {
+	return !d.IngressIsolated || anyAllowed(d.Ingress)
+}
+
+func anyAllowed(verdicts []PolicyVerdict) bool {
+	for _, v := range verdicts {
+		if v.Allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// PolicyEvaluator decides allow/deny for connections using the Kubernetes
+// NetworkPolicy model: a pod is isolated in a direction once any policy of that
+// type selects it, and isolated traffic is allowed if any selecting policy has
+// a matching rule. A connection needs both egress from the source and ingress
+// to the destination to be allowed.
+type PolicyEvaluator struct {
+	policies        []*networkingv1.NetworkPolicy
+	namespaceLabels map[string]map[string]string
+}
+
+// NewPolicyEvaluator creates an evaluator over a fixed set of policies.
+// namespaceLabels maps namespace name to its labels and is used for namespaceSelector
+// matching; unknown namespaces only carry the automatic kubernetes.io/metadata.name label.
+func NewPolicyEvaluator(policies []*networkingv1.NetworkPolicy, namespaceLabels map[string]map[string]string) *PolicyEvaluator {
+	if namespaceLabels == nil {
+		namespaceLabels = make(map[string]map[string]string)
+	}
+	return &PolicyEvaluator{
+		policies:        policies,
+		namespaceLabels: namespaceLabels,
+	}
+}
+
+// Policies returns the policies the evaluator was built with
+func (e *PolicyEvaluator) Policies() []*networkingv1.NetworkPolicy {
+	return e.policies
+}
+
+// Allowed reports whether the connection is allowed
+func (e *PolicyEvaluator) Allowed(conn Connection) bool {
+	return e.Evaluate(conn).Allowed
+}
+
+// Evaluate evaluates a connection and explains which policies applied
+func (e *PolicyEvaluator) Evaluate(conn Connection) PolicyDecision {
+	if conn.Protocol == "" {
+		conn.Protocol = corev1.ProtocolTCP
+	}
+	sourceIP := conn.SourceIP
+	if sourceIP == "" && conn.Source != nil {
+		sourceIP = conn.Source.Status.PodIP
+	}
+	destIP := conn.DestinationIP
+	if destIP == "" && conn.Destination != nil {
+		destIP = conn.Destination.Status.PodIP
+	}
+
+	decision := PolicyDecision{}
+
+	if conn.Source != nil {
+		for _, policy := range e.policies {
+			if !PolicyHasType(policy, networkingv1.PolicyTypeEgress) || !e.SelectsPod(policy, conn.Source) {
+				continue
+			}
+			verdict := PolicyVerdict{Policy: PolicyKey(policy), Rule: -1}
+			for i, rule := range policy.Spec.Egress {
+				if e.peersMatch(policy.Namespace, rule.To, conn.Destination, destIP) &&
+					portsMatch(rule.Ports, conn.Destination, conn.Port, conn.Protocol) {
+					verdict.Allowed = true
+					verdict.Rule = i
+					break
+				}
+			}
+			decision.Egress = append(decision.Egress, verdict)
+		}
+		decision.EgressIsolated = len(decision.Egress) > 0
+	}
+
+	if conn.Destination != nil {
+		for _, policy := range e.policies {
+			if !PolicyHasType(policy, networkingv1.PolicyTypeIngress) || !e.SelectsPod(policy, conn.Destination) {
+				continue
+			}
+			verdict := PolicyVerdict{Policy: PolicyKey(policy), Rule: -1}
+			for i, rule := range policy.Spec.Ingress {
+				if e.peersMatch(policy.Namespace, rule.From, conn.Source, sourceIP) &&
+					portsMatch(rule.Ports, conn.Destination, conn.Port, conn.Protocol) {
+					verdict.Allowed = true
+					verdict.Rule = i
+					break
+				}
+			}
+			decision.Ingress = append(decision.Ingress, verdict)
+		}
+		decision.IngressIsolated = len(decision.Ingress) > 0
+	}
+
+	decision.Allowed = decision.EgressAllowed() && decision.IngressAllowed()
+	return decision
+}
+
+// EgressRuleAllows reports whether one egress rule of the policy allows
+// connections to the destination pod on the port and protocol
+func (e *PolicyEvaluator) EgressRuleAllows(policy *networkingv1.NetworkPolicy, rule networkingv1.NetworkPolicyEgressRule, destination *corev1.Pod, port int32, protocol corev1.Protocol) bool {
+	return e.peersMatch(policy.Namespace, rule.To, destination, destination.Status.PodIP) &&
+		portsMatch(rule.Ports, destination, port, protocol)
+}
+
+// SelectsPod reports whether a policy's podSelector selects the given pod
+func (e *PolicyEvaluator) SelectsPod(policy *networkingv1.NetworkPolicy, pod *corev1.Pod) bool {
+	if pod == nil || pod.Spec.HostNetwork || pod.Namespace != policy.Namespace {
+		return false
+	}
+	return SelectorMatches(&policy.Spec.PodSelector, pod.Labels)
+}
+
+// PoliciesSelecting returns the keys of policies of the given type that select the pod
+func (e *PolicyEvaluator) PoliciesSelecting(pod *corev1.Pod, policyType networkingv1.PolicyType) []string {
+	var keys []string
+	for _, policy := range e.policies {
+		if PolicyHasType(policy, policyType) && e.SelectsPod(policy, pod) {
+			keys = append(keys, PolicyKey(policy))
+		}
+	}
+	return keys
+}
+
+// NamespaceMatches reports whether a namespaceSelector selects the namespace
+func (e *PolicyEvaluator) NamespaceMatches(selector *metav1.LabelSelector, namespace string) bool {
+	return SelectorMatches(selector, e.labelsForNamespace(namespace))
+}
+
+func (e *PolicyEvaluator) labelsForNamespace(namespace string) map[string]string {
+	nsLabels := map[string]string{namespaceNameLabel: namespace}
+	for key, value := range e.namespaceLabels[namespace] {
+		nsLabels[key] = value
+	}
+	return nsLabels
+}
+
+// peersMatch checks a rule's from/to list against the remote end of a connection.
+// An empty list matches everything.
+func (e *PolicyEvaluator) peersMatch(policyNamespace string, peers []networkingv1.NetworkPolicyPeer, pod *corev1.Pod, ip string) bool {
+	if len(peers) == 0 {
+		return true
+	}
+	for _, peer := range peers {
+		if e.peerMatches(policyNamespace, peer, pod, ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func (e *PolicyEvaluator) peerMatches(policyNamespace string, peer networkingv1.NetworkPolicyPeer, pod *corev1.Pod, ip string) bool {
+	if peer.IPBlock != nil {
+		return IPBlockContains(peer.IPBlock, ip)
+	}
+	if peer.PodSelector == nil && peer.NamespaceSelector == nil {
+		return false
+	}
+	// Selectors only ever match pods on the pod network
+	if pod == nil || pod.Spec.HostNetwork {
+		return false
+	}
+
+	if peer.NamespaceSelector != nil {
+		if !e.NamespaceMatches(peer.NamespaceSelector, pod.Namespace) {
+			return false
+		}
+	} else if pod.Namespace != policyNamespace {
+		return false
+	}
+
+	if peer.PodSelector != nil {
+		return SelectorMatches(peer.PodSelector, pod.Labels)
+	}
+	return true
+}
+
+// portsMatch checks a rule's port list. An empty list matches every port and protocol.
+// Named ports are resolved against the destination pod's container ports.
+func portsMatch(ports []networkingv1.NetworkPolicyPort, destination *corev1.Pod, port int32, protocol corev1.Protocol) bool {
+	if len(ports) == 0 {
+		return true
+	}
+	for _, p := range ports {
+		ruleProtocol := corev1.ProtocolTCP
+		if p.Protocol != nil {
+			ruleProtocol = *p.Protocol
+		}
+		if ruleProtocol != protocol {
+			continue
+		}
+		if p.Port == nil {
+			return true
+		}
+		if p.Port.Type == intstr.String {
+			if destination != nil && ResolveNamedPort(destination, p.Port.StrVal, protocol) == port && port != 0 {
+				return true
+			}
+			continue
+		}
+		start := p.Port.IntVal
+		end := start
+		if p.EndPort != nil && *p.EndPort >= start {
+			end = *p.EndPort
+		}
+		if port >= start && port <= end {
+			return true
+		}
+	}
+	return false
+}
+
+// PolicyHasType reports whether a policy isolates pods in the given direction,
+// applying the API server's defaulting: policies without policyTypes always
+// affect ingress, and affect egress only when they carry egress rules.
+func PolicyHasType(policy *networkingv1.NetworkPolicy, policyType networkingv1.PolicyType) bool {
+	if len(policy.Spec.PolicyTypes) == 0 {
+		if policyType == networkingv1.PolicyTypeIngress {
+			return true
+		}
+		return len(policy.Spec.Egress) > 0
+	}
+	for _, t := range policy.Spec.PolicyTypes {
+		if t == policyType {
+			return true
+		}
+	}
+	return false
+}
+
+// PolicyKey returns the namespace/name key of a policy
+func PolicyKey(policy *networkingv1.NetworkPolicy) string {
+	return fmt.Sprintf("%s/%s", policy.Namespace, policy.Name)
+}
+
+// SelectorMatches evaluates a label selector, including matchExpressions.
+// A nil selector matches nothing; an empty selector matches everything.
+func SelectorMatches(selector *metav1.LabelSelector, objLabels map[string]string) bool {
+	if selector == nil {
+		return false
+	}
+	sel, err := metav1.LabelSelectorAsSelector(selector)
+	if err != nil {
+		return false
+	}
+	return sel.Matches(labels.Set(objLabels))
+}
+
+// IPBlockContains reports whether ip falls inside the block's CIDR and outside all exceptions
+func IPBlockContains(block *networkingv1.IPBlock, ip string) bool {
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return false
+	}
+	_, cidr, err := net.ParseCIDR(block.CIDR)
+	if err != nil || !cidr.Contains(addr) {
+		return false
+	}
+	for _, except := range block.Except {
+		if _, exceptNet, err := net.ParseCIDR(except); err == nil && exceptNet.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+// ResolveNamedPort returns the container port number for a named port, or 0 if not found
+func ResolveNamedPort(pod *corev1.Pod, name string, protocol corev1.Protocol) int32 {
+	for _, container := range pod.Spec.Containers {
+		for _, port := range container.Ports {
+			portProtocol := port.Protocol
+			if portProtocol == "" {
+				portProtocol = corev1.ProtocolTCP
+			}
+			if port.Name == name && portProtocol == protocol {
+				return port.ContainerPort
+			}
+		}
+	}
+	return 0
+}
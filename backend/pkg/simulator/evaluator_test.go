@@ -0,0 +1,381 @@
+package simulator
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func testPod(namespace, name, ip string, podLabels map[string]string, ports ...corev1.ContainerPort) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name, Labels: podLabels},
+		Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "app", Ports: ports}}},
+		Status:     corev1.PodStatus{PodIP: ip},
+	}
+}
+
+func testPolicy(namespace, name string, selector map[string]string, types ...networkingv1.PolicyType) *networkingv1.NetworkPolicy {
+	return &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+		Spec: networkingv1.NetworkPolicySpec{
+			PodSelector: metav1.LabelSelector{MatchLabels: selector},
+			PolicyTypes: types,
+		},
+	}
+}
+
+func withIngress(policy *networkingv1.NetworkPolicy, rules ...networkingv1.NetworkPolicyIngressRule) *networkingv1.NetworkPolicy {
+	policy.Spec.Ingress = append(policy.Spec.Ingress, rules...)
+	return policy
+}
+
+func withEgress(policy *networkingv1.NetworkPolicy, rules ...networkingv1.NetworkPolicyEgressRule) *networkingv1.NetworkPolicy {
+	policy.Spec.Egress = append(policy.Spec.Egress, rules...)
+	return policy
+}
+
+func podPeer(podLabels map[string]string) networkingv1.NetworkPolicyPeer {
+	return networkingv1.NetworkPolicyPeer{PodSelector: &metav1.LabelSelector{MatchLabels: podLabels}}
+}
+
+func tcpPort(port intstr.IntOrString) networkingv1.NetworkPolicyPort {
+	protocol := corev1.ProtocolTCP
+	return networkingv1.NetworkPolicyPort{Protocol: &protocol, Port: &port}
+}
+
+func TestPolicyEvaluator(t *testing.T) {
+	web := map[string]string{"app": "web"}
+	api := map[string]string{"app": "api"}
+	db := map[string]string{"app": "db"}
+	endPort := int32(8090)
+
+	frontend := testPod("shop", "web-1", "10.0.0.1", web)
+	backend := testPod("shop", "api-1", "10.0.0.2", api,
+		corev1.ContainerPort{Name: "http", ContainerPort: 8080},
+		corev1.ContainerPort{Name: "metrics", ContainerPort: 9090, Protocol: corev1.ProtocolUDP})
+	database := testPod("data", "db-1", "10.0.1.1", db)
+	monitor := testPod("ops", "prom-1", "10.0.2.1", web)
+	node := testPod("kube-system", "node-agent", "192.168.1.10", web)
+	node.Spec.HostNetwork = true
+
+	namespaceLabels := map[string]map[string]string{
+		"shop": {"team": "shop"},
+		"ops":  {"team": "ops"},
+		"data": {"team": "data"},
+	}
+
+	tests := []struct {
+		name     string
+		policies []*networkingv1.NetworkPolicy
+		conn     Connection
+		allowed  bool
+		ingress  bool // destination isolated for ingress
+		egress   bool // source isolated for egress
+	}{
+		{
+			name:    "no policies allow everything",
+			conn:    Connection{Source: frontend, Destination: backend, Port: 8080},
+			allowed: true,
+		},
+		{
+			name:     "ingress policy without rules isolates the destination",
+			policies: []*networkingv1.NetworkPolicy{testPolicy("shop", "deny", api, networkingv1.PolicyTypeIngress)},
+			conn:     Connection{Source: frontend, Destination: backend, Port: 8080},
+			ingress:  true,
+		},
+		{
+			name: "union of policies allows what any of them allows",
+			policies: []*networkingv1.NetworkPolicy{
+				withIngress(testPolicy("shop", "from-db", api, networkingv1.PolicyTypeIngress),
+					networkingv1.NetworkPolicyIngressRule{From: []networkingv1.NetworkPolicyPeer{podPeer(db)}}),
+				withIngress(testPolicy("shop", "from-web", api, networkingv1.PolicyTypeIngress),
+					networkingv1.NetworkPolicyIngressRule{From: []networkingv1.NetworkPolicyPeer{podPeer(web)}}),
+			},
+			conn:    Connection{Source: frontend, Destination: backend, Port: 8080},
+			allowed: true,
+			ingress: true,
+		},
+		{
+			name: "policies in another namespace do not select the pod",
+			policies: []*networkingv1.NetworkPolicy{
+				testPolicy("data", "deny", nil, networkingv1.PolicyTypeIngress),
+			},
+			conn:    Connection{Source: frontend, Destination: backend, Port: 8080},
+			allowed: true,
+		},
+		{
+			name:     "no policyTypes and no egress rules affect ingress only",
+			policies: []*networkingv1.NetworkPolicy{testPolicy("shop", "default", web)},
+			conn:     Connection{Source: frontend, Destination: backend, Port: 8080},
+			allowed:  true,
+		},
+		{
+			name: "no policyTypes with egress rules isolate egress too",
+			policies: []*networkingv1.NetworkPolicy{
+				withEgress(testPolicy("shop", "to-db", web),
+					networkingv1.NetworkPolicyEgressRule{To: []networkingv1.NetworkPolicyPeer{{
+						NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"team": "data"}},
+					}}}),
+			},
+			conn:   Connection{Source: frontend, Destination: backend, Port: 8080},
+			egress: true,
+		},
+		{
+			name: "egress and ingress must both allow",
+			policies: []*networkingv1.NetworkPolicy{
+				withEgress(testPolicy("shop", "web-out", web, networkingv1.PolicyTypeEgress),
+					networkingv1.NetworkPolicyEgressRule{To: []networkingv1.NetworkPolicyPeer{podPeer(api)}}),
+				testPolicy("shop", "api-in", api, networkingv1.PolicyTypeIngress),
+			},
+			conn:    Connection{Source: frontend, Destination: backend, Port: 8080},
+			ingress: true,
+			egress:  true,
+		},
+		{
+			name: "namespaceSelector with podSelector needs both to match",
+			policies: []*networkingv1.NetworkPolicy{
+				withIngress(testPolicy("data", "from-shop-api", db, networkingv1.PolicyTypeIngress),
+					networkingv1.NetworkPolicyIngressRule{From: []networkingv1.NetworkPolicyPeer{{
+						NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"team": "shop"}},
+						PodSelector:       &metav1.LabelSelector{MatchLabels: api},
+					}}}),
+			},
+			conn:    Connection{Source: backend, Destination: database, Port: 5432},
+			allowed: true,
+			ingress: true,
+		},
+		{
+			name: "namespaceSelector with podSelector rejects other pods in the namespace",
+			policies: []*networkingv1.NetworkPolicy{
+				withIngress(testPolicy("data", "from-shop-api", db, networkingv1.PolicyTypeIngress),
+					networkingv1.NetworkPolicyIngressRule{From: []networkingv1.NetworkPolicyPeer{{
+						NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"team": "shop"}},
+						PodSelector:       &metav1.LabelSelector{MatchLabels: api},
+					}}}),
+			},
+			conn:    Connection{Source: frontend, Destination: database, Port: 5432},
+			ingress: true,
+		},
+		{
+			name: "namespaceSelector with podSelector rejects matching pods in other namespaces",
+			policies: []*networkingv1.NetworkPolicy{
+				withIngress(testPolicy("data", "from-shop-web", db, networkingv1.PolicyTypeIngress),
+					networkingv1.NetworkPolicyIngressRule{From: []networkingv1.NetworkPolicyPeer{{
+						NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"team": "shop"}},
+						PodSelector:       &metav1.LabelSelector{MatchLabels: web},
+					}}}),
+			},
+			conn:    Connection{Source: monitor, Destination: database, Port: 5432},
+			ingress: true,
+		},
+		{
+			name: "podSelector alone only matches the policy's namespace",
+			policies: []*networkingv1.NetworkPolicy{
+				withIngress(testPolicy("shop", "from-web", api, networkingv1.PolicyTypeIngress),
+					networkingv1.NetworkPolicyIngressRule{From: []networkingv1.NetworkPolicyPeer{podPeer(web)}}),
+			},
+			conn:    Connection{Source: monitor, Destination: backend, Port: 8080},
+			ingress: true,
+		},
+		{
+			name: "ipBlock allows addresses inside the CIDR",
+			policies: []*networkingv1.NetworkPolicy{
+				withEgress(testPolicy("shop", "to-net", web, networkingv1.PolicyTypeEgress),
+					networkingv1.NetworkPolicyEgressRule{To: []networkingv1.NetworkPolicyPeer{{
+						IPBlock: &networkingv1.IPBlock{CIDR: "203.0.113.0/24", Except: []string{"203.0.113.128/25"}},
+					}}}),
+			},
+			conn:    Connection{Source: frontend, DestinationIP: "203.0.113.10", Port: 443},
+			allowed: true,
+			egress:  true,
+		},
+		{
+			name: "ipBlock except carves addresses out",
+			policies: []*networkingv1.NetworkPolicy{
+				withEgress(testPolicy("shop", "to-net", web, networkingv1.PolicyTypeEgress),
+					networkingv1.NetworkPolicyEgressRule{To: []networkingv1.NetworkPolicyPeer{{
+						IPBlock: &networkingv1.IPBlock{CIDR: "203.0.113.0/24", Except: []string{"203.0.113.128/25"}},
+					}}}),
+			},
+			conn:   Connection{Source: frontend, DestinationIP: "203.0.113.200", Port: 443},
+			egress: true,
+		},
+		{
+			name: "named port resolves on the destination pod",
+			policies: []*networkingv1.NetworkPolicy{
+				withIngress(testPolicy("shop", "http", api, networkingv1.PolicyTypeIngress),
+					networkingv1.NetworkPolicyIngressRule{Ports: []networkingv1.NetworkPolicyPort{tcpPort(intstr.FromString("http"))}}),
+			},
+			conn:    Connection{Source: frontend, Destination: backend, Port: 8080},
+			allowed: true,
+			ingress: true,
+		},
+		{
+			name: "named port does not match other port numbers",
+			policies: []*networkingv1.NetworkPolicy{
+				withIngress(testPolicy("shop", "http", api, networkingv1.PolicyTypeIngress),
+					networkingv1.NetworkPolicyIngressRule{Ports: []networkingv1.NetworkPolicyPort{tcpPort(intstr.FromString("http"))}}),
+			},
+			conn:    Connection{Source: frontend, Destination: backend, Port: 9090},
+			ingress: true,
+		},
+		{
+			name: "named port must match the protocol",
+			policies: []*networkingv1.NetworkPolicy{
+				withIngress(testPolicy("shop", "metrics", api, networkingv1.PolicyTypeIngress),
+					networkingv1.NetworkPolicyIngressRule{Ports: []networkingv1.NetworkPolicyPort{tcpPort(intstr.FromString("metrics"))}}),
+			},
+			conn:    Connection{Source: frontend, Destination: backend, Port: 9090},
+			ingress: true,
+		},
+		{
+			name: "endPort covers the range",
+			policies: []*networkingv1.NetworkPolicy{
+				withIngress(testPolicy("shop", "range", api, networkingv1.PolicyTypeIngress),
+					networkingv1.NetworkPolicyIngressRule{Ports: []networkingv1.NetworkPolicyPort{func() networkingv1.NetworkPolicyPort {
+						port := tcpPort(intstr.FromInt(8080))
+						port.EndPort = &endPort
+						return port
+					}()}}),
+			},
+			conn:    Connection{Source: frontend, Destination: backend, Port: 8090},
+			allowed: true,
+			ingress: true,
+		},
+		{
+			name: "endPort stops at the end of the range",
+			policies: []*networkingv1.NetworkPolicy{
+				withIngress(testPolicy("shop", "range", api, networkingv1.PolicyTypeIngress),
+					networkingv1.NetworkPolicyIngressRule{Ports: []networkingv1.NetworkPolicyPort{func() networkingv1.NetworkPolicyPort {
+						port := tcpPort(intstr.FromInt(8080))
+						port.EndPort = &endPort
+						return port
+					}()}}),
+			},
+			conn:    Connection{Source: frontend, Destination: backend, Port: 8091},
+			ingress: true,
+		},
+		{
+			name: "hostNetwork pods are never selected",
+			policies: []*networkingv1.NetworkPolicy{
+				testPolicy("kube-system", "deny", nil, networkingv1.PolicyTypeIngress, networkingv1.PolicyTypeEgress),
+			},
+			conn:    Connection{Source: node, Destination: backend, Port: 8080},
+			allowed: true,
+		},
+		{
+			name: "hostNetwork pods do not match pod selectors",
+			policies: []*networkingv1.NetworkPolicy{
+				withIngress(testPolicy("shop", "from-any-web", api, networkingv1.PolicyTypeIngress),
+					networkingv1.NetworkPolicyIngressRule{From: []networkingv1.NetworkPolicyPeer{{
+						NamespaceSelector: &metav1.LabelSelector{},
+						PodSelector:       &metav1.LabelSelector{MatchLabels: web},
+					}}}),
+			},
+			conn:    Connection{Source: node, Destination: backend, Port: 8080},
+			ingress: true,
+		},
+		{
+			name: "hostNetwork pods match ipBlocks by node IP",
+			policies: []*networkingv1.NetworkPolicy{
+				withIngress(testPolicy("shop", "from-nodes", api, networkingv1.PolicyTypeIngress),
+					networkingv1.NetworkPolicyIngressRule{From: []networkingv1.NetworkPolicyPeer{{
+						IPBlock: &networkingv1.IPBlock{CIDR: "192.168.1.0/24"},
+					}}}),
+			},
+			conn:    Connection{Source: node, Destination: backend, Port: 8080},
+			allowed: true,
+			ingress: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decision := NewPolicyEvaluator(tt.policies, namespaceLabels).Evaluate(tt.conn)
+			if decision.Allowed != tt.allowed {
+				t.Errorf("Allowed = %v, want %v (decision %+v)", decision.Allowed, tt.allowed, decision)
+			}
+			if decision.IngressIsolated != tt.ingress {
+				t.Errorf("IngressIsolated = %v, want %v", decision.IngressIsolated, tt.ingress)
+			}
+			if decision.EgressIsolated != tt.egress {
+				t.Errorf("EgressIsolated = %v, want %v", decision.EgressIsolated, tt.egress)
+			}
+		})
+	}
+}
+
+func TestPolicyHasType(t *testing.T) {
+	egressRule := networkingv1.NetworkPolicyEgressRule{}
+
+	tests := []struct {
+		name    string
+		policy  *networkingv1.NetworkPolicy
+		ingress bool
+		egress  bool
+	}{
+		{
+			name:    "no policyTypes and no egress rules",
+			policy:  testPolicy("ns", "p", nil),
+			ingress: true,
+		},
+		{
+			name:    "no policyTypes with egress rules",
+			policy:  withEgress(testPolicy("ns", "p", nil), egressRule),
+			ingress: true,
+			egress:  true,
+		},
+		{
+			name:   "egress only",
+			policy: testPolicy("ns", "p", nil, networkingv1.PolicyTypeEgress),
+			egress: true,
+		},
+		{
+			name:    "explicit ingress ignores egress rules",
+			policy:  withEgress(testPolicy("ns", "p", nil, networkingv1.PolicyTypeIngress), egressRule),
+			ingress: true,
+		},
+		{
+			name:    "both",
+			policy:  testPolicy("ns", "p", nil, networkingv1.PolicyTypeIngress, networkingv1.PolicyTypeEgress),
+			ingress: true,
+			egress:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := PolicyHasType(tt.policy, networkingv1.PolicyTypeIngress); got != tt.ingress {
+				t.Errorf("ingress = %v, want %v", got, tt.ingress)
+			}
+			if got := PolicyHasType(tt.policy, networkingv1.PolicyTypeEgress); got != tt.egress {
+				t.Errorf("egress = %v, want %v", got, tt.egress)
+			}
+		})
+	}
+}
+
+func TestIPBlockContains(t *testing.T) {
+	block := &networkingv1.IPBlock{CIDR: "10.0.0.0/8", Except: []string{"10.1.0.0/16", "10.2.3.0/24"}}
+
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{"10.0.0.1", true},
+		{"10.1.2.3", false},
+		{"10.2.3.4", false},
+		{"10.2.4.4", true},
+		{"11.0.0.1", false},
+		{"not-an-ip", false},
+	}
+
+	for _, tt := range tests {
+		if got := IPBlockContains(block, tt.ip); got != tt.want {
+			t.Errorf("IPBlockContains(%s) = %v, want %v", tt.ip, got, tt.want)
+		}
+	}
+}
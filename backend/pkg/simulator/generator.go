@@ -0,0 +1,452 @@
+package simulator
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/christine33-creator/k8-network-visualizer/pkg/flowcollector"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// Labels set by controllers that change between rollouts or differ per pod,
+// and so must not be used in generated selectors
+var unstableLabels = map[string]bool{
+	"pod-template-hash":                  true,
+	"pod-template-generation":            true,
+	"controller-revision-hash":           true,
+	"controller-uid":                     true,
+	"batch.kubernetes.io/controller-uid": true,
+	"statefulset.kubernetes.io/pod-name": true,
+	"apps.kubernetes.io/pod-index":       true,
+}
+
+// ErrNoFlowCollector is returned when policies are generated without observed traffic
+var ErrNoFlowCollector = errors.New("flow collector is not running, cannot observe traffic")
+
+// generatedByLabel marks policies produced by the generator
+const generatedByLabel = "app.kubernetes.io/managed-by"
+
+// GeneratePolicyOptions selects the workloads and traffic to generate policies from
+type GeneratePolicyOptions struct {
+	Namespace string
+	Workload  string // optional, limits generation to a single workload
+	Window    time.Duration
+}
+
+// GeneratedPolicies holds least-privilege policies generated from observed traffic
+type GeneratedPolicies struct {
+	Policies      []*networkingv1.NetworkPolicy `json:"policies"`
+	FlowsObserved int                           `json:"flows_observed"`
+	Warnings      []string                      `json:"warnings"`
+	Manifest      string                        `json:"manifest,omitempty"`
+	Simulation    *SimulationResult             `json:"simulation,omitempty"`
+}
+
+// workload groups the pods run by a single controller
+type workload struct {
+	namespace string
+	name      string
+	pods      []*corev1.Pod
+	selector  map[string]string
+	overlap   string // a pod of another workload that selector also selects
+}
+
+func (w *workload) key() string {
+	return fmt.Sprintf("%s/%s", w.namespace, w.name)
+}
+
+// generatedRule accumulates the ports observed for one peer
+type generatedRule struct {
+	peer  networkingv1.NetworkPolicyPeer
+	ports map[string]networkingv1.NetworkPolicyPort
+}
+
+// GeneratePolicies builds one NetworkPolicy per workload that allows exactly the ingress
+// and egress observed by the flow collector within the window, plus DNS egress. Peers are
+// selected by their workload's stable labels rather than pod names; workloads
+// whose labels also select another workload's pods are skipped with a warning.
+func (s *Simulator) GeneratePolicies(opts GeneratePolicyOptions) (*GeneratedPolicies, error) {
+	if s.flowCollector == nil {
+		return nil, ErrNoFlowCollector
+	}
+	if opts.Namespace == "" {
+		return nil, fmt.Errorf("namespace is required")
+	}
+	if opts.Window <= 0 {
+		opts.Window = DefaultFlowWindow
+	}
+
+	result := &GeneratedPolicies{
+		Policies: []*networkingv1.NetworkPolicy{},
+		Warnings: []string{},
+	}
+
+	workloads := s.groupWorkloads()
+	podWorkload := make(map[*corev1.Pod]*workload)
+	targets := []*workload{}
+	for _, key := range sortedWorkloadKeys(workloads) {
+		w := workloads[key]
+		for _, pod := range w.pods {
+			podWorkload[pod] = w
+		}
+		if w.namespace != opts.Namespace || (opts.Workload != "" && w.name != opts.Workload) {
+			continue
+		}
+		if len(w.selector) == 0 {
+			result.Warnings = append(result.Warnings, fmt.Sprintf("Workload %s has no stable labels to select it, skipped", w.key()))
+			continue
+		}
+		if w.overlap != "" {
+			result.Warnings = append(result.Warnings, fmt.Sprintf("Workload %s shares its labels %s with pod %s, skipped", w.key(), labels.Set(w.selector), w.overlap))
+			continue
+		}
+		targets = append(targets, w)
+	}
+	if opts.Workload != "" && len(targets) == 0 && len(result.Warnings) == 0 {
+		return nil, fmt.Errorf("workload %s/%s not found", opts.Namespace, opts.Workload)
+	}
+
+	ingress := make(map[*workload]map[string]*generatedRule)
+	egress := make(map[*workload]map[string]*generatedRule)
+	for _, w := range targets {
+		ingress[w] = make(map[string]*generatedRule)
+		egress[w] = make(map[string]*generatedRule)
+	}
+
+	cutoff := time.Now().Add(-opts.Window)
+	warned := make(map[string]bool)
+	for _, metric := range s.flowCollector.GetFlowMetricsSince(cutoff) {
+		_, sourcePod, sourceIP, ok := s.resolveObservedPeer(metric.SourceNamespace, metric.SourcePod)
+		if !ok {
+			continue
+		}
+		_, destPod, destIP, ok := s.resolveObservedPeer(metric.DestNamespace, metric.DestPod)
+		if !ok {
+			continue
+		}
+
+		sourceWorkload := podWorkload[sourcePod]
+		destWorkload := podWorkload[destPod]
+		_, sourceTarget := ingress[sourceWorkload]
+		_, destTarget := ingress[destWorkload]
+		if !sourceTarget && !destTarget {
+			continue
+		}
+		result.FlowsObserved++
+
+		// Without a per-port breakdown all ports of the protocol are allowed
+		ports := metric.Ports
+		if len(ports) == 0 {
+			ports = []flowcollector.PortMetric{{Protocol: metric.Protocol}}
+		}
+
+		if destTarget {
+			peerKey, peer, err := generatedPeer(destWorkload.namespace, sourceWorkload, sourcePod, sourceIP)
+			if err != nil {
+				warnOnce(result, warned, fmt.Sprintf("Traffic not allowed: %v", err))
+			} else {
+				addGeneratedPorts(ingress[destWorkload], peerKey, peer, ports)
+			}
+		}
+		if sourceTarget && !isDNSPod(destPod) {
+			peerKey, peer, err := generatedPeer(sourceWorkload.namespace, destWorkload, destPod, destIP)
+			if err != nil {
+				warnOnce(result, warned, fmt.Sprintf("Traffic not allowed: %v", err))
+			} else {
+				addGeneratedPorts(egress[sourceWorkload], peerKey, peer, ports)
+			}
+		}
+	}
+
+	for _, w := range targets {
+		result.Policies = append(result.Policies, buildGeneratedPolicy(w, ingress[w], egress[w], opts.Window))
+	}
+	if result.FlowsObserved == 0 {
+		result.Warnings = append(result.Warnings, fmt.Sprintf("No traffic observed in the last %s; generated policies only allow DNS", opts.Window))
+	}
+
+	return result, nil
+}
+
+// groupWorkloads groups active pods by their owning controller
+func (s *Simulator) groupWorkloads() map[string]*workload {
+	workloads := make(map[string]*workload)
+	for _, key := range sortedPodKeys(s.pods) {
+		pod := s.pods[key]
+		if !isActivePod(pod) {
+			continue
+		}
+		name := WorkloadName(pod)
+		wKey := fmt.Sprintf("%s/%s", pod.Namespace, name)
+		w, ok := workloads[wKey]
+		if !ok {
+			w = &workload{namespace: pod.Namespace, name: name}
+			workloads[wKey] = w
+		}
+		w.pods = append(w.pods, pod)
+	}
+	for _, w := range workloads {
+		w.selector = stableSelector(w.pods)
+		w.overlap = s.selectorOverlap(w)
+	}
+	return workloads
+}
+
+// selectorOverlap returns a pod of another workload in the namespace that the
+// workload's selector also selects, or "" when it selects only its own pods
+func (s *Simulator) selectorOverlap(w *workload) string {
+	if len(w.selector) == 0 {
+		return ""
+	}
+	selector := &metav1.LabelSelector{MatchLabels: w.selector}
+	for _, key := range sortedPodKeys(s.pods) {
+		pod := s.pods[key]
+		if pod.Namespace != w.namespace || WorkloadName(pod) == w.name {
+			continue
+		}
+		if SelectorMatches(selector, pod.Labels) {
+			return key
+		}
+	}
+	return ""
+}
+
+// WorkloadName returns the name of the controller running the pod, resolving
+// ReplicaSets to their Deployment. Bare pods are their own workload.
+func WorkloadName(pod *corev1.Pod) string {
+	var owner *metav1.OwnerReference
+	for i := range pod.OwnerReferences {
+		ref := &pod.OwnerReferences[i]
+		if ref.Controller != nil && *ref.Controller {
+			owner = ref
+			break
+		}
+	}
+	if owner == nil && len(pod.OwnerReferences) > 0 {
+		owner = &pod.OwnerReferences[0]
+	}
+	if owner == nil {
+		return pod.Name
+	}
+	if owner.Kind == "ReplicaSet" {
+		if hash := pod.Labels["pod-template-hash"]; hash != "" {
+			return strings.TrimSuffix(owner.Name, "-"+hash)
+		}
+	}
+	return owner.Name
+}
+
+// stableSelector returns the labels shared by all pods, minus controller-managed ones
+func stableSelector(pods []*corev1.Pod) map[string]string {
+	if len(pods) == 0 {
+		return nil
+	}
+	selector := make(map[string]string)
+	for key, value := range pods[0].Labels {
+		if !unstableLabels[key] {
+			selector[key] = value
+		}
+	}
+	for _, pod := range pods[1:] {
+		for key, value := range selector {
+			if pod.Labels[key] != value {
+				delete(selector, key)
+			}
+		}
+	}
+	return selector
+}
+
+// generatedPeer builds the peer for the remote end of an observed flow
+func generatedPeer(policyNamespace string, w *workload, pod *corev1.Pod, ip string) (string, networkingv1.NetworkPolicyPeer, error) {
+	if pod == nil || pod.Spec.HostNetwork {
+		if pod != nil {
+			ip = pod.Status.PodIP
+		}
+		cidr, err := hostCIDR(ip)
+		if err != nil {
+			return "", networkingv1.NetworkPolicyPeer{}, err
+		}
+		return cidr, networkingv1.NetworkPolicyPeer{IPBlock: &networkingv1.IPBlock{CIDR: cidr}}, nil
+	}
+
+	if w == nil || len(w.selector) == 0 {
+		return "", networkingv1.NetworkPolicyPeer{}, fmt.Errorf("peer %s/%s has no stable labels to select it", pod.Namespace, pod.Name)
+	}
+	if w.overlap != "" {
+		return "", networkingv1.NetworkPolicyPeer{}, fmt.Errorf("peer %s/%s shares its labels with pod %s", pod.Namespace, pod.Name, w.overlap)
+	}
+	peer := networkingv1.NetworkPolicyPeer{
+		PodSelector: &metav1.LabelSelector{MatchLabels: w.selector},
+	}
+	if w.namespace != policyNamespace {
+		peer.NamespaceSelector = &metav1.LabelSelector{
+			MatchLabels: map[string]string{namespaceNameLabel: w.namespace},
+		}
+	}
+	return w.key(), peer, nil
+}
+
+// hostCIDR returns a single-address CIDR for an IP
+func hostCIDR(ip string) (string, error) {
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return "", fmt.Errorf("cannot build ipBlock for address %q", ip)
+	}
+	if addr.To4() != nil {
+		return addr.String() + "/32", nil
+	}
+	return addr.String() + "/128", nil
+}
+
+func addGeneratedPorts(rules map[string]*generatedRule, peerKey string, peer networkingv1.NetworkPolicyPeer, ports []flowcollector.PortMetric) {
+	rule, ok := rules[peerKey]
+	if !ok {
+		rule = &generatedRule{peer: peer, ports: make(map[string]networkingv1.NetworkPolicyPort)}
+		rules[peerKey] = rule
+	}
+	for _, p := range ports {
+		protocol := corev1.Protocol(observedProtocol(p.Protocol))
+		switch protocol {
+		case corev1.ProtocolTCP, corev1.ProtocolUDP, corev1.ProtocolSCTP:
+		default:
+			// NetworkPolicy cannot express other protocols such as ICMP
+			continue
+		}
+		policyPort := networkingv1.NetworkPolicyPort{Protocol: &protocol}
+		if p.Port > 0 {
+			port := intstr.FromInt(p.Port)
+			policyPort.Port = &port
+		}
+		rule.ports[fmt.Sprintf("%s/%05d", protocol, p.Port)] = policyPort
+	}
+}
+
+// buildGeneratedPolicy assembles the policy for a workload from its observed rules
+func buildGeneratedPolicy(w *workload, ingress, egress map[string]*generatedRule, window time.Duration) *networkingv1.NetworkPolicy {
+	policy := &networkingv1.NetworkPolicy{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "networking.k8s.io/v1",
+			Kind:       "NetworkPolicy",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("%s-observed", w.name),
+			Namespace: w.namespace,
+			Labels:    map[string]string{generatedByLabel: "k8s-network-visualizer"},
+			Annotations: map[string]string{
+				"network-visualizer/generated-from": fmt.Sprintf("traffic observed in the last %s", window),
+			},
+		},
+		Spec: networkingv1.NetworkPolicySpec{
+			PodSelector: metav1.LabelSelector{MatchLabels: w.selector},
+			PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress, networkingv1.PolicyTypeEgress},
+			Ingress:     []networkingv1.NetworkPolicyIngressRule{},
+			Egress:      []networkingv1.NetworkPolicyEgressRule{dnsEgressRule()},
+		},
+	}
+
+	for _, key := range sortedRuleKeys(ingress) {
+		rule := ingress[key]
+		policy.Spec.Ingress = append(policy.Spec.Ingress, networkingv1.NetworkPolicyIngressRule{
+			From:  []networkingv1.NetworkPolicyPeer{rule.peer},
+			Ports: rule.sortedPorts(),
+		})
+	}
+	for _, key := range sortedRuleKeys(egress) {
+		rule := egress[key]
+		policy.Spec.Egress = append(policy.Spec.Egress, networkingv1.NetworkPolicyEgressRule{
+			To:    []networkingv1.NetworkPolicyPeer{rule.peer},
+			Ports: rule.sortedPorts(),
+		})
+	}
+
+	return policy
+}
+
+// sortedPorts returns the rule's ports; a portless entry for a protocol covers all its ports
+func (r *generatedRule) sortedPorts() []networkingv1.NetworkPolicyPort {
+	allPorts := make(map[corev1.Protocol]bool)
+	for _, p := range r.ports {
+		if p.Port == nil {
+			allPorts[*p.Protocol] = true
+		}
+	}
+	keys := make([]string, 0, len(r.ports))
+	for key := range r.ports {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	ports := []networkingv1.NetworkPolicyPort{}
+	for _, key := range keys {
+		p := r.ports[key]
+		if p.Port != nil && allPorts[*p.Protocol] {
+			continue
+		}
+		ports = append(ports, p)
+	}
+	return ports
+}
+
+// dnsEgressRule allows DNS lookups against the cluster DNS service
+func dnsEgressRule() networkingv1.NetworkPolicyEgressRule {
+	udp, tcp := corev1.ProtocolUDP, corev1.ProtocolTCP
+	dnsPort := intstr.FromInt(53)
+	return networkingv1.NetworkPolicyEgressRule{
+		To: []networkingv1.NetworkPolicyPeer{{
+			NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{namespaceNameLabel: "kube-system"}},
+			PodSelector:       &metav1.LabelSelector{MatchLabels: map[string]string{"k8s-app": "kube-dns"}},
+		}},
+		Ports: []networkingv1.NetworkPolicyPort{
+			{Protocol: &udp, Port: &dnsPort},
+			{Protocol: &tcp, Port: &dnsPort},
+		},
+	}
+}
+
+// isDNSPod reports whether the pod is a cluster DNS server, already covered by the DNS rule
+func isDNSPod(pod *corev1.Pod) bool {
+	return pod != nil && pod.Namespace == "kube-system" && pod.Labels["k8s-app"] == "kube-dns"
+}
+
+func warnOnce(result *GeneratedPolicies, warned map[string]bool, warning string) {
+	if !warned[warning] {
+		warned[warning] = true
+		result.Warnings = append(result.Warnings, warning)
+	}
+}
+
+func sortedWorkloadKeys(workloads map[string]*workload) []string {
+	keys := make([]string, 0, len(workloads))
+	for key := range workloads {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func sortedRuleKeys(rules map[string]*generatedRule) []string {
+	keys := make([]string, 0, len(rules))
+	for key := range rules {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func sortedPodKeys(pods map[string]*corev1.Pod) []string {
+	keys := make([]string, 0, len(pods))
+	for key := range pods {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
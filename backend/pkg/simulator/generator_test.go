@@ -0,0 +1,129 @@
+package simulator
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/christine33-creator/k8-network-visualizer/pkg/flowcollector"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// fakeFlowCollector serves fixed flow metrics
+type fakeFlowCollector struct {
+	metrics []*flowcollector.FlowMetric
+}
+
+func (f *fakeFlowCollector) Start() error                             { return nil }
+func (f *fakeFlowCollector) Stop()                                    {}
+func (f *fakeFlowCollector) GetFlows(limit int) []*flowcollector.Flow { return nil }
+func (f *fakeFlowCollector) GetStats() map[string]interface{}         { return nil }
+
+func (f *fakeFlowCollector) GetFlowMetrics() map[string]*flowcollector.FlowMetric {
+	return f.GetFlowMetricsSince(time.Time{})
+}
+
+func (f *fakeFlowCollector) GetFlowMetricsSince(since time.Time) map[string]*flowcollector.FlowMetric {
+	metrics := make(map[string]*flowcollector.FlowMetric)
+	for _, metric := range f.metrics {
+		if metric.LastSeen.Before(since) {
+			continue
+		}
+		key := metric.SourceNamespace + "/" + metric.SourcePod + "->" + metric.DestNamespace + "/" + metric.DestPod
+		metrics[key] = metric
+	}
+	return metrics
+}
+
+// deploymentPod returns a pod owned by the deployment's ReplicaSet
+func deploymentPod(namespace, deployment, suffix, ip string, podLabels map[string]string) *corev1.Pod {
+	controller := true
+	podLabels["pod-template-hash"] = "abc123"
+	pod := testPod(namespace, deployment+"-abc123-"+suffix, ip, podLabels)
+	pod.OwnerReferences = []metav1.OwnerReference{{Kind: "ReplicaSet", Name: deployment + "-abc123", Controller: &controller}}
+	pod.Status.Phase = corev1.PodRunning
+	return pod
+}
+
+func TestGeneratePoliciesSkipsOverlappingSelectors(t *testing.T) {
+	web := deploymentPod("shop", "web", "x1", "10.0.0.1", map[string]string{"app": "web"})
+	admin := deploymentPod("shop", "web-admin", "y1", "10.0.0.2", map[string]string{"app": "web", "role": "admin"})
+	api := deploymentPod("shop", "api", "z1", "10.0.0.3", map[string]string{"app": "api"})
+
+	now := time.Now()
+	sim := NewSimulator(nil)
+	sim.SetFlowCollector(&fakeFlowCollector{metrics: []*flowcollector.FlowMetric{
+		{SourceNamespace: "shop", SourcePod: web.Name, DestNamespace: "shop", DestPod: api.Name, Protocol: "TCP", LastSeen: now,
+			Ports: []flowcollector.PortMetric{{Port: 8080, Protocol: "TCP", ConnectionCount: 1}}},
+		{SourceNamespace: "shop", SourcePod: admin.Name, DestNamespace: "shop", DestPod: api.Name, Protocol: "TCP", LastSeen: now,
+			Ports: []flowcollector.PortMetric{{Port: 9090, Protocol: "TCP", ConnectionCount: 1}}},
+	}})
+	sim.UpdateResources([]*corev1.Pod{web, admin, api}, nil, nil)
+
+	generated, err := sim.GeneratePolicies(GeneratePolicyOptions{Namespace: "shop"})
+	if err != nil {
+		t.Fatalf("GeneratePolicies: %v", err)
+	}
+
+	names := make(map[string]bool)
+	for _, policy := range generated.Policies {
+		names[policy.Name] = true
+	}
+	if names["web-observed"] {
+		t.Errorf("generated a policy for web, whose {app: web} selector also selects web-admin")
+	}
+	if !names["web-admin-observed"] || !names["api-observed"] {
+		t.Errorf("policies = %v, want web-admin-observed and api-observed", names)
+	}
+	if !containsWarning(generated.Warnings, "Workload shop/web shares its labels") {
+		t.Errorf("warnings = %q, want one about shop/web sharing its labels", generated.Warnings)
+	}
+	if !containsWarning(generated.Warnings, "shares its labels with pod shop/"+admin.Name) {
+		t.Errorf("warnings = %q, want one about the web peer not being allowed", generated.Warnings)
+	}
+
+	// api only admits the workload its ingress rules can single out
+	for _, policy := range generated.Policies {
+		if policy.Name != "api-observed" {
+			continue
+		}
+		if len(policy.Spec.Ingress) != 1 {
+			t.Fatalf("api ingress rules = %+v, want only web-admin's", policy.Spec.Ingress)
+		}
+		selector := policy.Spec.Ingress[0].From[0].PodSelector
+		if SelectorMatches(selector, web.Labels) || !SelectorMatches(selector, admin.Labels) {
+			t.Errorf("api ingress peer %v, want one selecting web-admin only", selector.MatchLabels)
+		}
+	}
+}
+
+func TestGeneratePoliciesIgnoresFlowsOutsideWindow(t *testing.T) {
+	web := deploymentPod("shop", "web", "x1", "10.0.0.1", map[string]string{"app": "web"})
+	api := deploymentPod("shop", "api", "z1", "10.0.0.3", map[string]string{"app": "api"})
+
+	sim := NewSimulator(nil)
+	sim.SetFlowCollector(&fakeFlowCollector{metrics: []*flowcollector.FlowMetric{
+		{SourceNamespace: "shop", SourcePod: web.Name, DestNamespace: "shop", DestPod: api.Name, Protocol: "TCP",
+			LastSeen: time.Now().Add(-2 * time.Hour),
+			Ports:    []flowcollector.PortMetric{{Port: 8080, Protocol: "TCP", ConnectionCount: 1}}},
+	}})
+	sim.UpdateResources([]*corev1.Pod{web, api}, nil, nil)
+
+	generated, err := sim.GeneratePolicies(GeneratePolicyOptions{Namespace: "shop", Window: time.Hour})
+	if err != nil {
+		t.Fatalf("GeneratePolicies: %v", err)
+	}
+	if generated.FlowsObserved != 0 {
+		t.Errorf("FlowsObserved = %d, want 0 for traffic older than the window", generated.FlowsObserved)
+	}
+}
+
+func containsWarning(warnings []string, substring string) bool {
+	for _, warning := range warnings {
+		if strings.Contains(warning, substring) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,277 @@
+package simulator
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	metavalidation "k8s.io/apimachinery/pkg/apis/meta/v1/validation"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	sigsyaml "sigs.k8s.io/yaml"
+)
+
+// Policy change actions accepted by the simulator
+const (
+	ActionAdd    = "add"
+	ActionModify = "modify"
+	ActionDelete = "delete"
+	ActionApply  = "apply" // add, or replace an existing policy with the same name
+)
+
+// ValidAction reports whether action is a supported policy change action
+func ValidAction(action string) bool {
+	switch action {
+	case ActionAdd, ActionModify, ActionDelete, ActionApply:
+		return true
+	}
+	return false
+}
+
+// ParsePolicies decodes one or more NetworkPolicy manifests from YAML or JSON.
+// Multi-document YAML streams and NetworkPolicyList/List objects are supported.
+func ParsePolicies(data []byte) ([]*networkingv1.NetworkPolicy, error) {
+	decoder := yaml.NewYAMLOrJSONDecoder(bytes.NewReader(data), 4096)
+	policies := []*networkingv1.NetworkPolicy{}
+
+	for doc := 0; ; doc++ {
+		var raw json.RawMessage
+		if err := decoder.Decode(&raw); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, fmt.Errorf("document %d: %w", doc, err)
+		}
+		if len(bytes.TrimSpace(raw)) == 0 || string(raw) == "null" {
+			continue
+		}
+
+		parsed, err := parsePolicyObject(raw)
+		if err != nil {
+			return nil, fmt.Errorf("document %d: %w", doc, err)
+		}
+		policies = append(policies, parsed...)
+	}
+
+	if len(policies) == 0 {
+		return nil, fmt.Errorf("no NetworkPolicy objects found")
+	}
+	return policies, nil
+}
+
+// RenderPolicies encodes policies as a multi-document YAML stream
+func RenderPolicies(policies []*networkingv1.NetworkPolicy) ([]byte, error) {
+	var buf bytes.Buffer
+	for i, policy := range policies {
+		data, err := sigsyaml.Marshal(policy)
+		if err != nil {
+			return nil, fmt.Errorf("policy %s: %w", PolicyKey(policy), err)
+		}
+		if i > 0 {
+			buf.WriteString("---\n")
+		}
+		buf.Write(data)
+	}
+	return buf.Bytes(), nil
+}
+
+func parsePolicyObject(raw json.RawMessage) ([]*networkingv1.NetworkPolicy, error) {
+	var typeMeta metav1.TypeMeta
+	if err := json.Unmarshal(raw, &typeMeta); err != nil {
+		return nil, err
+	}
+
+	switch typeMeta.Kind {
+	case "NetworkPolicy":
+		if typeMeta.APIVersion != "" && typeMeta.APIVersion != "networking.k8s.io/v1" {
+			return nil, fmt.Errorf("unsupported apiVersion %q for NetworkPolicy", typeMeta.APIVersion)
+		}
+		policy := &networkingv1.NetworkPolicy{}
+		if err := json.Unmarshal(raw, policy); err != nil {
+			return nil, err
+		}
+		return []*networkingv1.NetworkPolicy{policy}, nil
+	case "NetworkPolicyList", "List":
+		var list struct {
+			Items []json.RawMessage `json:"items"`
+		}
+		if err := json.Unmarshal(raw, &list); err != nil {
+			return nil, err
+		}
+		policies := []*networkingv1.NetworkPolicy{}
+		for i, item := range list.Items {
+			parsed, err := parsePolicyObject(item)
+			if err != nil {
+				return nil, fmt.Errorf("items[%d]: %w", i, err)
+			}
+			policies = append(policies, parsed...)
+		}
+		return policies, nil
+	case "":
+		return nil, fmt.Errorf("missing kind")
+	default:
+		return nil, fmt.Errorf("unsupported kind %q, expected NetworkPolicy", typeMeta.Kind)
+	}
+}
+
+// ValidatePolicy performs field-level validation of a NetworkPolicy, following
+// the rules the API server applies on create
+func ValidatePolicy(policy *networkingv1.NetworkPolicy) field.ErrorList {
+	allErrs := field.ErrorList{}
+	metaPath := field.NewPath("metadata")
+
+	if policy.Name == "" {
+		allErrs = append(allErrs, field.Required(metaPath.Child("name"), ""))
+	} else {
+		for _, msg := range validation.IsDNS1123Subdomain(policy.Name) {
+			allErrs = append(allErrs, field.Invalid(metaPath.Child("name"), policy.Name, msg))
+		}
+	}
+	if policy.Namespace == "" {
+		allErrs = append(allErrs, field.Required(metaPath.Child("namespace"), ""))
+	} else {
+		for _, msg := range validation.IsDNS1123Label(policy.Namespace) {
+			allErrs = append(allErrs, field.Invalid(metaPath.Child("namespace"), policy.Namespace, msg))
+		}
+	}
+
+	specPath := field.NewPath("spec")
+	selectorOpts := metavalidation.LabelSelectorValidationOptions{}
+	allErrs = append(allErrs, metavalidation.ValidateLabelSelector(&policy.Spec.PodSelector, selectorOpts, specPath.Child("podSelector"))...)
+
+	for i, rule := range policy.Spec.Ingress {
+		rulePath := specPath.Child("ingress").Index(i)
+		for j, port := range rule.Ports {
+			allErrs = append(allErrs, validatePolicyPort(port, rulePath.Child("ports").Index(j))...)
+		}
+		for j, peer := range rule.From {
+			allErrs = append(allErrs, validatePolicyPeer(peer, rulePath.Child("from").Index(j))...)
+		}
+	}
+	for i, rule := range policy.Spec.Egress {
+		rulePath := specPath.Child("egress").Index(i)
+		for j, port := range rule.Ports {
+			allErrs = append(allErrs, validatePolicyPort(port, rulePath.Child("ports").Index(j))...)
+		}
+		for j, peer := range rule.To {
+			allErrs = append(allErrs, validatePolicyPeer(peer, rulePath.Child("to").Index(j))...)
+		}
+	}
+
+	typesPath := specPath.Child("policyTypes")
+	if len(policy.Spec.PolicyTypes) > 2 {
+		allErrs = append(allErrs, field.Invalid(typesPath, policy.Spec.PolicyTypes, "may not specify more than two policyTypes"))
+	}
+	seenTypes := make(map[networkingv1.PolicyType]bool)
+	for i, policyType := range policy.Spec.PolicyTypes {
+		if policyType != networkingv1.PolicyTypeIngress && policyType != networkingv1.PolicyTypeEgress {
+			allErrs = append(allErrs, field.NotSupported(typesPath.Index(i), policyType, []string{string(networkingv1.PolicyTypeIngress), string(networkingv1.PolicyTypeEgress)}))
+		} else if seenTypes[policyType] {
+			allErrs = append(allErrs, field.Duplicate(typesPath.Index(i), policyType))
+		}
+		seenTypes[policyType] = true
+	}
+
+	return allErrs
+}
+
+func validatePolicyPort(port networkingv1.NetworkPolicyPort, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	if port.Protocol != nil {
+		switch *port.Protocol {
+		case corev1.ProtocolTCP, corev1.ProtocolUDP, corev1.ProtocolSCTP:
+		default:
+			allErrs = append(allErrs, field.NotSupported(fldPath.Child("protocol"), *port.Protocol, []string{string(corev1.ProtocolTCP), string(corev1.ProtocolUDP), string(corev1.ProtocolSCTP)}))
+		}
+	}
+
+	if port.Port == nil {
+		if port.EndPort != nil {
+			allErrs = append(allErrs, field.Required(fldPath.Child("port"), "must be specified when endPort is set"))
+		}
+		return allErrs
+	}
+
+	if port.Port.Type == intstr.Int {
+		for _, msg := range validation.IsValidPortNum(int(port.Port.IntVal)) {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("port"), port.Port.IntVal, msg))
+		}
+		if port.EndPort != nil {
+			if *port.EndPort < port.Port.IntVal {
+				allErrs = append(allErrs, field.Invalid(fldPath.Child("endPort"), *port.EndPort, "must be greater than or equal to port"))
+			}
+			for _, msg := range validation.IsValidPortNum(int(*port.EndPort)) {
+				allErrs = append(allErrs, field.Invalid(fldPath.Child("endPort"), *port.EndPort, msg))
+			}
+		}
+	} else {
+		if port.EndPort != nil {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("endPort"), *port.EndPort, "may not be specified when port is a named port"))
+		}
+		for _, msg := range validation.IsValidPortName(port.Port.StrVal) {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("port"), port.Port.StrVal, msg))
+		}
+	}
+
+	return allErrs
+}
+
+func validatePolicyPeer(peer networkingv1.NetworkPolicyPeer, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	selectorOpts := metavalidation.LabelSelectorValidationOptions{}
+
+	if peer.IPBlock == nil && peer.PodSelector == nil && peer.NamespaceSelector == nil {
+		return append(allErrs, field.Required(fldPath, "must specify a peer"))
+	}
+	if peer.IPBlock != nil && (peer.PodSelector != nil || peer.NamespaceSelector != nil) {
+		allErrs = append(allErrs, field.Forbidden(fldPath, "may not specify ipBlock together with podSelector or namespaceSelector"))
+	}
+
+	if peer.PodSelector != nil {
+		allErrs = append(allErrs, metavalidation.ValidateLabelSelector(peer.PodSelector, selectorOpts, fldPath.Child("podSelector"))...)
+	}
+	if peer.NamespaceSelector != nil {
+		allErrs = append(allErrs, metavalidation.ValidateLabelSelector(peer.NamespaceSelector, selectorOpts, fldPath.Child("namespaceSelector"))...)
+	}
+	if peer.IPBlock != nil {
+		allErrs = append(allErrs, validateIPBlock(peer.IPBlock, fldPath.Child("ipBlock"))...)
+	}
+
+	return allErrs
+}
+
+func validateIPBlock(block *networkingv1.IPBlock, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	if block.CIDR == "" {
+		return append(allErrs, field.Required(fldPath.Child("cidr"), ""))
+	}
+	_, cidr, err := net.ParseCIDR(block.CIDR)
+	if err != nil {
+		return append(allErrs, field.Invalid(fldPath.Child("cidr"), block.CIDR, "must be a valid CIDR"))
+	}
+	cidrSize, _ := cidr.Mask.Size()
+
+	for i, except := range block.Except {
+		exceptPath := fldPath.Child("except").Index(i)
+		exceptIP, exceptNet, err := net.ParseCIDR(except)
+		if err != nil {
+			allErrs = append(allErrs, field.Invalid(exceptPath, except, "must be a valid CIDR"))
+			continue
+		}
+		exceptSize, _ := exceptNet.Mask.Size()
+		if !cidr.Contains(exceptIP) || exceptSize <= cidrSize {
+			allErrs = append(allErrs, field.Invalid(exceptPath, except, "must be a strict subset of cidr"))
+		}
+	}
+
+	return allErrs
+}
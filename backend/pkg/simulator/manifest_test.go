@@ -0,0 +1,273 @@
+package simulator
+
+import (
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+const policyHeader = `apiVersion: networking.k8s.io/v1
+kind: NetworkPolicy
+metadata:
+  name: api
+  namespace: shop
+`
+
+func TestParsePolicies(t *testing.T) {
+	tests := []struct {
+		name     string
+		manifest string
+		want     []string // policy keys
+		err      string
+	}{
+		{
+			name:     "single policy",
+			manifest: policyHeader + "spec:\n  podSelector: {}\n",
+			want:     []string{"shop/api"},
+		},
+		{
+			name: "multi-document YAML with empty documents",
+			manifest: "---\n" + policyHeader + "spec:\n  podSelector: {}\n---\n---\n" +
+				strings.Replace(policyHeader, "name: api", "name: web", 1) + "spec:\n  podSelector: {}\n",
+			want: []string{"shop/api", "shop/web"},
+		},
+		{
+			name: "NetworkPolicyList",
+			manifest: `apiVersion: networking.k8s.io/v1
+kind: NetworkPolicyList
+items:
+- kind: NetworkPolicy
+  metadata: {name: api, namespace: shop}
+- kind: NetworkPolicy
+  metadata: {name: db, namespace: data}
+`,
+			want: []string{"shop/api", "data/db"},
+		},
+		{
+			name:     "JSON",
+			manifest: `{"apiVersion": "networking.k8s.io/v1", "kind": "NetworkPolicy", "metadata": {"name": "api", "namespace": "shop"}}`,
+			want:     []string{"shop/api"},
+		},
+		{
+			name:     "other kind in the second document",
+			manifest: policyHeader + "---\napiVersion: v1\nkind: Service\nmetadata:\n  name: api\n",
+			err:      `document 1: unsupported kind "Service"`,
+		},
+		{
+			name:     "unsupported apiVersion",
+			manifest: strings.Replace(policyHeader, "networking.k8s.io/v1", "extensions/v1beta1", 1),
+			err:      `document 0: unsupported apiVersion "extensions/v1beta1"`,
+		},
+		{
+			name:     "missing kind in a list item",
+			manifest: "kind: List\nitems:\n- metadata: {name: api}\n",
+			err:      "document 0: items[0]: missing kind",
+		},
+		{
+			name:     "wrong field type",
+			manifest: policyHeader + "spec:\n  podSelector: []\n",
+			err:      "document 0:",
+		},
+		{
+			name:     "no documents",
+			manifest: "---\n# nothing here\n",
+			err:      "no NetworkPolicy objects found",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policies, err := ParsePolicies([]byte(tt.manifest))
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("error = %v, want one containing %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			var got []string
+			for _, policy := range policies {
+				got = append(got, PolicyKey(policy))
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("policies = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidatePolicy(t *testing.T) {
+	tests := []struct {
+		name string
+		spec string
+		want []string // "type field" of each error
+	}{
+		{
+			name: "valid policy",
+			spec: `
+  podSelector: {matchLabels: {app: api}}
+  policyTypes: [Ingress, Egress]
+  ingress:
+  - from:
+    - namespaceSelector: {matchLabels: {team: shop}}
+      podSelector: {matchLabels: {app: web}}
+    ports:
+    - {protocol: TCP, port: 8080}
+    - {protocol: TCP, port: 9000, endPort: 9100}
+    - {protocol: UDP, port: metrics}
+  egress:
+  - to:
+    - ipBlock: {cidr: 10.0.0.0/8, except: [10.96.0.0/12]}
+`,
+		},
+		{
+			name: "port out of range",
+			spec: `
+  podSelector: {}
+  ingress:
+  - ports:
+    - {port: 0}
+    - {port: 70000}
+`,
+			want: []string{
+				"Invalid value spec.ingress[0].ports[0].port",
+				"Invalid value spec.ingress[0].ports[1].port",
+			},
+		},
+		{
+			name: "unsupported protocol and bad port name",
+			spec: `
+  podSelector: {}
+  egress:
+  - ports:
+    - {protocol: ICMP, port: Not_A_Name}
+`,
+			want: []string{
+				"Unsupported value spec.egress[0].ports[0].protocol",
+				"Invalid value spec.egress[0].ports[0].port",
+			},
+		},
+		{
+			name: "endPort below port",
+			spec: `
+  podSelector: {}
+  ingress:
+  - ports:
+    - {port: 9100, endPort: 9000}
+`,
+			want: []string{"Invalid value spec.ingress[0].ports[0].endPort"},
+		},
+		{
+			name: "endPort without a numeric port",
+			spec: `
+  podSelector: {}
+  ingress:
+  - ports:
+    - {endPort: 9000}
+    - {port: http, endPort: 9000}
+`,
+			want: []string{
+				"Required value spec.ingress[0].ports[0].port",
+				"Invalid value spec.ingress[0].ports[1].endPort",
+			},
+		},
+		{
+			name: "malformed CIDRs",
+			spec: `
+  podSelector: {}
+  egress:
+  - to:
+    - ipBlock: {cidr: 10.0.0.0}
+    - ipBlock: {cidr: 10.0.0.0/33}
+    - ipBlock: {cidr: ""}
+    - ipBlock: {cidr: 10.0.0.0/8, except: [10.1.0.0]}
+`,
+			want: []string{
+				"Invalid value spec.egress[0].to[0].ipBlock.cidr",
+				"Invalid value spec.egress[0].to[1].ipBlock.cidr",
+				"Required value spec.egress[0].to[2].ipBlock.cidr",
+				"Invalid value spec.egress[0].to[3].ipBlock.except[0]",
+			},
+		},
+		{
+			name: "except outside or equal to the CIDR",
+			spec: `
+  podSelector: {}
+  egress:
+  - to:
+    - ipBlock: {cidr: 10.0.0.0/16, except: [192.168.0.0/24, 10.0.0.0/16, 10.0.0.0/8, 10.0.1.0/24]}
+`,
+			want: []string{
+				"Invalid value spec.egress[0].to[0].ipBlock.except[0]",
+				"Invalid value spec.egress[0].to[0].ipBlock.except[1]",
+				"Invalid value spec.egress[0].to[0].ipBlock.except[2]",
+			},
+		},
+		{
+			name: "peers",
+			spec: `
+  podSelector: {}
+  ingress:
+  - from:
+    - {}
+    - ipBlock: {cidr: 10.0.0.0/8}
+      podSelector: {}
+    - podSelector: {matchLabels: {"bad key!": web}}
+`,
+			want: []string{
+				"Required value spec.ingress[0].from[0]",
+				"Forbidden spec.ingress[0].from[1]",
+				"Invalid value spec.ingress[0].from[2].podSelector.matchLabels",
+			},
+		},
+		{
+			name: "policyTypes",
+			spec: `
+  podSelector: {}
+  policyTypes: [Ingress, Ingress, Sideways]
+`,
+			want: []string{
+				"Invalid value spec.policyTypes",
+				"Duplicate value spec.policyTypes[1]",
+				"Unsupported value spec.policyTypes[2]",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policies, err := ParsePolicies([]byte(policyHeader + "spec:" + tt.spec))
+			if err != nil {
+				t.Fatalf("parse: %v", err)
+			}
+			got := errorFields(ValidatePolicy(policies[0]))
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("errors:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
+
+func TestValidatePolicyMetadata(t *testing.T) {
+	manifest := "kind: NetworkPolicy\nmetadata:\n  name: Not_Valid\nspec:\n  podSelector: {}\n"
+	policies, err := ParsePolicies([]byte(manifest))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	got := errorFields(ValidatePolicy(policies[0]))
+	want := []string{"Invalid value metadata.name", "Required value metadata.namespace"}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("errors = %v, want %v", got, want)
+	}
+}
+
+func errorFields(errs field.ErrorList) []string {
+	var fields []string
+	for _, err := range errs {
+		fields = append(fields, err.Type.String()+" "+err.Field)
+	}
+	return fields
+}
@@ -0,0 +1,122 @@
+package simulator
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/christine33-creator/k8-network-visualizer/pkg/flowcollector"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// DefaultFlowWindow is how far back observed flows are replayed when no window is given
+const DefaultFlowWindow = 15 * time.Minute
+
+// Sources of the flows a policy simulation is evaluated against
+const (
+	FlowSourceObserved     = "observed"      // replayed from the flow collector
+	FlowSourceServiceModel = "service_model" // every pod to every service backend
+)
+
+// SetFlowCollector enables replaying observed traffic in policy simulations
+func (s *Simulator) SetFlowCollector(collector flowcollector.FlowCollectorInterface) {
+	s.flowCollector = collector
+}
+
+// analyzeObservedFlows replays flows seen by the flow collector within the window
+// that touch the given pods, evaluated against the current policies. The second
+// return value is false when the collector has no traffic in the window at all.
+func (s *Simulator) analyzeObservedFlows(pods []*corev1.Pod, evaluator *PolicyEvaluator, window time.Duration) (map[string]*Flow, bool) {
+	flows := make(map[string]*Flow)
+	if s.flowCollector == nil {
+		return flows, false
+	}
+
+	affected := make(map[string]bool, len(pods))
+	for _, pod := range pods {
+		affected[fmt.Sprintf("%s/%s", pod.Namespace, pod.Name)] = true
+	}
+
+	cutoff := time.Now().Add(-window)
+	observed := false
+	for _, metric := range s.flowCollector.GetFlowMetricsSince(cutoff) {
+		observed = true
+
+		sourceKey, sourcePod, sourceIP, ok := s.resolveObservedPeer(metric.SourceNamespace, metric.SourcePod)
+		if !ok {
+			continue
+		}
+		destKey, destPod, destIP, ok := s.resolveObservedPeer(metric.DestNamespace, metric.DestPod)
+		if !ok {
+			continue
+		}
+		// Only connections touching the affected pods can change
+		if !affected[sourceKey] && !affected[destKey] {
+			continue
+		}
+
+		// Collectors without a per-port breakdown are replayed as a single
+		// portless flow, which only matches rules that allow all ports
+		ports := metric.Ports
+		if len(ports) == 0 {
+			ports = []flowcollector.PortMetric{{
+				Protocol:        metric.Protocol,
+				BytesPerSec:     metric.BytesPerSec,
+				PacketsPerSec:   metric.PacketsPerSec,
+				ConnectionCount: metric.ConnectionCount,
+			}}
+		}
+
+		for _, port := range ports {
+			flow := &Flow{
+				Source:          sourceKey,
+				Destination:     destKey,
+				Protocol:        observedProtocol(port.Protocol),
+				Port:            int32(port.Port),
+				SourcePod:       sourcePod,
+				SourceIP:        sourceIP,
+				DestinationPod:  destPod,
+				DestinationIP:   destIP,
+				BytesPerSec:     port.BytesPerSec,
+				ConnectionCount: port.ConnectionCount,
+			}
+			flow.Decision = evaluator.Evaluate(flow.Connection())
+			flow.State = flowState(flow.Decision.Allowed)
+
+			if existing, ok := flows[flow.Key()]; ok {
+				existing.BytesPerSec += flow.BytesPerSec
+				existing.ConnectionCount += flow.ConnectionCount
+				continue
+			}
+			flows[flow.Key()] = flow
+		}
+	}
+
+	return flows, observed
+}
+
+// resolveObservedPeer maps a collector endpoint to a known pod, or to a bare IP
+// for endpoints outside the pod network. Pods that no longer exist are skipped.
+func (s *Simulator) resolveObservedPeer(namespace, name string) (string, *corev1.Pod, string, bool) {
+	if pod, ok := s.pods[fmt.Sprintf("%s/%s", namespace, name)]; ok {
+		return fmt.Sprintf("%s/%s", pod.Namespace, pod.Name), pod, "", true
+	}
+	if net.ParseIP(name) != nil {
+		return name, nil, name, true
+	}
+	return "", nil, "", false
+}
+
+func observedProtocol(protocol string) string {
+	switch strings.ToUpper(protocol) {
+	case "", "TCP":
+		return string(corev1.ProtocolTCP)
+	case "UDP":
+		return string(corev1.ProtocolUDP)
+	case "SCTP":
+		return string(corev1.ProtocolSCTP)
+	default:
+		return strings.ToUpper(protocol)
+	}
+}
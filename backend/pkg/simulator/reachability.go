@@ -0,0 +1,354 @@
+package simulator
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/christine33-creator/k8-network-visualizer/pkg/graph"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// Reachability verdicts for a matrix cell
+const (
+	ReachabilityAllowed = "allowed" // every pod pair is allowed by policy
+	ReachabilityDenied  = "denied"  // every pod pair is denied by policy
+	ReachabilityPartial = "partial" // some pod pairs are allowed and some denied
+)
+
+// ReachabilityOptions filters the reachability computation
+type ReachabilityOptions struct {
+	SourceNamespace      string
+	DestinationNamespace string
+	Port                 int32  // 0 for every port
+	Protocol             string // empty for every protocol
+	Verdict              string // only return cells with this verdict
+	ObservedOnly         bool   // only return cells seen in flows
+	Window               time.Duration
+}
+
+// ReachabilityCell aggregates the policy decisions for every pod pair between a
+// source and destination group on one port. Port 0 stands for destinations
+// without declared ports and only counts rules that allow all ports.
+type ReachabilityCell struct {
+	Source       string  `json:"source"`
+	Destination  string  `json:"destination"`
+	Protocol     string  `json:"protocol"`
+	Port         int32   `json:"port"`
+	Verdict      string  `json:"verdict"`
+	AllowedPairs int     `json:"allowed_pairs"`
+	DeniedPairs  int     `json:"denied_pairs"`
+	Observed     bool    `json:"observed"`
+	BytesPerSec  float64 `json:"bytes_per_sec,omitempty"`
+}
+
+// ReachabilityMatrix holds namespace×namespace and workload×workload reachability
+type ReachabilityMatrix struct {
+	Namespaces     []string           `json:"namespaces"`
+	Workloads      []string           `json:"workloads"`
+	NamespaceCells []ReachabilityCell `json:"namespace_matrix"`
+	WorkloadCells  []ReachabilityCell `json:"workload_matrix"`
+	FlowSource     string             `json:"flow_source,omitempty"`
+	Window         string             `json:"window"`
+	GeneratedAt    time.Time          `json:"generated_at"`
+}
+
+// reachabilityPort is a destination port a pod pair is evaluated on
+type reachabilityPort struct {
+	protocol corev1.Protocol
+	port     int32
+}
+
+// observedTraffic indexes flows seen by the flow collector by pod pair and port
+type observedTraffic struct {
+	bytes map[string]float64
+	ports map[string][]reachabilityPort // destination pod key to observed ports
+}
+
+func observedTrafficKey(source, destination string, port reachabilityPort) string {
+	return fmt.Sprintf("%s->%s:%d/%s", source, destination, port.port, port.protocol)
+}
+
+// ComputeReachability evaluates the current policies for every pair of pods in the
+// graph, on each port the destination declares or was observed receiving traffic on,
+// and aggregates the results by namespace and by workload. It does not send traffic.
+func (s *Simulator) ComputeReachability(opts ReachabilityOptions) (*ReachabilityMatrix, error) {
+	protocol := corev1.Protocol(strings.ToUpper(opts.Protocol))
+	switch protocol {
+	case "", corev1.ProtocolTCP, corev1.ProtocolUDP, corev1.ProtocolSCTP:
+	default:
+		return nil, fmt.Errorf("unsupported protocol %q", opts.Protocol)
+	}
+	switch opts.Verdict {
+	case "", ReachabilityAllowed, ReachabilityDenied, ReachabilityPartial:
+	default:
+		return nil, fmt.Errorf("unknown verdict %q", opts.Verdict)
+	}
+	if opts.Port < 0 || opts.Port > 65535 {
+		return nil, fmt.Errorf("invalid port %d", opts.Port)
+	}
+	if opts.Window <= 0 {
+		opts.Window = DefaultFlowWindow
+	}
+
+	pods := s.graphPods()
+	evaluator := s.Evaluator()
+	traffic, observed := s.observedTraffic(opts.Window)
+
+	matrix := &ReachabilityMatrix{
+		Namespaces:     []string{},
+		Workloads:      []string{},
+		NamespaceCells: []ReachabilityCell{},
+		WorkloadCells:  []ReachabilityCell{},
+		Window:         opts.Window.String(),
+		GeneratedAt:    time.Now(),
+	}
+	if observed {
+		matrix.FlowSource = FlowSourceObserved
+	}
+
+	namespaces := make(map[string]bool)
+	workloads := make(map[string]bool)
+	podWorkloads := make(map[*corev1.Pod]string, len(pods))
+	for _, pod := range pods {
+		podWorkloads[pod] = fmt.Sprintf("%s/%s", pod.Namespace, WorkloadName(pod))
+		namespaces[pod.Namespace] = true
+		workloads[podWorkloads[pod]] = true
+	}
+
+	namespaceCells := make(map[string]*ReachabilityCell)
+	workloadCells := make(map[string]*ReachabilityCell)
+	for _, destination := range pods {
+		if opts.DestinationNamespace != "" && destination.Namespace != opts.DestinationNamespace {
+			continue
+		}
+		destKey := fmt.Sprintf("%s/%s", destination.Namespace, destination.Name)
+		ports := destinationPorts(destination, traffic.ports[destKey], protocol, opts.Port)
+
+		for _, source := range pods {
+			if source == destination {
+				continue
+			}
+			if opts.SourceNamespace != "" && source.Namespace != opts.SourceNamespace {
+				continue
+			}
+			sourceKey := fmt.Sprintf("%s/%s", source.Namespace, source.Name)
+
+			for _, port := range ports {
+				allowed := evaluator.Allowed(Connection{
+					Source:      source,
+					Destination: destination,
+					Port:        port.port,
+					Protocol:    port.protocol,
+				})
+				bytes, seen := traffic.bytes[observedTrafficKey(sourceKey, destKey, port)]
+				addReachability(namespaceCells, source.Namespace, destination.Namespace, port, allowed, seen, bytes)
+				addReachability(workloadCells, podWorkloads[source], podWorkloads[destination], port, allowed, seen, bytes)
+			}
+		}
+	}
+
+	matrix.Namespaces = sortedSet(namespaces)
+	matrix.Workloads = sortedSet(workloads)
+	matrix.NamespaceCells = filterReachabilityCells(namespaceCells, opts)
+	matrix.WorkloadCells = filterReachabilityCells(workloadCells, opts)
+	return matrix, nil
+}
+
+// graphPods returns the active pods in the graph engine, resolved to their
+// collected objects for labels and ports
+func (s *Simulator) graphPods() []*corev1.Pod {
+	var pods []*corev1.Pod
+	if s.graphEngine == nil {
+		for _, key := range sortedPodKeys(s.pods) {
+			if isActivePod(s.pods[key]) {
+				pods = append(pods, s.pods[key])
+			}
+		}
+		return pods
+	}
+
+	for _, node := range s.graphEngine.GetTopology().Nodes {
+		if node.Type != graph.NodeTypePod {
+			continue
+		}
+		pod, ok := s.pods[fmt.Sprintf("%s/%s", node.Namespace, node.Name)]
+		if !ok || !isActivePod(pod) {
+			continue
+		}
+		pods = append(pods, pod)
+	}
+	sort.Slice(pods, func(i, j int) bool {
+		if pods[i].Namespace != pods[j].Namespace {
+			return pods[i].Namespace < pods[j].Namespace
+		}
+		return pods[i].Name < pods[j].Name
+	})
+	return pods
+}
+
+// observedTraffic collects pod-to-pod traffic seen within the window. The second
+// return value is false when no flow collector is running or it saw no traffic.
+func (s *Simulator) observedTraffic(window time.Duration) (observedTraffic, bool) {
+	traffic := observedTraffic{
+		bytes: make(map[string]float64),
+		ports: make(map[string][]reachabilityPort),
+	}
+	if s.flowCollector == nil {
+		return traffic, false
+	}
+
+	cutoff := time.Now().Add(-window)
+	observed := false
+	for _, metric := range s.flowCollector.GetFlowMetricsSince(cutoff) {
+		observed = true
+
+		sourceKey, sourcePod, _, ok := s.resolveObservedPeer(metric.SourceNamespace, metric.SourcePod)
+		if !ok || sourcePod == nil {
+			continue
+		}
+		destKey, destPod, _, ok := s.resolveObservedPeer(metric.DestNamespace, metric.DestPod)
+		if !ok || destPod == nil {
+			continue
+		}
+
+		if len(metric.Ports) == 0 {
+			port := reachabilityPort{protocol: corev1.Protocol(observedProtocol(metric.Protocol))}
+			traffic.add(sourceKey, destKey, port, metric.BytesPerSec)
+			continue
+		}
+		for _, p := range metric.Ports {
+			port := reachabilityPort{protocol: corev1.Protocol(observedProtocol(p.Protocol)), port: int32(p.Port)}
+			traffic.add(sourceKey, destKey, port, p.BytesPerSec)
+		}
+	}
+	return traffic, observed
+}
+
+func (t observedTraffic) add(source, destination string, port reachabilityPort, bytesPerSec float64) {
+	key := observedTrafficKey(source, destination, port)
+	if _, ok := t.bytes[key]; !ok {
+		t.ports[destination] = appendPort(t.ports[destination], port)
+	}
+	t.bytes[key] += bytesPerSec
+}
+
+// destinationPorts lists the ports a destination pod is evaluated on: its declared
+// container ports plus any it was observed receiving traffic on, or port 0 when
+// there are neither. A port filter replaces the list.
+func destinationPorts(pod *corev1.Pod, observed []reachabilityPort, protocol corev1.Protocol, port int32) []reachabilityPort {
+	if port != 0 {
+		if protocol == "" {
+			protocol = corev1.ProtocolTCP
+		}
+		return []reachabilityPort{{protocol: protocol, port: port}}
+	}
+
+	var ports []reachabilityPort
+	for _, container := range pod.Spec.Containers {
+		for _, containerPort := range container.Ports {
+			p := reachabilityPort{protocol: containerPort.Protocol, port: containerPort.ContainerPort}
+			if p.protocol == "" {
+				p.protocol = corev1.ProtocolTCP
+			}
+			ports = appendPort(ports, p)
+		}
+	}
+	for _, p := range observed {
+		ports = appendPort(ports, p)
+	}
+	if len(ports) == 0 {
+		ports = append(ports, reachabilityPort{protocol: corev1.ProtocolTCP})
+	}
+
+	if protocol == "" {
+		return ports
+	}
+	var filtered []reachabilityPort
+	for _, p := range ports {
+		if p.protocol == protocol {
+			filtered = append(filtered, p)
+		}
+	}
+	return filtered
+}
+
+func appendPort(ports []reachabilityPort, port reachabilityPort) []reachabilityPort {
+	for _, p := range ports {
+		if p == port {
+			return ports
+		}
+	}
+	return append(ports, port)
+}
+
+func addReachability(cells map[string]*ReachabilityCell, source, destination string, port reachabilityPort, allowed, observed bool, bytesPerSec float64) {
+	key := observedTrafficKey(source, destination, port)
+	cell, ok := cells[key]
+	if !ok {
+		cell = &ReachabilityCell{
+			Source:      source,
+			Destination: destination,
+			Protocol:    string(port.protocol),
+			Port:        port.port,
+		}
+		cells[key] = cell
+	}
+	if allowed {
+		cell.AllowedPairs++
+	} else {
+		cell.DeniedPairs++
+	}
+	if observed {
+		cell.Observed = true
+		cell.BytesPerSec += bytesPerSec
+	}
+}
+
+// filterReachabilityCells sets each cell's verdict and returns the cells matching
+// the options, sorted by source, destination and port
+func filterReachabilityCells(cells map[string]*ReachabilityCell, opts ReachabilityOptions) []ReachabilityCell {
+	result := []ReachabilityCell{}
+	for _, cell := range cells {
+		switch {
+		case cell.DeniedPairs == 0:
+			cell.Verdict = ReachabilityAllowed
+		case cell.AllowedPairs == 0:
+			cell.Verdict = ReachabilityDenied
+		default:
+			cell.Verdict = ReachabilityPartial
+		}
+		if opts.Verdict != "" && cell.Verdict != opts.Verdict {
+			continue
+		}
+		if opts.ObservedOnly && !cell.Observed {
+			continue
+		}
+		result = append(result, *cell)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		a, b := result[i], result[j]
+		if a.Source != b.Source {
+			return a.Source < b.Source
+		}
+		if a.Destination != b.Destination {
+			return a.Destination < b.Destination
+		}
+		if a.Protocol != b.Protocol {
+			return a.Protocol < b.Protocol
+		}
+		return a.Port < b.Port
+	})
+	return result
+}
+
+func sortedSet(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -1,14 +1,19 @@
 package simulator
 
 import (
+	"errors"
 	"fmt"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/christine33-creator/k8-network-visualizer/pkg/ai"
+	"github.com/christine33-creator/k8-network-visualizer/pkg/flowcollector"
 	"github.com/christine33-creator/k8-network-visualizer/pkg/graph"
 	corev1 "k8s.io/api/core/v1"
 	networkingv1 "k8s.io/api/networking/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
 )
 
 // SimulationType represents the type of simulation
@@ -21,15 +26,23 @@ const (
 	SimulationTypeServiceMesh   SimulationType = "service_mesh"
 )
 
+// Errors returned when a policy change does not fit the current cluster state
+var (
+	ErrPolicyExists   = errors.New("policy already exists")
+	ErrPolicyNotFound = errors.New("policy not found")
+)
+
 // SimulationResult represents the outcome of a simulation
 type SimulationResult struct {
-	Type            SimulationType         `json:"type"`
-	Impact          ImpactAnalysis         `json:"impact"`
-	AffectedFlows   []NetworkFlow          `json:"affected_flows"`
-	Recommendations []string               `json:"recommendations"`
-	RiskLevel       string                 `json:"risk_level"`
-	Summary         string                 `json:"summary"`
-	AIAnalysis      string                 `json:"ai_analysis,omitempty"`
+	Type            SimulationType `json:"type"`
+	Impact          ImpactAnalysis `json:"impact"`
+	AffectedFlows   []NetworkFlow  `json:"affected_flows"`
+	Recommendations []string       `json:"recommendations"`
+	RiskLevel       string         `json:"risk_level"`
+	Summary         string         `json:"summary"`
+	AIAnalysis      string         `json:"ai_analysis,omitempty"`
+	FlowVerdicts    []FlowVerdict  `json:"flow_verdicts,omitempty"`
+	FlowSource      string         `json:"flow_source,omitempty"`
 }
 
 // ImpactAnalysis describes the impact of a change
@@ -40,26 +53,42 @@ type ImpactAnalysis struct {
 	NewConnections        int      `json:"new_connections"`
 	AffectedNamespaces    []string `json:"affected_namespaces"`
 	CriticalPathsImpacted []string `json:"critical_paths_impacted"`
+	DroppedBytesPerSec    float64  `json:"dropped_bytes_per_sec,omitempty"`
 }
 
 // NetworkFlow represents a network connection flow
 type NetworkFlow struct {
-	Source      string `json:"source"`
-	Destination string `json:"destination"`
-	Protocol    string `json:"protocol"`
-	Port        int32  `json:"port"`
-	CurrentState string `json:"current_state"`
-	NewState    string `json:"new_state"`
-	Impact      string `json:"impact"`
+	Source          string  `json:"source"`
+	Destination     string  `json:"destination"`
+	Protocol        string  `json:"protocol"`
+	Port            int32   `json:"port"`
+	CurrentState    string  `json:"current_state"`
+	NewState        string  `json:"new_state"`
+	Impact          string  `json:"impact"`
+	BytesPerSec     float64 `json:"bytes_per_sec,omitempty"`
+	ConnectionCount int     `json:"connection_count,omitempty"`
+}
+
+// FlowVerdict compares the policy decision for a flow before and after a change
+type FlowVerdict struct {
+	Source      string         `json:"source"`
+	Destination string         `json:"destination"`
+	Protocol    string         `json:"protocol"`
+	Port        int32          `json:"port"`
+	Before      PolicyDecision `json:"before"`
+	After       PolicyDecision `json:"after"`
+	Changed     bool           `json:"changed"`
 }
 
 // Simulator performs what-if analysis
 type Simulator struct {
-	graphEngine *graph.Engine
-	pods        map[string]*corev1.Pod
-	services    map[string]*corev1.Service
-	policies    map[string]*networkingv1.NetworkPolicy
-	aiClient    *ai.Client
+	graphEngine   *graph.Engine
+	pods          map[string]*corev1.Pod
+	services      map[string]*corev1.Service
+	policies      map[string]*networkingv1.NetworkPolicy
+	namespaces    map[string]*corev1.Namespace
+	aiClient      *ai.Client
+	flowCollector flowcollector.FlowCollectorInterface
 }
 
 // NewSimulator creates a new simulator instance
@@ -69,6 +98,7 @@ func NewSimulator(engine *graph.Engine) *Simulator {
 		pods:        make(map[string]*corev1.Pod),
 		services:    make(map[string]*corev1.Service),
 		policies:    make(map[string]*networkingv1.NetworkPolicy),
+		namespaces:  make(map[string]*corev1.Namespace),
 		aiClient:    nil, // Will be set when API key is provided
 	}
 }
@@ -102,8 +132,55 @@ func (s *Simulator) UpdateResources(pods []*corev1.Pod, services []*corev1.Servi
 	}
 }
 
-// SimulateNetworkPolicy simulates the impact of adding/modifying a NetworkPolicy
+// UpdateNamespaces updates the namespaces used for namespaceSelector matching
+func (s *Simulator) UpdateNamespaces(namespaces []*corev1.Namespace) {
+	s.namespaces = make(map[string]*corev1.Namespace)
+	for _, ns := range namespaces {
+		s.namespaces[ns.Name] = ns
+	}
+}
+
+// Snapshot returns a simulator over the given cluster resources that shares
+// this one's graph, AI client and flow collector. Each request simulates against
+// its own snapshot, so concurrent requests never write to a shared simulator.
+func (s *Simulator) Snapshot(pods []*corev1.Pod, services []*corev1.Service, policies []*networkingv1.NetworkPolicy, namespaces []*corev1.Namespace) *Simulator {
+	snapshot := NewSimulator(s.graphEngine)
+	snapshot.aiClient = s.aiClient
+	snapshot.flowCollector = s.flowCollector
+	snapshot.UpdateResources(pods, services, policies)
+	snapshot.UpdateNamespaces(namespaces)
+	return snapshot
+}
+
+// Evaluator returns a policy evaluator over the current cluster policies
+func (s *Simulator) Evaluator() *PolicyEvaluator {
+	return s.newEvaluator(s.currentPolicies())
+}
+
+// SimulateNetworkPolicy simulates the impact of adding, modifying or deleting a NetworkPolicy
 func (s *Simulator) SimulateNetworkPolicy(policy *networkingv1.NetworkPolicy, action string) (*SimulationResult, error) {
+	return s.SimulateNetworkPolicies([]*networkingv1.NetworkPolicy{policy}, action)
+}
+
+// SimulateNetworkPolicies simulates the combined impact of changing several NetworkPolicies at once.
+// Modify and delete apply to the existing policies with the same namespace and name.
+func (s *Simulator) SimulateNetworkPolicies(policies []*networkingv1.NetworkPolicy, action string) (*SimulationResult, error) {
+	return s.SimulateNetworkPoliciesInWindow(policies, action, DefaultFlowWindow)
+}
+
+// SimulateNetworkPoliciesInWindow simulates a policy change against the traffic observed
+// by the flow collector within the window. Without a flow collector, or when it saw no
+// traffic in the window, every pod is assumed to talk to every service.
+func (s *Simulator) SimulateNetworkPoliciesInWindow(policies []*networkingv1.NetworkPolicy, action string, window time.Duration) (*SimulationResult, error) {
+	if len(policies) == 0 {
+		return nil, fmt.Errorf("no policies to simulate")
+	}
+
+	proposed, changed, err := s.applyPolicyChange(policies, action)
+	if err != nil {
+		return nil, err
+	}
+
 	result := &SimulationResult{
 		Type: SimulationTypeNetworkPolicy,
 		Impact: ImpactAnalysis{
@@ -111,43 +188,73 @@ func (s *Simulator) SimulateNetworkPolicy(policy *networkingv1.NetworkPolicy, ac
 		},
 		AffectedFlows:   []NetworkFlow{},
 		Recommendations: []string{},
+		FlowVerdicts:    []FlowVerdict{},
 	}
 
-	// Find pods affected by this policy
-	affectedPods := s.findPodsMatchingSelector(policy.Namespace, &policy.Spec.PodSelector)
+	// Find pods selected by the changed policies, before or after the change
+	affectedPods := s.findPodsSelectedByPolicies(changed)
 	result.Impact.TotalPodsAffected = len(affectedPods)
 
-	// Analyze current connectivity
-	currentFlows := s.analyzeCurrentFlows(affectedPods)
+	// Evaluate candidate flows against the current and the proposed policy sets
+	currentEval := s.newEvaluator(s.currentPolicies())
+	proposedEval := s.newEvaluator(proposed)
 
-	// Simulate new connectivity with policy
-	newFlows := s.simulateFlowsWithPolicy(affectedPods, policy)
+	currentFlows, observed := s.analyzeObservedFlows(affectedPods, currentEval, window)
+	result.FlowSource = FlowSourceObserved
+	if !observed {
+		currentFlows = s.analyzeCurrentFlows(affectedPods, currentEval)
+		result.FlowSource = FlowSourceServiceModel
+	}
+	newFlows := s.simulateFlowsWithPolicy(currentFlows, proposedEval)
 
 	// Compare flows and identify changes
-	for key, currentFlow := range currentFlows {
-		newFlow, exists := newFlows[key]
-		if !exists || newFlow.State != currentFlow.State {
-			flow := NetworkFlow{
-				Source:       currentFlow.Source,
-				Destination:  currentFlow.Destination,
-				Protocol:     currentFlow.Protocol,
-				Port:         currentFlow.Port,
-				CurrentState: currentFlow.State,
-				NewState:     "blocked",
-				Impact:       "Connection will be blocked",
-			}
-			if exists {
-				flow.NewState = newFlow.State
-				if newFlow.State == "allowed" {
-					flow.Impact = "Connection remains allowed"
-				}
-			}
-			result.AffectedFlows = append(result.AffectedFlows, flow)
-			if flow.NewState == "blocked" {
-				result.Impact.BlockedConnections++
-			}
+	affectedNamespaces := make(map[string]bool)
+	for _, key := range sortedFlowKeys(currentFlows) {
+		currentFlow := currentFlows[key]
+		newFlow := newFlows[key]
+		result.FlowVerdicts = append(result.FlowVerdicts, FlowVerdict{
+			Source:      currentFlow.Source,
+			Destination: currentFlow.Destination,
+			Protocol:    currentFlow.Protocol,
+			Port:        currentFlow.Port,
+			Before:      currentFlow.Decision,
+			After:       newFlow.Decision,
+			Changed:     newFlow.State != currentFlow.State,
+		})
+		if newFlow.State == currentFlow.State {
+			continue
+		}
+
+		flow := NetworkFlow{
+			Source:          currentFlow.Source,
+			Destination:     currentFlow.Destination,
+			Protocol:        currentFlow.Protocol,
+			Port:            currentFlow.Port,
+			CurrentState:    currentFlow.State,
+			NewState:        newFlow.State,
+			BytesPerSec:     currentFlow.BytesPerSec,
+			ConnectionCount: currentFlow.ConnectionCount,
+		}
+		if newFlow.State == "blocked" {
+			flow.Impact = "Connection will be blocked"
+			result.Impact.BlockedConnections++
+			result.Impact.DroppedBytesPerSec += currentFlow.BytesPerSec
+		} else {
+			flow.Impact = "Connection will be allowed"
+			result.Impact.NewConnections++
+		}
+		result.AffectedFlows = append(result.AffectedFlows, flow)
+		if currentFlow.SourcePod != nil {
+			affectedNamespaces[currentFlow.SourcePod.Namespace] = true
+		}
+		if currentFlow.DestinationPod != nil {
+			affectedNamespaces[currentFlow.DestinationPod.Namespace] = true
 		}
 	}
+	for ns := range affectedNamespaces {
+		result.Impact.AffectedNamespaces = append(result.Impact.AffectedNamespaces, ns)
+	}
+	sort.Strings(result.Impact.AffectedNamespaces)
 
 	// Analyze impact on services
 	result.Impact.TotalServicesAffected = s.countAffectedServices(affectedPods)
@@ -159,41 +266,52 @@ func (s *Simulator) SimulateNetworkPolicy(policy *networkingv1.NetworkPolicy, ac
 	result.RiskLevel = s.assessRisk(result.Impact)
 
 	// Generate recommendations
-	result.Recommendations = s.generatePolicyRecommendations(policy, result.Impact)
+	result.Recommendations = s.generatePolicyRecommendations(policies, action, result.Impact)
 
 	// Create summary
+	target := fmt.Sprintf("NetworkPolicy '%s' in namespace '%s'", policies[0].Name, policies[0].Namespace)
+	if len(policies) > 1 {
+		target = fmt.Sprintf("%d NetworkPolicies (%s)", len(policies), strings.Join(policyNames(policies), ", "))
+	}
 	result.Summary = fmt.Sprintf(
-		"NetworkPolicy '%s' in namespace '%s' will affect %d pods and potentially block %d connections. Risk level: %s",
-		policy.Name, policy.Namespace, result.Impact.TotalPodsAffected, result.Impact.BlockedConnections, result.RiskLevel,
+		"%s %s will affect %d pods, block %d connections and allow %d new connections. Risk level: %s",
+		actionVerb(action), target, result.Impact.TotalPodsAffected, result.Impact.BlockedConnections, result.Impact.NewConnections, result.RiskLevel,
 	)
+	if result.FlowSource == FlowSourceObserved {
+		result.Summary += fmt.Sprintf(" (based on traffic observed in the last %s)", window)
+	}
 
 	// Generate AI analysis if available
 	if s.aiClient != nil {
 		context := fmt.Sprintf(`Current State:
-- Namespace: %s
+- Affected Namespaces: %v
 - Affected Pods: %d
 - Blocked Connections: %d
+- New Connections: %d
 - Affected Services: %d
 - Critical Paths Impacted: %d
 
-Policy Details:
-- Name: %s
-- Policy Types: %v
-- Ingress Rules: %d
-- Egress Rules: %d`,
-			policy.Namespace,
+Policy Details (%s):`,
+			result.Impact.AffectedNamespaces,
 			result.Impact.TotalPodsAffected,
 			result.Impact.BlockedConnections,
+			result.Impact.NewConnections,
 			result.Impact.TotalServicesAffected,
 			len(result.Impact.CriticalPathsImpacted),
-			policy.Name,
-			policy.Spec.PolicyTypes,
-			len(policy.Spec.Ingress),
-			len(policy.Spec.Egress))
+			action)
+		for _, policy := range policies {
+			context += fmt.Sprintf(`
+- Name: %s/%s (Policy Types: %v, Ingress Rules: %d, Egress Rules: %d)`,
+				policy.Namespace,
+				policy.Name,
+				policy.Spec.PolicyTypes,
+				len(policy.Spec.Ingress),
+				len(policy.Spec.Egress))
+		}
 
 		analysis, err := s.aiClient.GenerateScenarioAnalysis(
 			"NetworkPolicy Change",
-			fmt.Sprintf("Add/modify NetworkPolicy '%s' in namespace '%s'", policy.Name, policy.Namespace),
+			fmt.Sprintf("Apply %q action to %s", action, target),
 			context,
 		)
 		if err == nil {
@@ -378,16 +496,11 @@ func (s *Simulator) findPodsMatchingSelector(namespace string, selector *metav1.
 	var matched []*corev1.Pod
 	
 	for _, pod := range s.pods {
-		if pod.Namespace != namespace {
+		if pod.Namespace != namespace || pod.Spec.HostNetwork {
 			continue
 		}
 		
-		if selector == nil || len(selector.MatchLabels) == 0 {
-			matched = append(matched, pod)
-			continue
-		}
-		
-		if s.labelsMatch(pod.Labels, selector.MatchLabels) {
+		if selector == nil || SelectorMatches(selector, pod.Labels) {
 			matched = append(matched, pod)
 		}
 	}
@@ -404,44 +517,174 @@ func (s *Simulator) labelsMatch(podLabels, selectorLabels map[string]string) boo
 	return true
 }
 
-func (s *Simulator) analyzeCurrentFlows(pods []*corev1.Pod) map[string]*Flow {
-	flows := make(map[string]*Flow)
-	
-	for _, pod := range pods {
-		// Find services this pod connects to
-		for _, svc := range s.services {
-			flowKey := fmt.Sprintf("%s/%s->%s/%s", pod.Namespace, pod.Name, svc.Namespace, svc.Name)
-			flows[flowKey] = &Flow{
-				Source:      fmt.Sprintf("%s/%s", pod.Namespace, pod.Name),
-				Destination: fmt.Sprintf("%s/%s", svc.Namespace, svc.Name),
-				Protocol:    "TCP",
-				State:       "allowed",
+// newEvaluator builds a policy evaluator using the simulator's namespace labels
+func (s *Simulator) newEvaluator(policies []*networkingv1.NetworkPolicy) *PolicyEvaluator {
+	namespaceLabels := make(map[string]map[string]string, len(s.namespaces))
+	for name, ns := range s.namespaces {
+		namespaceLabels[name] = ns.Labels
+	}
+	return NewPolicyEvaluator(policies, namespaceLabels)
+}
+
+// currentPolicies returns the policies currently in the cluster
+func (s *Simulator) currentPolicies() []*networkingv1.NetworkPolicy {
+	policies := make([]*networkingv1.NetworkPolicy, 0, len(s.policies))
+	for _, key := range sortedPolicyKeys(s.policies) {
+		policies = append(policies, s.policies[key])
+	}
+	return policies
+}
+
+// applyPolicyChange returns the policy set that results from applying action to the
+// given policies, along with every version of the changed policies (existing and proposed)
+func (s *Simulator) applyPolicyChange(policies []*networkingv1.NetworkPolicy, action string) ([]*networkingv1.NetworkPolicy, []*networkingv1.NetworkPolicy, error) {
+	changed := []*networkingv1.NetworkPolicy{}
+	for _, policy := range policies {
+		existing, exists := s.policies[PolicyKey(policy)]
+		switch action {
+		case ActionAdd:
+			if exists {
+				return nil, nil, fmt.Errorf("%w: %s, use the %q action", ErrPolicyExists, PolicyKey(policy), ActionModify)
+			}
+			changed = append(changed, policy)
+		case ActionModify:
+			if !exists {
+				return nil, nil, fmt.Errorf("%w: %s", ErrPolicyNotFound, PolicyKey(policy))
+			}
+			changed = append(changed, existing, policy)
+		case ActionDelete:
+			if !exists {
+				return nil, nil, fmt.Errorf("%w: %s", ErrPolicyNotFound, PolicyKey(policy))
+			}
+			changed = append(changed, existing)
+		case ActionApply:
+			if exists {
+				changed = append(changed, existing)
 			}
+			changed = append(changed, policy)
+		default:
+			return nil, nil, fmt.Errorf("unsupported action %q", action)
 		}
 	}
-	
-	return flows
+
+	if action == ActionDelete {
+		return s.policiesWithout(policies), changed, nil
+	}
+	return s.policiesWith(policies), changed, nil
+}
+
+// policiesWith returns the current policies with the candidates added,
+// replacing any existing policy with the same namespace and name
+func (s *Simulator) policiesWith(candidates []*networkingv1.NetworkPolicy) []*networkingv1.NetworkPolicy {
+	replaced := make(map[string]bool, len(candidates))
+	for _, candidate := range candidates {
+		replaced[PolicyKey(candidate)] = true
+	}
+	policies := []*networkingv1.NetworkPolicy{}
+	for _, policy := range s.currentPolicies() {
+		if !replaced[PolicyKey(policy)] {
+			policies = append(policies, policy)
+		}
+	}
+	return append(policies, candidates...)
+}
+
+// policiesWithout returns the current policies minus those with the same namespace and name as removed
+func (s *Simulator) policiesWithout(removed []*networkingv1.NetworkPolicy) []*networkingv1.NetworkPolicy {
+	skip := make(map[string]bool, len(removed))
+	for _, policy := range removed {
+		skip[PolicyKey(policy)] = true
+	}
+	policies := []*networkingv1.NetworkPolicy{}
+	for _, policy := range s.currentPolicies() {
+		if !skip[PolicyKey(policy)] {
+			policies = append(policies, policy)
+		}
+	}
+	return policies
+}
+
+// findPodsSelectedByPolicies returns the union of pods selected by the given policies
+func (s *Simulator) findPodsSelectedByPolicies(policies []*networkingv1.NetworkPolicy) []*corev1.Pod {
+	seen := make(map[*corev1.Pod]bool)
+	var pods []*corev1.Pod
+	for _, policy := range policies {
+		for _, pod := range s.findPodsMatchingSelector(policy.Namespace, &policy.Spec.PodSelector) {
+			if !seen[pod] {
+				seen[pod] = true
+				pods = append(pods, pod)
+			}
+		}
+	}
+	return pods
+}
+
+func actionVerb(action string) string {
+	switch action {
+	case ActionModify:
+		return "Modifying"
+	case ActionDelete:
+		return "Deleting"
+	case ActionApply:
+		return "Applying"
+	default:
+		return "Adding"
+	}
+}
+
+func policyNames(policies []*networkingv1.NetworkPolicy) []string {
+	names := make([]string, 0, len(policies))
+	for _, policy := range policies {
+		names = append(names, PolicyKey(policy))
+	}
+	return names
 }
 
-func (s *Simulator) simulateFlowsWithPolicy(pods []*corev1.Pod, policy *networkingv1.NetworkPolicy) map[string]*Flow {
+// analyzeCurrentFlows enumerates service-backed connections into and out of the
+// given pods and evaluates them against the current policies
+func (s *Simulator) analyzeCurrentFlows(pods []*corev1.Pod, evaluator *PolicyEvaluator) map[string]*Flow {
 	flows := make(map[string]*Flow)
 	
+	affected := make(map[string]bool, len(pods))
 	for _, pod := range pods {
-		for _, svc := range s.services {
-			flowKey := fmt.Sprintf("%s/%s->%s/%s", pod.Namespace, pod.Name, svc.Namespace, svc.Name)
-			
-			// Check if flow is allowed by policy
-			allowed := s.isPolicyAllowed(pod, svc, policy)
-			state := "blocked"
-			if allowed {
-				state = "allowed"
-			}
-			
-			flows[flowKey] = &Flow{
-				Source:      fmt.Sprintf("%s/%s", pod.Namespace, pod.Name),
-				Destination: fmt.Sprintf("%s/%s", svc.Namespace, svc.Name),
-				Protocol:    "TCP",
-				State:       state,
+		affected[fmt.Sprintf("%s/%s", pod.Namespace, pod.Name)] = true
+	}
+	
+	for _, svc := range s.services {
+		for _, backend := range s.findPodsForService(svc) {
+			backendKey := fmt.Sprintf("%s/%s", backend.Namespace, backend.Name)
+			for _, port := range svc.Spec.Ports {
+				targetPort := resolveTargetPort(backend, port)
+				if targetPort == 0 {
+					continue
+				}
+				protocol := port.Protocol
+				if protocol == "" {
+					protocol = corev1.ProtocolTCP
+				}
+				
+				for _, source := range s.pods {
+					if !isActivePod(source) || source == backend {
+						continue
+					}
+					sourceKey := fmt.Sprintf("%s/%s", source.Namespace, source.Name)
+					// Only connections touching the affected pods can change
+					if !affected[sourceKey] && !affected[backendKey] {
+						continue
+					}
+					
+					flow := &Flow{
+						Source:         sourceKey,
+						Destination:    backendKey,
+						Protocol:       string(protocol),
+						Port:           targetPort,
+						SourcePod:      source,
+						DestinationPod: backend,
+					}
+					flow.Decision = evaluator.Evaluate(flow.Connection())
+					flow.State = flowState(flow.Decision.Allowed)
+					flows[flow.Key()] = flow
+				}
 			}
 		}
 	}
@@ -449,46 +692,79 @@ func (s *Simulator) simulateFlowsWithPolicy(pods []*corev1.Pod, policy *networki
 	return flows
 }
 
-func (s *Simulator) isPolicyAllowed(pod *corev1.Pod, svc *corev1.Service, policy *networkingv1.NetworkPolicy) bool {
-	// Simplified policy evaluation
-	if policy.Spec.PolicyTypes == nil || len(policy.Spec.PolicyTypes) == 0 {
-		return true
-	}
+// simulateFlowsWithPolicy re-evaluates the given flows against a proposed policy set
+func (s *Simulator) simulateFlowsWithPolicy(current map[string]*Flow, evaluator *PolicyEvaluator) map[string]*Flow {
+	flows := make(map[string]*Flow, len(current))
 	
-	for _, policyType := range policy.Spec.PolicyTypes {
-		if policyType == networkingv1.PolicyTypeEgress {
-			// Check egress rules
-			if policy.Spec.Egress == nil || len(policy.Spec.Egress) == 0 {
-				return false // Default deny
-			}
-			
-			// Check if any egress rule matches
-			for _, rule := range policy.Spec.Egress {
-				if s.egressRuleMatches(rule, svc) {
-					return true
-				}
-			}
-			return false
-		}
+	for key, flow := range current {
+		simulated := *flow
+		simulated.Decision = evaluator.Evaluate(flow.Connection())
+		simulated.State = flowState(simulated.Decision.Allowed)
+		flows[key] = &simulated
 	}
 	
-	return true
+	return flows
 }
 
-func (s *Simulator) egressRuleMatches(rule networkingv1.NetworkPolicyEgressRule, svc *corev1.Service) bool {
-	// Simplified rule matching
-	if rule.To == nil || len(rule.To) == 0 {
-		return true // Allow all
+// findPodsForService returns the active pods selected by a service
+func (s *Simulator) findPodsForService(svc *corev1.Service) []*corev1.Pod {
+	var pods []*corev1.Pod
+	if len(svc.Spec.Selector) == 0 {
+		return pods
 	}
 	
-	for _, to := range rule.To {
-		if to.PodSelector != nil {
-			// Check if service endpoints match selector
-			return true // Simplified
+	for _, pod := range s.pods {
+		if pod.Namespace == svc.Namespace && isActivePod(pod) && s.labelsMatch(pod.Labels, svc.Spec.Selector) {
+			pods = append(pods, pod)
 		}
 	}
 	
-	return false
+	return pods
+}
+
+// resolveTargetPort maps a service port to the container port on a backend pod
+func resolveTargetPort(pod *corev1.Pod, port corev1.ServicePort) int32 {
+	protocol := port.Protocol
+	if protocol == "" {
+		protocol = corev1.ProtocolTCP
+	}
+	switch {
+	case port.TargetPort.Type == intstr.String:
+		return ResolveNamedPort(pod, port.TargetPort.StrVal, protocol)
+	case port.TargetPort.IntVal != 0:
+		return port.TargetPort.IntVal
+	default:
+		return port.Port
+	}
+}
+
+func isActivePod(pod *corev1.Pod) bool {
+	return pod.Status.Phase != corev1.PodSucceeded && pod.Status.Phase != corev1.PodFailed
+}
+
+func flowState(allowed bool) string {
+	if allowed {
+		return "allowed"
+	}
+	return "blocked"
+}
+
+func sortedFlowKeys(flows map[string]*Flow) []string {
+	keys := make([]string, 0, len(flows))
+	for key := range flows {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func sortedPolicyKeys(policies map[string]*networkingv1.NetworkPolicy) []string {
+	keys := make([]string, 0, len(policies))
+	for key := range policies {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
 }
 
 func (s *Simulator) countAffectedServices(pods []*corev1.Pod) int {
@@ -538,30 +814,48 @@ func (s *Simulator) assessRisk(impact ImpactAnalysis) string {
 	return "low"
 }
 
-func (s *Simulator) generatePolicyRecommendations(policy *networkingv1.NetworkPolicy, impact ImpactAnalysis) []string {
+func (s *Simulator) generatePolicyRecommendations(policies []*networkingv1.NetworkPolicy, action string, impact ImpactAnalysis) []string {
 	recommendations := []string{}
 	
 	if impact.BlockedConnections > 0 {
 		recommendations = append(recommendations, 
-			fmt.Sprintf("This policy will block %d existing connections.", impact.BlockedConnections),
+			fmt.Sprintf("This change will block %d existing connections.", impact.BlockedConnections),
 			"Review the blocked connections to ensure they are intentional.",
 		)
 	}
 	
-	if len(impact.CriticalPathsImpacted) > 0 {
+	if impact.NewConnections > 0 {
 		recommendations = append(recommendations,
-			"CRITICAL: This policy affects critical service paths!",
-			"Consider adding explicit allow rules for critical services.",
+			fmt.Sprintf("This change will allow %d previously blocked connections.", impact.NewConnections),
+			"Confirm the newly allowed connections do not weaken workload isolation.",
 		)
 	}
 	
-	if policy.Spec.Egress == nil || len(policy.Spec.Egress) == 0 {
+	if len(impact.CriticalPathsImpacted) > 0 {
 		recommendations = append(recommendations,
-			"No egress rules defined - all outbound traffic will be blocked.",
-			"Add egress rules for DNS and required services.",
+			"CRITICAL: This change affects critical service paths!",
+			"Consider adding explicit allow rules for critical services.",
 		)
 	}
 	
+	if action == ActionDelete {
+		if impact.NewConnections > 0 {
+			recommendations = append(recommendations,
+				"Selected pods lose isolation from this policy; check that another policy still restricts them.",
+			)
+		}
+		return recommendations
+	}
+	
+	for _, policy := range policies {
+		if PolicyHasType(policy, networkingv1.PolicyTypeEgress) && len(policy.Spec.Egress) == 0 {
+			recommendations = append(recommendations,
+				fmt.Sprintf("Policy %s has no egress rules defined - all outbound traffic from selected pods will be blocked.", PolicyKey(policy)),
+				"Add egress rules for DNS and required services.",
+			)
+		}
+	}
+	
 	return recommendations
 }
 
@@ -713,11 +1007,35 @@ func (s *Simulator) simulateTimeout(policy ServiceMeshPolicy) *SimulationResult
 
 // Flow represents a network flow
 type Flow struct {
-	Source      string
-	Destination string
-	Protocol    string
-	Port        int32
-	State       string
+	Source          string
+	Destination     string
+	Protocol        string
+	Port            int32
+	State           string
+	SourcePod       *corev1.Pod
+	SourceIP        string // set when the source is not a known pod
+	DestinationPod  *corev1.Pod
+	DestinationIP   string // set when the destination is not a known pod
+	Decision        PolicyDecision
+	BytesPerSec     float64
+	ConnectionCount int
+}
+
+// Key returns a unique key for the flow
+func (f *Flow) Key() string {
+	return fmt.Sprintf("%s->%s:%d/%s", f.Source, f.Destination, f.Port, f.Protocol)
+}
+
+// Connection converts the flow into a connection for policy evaluation
+func (f *Flow) Connection() Connection {
+	return Connection{
+		Source:        f.SourcePod,
+		SourceIP:      f.SourceIP,
+		Destination:   f.DestinationPod,
+		DestinationIP: f.DestinationIP,
+		Port:          f.Port,
+		Protocol:      corev1.Protocol(f.Protocol),
+	}
 }
 
 // ServiceMeshPolicy represents a service mesh policy
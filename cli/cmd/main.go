@@ -1,12 +1,16 @@
 package main
 
 import (
+	"bytes"
+	"encoding/csv"
 	"encoding/json"
 	"flag"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"os"
+	"strconv"
 	"strings"
 	"text/tabwriter"
 )
@@ -15,12 +19,17 @@ import (
 type Command string
 
 const (
-	CmdVisualize Command = "visualize"
-	CmdHealth    Command = "health"
-	CmdIssues    Command = "issues"
-	CmdProbe     Command = "probe"
-	CmdExport    Command = "export"
-	CmdSimulate  Command = "simulate"
+	CmdVisualize  Command = "visualize"
+	CmdHealth     Command = "health"
+	CmdIssues     Command = "issues"
+	CmdProbe      Command = "probe"
+	CmdExport     Command = "export"
+	CmdSimulate   Command = "simulate"
+	CmdGenerate   Command = "generate-policy"
+	CmdReach      Command = "reachability"
+	CmdAssert     Command = "assert"
+	CmdTrace      Command = "trace"
+	CmdThroughput Command = "throughput"
 )
 
 // Config holds CLI configuration
@@ -58,21 +67,255 @@ type ProbeResult struct {
 	TargetPod      string `json:"target_pod,omitempty"`
 	TargetNS       string `json:"target_namespace,omitempty"`
 	TargetSvc      string `json:"target_service,omitempty"`
+	TargetNode     string `json:"target_node,omitempty"`
 	TargetIP       string `json:"target_ip"`
 	TargetPort     int32  `json:"target_port"`
+	ProbeType      string `json:"probe_type"`
 	Success        bool   `json:"success"`
 	LatencyMs      int64  `json:"latency_ms"`
+	StatusCode     int    `json:"status_code,omitempty"`
+	Path           string `json:"path,omitempty"`
+	GRPCStatus     string `json:"grpc_status,omitempty"`
+	Expected       string `json:"expected,omitempty"`
+	Query          string `json:"query,omitempty"`
+	QueryType      string `json:"query_type,omitempty"`
+	Rcode          string `json:"rcode,omitempty"`
+	Answers        int    `json:"answers,omitempty"`
+	Sent           int    `json:"packets_sent,omitempty"`
+	Received       int    `json:"packets_received,omitempty"`
+	NoResponse     bool   `json:"no_response,omitempty"`
 	Error          string `json:"error,omitempty"`
+	ExecMode       string `json:"exec_mode,omitempty"`
+	Fallback       string `json:"exec_fallback,omitempty"`
+	Verdict        string `json:"expected_verdict,omitempty"`
+	Node           string `json:"node,omitempty"`
+	TLS            *ProbeTLS `json:"tls,omitempty"`
+	Throughput     *ProbeThroughput `json:"throughput,omitempty"`
 	Timestamp      string `json:"timestamp"`
 }
 
+// ProbeTLS describes the handshake and certificate of a TLS probe
+type ProbeTLS struct {
+	HandshakeMs  int64    `json:"handshake_ms"`
+	Version      string   `json:"version"`
+	CipherSuite  string   `json:"cipher_suite"`
+	ALPN         string   `json:"alpn,omitempty"`
+	ServerName   string   `json:"server_name,omitempty"`
+	SNIMatch     bool     `json:"sni_match"`
+	ServiceNames []string `json:"service_names,omitempty"`
+	NameCovered  bool     `json:"name_covered"`
+	ChainValid   bool     `json:"chain_valid"`
+	ChainError   string   `json:"chain_error,omitempty"`
+	Subject      string   `json:"subject"`
+	Issuer       string   `json:"issuer"`
+	DNSNames     []string `json:"dns_names,omitempty"`
+	NotAfter     string   `json:"not_after"`
+}
+
+// ProbeThroughput is the outcome of a throughput test
+type ProbeThroughput struct {
+	DurationMs  int64   `json:"duration_ms"`
+	Bytes       int64   `json:"bytes"`
+	Mbps        float64 `json:"mbps"`
+	Retransmits int64   `json:"retransmits"`
+	RTTMs       float64 `json:"rtt_ms,omitempty"`
+	LimitMbps   float64 `json:"limit_mbps,omitempty"`
+}
+
+// ThroughputRequest asks the server to run a throughput test now
+type ThroughputRequest struct {
+	Source     string  `json:"source,omitempty"`
+	Target     string  `json:"target"`
+	DurationMs int64   `json:"duration_ms,omitempty"`
+	LimitMbps  float64 `json:"limit_mbps,omitempty"`
+	Confirm    bool    `json:"confirm"`
+}
+
+// ProbeRequest asks the server to run probes now
+type ProbeRequest struct {
+	Source    string `json:"source"`
+	Target    string `json:"target"`
+	Port      int32  `json:"port,omitempty"`
+	Type      string `json:"type,omitempty"`
+	Count     int    `json:"count,omitempty"`
+	TimeoutMs int64  `json:"timeout_ms,omitempty"`
+}
+
+// ProbeRun is the outcome of an on-demand probe request
+type ProbeRun struct {
+	ID      string        `json:"id"`
+	Status  string        `json:"status"`
+	Success bool          `json:"success"`
+	Results []ProbeResult `json:"results"`
+}
+
+// SimulationResult represents the outcome of a what-if simulation
+type SimulationResult struct {
+	Type            string          `json:"type"`
+	Impact          ImpactAnalysis  `json:"impact"`
+	AffectedFlows   []SimulatedFlow `json:"affected_flows"`
+	Recommendations []string        `json:"recommendations"`
+	RiskLevel       string          `json:"risk_level"`
+	Summary         string          `json:"summary"`
+	AIAnalysis      string          `json:"ai_analysis,omitempty"`
+	FlowVerdicts    []FlowVerdict   `json:"flow_verdicts,omitempty"`
+	FlowSource      string          `json:"flow_source,omitempty"`
+}
+
+// ImpactAnalysis describes the impact of a simulated change
+type ImpactAnalysis struct {
+	TotalPodsAffected     int      `json:"total_pods_affected"`
+	TotalServicesAffected int      `json:"total_services_affected"`
+	BlockedConnections    int      `json:"blocked_connections"`
+	NewConnections        int      `json:"new_connections"`
+	AffectedNamespaces    []string `json:"affected_namespaces"`
+	CriticalPathsImpacted []string `json:"critical_paths_impacted"`
+	DroppedBytesPerSec    float64  `json:"dropped_bytes_per_sec,omitempty"`
+}
+
+// SimulatedFlow represents a flow whose state changes in a simulation
+type SimulatedFlow struct {
+	Source          string  `json:"source"`
+	Destination     string  `json:"destination"`
+	Protocol        string  `json:"protocol"`
+	Port            int32   `json:"port"`
+	CurrentState    string  `json:"current_state"`
+	NewState        string  `json:"new_state"`
+	Impact          string  `json:"impact"`
+	BytesPerSec     float64 `json:"bytes_per_sec,omitempty"`
+	ConnectionCount int     `json:"connection_count,omitempty"`
+}
+
+// FlowVerdict compares the policy decision for a flow before and after a change
+type FlowVerdict struct {
+	Source      string         `json:"source"`
+	Destination string         `json:"destination"`
+	Protocol    string         `json:"protocol"`
+	Port        int32          `json:"port"`
+	Before      PolicyDecision `json:"before"`
+	After       PolicyDecision `json:"after"`
+	Changed     bool           `json:"changed"`
+}
+
+// PolicyDecision explains which policies allowed or denied a flow
+type PolicyDecision struct {
+	Allowed         bool            `json:"allowed"`
+	EgressIsolated  bool            `json:"egress_isolated"`
+	IngressIsolated bool            `json:"ingress_isolated"`
+	Egress          []PolicyVerdict `json:"egress,omitempty"`
+	Ingress         []PolicyVerdict `json:"ingress,omitempty"`
+}
+
+// PolicyVerdict records how a single policy treated a flow
+type PolicyVerdict struct {
+	Policy  string `json:"policy"`
+	Allowed bool   `json:"allowed"`
+	Rule    int    `json:"rule"`
+}
+
+// GeneratedPolicies is returned by the policy generator
+type GeneratedPolicies struct {
+	Policies      []map[string]interface{} `json:"policies"`
+	FlowsObserved int                      `json:"flows_observed"`
+	Warnings      []string                 `json:"warnings"`
+	Manifest      string                   `json:"manifest,omitempty"`
+	Simulation    *SimulationResult        `json:"simulation,omitempty"`
+}
+
+// ReachabilityMatrix holds policy reachability between namespaces and workloads
+type ReachabilityMatrix struct {
+	Namespaces     []string           `json:"namespaces"`
+	Workloads      []string           `json:"workloads"`
+	NamespaceCells []ReachabilityCell `json:"namespace_matrix"`
+	WorkloadCells  []ReachabilityCell `json:"workload_matrix"`
+	FlowSource     string             `json:"flow_source,omitempty"`
+	Window         string             `json:"window"`
+	GeneratedAt    string             `json:"generated_at"`
+}
+
+// ReachabilityCell is the policy verdict between two groups on one port
+type ReachabilityCell struct {
+	Source       string  `json:"source"`
+	Destination  string  `json:"destination"`
+	Protocol     string  `json:"protocol"`
+	Port         int32   `json:"port"`
+	Verdict      string  `json:"verdict"`
+	AllowedPairs int     `json:"allowed_pairs"`
+	DeniedPairs  int     `json:"denied_pairs"`
+	Observed     bool    `json:"observed"`
+	BytesPerSec  float64 `json:"bytes_per_sec,omitempty"`
+}
+
+// IntentReport is the outcome of checking connectivity intents
+type IntentReport struct {
+	Satisfied bool           `json:"satisfied"`
+	Results   []IntentResult `json:"results"`
+}
+
+// IntentResult is the outcome of checking one intent
+type IntentResult struct {
+	Intent struct {
+		Name   string `json:"name"`
+		Expect string `json:"expect"`
+	} `json:"intent"`
+	Satisfied       bool              `json:"satisfied"`
+	SourcePods      int               `json:"source_pods"`
+	DestinationPods int               `json:"destination_pods"`
+	PairsChecked    int               `json:"pairs_checked"`
+	ProbesChecked   int               `json:"probes_checked"`
+	FlowsChecked    int               `json:"flows_checked"`
+	ViolationCount  int               `json:"violation_count"`
+	Violations      []IntentViolation `json:"violations"`
+}
+
+// IntentViolation is one connection that contradicts an intent
+type IntentViolation struct {
+	Source      string `json:"source"`
+	Destination string `json:"destination"`
+	Port        int32  `json:"port"`
+	Protocol    string `json:"protocol"`
+	Evidence    string `json:"evidence"`
+	Detail      string `json:"detail"`
+}
+
+// PathTrace explains hop by hop whether a pod can reach a service or pod
+type PathTrace struct {
+	From      string      `json:"from"`
+	To        string      `json:"to"`
+	Port      int32       `json:"port"`
+	Protocol  string      `json:"protocol"`
+	Reachable bool        `json:"reachable"`
+	Summary   string      `json:"summary"`
+	Steps     []TraceStep `json:"steps"`
+}
+
+// TraceStep explains one hop of a path trace
+type TraceStep struct {
+	Hop      string `json:"hop"`
+	Resource string `json:"resource"`
+	Backend  string `json:"backend,omitempty"`
+	Status   string `json:"status"`
+	Message  string `json:"message"`
+}
+
+// ValidationError is returned by the server when a submitted policy is invalid
+type ValidationError struct {
+	Error       string `json:"error"`
+	FieldErrors []struct {
+		Policy int    `json:"policy"`
+		Field  string `json:"field"`
+		Type   string `json:"type"`
+		Detail string `json:"detail"`
+	} `json:"field_errors"`
+}
+
 func main() {
 	var config Config
 
 	// Global flags
 	flag.StringVar(&config.ServerURL, "server", "http://localhost:8080", "Network visualizer server URL")
 	flag.StringVar(&config.Output, "output", "", "Output file (default: stdout)")
-	flag.StringVar(&config.Format, "format", "table", "Output format: table, json, yaml")
+	flag.StringVar(&config.Format, "format", "table", "Output format: table, json, yaml, csv")
 
 	// Parse command
 	flag.Parse()
@@ -100,6 +343,16 @@ func main() {
 		handleExport(config, cmdArgs)
 	case CmdSimulate:
 		handleSimulate(config, cmdArgs)
+	case CmdGenerate:
+		handleGeneratePolicy(config, cmdArgs)
+	case CmdReach:
+		handleReachability(config, cmdArgs)
+	case CmdAssert:
+		handleAssert(config, cmdArgs)
+	case CmdTrace:
+		handleTrace(config, cmdArgs)
+	case CmdThroughput:
+		handleThroughput(config, cmdArgs)
 	default:
 		fmt.Fprintf(os.Stderr, "Unknown command: %s\n", command)
 		printUsage()
@@ -114,24 +367,38 @@ Usage:
   k8s-netvis [flags] <command> [args]
 
 Commands:
-  visualize    Display current network topology
-  health       Run health checks on the cluster
-  issues       List detected network issues
-  probe        Run connectivity probes
-  export       Export topology data
-  simulate     Simulate network policy changes
+  visualize        Display current network topology
+  health           Run health checks on the cluster
+  issues           List detected network issues
+  probe            Run connectivity probes now from a pod
+  export           Export topology data
+  simulate         Simulate network policy changes
+  generate-policy  Generate NetworkPolicies from observed traffic
+  reachability     Show which namespaces and workloads policies allow to connect
+  assert           Check connectivity intents, exits non-zero on violations
+  trace            Explain why a pod can or cannot reach a service or pod
+  throughput       Measure TCP throughput to a receiver pod or node agent
 
 Global Flags:
   -server string    Network visualizer server URL (default: http://localhost:8080)
   -output string    Output file (default: stdout)
-  -format string    Output format: table, json, yaml (default: table)
+  -format string    Output format: table, json, yaml, csv (default: table)
 
 Examples:
   k8s-netvis visualize --namespace default
   k8s-netvis health --all-namespaces
   k8s-netvis issues --severity critical
+  k8s-netvis probe --source shop/frontend-7d9f --target shop/api:8080 --count 3
   k8s-netvis export --format json --output topology.json
-  k8s-netvis simulate --policy new-policy.yaml`)
+  k8s-netvis simulate --policy new-policy.yaml
+  k8s-netvis simulate --policy old-policy.yaml --action delete --verdicts
+  k8s-netvis simulate --policy default-deny.yaml --window 24h
+  k8s-netvis generate-policy --namespace shop --workload checkout --window 24h --output checkout.yaml
+  k8s-netvis reachability --by workload --to payments --verdict allowed
+  k8s-netvis -format csv -output audit.csv reachability --by namespace
+  k8s-netvis assert --intents segmentation.yaml
+  k8s-netvis trace --from shop/frontend-7d9f --to shop/api:8080
+  k8s-netvis throughput --source node/worker-1 --target node/worker-2 --duration 10s --yes`)
 }
 
 func handleVisualize(config Config, args []string) {
@@ -259,7 +526,11 @@ func handleIssues(config Config, args []string) {
 func handleProbe(config Config, args []string) {
 	fs := flag.NewFlagSet("probe", flag.ExitOnError)
 	source := fs.String("source", "", "Source pod (namespace/name)")
-	target := fs.String("target", "", "Target pod or service (namespace/name)")
+	target := fs.String("target", "", "Target pod or service as namespace/name[:port], or IP:port")
+	port := fs.Int("port", 0, "Target port (default: the port in -target, or every declared port)")
+	probeType := fs.String("type", "", "Probe type: tcp, udp, icmp, http, grpc, tls (default: chosen per port)")
+	count := fs.Int("count", 1, "Times to repeat each probe, or echo requests for icmp")
+	timeout := fs.Int("timeout", 0, "Per-probe timeout in milliseconds (default: server default)")
 	fs.Parse(args)
 
 	if *source == "" || *target == "" {
@@ -267,37 +538,121 @@ func handleProbe(config Config, args []string) {
 		os.Exit(1)
 	}
 
-	fmt.Printf("Running probe from %s to %s...\n", *source, *target)
+	payload, err := json.Marshal(ProbeRequest{
+		Source:    *source,
+		Target:    *target,
+		Port:      int32(*port),
+		Type:      *probeType,
+		Count:     *count,
+		TimeoutMs: int64(*timeout),
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error encoding request: %v\n", err)
+		os.Exit(1)
+	}
 
-	// Fetch latest probe results
-	url := fmt.Sprintf("%s/api/probes", config.ServerURL)
-	resp, err := http.Get(url)
+	if config.Format != "json" {
+		fmt.Printf("Running probe from %s to %s...\n", *source, *target)
+	}
+	endpoint := fmt.Sprintf("%s/api/probes/run", config.ServerURL)
+	resp, err := http.Post(endpoint, "application/json", bytes.NewReader(payload))
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error fetching probes: %v\n", err)
+		fmt.Fprintf(os.Stderr, "Error running probe: %v\n", err)
 		os.Exit(1)
 	}
 	defer resp.Body.Close()
 
-	var probes []ProbeResult
-	if err := json.NewDecoder(resp.Body).Decode(&probes); err != nil {
-		fmt.Fprintf(os.Stderr, "Error decoding probes: %v\n", err)
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		fmt.Fprintf(os.Stderr, "Probe failed: %s\n", strings.TrimSpace(string(body)))
 		os.Exit(1)
 	}
 
-	// Find matching probes
-	sourceParts := strings.Split(*source, "/")
-	targetParts := strings.Split(*target, "/")
+	var run ProbeRun
+	if err := json.Unmarshal(body, &run); err != nil {
+		fmt.Fprintf(os.Stderr, "Error decoding response: %v\n", err)
+		os.Exit(1)
+	}
 
-	for _, probe := range probes {
-		if len(sourceParts) == 2 && probe.SourceNS == sourceParts[0] && probe.SourcePod == sourceParts[1] {
-			if len(targetParts) == 2 {
-				if (probe.TargetNS == targetParts[0] && probe.TargetPod == targetParts[1]) ||
-					(probe.TargetNS == targetParts[0] && probe.TargetSvc == targetParts[1]) {
-					printProbeResult(probe)
-				}
-			}
+	switch config.Format {
+	case "json":
+		outputJSON(run, config.Output)
+	default:
+		for _, probe := range run.Results {
+			printProbeResult(probe)
+		}
+	}
+
+	if !run.Success {
+		os.Exit(1)
+	}
+}
+
+func handleThroughput(config Config, args []string) {
+	fs := flag.NewFlagSet("throughput", flag.ExitOnError)
+	source := fs.String("source", "", "Sending node as node/NAME (default: the server itself)")
+	target := fs.String("target", "", "Receiver as node/NAME or namespace/pod")
+	duration := fs.Duration("duration", 0, "How long to send (default: server default, at most 30s)")
+	limit := fs.Float64("limit", 0, "Pace the sender to this many Mbps (default: unlimited)")
+	confirm := fs.Bool("yes", false, "Confirm the test; it loads the path for its whole duration")
+	fs.Parse(args)
+
+	if *target == "" {
+		fmt.Fprintf(os.Stderr, "A target is required\n")
+		os.Exit(1)
+	}
+	if !*confirm {
+		fmt.Fprintf(os.Stderr, "A throughput test saturates the path to %s for its whole duration; rerun with --yes to confirm\n", *target)
+		os.Exit(1)
+	}
+
+	payload, err := json.Marshal(ThroughputRequest{
+		Source:     *source,
+		Target:     *target,
+		DurationMs: duration.Milliseconds(),
+		LimitMbps:  *limit,
+		Confirm:    *confirm,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error encoding request: %v\n", err)
+		os.Exit(1)
+	}
+
+	if config.Format != "json" {
+		fmt.Printf("Measuring throughput to %s...\n", *target)
+	}
+	endpoint := fmt.Sprintf("%s/api/probes/throughput", config.ServerURL)
+	resp, err := http.Post(endpoint, "application/json", bytes.NewReader(payload))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error running throughput test: %v\n", err)
+		os.Exit(1)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		fmt.Fprintf(os.Stderr, "Throughput test failed: %s\n", strings.TrimSpace(string(body)))
+		os.Exit(1)
+	}
+
+	var run ProbeRun
+	if err := json.Unmarshal(body, &run); err != nil {
+		fmt.Fprintf(os.Stderr, "Error decoding response: %v\n", err)
+		os.Exit(1)
+	}
+
+	switch config.Format {
+	case "json":
+		outputJSON(run, config.Output)
+	default:
+		for _, probe := range run.Results {
+			printProbeResult(probe)
 		}
 	}
+
+	if !run.Success {
+		os.Exit(1)
+	}
 }
 
 func handleExport(config Config, args []string) {
@@ -322,7 +677,11 @@ func handleExport(config Config, args []string) {
 
 func handleSimulate(config Config, args []string) {
 	fs := flag.NewFlagSet("simulate", flag.ExitOnError)
-	policyFile := fs.String("policy", "", "Path to NetworkPolicy YAML file")
+	policyFile := fs.String("policy", "", "Path to NetworkPolicy YAML or JSON file")
+	action := fs.String("action", "add", "Policy change to simulate: add, modify, delete")
+	namespace := fs.String("namespace", "", "Namespace for policies that do not set one")
+	verdicts := fs.Bool("verdicts", false, "Show before/after verdicts for every evaluated flow")
+	window := fs.String("window", "", "Observed traffic window to replay, e.g. 1h (default: server default)")
 	fs.Parse(args)
 
 	if *policyFile == "" {
@@ -338,8 +697,16 @@ func handleSimulate(config Config, args []string) {
 	}
 
 	// Send to server for simulation
-	url := fmt.Sprintf("%s/api/simulate", config.ServerURL)
-	resp, err := http.Post(url, "application/yaml", strings.NewReader(string(policyData)))
+	query := url.Values{}
+	query.Set("action", *action)
+	if *namespace != "" {
+		query.Set("namespace", *namespace)
+	}
+	if *window != "" {
+		query.Set("window", *window)
+	}
+	endpoint := fmt.Sprintf("%s/api/simulate?%s", config.ServerURL, query.Encode())
+	resp, err := http.Post(endpoint, "application/yaml", bytes.NewReader(policyData))
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error simulating policy: %v\n", err)
 		os.Exit(1)
@@ -347,7 +714,266 @@ func handleSimulate(config Config, args []string) {
 	defer resp.Body.Close()
 
 	body, _ := io.ReadAll(resp.Body)
-	fmt.Printf("Simulation Result:\n%s\n", body)
+	if resp.StatusCode != http.StatusOK {
+		printSimulationError(body)
+		os.Exit(1)
+	}
+
+	var result SimulationResult
+	if err := json.Unmarshal(body, &result); err != nil {
+		fmt.Fprintf(os.Stderr, "Error decoding response: %v\n", err)
+		os.Exit(1)
+	}
+
+	switch config.Format {
+	case "json":
+		outputJSON(result, config.Output)
+	default:
+		printSimulationResult(result)
+		if *verdicts {
+			printFlowVerdicts(result.FlowVerdicts)
+		}
+	}
+}
+
+func handleGeneratePolicy(config Config, args []string) {
+	fs := flag.NewFlagSet("generate-policy", flag.ExitOnError)
+	namespace := fs.String("namespace", "", "Namespace to generate policies for")
+	workload := fs.String("workload", "", "Limit generation to one workload (deployment, statefulset, ...)")
+	window := fs.String("window", "", "Observed traffic window, e.g. 24h (default: server default)")
+	fs.Parse(args)
+
+	if *namespace == "" {
+		fmt.Fprintf(os.Stderr, "Namespace is required\n")
+		os.Exit(1)
+	}
+
+	query := url.Values{}
+	query.Set("namespace", *namespace)
+	if *workload != "" {
+		query.Set("workload", *workload)
+	}
+	if *window != "" {
+		query.Set("window", *window)
+	}
+	endpoint := fmt.Sprintf("%s/api/policies/generate?%s", config.ServerURL, query.Encode())
+	resp, err := http.Get(endpoint)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error generating policies: %v\n", err)
+		os.Exit(1)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		fmt.Fprintf(os.Stderr, "Policy generation failed: %s\n", strings.TrimSpace(string(body)))
+		os.Exit(1)
+	}
+
+	var generated GeneratedPolicies
+	if err := json.Unmarshal(body, &generated); err != nil {
+		fmt.Fprintf(os.Stderr, "Error decoding response: %v\n", err)
+		os.Exit(1)
+	}
+
+	if config.Format == "json" {
+		outputJSON(generated, config.Output)
+		return
+	}
+
+	// Manifests go to stdout or the output file, the report to stderr
+	if config.Output != "" {
+		if err := os.WriteFile(config.Output, []byte(generated.Manifest), 0644); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing output file: %v\n", err)
+			os.Exit(1)
+		}
+	} else {
+		fmt.Print(generated.Manifest)
+	}
+
+	fmt.Fprintf(os.Stderr, "Generated %d policies from %d observed flows\n", len(generated.Policies), generated.FlowsObserved)
+	for _, warning := range generated.Warnings {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", warning)
+	}
+	if generated.Simulation != nil {
+		fmt.Fprintf(os.Stderr, "Simulation: %s\n", generated.Simulation.Summary)
+		for _, flow := range generated.Simulation.AffectedFlows {
+			if flow.NewState == "blocked" {
+				fmt.Fprintf(os.Stderr, "  would block %s -> %s %d/%s\n", flow.Source, flow.Destination, flow.Port, flow.Protocol)
+			}
+		}
+	}
+}
+
+func handleReachability(config Config, args []string) {
+	fs := flag.NewFlagSet("reachability", flag.ExitOnError)
+	by := fs.String("by", "namespace", "Matrix to show: namespace or workload")
+	from := fs.String("from", "", "Only show sources in this namespace")
+	to := fs.String("to", "", "Only show destinations in this namespace")
+	port := fs.Int("port", 0, "Only evaluate this destination port")
+	protocol := fs.String("protocol", "", "Only evaluate this protocol: TCP, UDP, SCTP")
+	verdict := fs.String("verdict", "", "Only show cells with this verdict: allowed, denied, partial")
+	observed := fs.Bool("observed", false, "Only show cells with observed traffic")
+	window := fs.String("window", "", "Observed traffic window, e.g. 1h (default: server default)")
+	fs.Parse(args)
+
+	if *by != "namespace" && *by != "workload" {
+		fmt.Fprintf(os.Stderr, "Invalid -by %q, expected namespace or workload\n", *by)
+		os.Exit(1)
+	}
+
+	query := url.Values{}
+	if *from != "" {
+		query.Set("source_namespace", *from)
+	}
+	if *to != "" {
+		query.Set("destination_namespace", *to)
+	}
+	if *port != 0 {
+		query.Set("port", strconv.Itoa(*port))
+	}
+	if *protocol != "" {
+		query.Set("protocol", *protocol)
+	}
+	if *verdict != "" {
+		query.Set("verdict", *verdict)
+	}
+	if *observed {
+		query.Set("observed", "true")
+	}
+	if *window != "" {
+		query.Set("window", *window)
+	}
+	endpoint := fmt.Sprintf("%s/api/reachability?%s", config.ServerURL, query.Encode())
+	resp, err := http.Get(endpoint)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error fetching reachability: %v\n", err)
+		os.Exit(1)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		fmt.Fprintf(os.Stderr, "Reachability failed: %s\n", strings.TrimSpace(string(body)))
+		os.Exit(1)
+	}
+
+	var matrix ReachabilityMatrix
+	if err := json.Unmarshal(body, &matrix); err != nil {
+		fmt.Fprintf(os.Stderr, "Error decoding response: %v\n", err)
+		os.Exit(1)
+	}
+
+	cells := matrix.NamespaceCells
+	if *by == "workload" {
+		cells = matrix.WorkloadCells
+	}
+
+	switch config.Format {
+	case "json":
+		outputJSON(matrix, config.Output)
+	case "csv":
+		outputReachabilityCSV(cells, config.Output)
+	default:
+		printReachabilityTable(matrix, cells, *by)
+	}
+}
+
+func handleAssert(config Config, args []string) {
+	fs := flag.NewFlagSet("assert", flag.ExitOnError)
+	intentsFile := fs.String("intents", "", "Intent spec to check now (default: the server's configured intents)")
+	fs.Parse(args)
+
+	var resp *http.Response
+	var err error
+	endpoint := fmt.Sprintf("%s/api/intents", config.ServerURL)
+	if *intentsFile != "" {
+		data, readErr := os.ReadFile(*intentsFile)
+		if readErr != nil {
+			fmt.Fprintf(os.Stderr, "Error reading intents file: %v\n", readErr)
+			os.Exit(1)
+		}
+		resp, err = http.Post(endpoint, "application/yaml", bytes.NewReader(data))
+	} else {
+		resp, err = http.Get(endpoint)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error checking intents: %v\n", err)
+		os.Exit(1)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		fmt.Fprintf(os.Stderr, "Intent check failed: %s\n", strings.TrimSpace(string(body)))
+		os.Exit(1)
+	}
+
+	var report IntentReport
+	if err := json.Unmarshal(body, &report); err != nil {
+		fmt.Fprintf(os.Stderr, "Error decoding response: %v\n", err)
+		os.Exit(1)
+	}
+
+	switch config.Format {
+	case "json":
+		outputJSON(report, config.Output)
+	default:
+		printIntentReport(report)
+	}
+
+	if len(report.Results) == 0 {
+		fmt.Fprintf(os.Stderr, "No intents were checked\n")
+		os.Exit(1)
+	}
+	if !report.Satisfied {
+		os.Exit(1)
+	}
+}
+
+func handleTrace(config Config, args []string) {
+	fs := flag.NewFlagSet("trace", flag.ExitOnError)
+	from := fs.String("from", "", "Source pod as namespace/name")
+	to := fs.String("to", "", "Destination service or pod as namespace/name:port")
+	protocol := fs.String("protocol", "", "Protocol: TCP, UDP, SCTP (default: TCP)")
+	fs.Parse(args)
+
+	if *from == "" || *to == "" {
+		fmt.Fprintf(os.Stderr, "Both -from and -to are required\n")
+		os.Exit(1)
+	}
+
+	query := url.Values{}
+	query.Set("from", *from)
+	query.Set("to", *to)
+	if *protocol != "" {
+		query.Set("protocol", *protocol)
+	}
+	resp, err := http.Get(fmt.Sprintf("%s/api/trace?%s", config.ServerURL, query.Encode()))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error tracing path: %v\n", err)
+		os.Exit(1)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		fmt.Fprintf(os.Stderr, "Trace failed: %s\n", strings.TrimSpace(string(body)))
+		os.Exit(1)
+	}
+
+	var trace PathTrace
+	if err := json.Unmarshal(body, &trace); err != nil {
+		fmt.Fprintf(os.Stderr, "Error decoding response: %v\n", err)
+		os.Exit(1)
+	}
+
+	switch config.Format {
+	case "json":
+		outputJSON(trace, config.Output)
+	default:
+		printPathTrace(trace)
+	}
 }
 
 // Helper functions for output formatting
@@ -420,10 +1046,13 @@ func printProbesTable(probes []ProbeResult) {
 	fmt.Printf("\n=== Connectivity Probes ===\n\n")
 	
 	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
-	fmt.Fprintln(w, "SOURCE\tTARGET\tSUCCESS\tLATENCY\tERROR")
+	fmt.Fprintln(w, "SOURCE\tTARGET\tMODE\tSUCCESS\tLATENCY\tERROR")
 	
 	for _, probe := range probes {
 		source := fmt.Sprintf("%s/%s", probe.SourceNS, probe.SourcePod)
+		if probe.SourcePod == "" {
+			source = "visualizer"
+		}
 		target := ""
 		if probe.TargetSvc != "" {
 			target = fmt.Sprintf("%s/%s", probe.TargetNS, probe.TargetSvc)
@@ -443,6 +1072,11 @@ func printProbesTable(probes []ProbeResult) {
 			latency = "-"
 		}
 		
+		mode := probe.ExecMode
+		if mode == "" {
+			mode = "-"
+		}
+
 		error := probe.Error
 		if error == "" {
 			error = "-"
@@ -450,22 +1084,73 @@ func printProbesTable(probes []ProbeResult) {
 			error = error[:47] + "..."
 		}
 		
-		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", source, target, success, latency, error)
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", source, target, mode, success, latency, error)
 	}
 	w.Flush()
 }
 
 func printProbeResult(probe ProbeResult) {
 	fmt.Printf("\nProbe Result:\n")
-	fmt.Printf("  Source: %s/%s\n", probe.SourceNS, probe.SourcePod)
+	if probe.SourcePod == "" && probe.Node != "" {
+		fmt.Printf("  Source: node/%s\n", probe.Node)
+	} else if probe.SourcePod == "" {
+		fmt.Printf("  Source: visualizer\n")
+	} else {
+		fmt.Printf("  Source: %s/%s\n", probe.SourceNS, probe.SourcePod)
+	}
+	if probe.ExecMode != "" {
+		fmt.Printf("  Dialed From: %s\n", probe.ExecMode)
+	}
+	if probe.Fallback != "" {
+		fmt.Printf("  Exec Fallback: %s\n", probe.Fallback)
+	}
 	
-	if probe.TargetSvc != "" {
+	if probe.TargetNode != "" {
+		fmt.Printf("  Target Node: %s\n", probe.TargetNode)
+	} else if probe.TargetSvc != "" {
 		fmt.Printf("  Target Service: %s/%s\n", probe.TargetNS, probe.TargetSvc)
 	} else if probe.TargetPod != "" {
 		fmt.Printf("  Target Pod: %s/%s\n", probe.TargetNS, probe.TargetPod)
 	}
 	
 	fmt.Printf("  Target IP: %s:%d\n", probe.TargetIP, probe.TargetPort)
+	if probe.ProbeType != "" {
+		fmt.Printf("  Probe Type: %s\n", probe.ProbeType)
+	}
+	if probe.StatusCode != 0 {
+		if probe.Expected != "" {
+			fmt.Printf("  HTTP Status: %d from %s (expected %s)\n", probe.StatusCode, probe.Path, probe.Expected)
+		} else {
+			fmt.Printf("  HTTP Status: %d from %s\n", probe.StatusCode, probe.Path)
+		}
+	}
+	if probe.GRPCStatus != "" {
+		if probe.Success && probe.GRPCStatus != probe.Expected {
+			fmt.Printf("  gRPC Status: %s (no health check for the service, counted as reached)\n", probe.GRPCStatus)
+		} else {
+			fmt.Printf("  gRPC Status: %s (expected %s)\n", probe.GRPCStatus, probe.Expected)
+		}
+	}
+	if probe.Query != "" {
+		fmt.Printf("  DNS Query: %s %s\n", probe.QueryType, probe.Query)
+		fmt.Printf("  DNS Response: %s, %d answers\n", probe.Rcode, probe.Answers)
+	}
+	if probe.Sent > 0 {
+		loss := float64(probe.Sent-probe.Received) / float64(probe.Sent) * 100
+		fmt.Printf("  Packets: %d sent, %d received, %.1f%% loss\n", probe.Sent, probe.Received, loss)
+	}
+	if probe.NoResponse {
+		fmt.Printf("  UDP Reply: none (port open or filtered)\n")
+	}
+	if probe.Verdict != "" {
+		fmt.Printf("  Policy Verdict: %s\n", probe.Verdict)
+	}
+	if probe.TLS != nil {
+		printProbeTLS(probe.TLS)
+	}
+	if probe.Throughput != nil {
+		printProbeThroughput(probe.Throughput)
+	}
 	
 	if probe.Success {
 		fmt.Printf("  Status: SUCCESS\n")
@@ -478,6 +1163,267 @@ func printProbeResult(probe ProbeResult) {
 	fmt.Printf("  Timestamp: %s\n", probe.Timestamp)
 }
 
+func printSimulationResult(result SimulationResult) {
+	fmt.Printf("\n=== Simulation Result ===\n")
+	fmt.Printf("Summary: %s\n", result.Summary)
+	fmt.Printf("Risk Level: %s\n", strings.ToUpper(result.RiskLevel))
+	fmt.Printf("Pods Affected: %d, Services Affected: %d\n", result.Impact.TotalPodsAffected, result.Impact.TotalServicesAffected)
+	fmt.Printf("Blocked Connections: %d, New Connections: %d\n", result.Impact.BlockedConnections, result.Impact.NewConnections)
+	if result.FlowSource == "observed" {
+		fmt.Printf("Flows: observed traffic, %.0f bytes/sec would be dropped\n", result.Impact.DroppedBytesPerSec)
+	} else if result.FlowSource != "" {
+		fmt.Printf("Flows: modeled from services (no observed traffic)\n")
+	}
+	if len(result.Impact.AffectedNamespaces) > 0 {
+		fmt.Printf("Namespaces: %s\n", strings.Join(result.Impact.AffectedNamespaces, ", "))
+	}
+	if len(result.Impact.CriticalPathsImpacted) > 0 {
+		fmt.Printf("Critical Paths: %s\n", strings.Join(result.Impact.CriticalPathsImpacted, ", "))
+	}
+
+	if len(result.AffectedFlows) > 0 {
+		fmt.Println("\nAFFECTED FLOWS:")
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "SOURCE\tDESTINATION\tPORT\tCURRENT\tNEW\tBYTES/S\tCONNS\tIMPACT")
+		for _, flow := range result.AffectedFlows {
+			traffic, conns := "-", "-"
+			if result.FlowSource == "observed" {
+				traffic = fmt.Sprintf("%.0f", flow.BytesPerSec)
+				conns = fmt.Sprintf("%d", flow.ConnectionCount)
+			}
+			fmt.Fprintf(w, "%s\t%s\t%d/%s\t%s\t%s\t%s\t%s\t%s\n", flow.Source, flow.Destination, flow.Port, flow.Protocol, flow.CurrentState, flow.NewState, traffic, conns, flow.Impact)
+		}
+		w.Flush()
+	}
+
+	if len(result.Recommendations) > 0 {
+		fmt.Println("\nRECOMMENDATIONS:")
+		for _, rec := range result.Recommendations {
+			fmt.Printf("  - %s\n", rec)
+		}
+	}
+
+	if result.AIAnalysis != "" {
+		fmt.Printf("\nAI Analysis:\n%s\n", result.AIAnalysis)
+	}
+}
+
+func printProbeTLS(info *ProbeTLS) {
+	fmt.Printf("  TLS: %s, %s, handshake %dms\n", info.Version, info.CipherSuite, info.HandshakeMs)
+	if info.ALPN != "" {
+		fmt.Printf("  ALPN: %s\n", info.ALPN)
+	}
+	if info.ServerName != "" {
+		fmt.Printf("  Server Name: %s (covered: %t)\n", info.ServerName, info.SNIMatch)
+	}
+	if len(info.ServiceNames) > 0 {
+		fmt.Printf("  Service Name Covered: %t\n", info.NameCovered)
+	}
+	fmt.Printf("  Certificate: %s, issued by %s\n", info.Subject, info.Issuer)
+	if len(info.DNSNames) > 0 {
+		fmt.Printf("  Certificate Names: %s\n", strings.Join(info.DNSNames, ", "))
+	}
+	fmt.Printf("  Expires: %s\n", info.NotAfter)
+	if info.ChainValid {
+		fmt.Printf("  Chain: valid\n")
+	} else {
+		fmt.Printf("  Chain: invalid (%s)\n", info.ChainError)
+	}
+}
+
+func printProbeThroughput(info *ProbeThroughput) {
+	fmt.Printf("  Throughput: %.1f Mbps (%d bytes in %dms)\n", info.Mbps, info.Bytes, info.DurationMs)
+	if info.LimitMbps > 0 {
+		fmt.Printf("  Sender Limit: %.1f Mbps\n", info.LimitMbps)
+	}
+	if info.Retransmits >= 0 {
+		fmt.Printf("  Retransmits: %d\n", info.Retransmits)
+	}
+	if info.RTTMs > 0 {
+		fmt.Printf("  RTT: %.2fms\n", info.RTTMs)
+	}
+}
+
+func printFlowVerdicts(verdicts []FlowVerdict) {
+	fmt.Printf("\nFLOW VERDICTS (%d):\n", len(verdicts))
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "SOURCE\tDESTINATION\tPORT\tBEFORE\tAFTER\tCHANGED")
+	for _, v := range verdicts {
+		changed := ""
+		if v.Changed {
+			changed = "*"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d/%s\t%s\t%s\t%s\n", v.Source, v.Destination, v.Port, v.Protocol, describeDecision(v.Before), describeDecision(v.After), changed)
+	}
+	w.Flush()
+}
+
+func printReachabilityTable(matrix ReachabilityMatrix, cells []ReachabilityCell, by string) {
+	fmt.Printf("\n=== Reachability by %s ===\n", by)
+	if matrix.FlowSource == "observed" {
+		fmt.Printf("Observed traffic from the last %s is marked with *\n", matrix.Window)
+	} else {
+		fmt.Printf("No observed traffic, showing policy verdicts only\n")
+	}
+	fmt.Println()
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "SOURCE\tDESTINATION\tPORT\tVERDICT\tALLOWED\tDENIED\tOBSERVED\tBYTES/S")
+	for _, cell := range cells {
+		observed, traffic := "", "-"
+		if cell.Observed {
+			observed = "*"
+			traffic = fmt.Sprintf("%.0f", cell.BytesPerSec)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%d\t%s\t%s\n", cell.Source, cell.Destination, reachabilityPort(cell), cell.Verdict, cell.AllowedPairs, cell.DeniedPairs, observed, traffic)
+	}
+	w.Flush()
+}
+
+func outputReachabilityCSV(cells []ReachabilityCell, outputFile string) {
+	out := os.Stdout
+	if outputFile != "" {
+		file, err := os.Create(outputFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error creating output file: %v\n", err)
+			os.Exit(1)
+		}
+		defer file.Close()
+		out = file
+	}
+
+	writer := csv.NewWriter(out)
+	writer.Write([]string{"source", "destination", "protocol", "port", "verdict", "allowed_pairs", "denied_pairs", "observed", "bytes_per_sec"})
+	for _, cell := range cells {
+		writer.Write([]string{
+			cell.Source,
+			cell.Destination,
+			cell.Protocol,
+			strconv.Itoa(int(cell.Port)),
+			cell.Verdict,
+			strconv.Itoa(cell.AllowedPairs),
+			strconv.Itoa(cell.DeniedPairs),
+			strconv.FormatBool(cell.Observed),
+			strconv.FormatFloat(cell.BytesPerSec, 'f', 0, 64),
+		})
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing CSV: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func printIntentReport(report IntentReport) {
+	fmt.Printf("\n=== Connectivity Intents ===\n\n")
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "INTENT\tEXPECT\tRESULT\tPAIRS\tPROBES\tFLOWS\tVIOLATIONS")
+	failed := 0
+	for _, result := range report.Results {
+		status := "PASS"
+		if !result.Satisfied {
+			status = "FAIL"
+			failed++
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%d\t%d\t%d\n", result.Intent.Name, result.Intent.Expect, status, result.PairsChecked, result.ProbesChecked, result.FlowsChecked, result.ViolationCount)
+	}
+	w.Flush()
+
+	for _, result := range report.Results {
+		if result.Satisfied {
+			continue
+		}
+		fmt.Printf("\n%s:\n", result.Intent.Name)
+		for _, v := range result.Violations {
+			fmt.Printf("  [%s] %s -> %s %d/%s: %s\n", v.Evidence, v.Source, v.Destination, v.Port, v.Protocol, v.Detail)
+		}
+		if hidden := result.ViolationCount - len(result.Violations); hidden > 0 {
+			fmt.Printf("  ... and %d more\n", hidden)
+		}
+	}
+
+	fmt.Printf("\n%d of %d intents satisfied\n", len(report.Results)-failed, len(report.Results))
+}
+
+func printPathTrace(trace PathTrace) {
+	fmt.Printf("\n=== Trace %s -> %s (%d/%s) ===\n\n", trace.From, trace.To, trace.Port, trace.Protocol)
+
+	backend := ""
+	for _, step := range trace.Steps {
+		indent := "  "
+		if step.Backend != "" {
+			if step.Backend != backend {
+				fmt.Printf("  backend %s\n", step.Backend)
+			}
+			indent = "    "
+		}
+		backend = step.Backend
+		fmt.Printf("%s%s %-14s %s\n", indent, traceStatusSymbol(step.Status), step.Hop, step.Message)
+	}
+
+	result := "REACHABLE"
+	if !trace.Reachable {
+		result = "NOT REACHABLE"
+	}
+	fmt.Printf("\n%s: %s\n", result, trace.Summary)
+}
+
+func traceStatusSymbol(status string) string {
+	switch status {
+	case "pass":
+		return "✓"
+	case "fail":
+		return "✗"
+	case "warn":
+		return "!"
+	default:
+		return "·"
+	}
+}
+
+// reachabilityPort formats a cell's port, where port 0 means any port
+func reachabilityPort(cell ReachabilityCell) string {
+	if cell.Port == 0 {
+		return fmt.Sprintf("any/%s", cell.Protocol)
+	}
+	return fmt.Sprintf("%d/%s", cell.Port, cell.Protocol)
+}
+
+// describeDecision summarizes a decision as allowed/blocked plus the deciding policies
+func describeDecision(d PolicyDecision) string {
+	state := "blocked"
+	if d.Allowed {
+		state = "allowed"
+	}
+	var policies []string
+	for _, v := range append(d.Egress, d.Ingress...) {
+		if v.Allowed == d.Allowed {
+			policies = append(policies, v.Policy)
+		}
+	}
+	if !d.EgressIsolated && !d.IngressIsolated {
+		return state + " (no policy)"
+	}
+	if len(policies) == 0 {
+		return state
+	}
+	return fmt.Sprintf("%s (%s)", state, strings.Join(policies, ","))
+}
+
+func printSimulationError(body []byte) {
+	var validation ValidationError
+	if err := json.Unmarshal(body, &validation); err != nil || len(validation.FieldErrors) == 0 {
+		fmt.Fprintf(os.Stderr, "Simulation failed: %s\n", strings.TrimSpace(string(body)))
+		return
+	}
+
+	fmt.Fprintf(os.Stderr, "%s:\n", validation.Error)
+	for _, fieldErr := range validation.FieldErrors {
+		fmt.Fprintf(os.Stderr, "  policy %d: %s: %s\n", fieldErr.Policy, fieldErr.Field, fieldErr.Detail)
+	}
+}
+
 func outputJSON(data interface{}, outputFile string) {
 	encoder := json.NewEncoder(os.Stdout)
 	encoder.SetIndent("", "  ")
@@ -392,6 +392,15 @@ const NetworkGraph: React.FC<NetworkGraphProps> = ({
             },
           },
         },
+        {
+          // Pods not selected by any NetworkPolicy
+          selector: 'node[isolation = "none"]',
+          style: {
+            "border-color": "#ff9800",
+            "border-style": "dashed",
+            "border-width": 3,
+          },
+        },
         {
           selector: "edge.flowing",
           style: {
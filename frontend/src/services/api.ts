@@ -146,6 +146,26 @@ export interface SimulationResult {
   timestamp: string;
 }
 
+export interface TraceStep {
+  hop: 'source' | 'service' | 'endpoints' | 'pod' | 'egress_policy' | 'ingress_policy' | 'probe' | 'flow';
+  resource: string;
+  backend?: string;
+  status: 'pass' | 'fail' | 'warn' | 'info';
+  message: string;
+  details?: Record<string, any>;
+}
+
+export interface PathTrace {
+  from: string;
+  to: string;
+  port: number;
+  protocol: string;
+  reachable: boolean;
+  summary: string;
+  steps: TraceStep[];
+  traced_at: string;
+}
+
 class ApiClient {
   async getTopology(): Promise<NetworkTopology> {
     const response = await axios.get('/topology');
@@ -193,6 +213,12 @@ class ApiClient {
     return response.data;
   }
 
+  async tracePath(from: string, to: string, protocol?: string): Promise<PathTrace> {
+    const params = protocol ? { from, to, protocol } : { from, to };
+    const response = await axios.get('/trace', { params });
+    return response.data;
+  }
+
   async getNetworkPolicies(namespace?: string): Promise<any[]> {
     const params = namespace ? { namespace } : {};
     const response = await axios.get('/network-policies', { params });